@@ -0,0 +1,35 @@
+package main
+
+import (
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/config"
+	"promfire/internal/logger"
+)
+
+// startLoopback points remote_write_url at an in-process receiver failing
+// the configured requests. The returned stop function logs how many
+// requests it received and failed, then shuts it down.
+func startLoopback(cfg *config.Config) func() {
+	recv := testutil.NewFakeReceiver()
+	recv.SetDiscard(true)
+	failures := cfg.Loopback.Failures
+	recv.SetFailures(testutil.FailurePattern{
+		FirstK:   failures.FirstK,
+		EveryN:   failures.EveryN,
+		Percent:  failures.Percent,
+		Seed:     failures.Seed,
+		Statuses: failures.Statuses,
+	})
+	cfg.Prometheus.RemoteWriteURL = recv.WriteURL()
+	logger.Info("Writing to the loopback receiver", map[string]any{
+		"remote_write_url": cfg.Prometheus.RemoteWriteURL,
+	})
+
+	return func() {
+		logger.Info("Loopback receiver summary", map[string]any{
+			"requests":        recv.Requests(),
+			"failed_requests": recv.Failed(),
+		})
+		recv.Close()
+	}
+}
@@ -10,25 +10,43 @@ import (
 	"promfire/internal/benchmarker"
 	"promfire/internal/config"
 	"promfire/internal/logger"
+	"promfire/internal/selfmetrics"
+	"promfire/internal/version"
 )
 
 func main() {
 	var (
-		configPath = flag.String("config", "config.yaml", "Path to configuration file")
-		dryRun     = flag.Bool("dry-run", false, "Print what would be done without executing")
-		version    = flag.Bool("version", false, "Print version information")
-		logLevel   = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+		configPath    = flag.String("config", "config.yaml", "Path to configuration file, \"-\" for stdin or an http(s) URL")
+		allowUnknown  = flag.Bool("allow-unknown-fields", false, "Ignore unknown keys in the configuration file instead of failing")
+		expandRemote  = flag.Bool("expand-remote-env", false, "Expand ${VAR} references in a config fetched from a URL")
+		dryRun        = flag.Bool("dry-run", false, "Print what would be done without executing")
+		dryRunSample  = flag.Int("dry-run-sample", 0, "Dry run logging N example series per metric plus counts instead of every series")
+		showVersion   = flag.Bool("version", false, "Print version information")
+		logLevel      = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+		logFormat     = flag.String("log-format", "", "Log format (json, text), overrides log_format from the config")
+		statsInterval = flag.Duration("stats-interval", 0, "Log progress at this interval, e.g. 10s (0 disables)")
+		compliance    = flag.Bool("compliance-check", false, "Probe the remote write endpoint with crafted requests and report how it responds")
+		estimate      = flag.Bool("estimate", false, "Estimate the run's series, samples and bytes from metric discovery only, then exit")
+		validate      = flag.Bool("validate", false, "Validate the config and check connectivity to the query and remote write endpoints, then exit")
+		cpuProfile    = flag.String("cpuprofile", "", "Write a CPU profile of the run to this file")
+		memProfile    = flag.String("memprofile", "", "Write a heap profile to this file when the run ends")
+		metric        = flag.String("metric", "", "Replicate only this metric, skipping discovery and exclusion filters")
+		resume        = flag.Bool("resume", false, "Skip the metrics completed by a previous run according to the checkpoint file")
+		metricsAddr   = flag.String("metrics-addr", "", "Serve promfire's own metrics on this address, e.g. :9099 (empty disables)")
 	)
 	flag.Parse()
 
-	if *version {
+	if *showVersion {
 		logger.Init(logger.INFO, "promfire")
-		logger.Info("PromFire v1.0.0 - Prometheus Benchmarking Tool")
+		logger.Info("PromFire v" + version.Version + " - Prometheus Benchmarking Tool")
 		return
 	}
 
 	// Load configuration
-	cfg, err := config.LoadConfig(*configPath)
+	cfg, err := config.LoadConfigWithOptions(*configPath, config.LoadOptions{
+		AllowUnknownFields: *allowUnknown,
+		ExpandRemoteEnv:    *expandRemote,
+	})
 	if err != nil {
 		logger.Init(logger.ERROR, "promfire")
 		logger.Fatal("Failed to load config", map[string]any{
@@ -40,6 +58,23 @@ func main() {
 	// Initialize logger with configured level
 	logl := logger.ParseLogLevel(*logLevel)
 	logger.Init(logl, "promfire")
+	if *logFormat != "" {
+		cfg.LogFormat = *logFormat
+	}
+	logger.SetFormat(cfg.LogFormat)
+	logger.SetSampleRate(cfg.LogSampleRate)
+
+	if cfg.LogFile != "" {
+		logFile, err := logger.NewRotatingFile(cfg.LogFile, cfg.LogMaxSize(), cfg.LogBackups())
+		if err != nil {
+			logger.Fatal("Failed to open log file", map[string]any{
+				"error":    err.Error(),
+				"log_file": cfg.LogFile,
+			})
+		}
+		defer logFile.Close()
+		logger.SetOutput(logFile)
+	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
@@ -48,11 +83,15 @@ func main() {
 		})
 	}
 
+	if cfg.Loopback.Enabled {
+		defer startLoopback(cfg)()
+	}
+
 	logger.Info("Starting Prometheus benchmark tool", map[string]any{
 		"query_url":          cfg.Prometheus.QueryURL,
 		"remote_write_url":   cfg.Prometheus.RemoteWriteURL,
-		"replication_factor": cfg.Benchmark.ReplicationFactor,
-		"dry_run":            *dryRun,
+		"replication_factor": cfg.ReplicationFactor(),
+		"dry_run":            *dryRun || *dryRunSample > 0,
 		"log_level":          logl.String(),
 	})
 
@@ -60,26 +99,111 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Handle interrupt signals gracefully
+	if *metricsAddr != "" {
+		go func() {
+			if err := selfmetrics.Serve(ctx, *metricsAddr); err != nil {
+				logger.Error("Metrics server failed", map[string]any{
+					"error": err.Error(),
+					"addr":  *metricsAddr,
+				})
+			}
+		}()
+	}
+
+	// Create and run benchmarker
+	bench, err := benchmarker.NewBenchmarker(cfg, benchmarker.Options{
+		DryRun:        *dryRun || *dryRunSample > 0 || *estimate,
+		DryRunSample:  *dryRunSample,
+		StatsInterval: *statsInterval,
+		Resume:        *resume,
+	})
+
+	if err != nil {
+		logger.Fatal("Failed to create benchmarker", map[string]any{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Handle interrupt signals gracefully: the first one drains a run, the
+	// second one (or the first outside a run) cancels immediately
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	drain := !*estimate && !*compliance && !*validate && *metric == ""
 	go func() {
 		<-sigChan
+		if drain {
+			logger.Info("Received interrupt signal, draining (interrupt again to exit immediately)...")
+			bench.Drain()
+			<-sigChan
+		}
 		logger.Info("Received interrupt signal, shutting down...")
 		cancel()
 	}()
 
-	// Create and run benchmarker
-	bench, err := benchmarker.NewBenchmarker(cfg, *dryRun)
-
+	stopProfiling, err := startProfiling(*cpuProfile, *memProfile)
 	if err != nil {
-		logger.Fatal("Failed to create benchmarker", map[string]any{
+		logger.Fatal("Failed to start profiling", map[string]any{
 			"error": err.Error(),
 		})
+	}
+	// Fatal exits without running deferred calls, so profiles are also
+	// flushed explicitly before it
+	defer stopProfiling()
+
+	if *validate {
+		if _, ok := bench.Preflight(ctx); !ok {
+			stopProfiling()
+			logger.Fatal("Preflight checks failed")
+		}
+		logger.Info("Preflight checks passed")
+		return
+	}
+
+	if *estimate {
+		if _, err := bench.Estimate(ctx); err != nil {
+			stopProfiling()
+			logger.Fatal("Estimate failed", map[string]any{
+				"error": err.Error(),
+			})
+		}
+		return
+	}
+
+	if *compliance {
+		results, err := bench.ComplianceCheck(ctx)
+		if err != nil {
+			stopProfiling()
+			logger.Fatal("Compliance check failed", map[string]any{
+				"error": err.Error(),
+			})
+		}
+		matched := 0
+		for _, r := range results {
+			if r.AsExpected {
+				matched++
+			}
+		}
+		logger.Info("Compliance check completed", map[string]any{
+			"probes":      len(results),
+			"as_expected": matched,
+		})
+		return
+	}
+
+	if *metric != "" {
+		if _, err := bench.RunOnce(ctx, *metric); err != nil {
+			stopProfiling()
+			logger.Fatal("Benchmarker failed", map[string]any{
+				"error": err.Error(),
+			})
+		}
+		logger.Info("Benchmark completed successfully")
 		return
 	}
 
 	if err := bench.Run(ctx); err != nil {
+		stopProfiling()
 		logger.Fatal("Benchmarker failed", map[string]any{
 			"error": err.Error(),
 		})
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+
+	"promfire/internal/logger"
+)
+
+// startProfiling starts a CPU profile written to cpuPath and arranges for a
+// heap profile to be written to memPath; empty paths disable either. The
+// returned stop function finishes both and is safe to call more than once,
+// so it can run both deferred and right before a fatal exit.
+func startProfiling(cpuPath, memPath string) (func(), error) {
+	var cpuFile *os.File
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("creating cpu profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("starting cpu profile: %w", err)
+		}
+		cpuFile = f
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if cpuFile != nil {
+				pprof.StopCPUProfile()
+				cpuFile.Close()
+				logger.Info("CPU profile written", map[string]any{"path": cpuPath})
+			}
+			if memPath != "" {
+				if err := writeHeapProfile(memPath); err != nil {
+					logger.Error("Failed to write heap profile", map[string]any{
+						"error": err.Error(),
+						"path":  memPath,
+					})
+					return
+				}
+				logger.Info("Heap profile written", map[string]any{"path": memPath})
+			}
+		})
+	}, nil
+}
+
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Collect garbage first so the profile reflects live memory
+	runtime.GC()
+	return pprof.WriteHeapProfile(f)
+}
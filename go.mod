@@ -4,14 +4,18 @@ go 1.21
 
 require (
 	github.com/golang/snappy v0.0.4
+	github.com/prometheus/common v0.44.0
 	github.com/prometheus/prometheus v0.47.2
+	go.opentelemetry.io/proto/otlp v1.0.0
 	golang.org/x/time v0.5.0
+	google.golang.org/protobuf v1.31.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
+	github.com/grafana/regexp v0.0.0-20221122212121-6b5c0a4cb7fd // indirect
 	github.com/kr/pretty v0.3.1 // indirect
-	github.com/rogpeppe/go-internal v1.10.0 // indirect
-	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
+	golang.org/x/exp v0.0.0-20230713183714-613f0c0eb8a1 // indirect
 )
@@ -0,0 +1,68 @@
+package benchmarker
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"promfire/internal/writer"
+)
+
+// errTargetRejectsWrites aborts the run when the remote write endpoint
+// consistently answers as if it does not accept remote write at all
+var errTargetRejectsWrites = errors.New("target does not appear to accept remote write")
+
+// writeProbe watches the first batches of a run for a consistent rejection
+// pattern (404/405) that points to a read-only or query-only endpoint
+type writeProbe struct {
+	mu        sync.Mutex
+	threshold int
+	rejected  int
+	settled   bool
+	tripped   bool
+}
+
+// newWriteProbe creates a probe that trips after threshold rejected batches;
+// a threshold below 1 disables the probe
+func newWriteProbe(threshold int) *writeProbe {
+	return &writeProbe{
+		threshold: threshold,
+		settled:   threshold < 1,
+	}
+}
+
+// observe records the outcome of a sent batch. The remote writer reports
+// every request through it, since buffered writes do not each send one.
+func (p *writeProbe) observe(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.settled {
+		return
+	}
+
+	var statusErr *writer.StatusError
+	if err == nil || !errors.As(err, &statusErr) ||
+		(statusErr.StatusCode != http.StatusNotFound && statusErr.StatusCode != http.StatusMethodNotAllowed) {
+		// Any other outcome breaks the read-only pattern
+		p.settled = true
+		return
+	}
+
+	p.rejected++
+	if p.rejected >= p.threshold {
+		p.tripped = true
+		p.settled = true
+	}
+}
+
+// err returns errTargetRejectsWrites once the first threshold batches have
+// all been rejected
+func (p *writeProbe) err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tripped {
+		return errTargetRejectsWrites
+	}
+	return nil
+}
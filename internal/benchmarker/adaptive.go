@@ -0,0 +1,255 @@
+package benchmarker
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"promfire/internal/config"
+)
+
+// aimdIncreaseEvery is how many successful requests raise the rate once
+const aimdIncreaseEvery = 10
+
+// aimdCooldown is the minimum time between two decreases, so a burst of
+// rejections from requests already in flight only lowers the rate once
+const aimdCooldown = time.Second
+
+// aimdController adjusts the sample rate limiter with additive increase and
+// multiplicative decrease: the rate grows by a fixed step while requests
+// succeed and is scaled down whenever the target answers 429 or 503
+type aimdController struct {
+	mu        sync.Mutex
+	limiter   *rate.Limiter
+	min, max  float64
+	increase  float64
+	decrease  float64
+	successes int
+	lastCut   time.Time
+}
+
+// newAIMDController returns nil when adaptive rate limiting is disabled
+func newAIMDController(cfg config.AdaptiveRate) *aimdController {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &aimdController{
+		min:      float64(cfg.MinSamplesPerSecond),
+		max:      float64(cfg.MaxSamplesPerSecond),
+		increase: float64(cfg.IncreaseSamplesPerSecond),
+		decrease: cfg.DecreaseFactor,
+	}
+}
+
+// attach makes the controller drive limiter
+func (c *aimdController) attach(limiter *rate.Limiter) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limiter = limiter
+	c.successes = 0
+}
+
+// observe feeds the status of one remote write response into the controller
+func (c *aimdController) observe(status int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.limiter == nil {
+		return
+	}
+
+	current := float64(c.limiter.Limit())
+	switch {
+	case status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable:
+		c.successes = 0
+		if time.Since(c.lastCut) < aimdCooldown {
+			return
+		}
+		c.lastCut = time.Now()
+		next := current * c.decrease
+		if next < c.min {
+			next = c.min
+		}
+		if next == current {
+			return
+		}
+		c.limiter.SetLimit(rate.Limit(next))
+		log.Info("Target is throttling, lowering sample rate", map[string]interface{}{
+			"status":             status,
+			"samples_per_second": next,
+			"previous":           current,
+		})
+	case status >= 200 && status < 300:
+		c.successes++
+		if c.successes < aimdIncreaseEvery {
+			return
+		}
+		c.successes = 0
+		next := current + c.increase
+		if next > c.max {
+			next = c.max
+		}
+		if next == current {
+			return
+		}
+		c.limiter.SetLimit(rate.Limit(next))
+		log.Debug("Raising sample rate", map[string]interface{}{
+			"samples_per_second": next,
+			"previous":           current,
+		})
+	}
+}
+
+// concurrencyController gates the replica writes in flight, and with them
+// the remote write requests, with additive increase and multiplicative
+// decrease: every aimdIncreaseEvery responses without errors whose mean
+// latency stays within tolerance times the lowest mean seen allow one more,
+// and throttling, server errors, transport errors or a rising latency scale
+// the limit down
+type concurrencyController struct {
+	mu        sync.Mutex
+	limit     int
+	inFlight  int
+	wake      chan struct{}
+	min, max  int
+	tolerance float64
+	decrease  float64
+	baseline  time.Duration
+	latency   time.Duration
+	responses int
+	lastCut   time.Time
+}
+
+// newConcurrencyController returns nil when adaptive concurrency is
+// disabled, starting at the minimum concurrency otherwise
+func newConcurrencyController(cfg config.AdaptiveConcurrency) *concurrencyController {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &concurrencyController{
+		limit:     cfg.MinConcurrency,
+		wake:      make(chan struct{}),
+		min:       cfg.MinConcurrency,
+		max:       cfg.MaxConcurrency,
+		tolerance: cfg.LatencyTolerance,
+		decrease:  cfg.DecreaseFactor,
+	}
+}
+
+// acquire blocks until a write may start or ctx is done
+func (c *concurrencyController) acquire(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	for {
+		c.mu.Lock()
+		if c.inFlight < c.limit {
+			c.inFlight++
+			c.mu.Unlock()
+			return nil
+		}
+		wake := c.wake
+		c.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release ends a write started by acquire
+func (c *concurrencyController) release() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight--
+	c.signal()
+}
+
+// signal wakes the writers waiting in acquire. It must be called with mu held.
+func (c *concurrencyController) signal() {
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// current returns the concurrency limit the controller has converged to
+func (c *concurrencyController) current() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+// observe feeds the status and latency of one remote write response into
+// the controller
+func (c *concurrencyController) observe(status int, latency time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case status == 0 || status == http.StatusTooManyRequests || status >= 500:
+		c.responses, c.latency = 0, 0
+		c.cut("Target is failing, lowering write concurrency", map[string]interface{}{"status": status})
+	case status >= 200 && status < 300:
+		c.responses++
+		c.latency += latency
+		if c.responses < aimdIncreaseEvery {
+			return
+		}
+		mean := c.latency / time.Duration(c.responses)
+		c.responses, c.latency = 0, 0
+		if c.baseline == 0 || mean < c.baseline {
+			c.baseline = mean
+		}
+
+		if float64(mean) > float64(c.baseline)*c.tolerance {
+			c.cut("Write latency is rising, lowering write concurrency", map[string]interface{}{
+				"latency_ms":  mean.Milliseconds(),
+				"baseline_ms": c.baseline.Milliseconds(),
+			})
+			return
+		}
+		if c.limit >= c.max {
+			return
+		}
+		c.limit++
+		c.signal()
+		log.Debug("Raising write concurrency", map[string]interface{}{
+			"write_concurrency": c.limit,
+			"latency_ms":        mean.Milliseconds(),
+		})
+	}
+}
+
+// cut scales the limit down at most once per aimdCooldown. It must be
+// called with mu held.
+func (c *concurrencyController) cut(msg string, fields map[string]interface{}) {
+	if time.Since(c.lastCut) < aimdCooldown {
+		return
+	}
+	c.lastCut = time.Now()
+	next := int(float64(c.limit) * c.decrease)
+	if next < c.min {
+		next = c.min
+	}
+	if next == c.limit {
+		return
+	}
+	fields["write_concurrency"] = next
+	fields["previous"] = c.limit
+	c.limit = next
+	log.Info(msg, fields)
+}
@@ -0,0 +1,186 @@
+package benchmarker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/config"
+	"promfire/internal/logger"
+)
+
+func testConcurrencyController() *concurrencyController {
+	return newConcurrencyController(config.AdaptiveConcurrency{
+		Enabled:          true,
+		MinConcurrency:   1,
+		MaxConcurrency:   4,
+		LatencyTolerance: 1.5,
+		DecreaseFactor:   0.5,
+	})
+}
+
+// observeMany feeds n responses of the same status and latency
+func observeMany(c *concurrencyController, n, status int, latency time.Duration) {
+	for i := 0; i < n; i++ {
+		c.observe(status, latency)
+	}
+}
+
+func TestConcurrencyRampsUpToMax(t *testing.T) {
+	c := testConcurrencyController()
+	if got := c.current(); got != 1 {
+		t.Fatalf("initial concurrency = %d, want the minimum 1", got)
+	}
+
+	observeMany(c, aimdIncreaseEvery, http.StatusOK, 10*time.Millisecond)
+	if got := c.current(); got != 2 {
+		t.Fatalf("concurrency = %d after one window of flat latency, want 2", got)
+	}
+	observeMany(c, 10*aimdIncreaseEvery, http.StatusOK, 10*time.Millisecond)
+	if got := c.current(); got != 4 {
+		t.Errorf("concurrency = %d, want it capped at the maximum 4", got)
+	}
+}
+
+func TestConcurrencyBacksOff(t *testing.T) {
+	tests := []struct {
+		name    string
+		observe func(c *concurrencyController)
+	}{
+		{"throttled", func(c *concurrencyController) { c.observe(http.StatusTooManyRequests, time.Millisecond) }},
+		{"server error", func(c *concurrencyController) { c.observe(http.StatusInternalServerError, time.Millisecond) }},
+		{"transport error", func(c *concurrencyController) { c.observe(0, time.Millisecond) }},
+		{"latency rise", func(c *concurrencyController) {
+			observeMany(c, aimdIncreaseEvery, http.StatusOK, 100*time.Millisecond)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConcurrencyController()
+			observeMany(c, 3*aimdIncreaseEvery, http.StatusOK, 10*time.Millisecond)
+			if got := c.current(); got != 4 {
+				t.Fatalf("concurrency = %d before backing off, want 4", got)
+			}
+
+			tt.observe(c)
+			if got := c.current(); got != 2 {
+				t.Errorf("concurrency = %d, want it halved to 2", got)
+			}
+		})
+	}
+}
+
+func TestConcurrencyIgnoresClientErrors(t *testing.T) {
+	c := testConcurrencyController()
+	observeMany(c, aimdIncreaseEvery, http.StatusOK, 10*time.Millisecond)
+	observeMany(c, aimdIncreaseEvery, http.StatusBadRequest, time.Second)
+	if got := c.current(); got != 2 {
+		t.Errorf("concurrency = %d after 400 responses, want them ignored at 2", got)
+	}
+}
+
+func TestConcurrencyGateBlocksAtLimit(t *testing.T) {
+	c := testConcurrencyController()
+	if err := c.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second acquire at limit 1 = %v, want it to block until the deadline", err)
+	}
+
+	// Raising the limit admits a waiting writer
+	acquired := make(chan error, 1)
+	go func() { acquired <- c.acquire(context.Background()) }()
+	observeMany(c, aimdIncreaseEvery, http.StatusOK, 10*time.Millisecond)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("raising the limit did not admit the waiting writer")
+	}
+
+	c.release()
+	c.release()
+	if c.inFlight != 0 {
+		t.Errorf("in flight = %d after releasing every write, want 0", c.inFlight)
+	}
+}
+
+func TestRunReportsConvergedConcurrency(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	cfg := testConfig(t, nil, recv,
+		"  replication_factor: 1\n  batch_size: 1\n  concurrency: 4\n  query_range: 5m\n  query_step: 1m\n  adaptive_concurrency:\n    enabled: true\n",
+		"source:\n  type: synthetic\n  synthetic:\n    series_count: 50\n")
+	logs := captureLogs(t, logger.INFO)
+
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := b.concurrency.current(); got < 1 || got > 4 {
+		t.Errorf("converged concurrency = %d, want it within 1..4", got)
+	}
+	if !bytes.Contains(logs.Bytes(), []byte(`"write_concurrency":`)) {
+		t.Errorf("summary does not report write_concurrency:\n%s", logs)
+	}
+}
+
+func TestAdaptiveRateBacksOffAboveThreshold(t *testing.T) {
+	const threshold = 2000
+	limiter := rate.NewLimiter(1000, 1000)
+	c := newAIMDController(config.AdaptiveRate{
+		Enabled:                  true,
+		MinSamplesPerSecond:      100,
+		MaxSamplesPerSecond:      10000,
+		IncreaseSamplesPerSecond: 200,
+		DecreaseFactor:           0.5,
+	})
+	c.attach(limiter)
+
+	// The target throttles whenever the client's rate exceeds the threshold
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if float64(limiter.Limit()) > threshold {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var highest, lowestAfterPeak float64
+	for i := 0; i < 200; i++ {
+		resp, err := http.Post(srv.URL, "application/x-protobuf", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		c.observe(resp.StatusCode)
+
+		current := float64(limiter.Limit())
+		if current > highest {
+			highest, lowestAfterPeak = current, current
+		}
+		if current < lowestAfterPeak {
+			lowestAfterPeak = current
+		}
+	}
+
+	if highest != threshold+200 {
+		t.Errorf("rate peaked at %g, want one increase past the threshold to %d", highest, threshold+200)
+	}
+	if lowestAfterPeak != (threshold+200)/2 {
+		t.Errorf("rate fell to %g after the first 429, want it halved to %d", lowestAfterPeak, (threshold+200)/2)
+	}
+}
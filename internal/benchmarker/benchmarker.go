@@ -3,116 +3,481 @@ package benchmarker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"golang.org/x/time/rate"
 	"promfire/internal/config"
+	"promfire/internal/httpclient"
 	"promfire/internal/logger"
+	"promfire/internal/selfmetrics"
+	"promfire/internal/version"
 	"promfire/internal/writer"
 )
 
+// log writes the benchmarker's entries with component "benchmarker"
+var log = logger.With("benchmarker")
+
 // Benchmarker handles the main benchmarking logic
 type Benchmarker struct {
 	config         *config.Config
 	dryRun         bool
+	dryRunSampler  *dryRunSampler
 	client         *http.Client
 	excludeRegexes []*regexp.Regexp
+	includeRegexes []*regexp.Regexp
+	seriesMatchers []*labels.Matcher
 	remoteWriter   *writer.RemoteWriter
+	manifest       *writer.Manifest
+	writeProbe     *writeProbe
+	adaptive       *aimdController
+	concurrency    *concurrencyController
+	queryAuth      config.QueryAuth
+	queryHeaders   map[string]string
+	stats          runStats
+	failures       failureSummary
+	checkpoint     *checkpoint
+	resume         bool
+	statsInterval  time.Duration
+	goroutines     *goroutineLimiter
+	queries        *queryLimiter
+	writes         *writeQueue
+	source         SeriesSource
+	relabelRules   []relabelRule
+	// valuePattern generates replica values, nil keeping the source values
+	valuePattern valueGeneratorFunc
+	// counters keeps counter replicas monotonic, nil unless enabled
+	counters *counterTracker
+	// summaryFamilies pairs the _sum and _count metrics found by discovery
+	summaryFamilies summaryFamilies
+	// labelCombinations holds the replica label sets, shared by all series
+	labelCombinations []map[string]string
+	runID             string
+
+	// replicas counts replica series generated against max_total_series
+	replicas      atomic.Int64
+	cappedSeries  atomic.Int64
+	seriesCapOnce sync.Once
+	// truncatedSeries counts source series cut to max_samples_per_series
+	truncatedSeries atomic.Int64
+
+	// drain is closed by Drain to stop dispatching new metrics
+	drain      chan struct{}
+	drainOnce  sync.Once
+	runMu      sync.Mutex
+	cancelRun  context.CancelFunc
+	drainTimer *time.Timer
 }
 
-// PrometheusResponse represents a response from Prometheus API
-type PrometheusResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		ResultType string `json:"resultType"`
-		Result     []struct {
-			Metric map[string]string `json:"metric"`
-			Values [][]any           `json:"values"`
-		} `json:"result"`
-	} `json:"data"`
+// Options holds runtime settings that come from the command line rather than
+// the configuration file
+type Options struct {
+	DryRun bool
+	// DryRunSample logs this many example replicas per metric plus counts in
+	// dry-run mode instead of every replica; 0 logs every replica
+	DryRunSample int
+	// StatsInterval enables periodic progress logging; 0 disables it
+	StatsInterval time.Duration
+	// Source overrides the series source selected by source.type
+	Source SeriesSource
+	// Resume skips the metrics recorded as completed in the checkpoint and
+	// enables checkpointing even when output.checkpoint is off
+	Resume bool
 }
 
 // NewBenchmarker creates a new Benchmarker instance
-func NewBenchmarker(cfg *config.Config, dryRun bool) (*Benchmarker, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+func NewBenchmarker(cfg *config.Config, opts Options) (*Benchmarker, error) {
+	tlsConfig, err := httpclient.NewTLSConfig(httpclient.TLSOptions{
+		CAFile:             cfg.Prometheus.TLS.CAFile,
+		CertFile:           cfg.Prometheus.TLS.CertFile,
+		KeyFile:            cfg.Prometheus.TLS.KeyFile,
+		InsecureSkipVerify: cfg.Prometheus.TLS.InsecureSkipVerify,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configuring tls: %w", err)
 	}
 
-	// Compile exclude regex patterns
-	var excludeRegexes []*regexp.Regexp
-	for _, pattern := range cfg.ExcludeMetrics {
-		regex, err := regexp.Compile(pattern)
-		if err != nil {
-			logger.Warn("Invalid exclude pattern", map[string]any{
-				"pattern": pattern,
-				"error":   err.Error(),
-			})
-			continue
-		}
-		excludeRegexes = append(excludeRegexes, regex)
+	pool := httpclient.PoolOptions{
+		MaxIdleConns:    cfg.Prometheus.MaxIdleConns,
+		MaxConnsPerHost: cfg.Prometheus.MaxConnsPerHost,
+		IdleConnTimeout: cfg.IdleConnTimeout(),
+	}
+	client := httpclient.NewStreaming(cfg.QueryTimeout(), tlsConfig, pool)
+
+	// Regex patterns are compiled and validated once by the config
+	includeRegexes, excludeRegexes, err := cfg.MetricFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	seriesMatchers, err := cfg.SeriesMatchers()
+	if err != nil {
+		return nil, err
+	}
+
+	relabelRules, err := compileRelabelRules(cfg.Benchmark.Relabel)
+	if err != nil {
+		return nil, err
+	}
+
+	jitter, ok := writer.JitterFor(cfg.Benchmark.Retry.Jitter)
+	if !ok {
+		return nil, fmt.Errorf("unknown retry jitter %q", cfg.Benchmark.Retry.Jitter)
+	}
+
+	runID := newRunID()
+	queryHeaders, remoteWriteHeaders := cfg.QueryHeaders(), cfg.RemoteWriteHeaders()
+	if cfg.Prometheus.RunIDHeader {
+		queryHeaders = withHeader(queryHeaders, RunIDHeader, runID)
+		remoteWriteHeaders = withHeader(remoteWriteHeaders, RunIDHeader, runID)
+	}
+
+	probe := newWriteProbe(cfg.Benchmark.EarlyAbortBatches)
+	adaptive := newAIMDController(cfg.Benchmark.AdaptiveRate)
+	concurrency := newConcurrencyController(cfg.Benchmark.AdaptiveConcurrency)
+	onResponse := func(status int, latency time.Duration) {
+		adaptive.observe(status)
+		concurrency.observe(status, latency)
+	}
+	var dryRunSampler *dryRunSampler
+	if opts.DryRun {
+		dryRunSampler = newDryRunSampler(opts.DryRunSample, cfg.Benchmark.RunLabel)
+	}
+
+	sigv4, err := sigV4Credentials(cfg.Prometheus.RemoteWriteAuth.SigV4)
+	if err != nil {
+		return nil, err
 	}
 
 	var remoteWriter *writer.RemoteWriter
-	if !dryRun {
-		remoteWriter = writer.NewRemoteWriter(cfg.Prometheus.RemoteWriteURL, cfg.Benchmark.BatchSize)
+	var manifest *writer.Manifest
+	if !opts.DryRun {
+		remoteWriter = writer.NewRemoteWriter(cfg.Prometheus.RemoteWriteURL, cfg.Benchmark.BatchSize, writer.Options{
+			Auth: writer.BasicAuth{
+				Username: cfg.Prometheus.RemoteWriteAuth.Username,
+				Password: cfg.Prometheus.RemoteWriteAuth.Password,
+			},
+			SigV4:   sigv4,
+			Headers: remoteWriteHeaders,
+			Retry: writer.RetryPolicy{
+				MaxRetries:     cfg.Benchmark.Retry.MaxRetries,
+				InitialBackoff: time.Duration(cfg.Benchmark.Retry.InitialBackoffMs) * time.Millisecond,
+				MaxDelay:       time.Duration(cfg.Benchmark.Retry.MaxDelayMs) * time.Millisecond,
+				Jitter:         jitter,
+			},
+			CircuitBreaker: writer.CircuitBreaker{
+				Threshold: cfg.Benchmark.CircuitBreaker.FailureThreshold,
+				Cooldown:  time.Duration(cfg.Benchmark.CircuitBreaker.CooldownSeconds) * time.Second,
+			},
+			NormalizeLabelNames: cfg.Benchmark.NormalizeLabelNames,
+			TLSConfig:           tlsConfig,
+			Pool:                pool,
+			Timeout:             cfg.RemoteWriteTimeout(),
+			MaxRequestBytes:     cfg.Prometheus.MaxRequestBytes,
+			TimestampMode:       cfg.Benchmark.TimestampMode,
+			TimeAcceleration:    cfg.Benchmark.TimeAcceleration,
+			DuplicateTimestamps: cfg.Benchmark.DuplicateTimestamps,
+			Encoding:            cfg.Prometheus.RemoteWriteEncoding,
+			SnappyFramed:        cfg.Prometheus.SnappyFramed,
+			Protocol:            cfg.Prometheus.WriteProtocol,
+			TimestampResolution: cfg.TimestampResolution(),
+			TimestampIncrement:  cfg.TimestampIncrement(),
+			FutureGuard: writer.FutureGuard{
+				Policy:    cfg.Benchmark.FutureSamples.Policy,
+				Tolerance: time.Duration(cfg.Benchmark.FutureSamples.ToleranceMs) * time.Millisecond,
+			},
+			AgeGuard: writer.AgeGuard{
+				Policy: cfg.Benchmark.OldSamples.Policy,
+				MaxAge: cfg.MaxSampleAge(),
+			},
+			OrderCheck:           orderingCheck(cfg.Benchmark.OrderingCheck),
+			ExemplarFraction:     cfg.ExemplarFraction(),
+			OnBatch:              probe.observe,
+			OnResponse:           onResponse,
+			LatencyWarnThreshold: time.Duration(cfg.Benchmark.LatencyWarnMs) * time.Millisecond,
+		})
 		if remoteWriter == nil {
 			return nil, fmt.Errorf("failed to create remote writer")
 		}
-		logger.Info("Remote writer initialized", map[string]any{
+		log.Info("Remote writer initialized", map[string]any{
 			"remote_write_url": cfg.Prometheus.RemoteWriteURL,
 			"batch_size":       cfg.Benchmark.BatchSize,
+			"encoding":         cfg.Prometheus.RemoteWriteEncoding,
 		})
+
+		if cfg.Output.Manifest {
+			manifest = writer.NewManifest()
+			remoteWriter.SetManifest(manifest)
+		}
+		selfmetrics.RemoteWriteSeconds.SetSource(remoteWriter.LatencyHistogram)
 	}
 
-	return &Benchmarker{
+	selfmetrics.RunInfo.Set(map[string]string{
+		"run_id":             runID,
+		"version":            version.Version,
+		"seed":               strconv.FormatInt(cfg.Benchmark.Seed, 10),
+		"source":             cfg.Source.Type,
+		"replication_factor": strconv.Itoa(cfg.ReplicationFactor()),
+		"samples_per_second": strconv.Itoa(cfg.Benchmark.SamplesPerSecond),
+		"batch_size":         strconv.Itoa(cfg.Benchmark.BatchSize),
+		"concurrency":        strconv.Itoa(cfg.Benchmark.Concurrency),
+		"timestamp_mode":     cfg.Benchmark.TimestampMode,
+		"dry_run":            strconv.FormatBool(opts.DryRun),
+	})
+
+	if cfg.Benchmark.RunLabel != "" {
+		log.Info("Stamping run id onto replicated series", map[string]any{
+			"run_label": cfg.Benchmark.RunLabel,
+			"run_id":    runID,
+			"selector":  fmt.Sprintf("{%s=%q}", cfg.Benchmark.RunLabel, runID),
+		})
+	}
+
+	b := &Benchmarker{
 		config:         cfg,
-		dryRun:         dryRun,
+		dryRun:         opts.DryRun,
+		dryRunSampler:  dryRunSampler,
+		statsInterval:  opts.StatsInterval,
+		goroutines:     newGoroutineLimiter(cfg.Benchmark.MaxGoroutines),
+		queries:        newQueryLimiter(cfg.Benchmark.MaxConcurrentQueries),
+		drain:          make(chan struct{}),
 		client:         client,
 		excludeRegexes: excludeRegexes,
+		includeRegexes: includeRegexes,
+		seriesMatchers: seriesMatchers,
 		remoteWriter:   remoteWriter,
-	}, nil
+		manifest:       manifest,
+		writeProbe:     probe,
+		adaptive:       adaptive,
+		concurrency:    concurrency,
+		queryAuth:      cfg.Prometheus.QueryAuth,
+		queryHeaders:   queryHeaders,
+		relabelRules:   relabelRules,
+		valuePattern:   newValuePattern(cfg),
+		runID:          runID,
+		resume:         opts.Resume,
+	}
+	if cfg.Benchmark.EnforceCounterMonotonicity {
+		b.counters = newCounterTracker()
+	}
+	if (cfg.Output.Checkpoint || opts.Resume) && !opts.DryRun {
+		b.checkpoint = newCheckpoint(cfg.Output.CheckpointPath, runID)
+	}
+
+	b.labelCombinations = b.generateLabelCombinations()
+
+	b.source = opts.Source
+	if b.source == nil {
+		if b.source, err = newSeriesSource(cfg, b); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// RunID returns the id that identifies this benchmark run
+func (b *Benchmarker) RunID() string {
+	return b.runID
+}
+
+// newRunID returns a sortable run id made of the UTC start time and a random
+// suffix, so runs started in the same second still differ
+func newRunID() string {
+	return fmt.Sprintf("%s-%06x", time.Now().UTC().Format("20060102T150405Z"), rand.Int63n(1<<24))
 }
 
 // Run executes the benchmarking process
 func (b *Benchmarker) Run(ctx context.Context) error {
-	logger.Info("Starting benchmark process")
+	log.Info("Starting benchmark process")
+
+	if b.config.Benchmark.CheckGoroutineLeaks {
+		defer b.checkGoroutineLeaks(runtime.NumGoroutine())
+	}
+
+	b.stats.start()
+	defer b.logStats()
+	defer b.stats.finish()
+	if b.remoteWriter != nil {
+		defer b.remoteWriter.Close()
+	}
+
+	if timeout := b.config.TotalTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ctx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	b.setRunCancel(cancelRun)
+	defer b.setRunCancel(nil)
 
 	// Step 1: Discover all metrics
-	metrics, err := b.discoverMetrics(ctx)
+	metrics, err := b.source.Metrics(ctx)
 	if err != nil {
 		return fmt.Errorf("discovering metrics: %w", err)
 	}
 
-	logger.Info("Metric discovery completed", map[string]interface{}{
+	log.Info("Metric discovery completed", map[string]interface{}{
 		"total_metrics": len(metrics),
 	})
 
+	b.summaryFamilies = detectSummaryFamilies(metrics)
+	if len(b.summaryFamilies) > 0 {
+		log.Debug("Summary families detected, coordinating their _sum and _count replicas", map[string]interface{}{
+			"families": len(b.summaryFamilies) / 2,
+		})
+	}
+
 	// Step 2: Filter metrics
 	filteredMetrics := b.filterMetrics(metrics)
-	logger.Info("Metric filtering completed", map[string]interface{}{
+	log.Info("Metric filtering completed", map[string]interface{}{
 		"filtered_metrics": len(filteredMetrics),
 		"excluded_metrics": len(metrics) - len(filteredMetrics),
 	})
 
+	if sampling := b.config.Benchmark.SampleMetrics; sampling.Count > 0 || sampling.Percent > 0 {
+		sampled := sampleMetrics(filteredMetrics, sampling, b.config.Benchmark.Seed)
+		log.Info("Metric sampling completed", map[string]interface{}{
+			"sampled_metrics": len(sampled),
+			"skipped_metrics": len(filteredMetrics) - len(sampled),
+		})
+		filteredMetrics = sampled
+	}
+
+	if b.checkpoint != nil {
+		all := filteredMetrics
+		if b.resume {
+			if filteredMetrics, err = b.checkpoint.resume(filteredMetrics); err != nil {
+				return err
+			}
+		}
+		if err := b.checkpoint.start(all); err != nil {
+			return err
+		}
+	}
+
+	b.logProjectedPoints(len(filteredMetrics))
+	b.warnCardinality(ctx, filteredMetrics)
+
+	b.loadMetadata(ctx, filteredMetrics)
+
 	// Step 3: Query and replicate each metric
-	return b.processMetrics(ctx, filteredMetrics)
+	b.stats.totalMetrics.Store(int64(len(filteredMetrics)))
+	stopProgress := b.startProgressLogger(ctx)
+	err = b.processMetrics(ctx, filteredMetrics)
+	stopProgress()
+	if errors.Is(err, context.DeadlineExceeded) && b.config.TotalTimeout() > 0 {
+		// Running out of the total budget ends the run early but keeps its reports
+		log.Warn("Run exceeded total_timeout_seconds, stopping", map[string]interface{}{
+			"timeout_seconds":   b.config.Benchmark.TotalTimeoutSeconds,
+			"processed_metrics": b.stats.metrics.Load(),
+			"total_metrics":     len(filteredMetrics),
+		})
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	b.dryRunSampler.logSummary()
+	b.reportQueryWaits()
+	b.reportFutureSamples()
+	b.reportDuplicateSamples()
+	b.reportOldSamples()
+	b.reportUnorderedSeries()
+	b.reportSeriesCap()
+	b.counters.report()
+
+	if normalized, collisions := b.remoteWriterNormalized(); normalized > 0 {
+		log.Info("Label name normalization summary", map[string]interface{}{
+			"normalized_labels": normalized,
+			"collisions":        collisions,
+		})
+	}
+
+	// Step 4: Write the manifest of written series
+	if b.manifest != nil {
+		if err := b.writeManifest(); err != nil {
+			return fmt.Errorf("writing manifest: %w", err)
+		}
+	}
+
+	if b.remoteWriter != nil {
+		if err := b.reportCompression(); err != nil {
+			return fmt.Errorf("writing compression report: %w", err)
+		}
+	}
+
+	if b.checkpoint != nil && b.checkpoint.done() {
+		if err := b.checkpoint.remove(); err != nil {
+			return err
+		}
+		log.Info("All metrics completed, checkpoint removed", map[string]interface{}{
+			"path": b.config.Output.CheckpointPath,
+		})
+	}
+
+	return b.checkFailures(b.stats.metrics.Load())
+}
+
+// remoteWriterNormalized returns the writer's label normalization counters
+func (b *Benchmarker) remoteWriterNormalized() (int64, int64) {
+	if b.remoteWriter == nil {
+		return 0, 0
+	}
+	return b.remoteWriter.NormalizedLabels()
+}
+
+// writeManifest writes the series manifest to the configured output directory
+func (b *Benchmarker) writeManifest() error {
+	if err := os.MkdirAll(b.config.Output.Dir, 0o755); err != nil {
+		return fmt.Errorf("creating output dir: %w", err)
+	}
+
+	path := filepath.Join(b.config.Output.Dir, "manifest.ndjson")
+	if err := b.manifest.WriteFile(path); err != nil {
+		return err
+	}
+
+	log.Info("Manifest written", map[string]interface{}{
+		"path":   path,
+		"series": b.manifest.Len(),
+	})
+	return nil
 }
 
 // discoverMetrics discovers all available metrics from Prometheus
 func (b *Benchmarker) discoverMetrics(ctx context.Context) ([]string, error) {
 	queryURL := fmt.Sprintf("%s/api/v1/label/__name__/values", b.config.Prometheus.QueryURL)
 
-	req, err := http.NewRequestWithContext(ctx, "GET", queryURL, nil)
+	req, err := b.newQueryRequest(ctx, queryURL)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
+	release, err := b.queries.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	resp, err := b.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("making request: %w", err)
@@ -140,177 +505,724 @@ func (b *Benchmarker) discoverMetrics(ctx context.Context) ([]string, error) {
 	return result.Data, nil
 }
 
-// filterMetrics filters out excluded metrics based on regex patterns
+// orderingCheck resolves the configured ordering check mode. The check costs a
+// pass over every series, so when unset it is only enabled at debug level.
+func orderingCheck(mode string) string {
+	if mode != "" {
+		return mode
+	}
+	if logger.GetLevel() <= logger.DEBUG {
+		return writer.OrderCheckFail
+	}
+	return writer.OrderCheckOff
+}
+
+// sigV4Credentials resolves the SigV4 settings, taking keys missing from the
+// config from the standard AWS environment variables
+func sigV4Credentials(cfg *config.SigV4) (*writer.SigV4, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	creds := &writer.SigV4{
+		Region:       cfg.Region,
+		Service:      cfg.Service,
+		AccessKey:    cfg.AccessKey,
+		SecretKey:    cfg.SecretKey,
+		SessionToken: cfg.SessionToken,
+	}
+	if creds.AccessKey == "" {
+		creds.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		creds.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		if creds.SessionToken == "" {
+			creds.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+		}
+	}
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return nil, fmt.Errorf("sigv4: no credentials in config or AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY")
+	}
+	return creds, nil
+}
+
+// RunIDHeader carries the run id when prometheus.run_id_header is set
+const RunIDHeader = "X-Promfire-Run-ID"
+
+// withHeader returns a copy of headers with name set to value
+func withHeader(headers map[string]string, name, value string) map[string]string {
+	merged := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		merged[k] = v
+	}
+	merged[name] = value
+	return merged
+}
+
+// newQueryRequest builds a GET request against the query API with authentication applied
+func (b *Benchmarker) newQueryRequest(ctx context.Context, queryURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", queryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range b.queryHeaders {
+		req.Header.Set(name, value)
+	}
+
+	token := b.queryAuth.BearerToken
+	if b.queryAuth.BearerTokenFile != "" {
+		data, err := os.ReadFile(b.queryAuth.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading bearer token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return req, nil
+}
+
+// filterMetrics filters metrics based on regex patterns. When include patterns
+// are configured a metric must match at least one of them; a metric matching
+// any exclude pattern is always dropped, even if it is also included.
 func (b *Benchmarker) filterMetrics(metrics []string) []string {
 	var filtered []string
 	for _, metric := range metrics {
-		excluded := false
-		for _, regex := range b.excludeRegexes {
-			if regex.MatchString(metric) {
-				excluded = true
-				break
-			}
+		if len(b.includeRegexes) > 0 && !matchesAny(b.includeRegexes, metric) {
+			continue
 		}
-		if !excluded {
-			filtered = append(filtered, metric)
+		if matchesAny(b.excludeRegexes, metric) {
+			continue
 		}
+		filtered = append(filtered, metric)
 	}
 	return filtered
 }
 
+// matchesAny reports whether metric matches at least one of the regexes
+func matchesAny(regexes []*regexp.Regexp, metric string) bool {
+	for _, regex := range regexes {
+		if regex.MatchString(metric) {
+			return true
+		}
+	}
+	return false
+}
+
+// newRateLimiter creates the limiter of samples per second with the
+// configured burst capacity, handing it to adaptive rate control
+func (b *Benchmarker) newRateLimiter() *rate.Limiter {
+	rateLimiter := rate.NewLimiter(rate.Limit(b.config.Benchmark.SamplesPerSecond), b.config.Burst())
+	b.adaptive.attach(rateLimiter)
+	return rateLimiter
+}
+
 // processMetrics processes each metric by querying and replicating data
-func (b *Benchmarker) processMetrics(ctx context.Context, metrics []string) error {
+// across a pool of benchmark.concurrency workers sharing one rate limiter
+func (b *Benchmarker) processMetrics(parent context.Context, metrics []string) error {
 	endTime := time.Now()
-	startTime := endTime.Add(-time.Duration(b.config.Benchmark.QueryRangeHours) * time.Hour)
-	step := time.Duration(b.config.Benchmark.QueryStepSeconds) * time.Second
+	startTime := endTime.Add(-b.config.QueryRange())
+	step := b.config.QueryStep()
 
-	// Create rate limiter for samples per second with larger burst capacity
-	samplesPerSecond := b.config.Benchmark.SamplesPerSecond
-	burstCapacity := samplesPerSecond * 2 // Allow bursts up to 2 seconds worth of samples
-	rateLimiter := rate.NewLimiter(rate.Limit(samplesPerSecond), burstCapacity)
+	if b.remoteWriter != nil {
+		b.remoteWriter.SetShiftOrigin(endTime)
+	}
+
+	rateLimiter := b.newRateLimiter()
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		failed   []error
+		timedOut int
+		abortErr error
+		wg       sync.WaitGroup
+	)
+
+	b.writes = newWriteQueue(b.config.Benchmark.WriteQueue.Depth, b.concurrency, &b.failures)
+
+	jobs := make(chan string)
+	for w := 0; w < b.config.Benchmark.Concurrency; w++ {
+		worker := w
+		wg.Add(1)
+		started := b.goroutines.tryGo(func() {
+			defer wg.Done()
+			for metricName := range jobs {
+				// Every log entry downstream of this metric carries its name and worker
+				logCtx := logger.WithFields(ctx, map[string]interface{}{
+					"metric_name": metricName,
+					"worker_id":   worker,
+				})
+				log.DebugContext(logCtx, "Processing metric")
+
+				metricCtx, cancelMetric := b.metricContext(logCtx)
+				err := b.processMetric(metricCtx, metricName, startTime, endTime, step, rateLimiter)
+				metricTimedOut := err != nil && errors.Is(metricCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+				cancelMetric()
+				b.stats.metrics.Add(1)
+				if err == nil {
+					continue
+				}
+
+				mu.Lock()
+				if metricTimedOut {
+					timedOut++
+					b.failures.timeouts.Add(1)
+					log.WarnContext(logCtx, "Metric exceeded per_metric_timeout_seconds, skipping", map[string]interface{}{
+						"timeout_seconds": b.config.Benchmark.PerMetricTimeoutSeconds,
+					})
+				} else if errors.Is(err, errTargetRejectsWrites) || errors.Is(err, writer.ErrUnorderedSamples) {
+					if abortErr == nil {
+						abortErr = fmt.Errorf("%s: %w", metricName, err)
+					}
+					cancel()
+				} else if ctx.Err() == nil {
+					failed = append(failed, fmt.Errorf("%s: %w", metricName, err))
+					b.failures.queries.Add(1)
+					log.ErrorContext(logCtx, "Error processing metric", map[string]interface{}{
+						"error": err.Error(),
+					})
+				}
+				mu.Unlock()
+			}
+		})
+		if !started {
+			wg.Done()
+			if w == 0 {
+				close(jobs)
+				return fmt.Errorf("max_goroutines (%d) leaves no room for metric workers", b.config.Benchmark.MaxGoroutines)
+			}
+			log.Debug("Worker pool capped by max_goroutines", map[string]interface{}{
+				"workers":     w,
+				"concurrency": b.config.Benchmark.Concurrency,
+			})
+			break
+		}
+	}
+
+	// Workers block on jobs until dispatch, so no write is queued before the
+	// senders have started
+	b.writes.start(b.goroutines, b.config.Benchmark.WriteQueue.Senders)
 
+	dispatched := 0
+dispatch:
 	for _, metricName := range metrics {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
-		default:
+			break dispatch
+		case <-b.drain:
+			log.Info("Draining, not starting remaining metrics", map[string]interface{}{
+				"skipped_metrics": len(metrics) - dispatched,
+			})
+			break dispatch
+		case jobs <- metricName:
+			dispatched++
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	b.writes.close()
+
+	// Send series still buffered by the remote writer, also when the run
+	// timed out or was cancelled
+	if b.remoteWriter != nil {
+		if err := b.flushWriter(); err != nil {
+			log.Error("Error flushing buffered series", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		if abortErr == nil {
+			abortErr = b.writeProbe.err()
 		}
+	}
 
-		logger.Debug("Processing metric", map[string]interface{}{
-			"metric_name": metricName,
+	if abortErr != nil && !errors.Is(abortErr, errTargetRejectsWrites) {
+		return abortErr
+	}
+	if abortErr != nil {
+		return fmt.Errorf("%w: first %d batches were rejected with 404/405, check remote_write_url",
+			errTargetRejectsWrites, b.config.Benchmark.EarlyAbortBatches)
+	}
+	if err := parent.Err(); err != nil {
+		if b.draining() && errors.Is(err, context.Canceled) {
+			return fmt.Errorf("in-flight work did not finish within shutdown_timeout_seconds: %w", err)
+		}
+		return err
+	}
+
+	if len(failed) > 0 {
+		log.Warn("Some metrics failed to process", map[string]interface{}{
+			"failed_metrics": len(failed),
+			"total_metrics":  len(metrics),
+		})
+	}
+	if timedOut > 0 {
+		log.Warn("Some metrics were skipped after timing out", map[string]interface{}{
+			"timed_out_metrics": timedOut,
+			"total_metrics":     len(metrics),
 		})
+	}
 
-		if err := b.processMetric(ctx, metricName, startTime, endTime, step, rateLimiter); err != nil {
-			logger.Error("Error processing metric", map[string]interface{}{
-				"metric_name": metricName,
-				"error":       err.Error(),
-			})
-			continue
+	return nil
+}
+
+// flushWriter sends the series buffered by the remote writer under a fresh
+// context bounded by shutdown_timeout_seconds, so a timed-out or cancelled
+// run still delivers them. A failed flush also counts among the failed
+// batches checked against max_failure_ratio.
+func (b *Benchmarker) flushWriter() error {
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.ShutdownTimeout())
+	defer cancel()
+	return b.remoteWriter.Flush(ctx)
+}
+
+// Drain stops the run from starting new metrics while in-flight metrics and
+// queued writes finish. If they take longer than shutdown_timeout_seconds the
+// run is cancelled.
+func (b *Benchmarker) Drain() {
+	b.drainOnce.Do(func() {
+		close(b.drain)
+
+		b.runMu.Lock()
+		defer b.runMu.Unlock()
+		if b.cancelRun == nil {
+			return
 		}
+		timeout := b.config.ShutdownTimeout()
+		log.Info("Finishing in-flight work before shutdown", map[string]interface{}{
+			"shutdown_timeout_seconds": b.config.Benchmark.ShutdownTimeoutSeconds,
+		})
+		b.drainTimer = time.AfterFunc(timeout, b.cancelRun)
+	})
+}
+
+// draining reports whether Drain has been called
+func (b *Benchmarker) draining() bool {
+	select {
+	case <-b.drain:
+		return true
+	default:
+		return false
 	}
+}
 
-	return nil
+// setRunCancel registers the cancel function of the current run for Drain,
+// stopping a pending drain timer when the run ends
+func (b *Benchmarker) setRunCancel(cancel context.CancelFunc) {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+	b.cancelRun = cancel
+	if cancel == nil && b.drainTimer != nil {
+		b.drainTimer.Stop()
+		b.drainTimer = nil
+	}
 }
 
-// processMetric processes a single metric
+// metricContext derives the context for processing a single metric, bounded
+// by per_metric_timeout_seconds when set
+func (b *Benchmarker) metricContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := b.config.PerMetricTimeout(); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// processMetric processes a single metric, replicating each series as it is
+// streamed from the query response
 func (b *Benchmarker) processMetric(ctx context.Context, metricName string, startTime, endTime time.Time, step time.Duration, rateLimiter *rate.Limiter) error {
-	// Query the metric data
-	data, err := b.queryMetricRange(ctx, metricName, startTime, endTime, step)
+	defer selfmetrics.MetricsProcessed.Inc()
+	defer b.counters.forget(metricName)
+	// Logged also when the query fails, so the examples are released
+	defer b.dryRunSampler.logMetric(ctx, metricName)
+
+	pending := &metricWrites{}
+	ctx = writer.WithTracker(ctx, &pending.batches)
+	resumeAt := b.checkpoint.resumePoint(metricName)
+	if resumeAt.Series > 0 {
+		log.InfoContext(ctx, "Resuming metric from checkpoint", map[string]interface{}{
+			"metric_name":    metricName,
+			"skipped_series": resumeAt.Series,
+		})
+	}
+	seriesCount, err := b.streamMetric(ctx, metricName, startTime, endTime, step, rateLimiter, pending, resumeAt)
+	if errors.Is(err, errResumeMismatch) {
+		// Nothing was replicated while skipping, so the metric starts over
+		log.WarnContext(ctx, "Source series changed since the checkpoint, processing the metric from its first series", map[string]interface{}{
+			"metric_name":    metricName,
+			"skipped_series": resumeAt.Series,
+		})
+		if err := b.checkpoint.restart(metricName); err != nil {
+			log.WarnContext(ctx, "Failed to write checkpoint", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		seriesCount, err = b.streamMetric(ctx, metricName, startTime, endTime, step, rateLimiter, pending, seriesProgress{})
+	}
+	// Queued writes finish even when the query failed part way through
+	if writeErr := pending.wait(); writeErr != nil {
+		return writeErr
+	}
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
 		return fmt.Errorf("querying metric data: %w", err)
 	}
 
-	if len(data.Data.Result) == 0 {
-		logger.Debug("No data found for metric", map[string]interface{}{
+	if seriesCount == 0 {
+		log.DebugContext(ctx, "No data found for metric", map[string]interface{}{
 			"metric_name": metricName,
 		})
-		return nil
 	}
 
-	// Replicate data with modified labels
-	for _, series := range data.Data.Result {
-		if err := b.replicateSeries(ctx, metricName, series, rateLimiter); err != nil {
-			logger.Error("Error replicating series", map[string]interface{}{
-				"metric_name": metricName,
-				"error":       err.Error(),
-			})
-			continue
+	b.completeMetric(ctx, metricName, pending)
+	return nil
+}
+
+// streamMetric replicates the series of a metric and returns how many the
+// source streamed. The first resumeAt.Series series, written by an earlier
+// run, are skipped, and with a checkpoint the progress is recorded every
+// checkpoint_every_series series.
+func (b *Benchmarker) streamMetric(ctx context.Context, metricName string, startTime, endTime time.Time, step time.Duration, rateLimiter *rate.Limiter, pending *metricWrites, resumeAt seriesProgress) (int, error) {
+	every := b.config.Output.CheckpointEverySeries
+	advancing := b.checkpoint != nil && every > 0
+	seriesCount := 0
+	err := b.source.Series(ctx, metricName, startTime, endTime, step, func(series Series) error {
+		seriesCount++
+		fingerprint := seriesFingerprint(series.Metric)
+		if seriesCount <= resumeAt.Series {
+			if seriesCount == resumeAt.Series && fingerprint != resumeAt.Last {
+				return errResumeMismatch
+			}
+			return nil
+		}
+
+		if err := pending.failed(); err != nil {
+			return err
+		}
+		if err := b.replicateSeries(ctx, metricName, series, rateLimiter, pending); err != nil {
+			return err
+		}
+		if advancing && seriesCount%every == 0 {
+			advancing = b.advanceMetric(ctx, metricName, pending, seriesProgress{Series: seriesCount, Last: fingerprint})
 		}
+		return nil
+	})
+	if err == nil && seriesCount < resumeAt.Series {
+		return seriesCount, errResumeMismatch
 	}
+	return seriesCount, err
+}
 
-	return nil
+// seriesQuery returns the PromQL selector for a metric, restricted by the
+// configured series_selector matchers
+func (b *Benchmarker) seriesQuery(metricName string) string {
+	if len(b.seriesMatchers) == 0 {
+		return metricName
+	}
+
+	matchers := make([]string, len(b.seriesMatchers))
+	for i, m := range b.seriesMatchers {
+		matchers[i] = m.String()
+	}
+	return metricName + "{" + strings.Join(matchers, ",") + "}"
+}
+
+// streamMetricRange runs a range query over a time range and calls fn for
+// each series while the response body is still being decoded. Ranges longer
+// than query_chunk_hours are fetched in chunks and merged per series first.
+func (b *Benchmarker) streamMetricRange(ctx context.Context, query string, startTime, endTime time.Time, step time.Duration, fn func(Series) error) error {
+	if b.config.Benchmark.QueryType == config.QueryTypeInstant {
+		return b.queryInstant(ctx, query, startTime, endTime, fn)
+	}
+	windows := queryWindows(startTime, endTime, step, b.config.QueryChunk())
+	if len(windows) == 1 {
+		return b.queryRange(ctx, query, startTime, endTime, step, fn)
+	}
+
+	// Chunked results have to be merged before a series is complete, so
+	// they are buffered instead of streamed
+	merged := newSeriesMerger()
+	for _, w := range windows {
+		if err := b.queryRange(ctx, query, w.start, w.end, step, merged.add); err != nil {
+			return fmt.Errorf("querying %s to %s: %w", w.start.Format(time.RFC3339), w.end.Format(time.RFC3339), err)
+		}
+	}
+	return merged.each(fn)
 }
 
-// queryMetricRange queries a metric over a time range
-func (b *Benchmarker) queryMetricRange(ctx context.Context, metricName string, startTime, endTime time.Time, step time.Duration) (*PrometheusResponse, error) {
+// queryRangeOnce runs a single range query and streams its series to fn
+func (b *Benchmarker) queryRangeOnce(ctx context.Context, query string, startTime, endTime time.Time, step time.Duration, fn func(Series) error) error {
 	params := url.Values{}
-	params.Set("query", metricName)
-	params.Set("start", strconv.FormatInt(startTime.Unix(), 10))
-	params.Set("end", strconv.FormatInt(endTime.Unix(), 10))
-	params.Set("step", strconv.FormatInt(int64(step.Seconds()), 10))
+	params.Set("query", query)
+	params.Set("start", formatQueryTime(startTime))
+	params.Set("end", formatQueryTime(endTime))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
 
 	queryURL := fmt.Sprintf("%s/api/v1/query_range?%s", b.config.Prometheus.QueryURL, params.Encode())
 
-	req, err := http.NewRequestWithContext(ctx, "GET", queryURL, nil)
+	req, err := b.newQueryRequest(ctx, queryURL)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	release, err := b.queries.acquire(ctx)
+	if err != nil {
+		return err
 	}
+	defer release()
 
 	resp, err := b.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("making request: %w", err)
+		return &queryError{err: fmt.Errorf("making request: %w", err)}
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+	head := &headBuffer{limit: 256}
+	if err := decodeQueryResponse(io.TeeReader(resp.Body, head), fn); err != nil {
+		return &queryError{statusCode: resp.StatusCode, body: head.String(), err: err}
 	}
+	return nil
+}
 
-	var result PrometheusResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("parsing response: %w", err)
+// formatQueryTime renders t as Unix seconds with millisecond precision, so
+// chunk boundaries stay aligned to sub-second steps
+func formatQueryTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
+
+// truncateSeries keeps the most recent limit float samples and native
+// histogram samples of series, reporting whether any were cut. Query results
+// are time-ordered, so the tail is the newest data.
+func truncateSeries(series Series, limit int) (Series, bool) {
+	truncated := false
+	if len(series.Values) > limit {
+		series.Values = series.Values[len(series.Values)-limit:]
+		truncated = true
 	}
+	if len(series.Histograms) > limit {
+		series.Histograms = series.Histograms[len(series.Histograms)-limit:]
+		truncated = true
+	}
+	return series, truncated
+}
 
-	if result.Status != "success" {
-		return nil, fmt.Errorf("query failed: %s", string(body))
+// replicateSeries replicates a single time series with modified labels,
+// queueing one write per replica
+func (b *Benchmarker) replicateSeries(ctx context.Context, metricName string, series Series, rateLimiter *rate.Limiter, pending *metricWrites) error {
+	if len(series.Histograms) > 0 && !b.config.Benchmark.SupportNativeHistograms {
+		log.DebugContext(ctx, "Skipping native histogram samples, support_native_histograms is disabled", map[string]interface{}{
+			"metric_name":     metricName,
+			"histogram_count": len(series.Histograms),
+		})
 	}
 
-	return &result, nil
-}
+	if limit := b.config.Benchmark.MaxSamplesPerSeries; limit > 0 {
+		if kept, truncated := truncateSeries(series, limit); truncated {
+			log.DebugContext(ctx, "Truncated series to max_samples_per_series", map[string]interface{}{
+				"metric_name":  metricName,
+				"samples":      len(series.Values) + len(series.Histograms),
+				"samples_kept": len(kept.Values) + len(kept.Histograms),
+				"max_samples":  limit,
+			})
+			b.truncatedSeries.Add(1)
+			series = kept
+		}
+	}
 
-// replicateSeries replicates a single time series with modified labels
-func (b *Benchmarker) replicateSeries(ctx context.Context, metricName string, series struct {
-	Metric map[string]string `json:"metric"`
-	Values [][]interface{}   `json:"values"`
-}, rateLimiter *rate.Limiter) error {
+	if b.dryRunSampler != nil {
+		b.dryRunSampler.addSource(metricName)
+	}
 
-	// Generate label combinations
-	labelCombinations := b.generateLabelCombinations()
+	labelCombinations := b.labelCombinations
+	family, paired := b.summaryFamilies[metricName]
+	familyOrName := metricName
+	if paired {
+		familyOrName = family
+	}
 
 	for i, labelSet := range labelCombinations {
-		if i >= b.config.Benchmark.ReplicationFactor {
-			break
-		}
-
 		// Create new labels by combining original with replication labels
 		newLabels := make(map[string]string)
 		for k, v := range series.Metric {
 			newLabels[k] = v
 		}
+		applyLabelRules(newLabels, b.config.Benchmark.LabelRules)
+		// Static labels override the source series, replication labels
+		// override both
+		for k, v := range b.config.Benchmark.ExtraLabels {
+			newLabels[k] = v
+		}
 		for k, v := range labelSet {
 			newLabels[k] = v
 		}
+		if !relabel(newLabels, b.relabelRules) || newLabels["__name__"] == "" {
+			log.DebugContext(ctx, "Replica dropped by relabel rules", map[string]interface{}{
+				"metric_name": metricName,
+				"replica":     i,
+			})
+			continue
+		}
 
+		if !b.reserveSeries(ctx) {
+			b.cappedSeries.Add(int64(len(labelCombinations) - i))
+			return nil
+		}
+
+		// The series seed is taken before stamping the run id, which differs
+		// on every run, so seeded runs stay reproducible
+		var seriesSeed int64
+		if b.config.Benchmark.ValueJitter > 0 || b.valuePattern != nil {
+			if paired {
+				seriesSeed = labelSetSeed(b.config.Benchmark.Seed, familyLabels(newLabels, family))
+			} else {
+				seriesSeed = labelSetSeed(b.config.Benchmark.Seed, newLabels)
+			}
+		}
+		if name := b.config.Benchmark.RunLabel; name != "" {
+			newLabels[name] = b.runID
+		}
+
+		if b.dryRunSampler != nil {
+			b.dryRunSampler.addReplica(metricName, series.Metric, newLabels, len(series.Values)+len(series.Histograms))
+			continue
+		}
 		if b.dryRun {
-			logger.Info("DRY RUN: Would replicate series", map[string]interface{}{
-				"metric_name":  metricName,
-				"labels":       newLabels,
-				"sample_count": len(series.Values),
+			log.InfoContext(ctx, "DRY RUN: Would replicate series", map[string]interface{}{
+				"metric_name":     metricName,
+				"replica":         i,
+				"labels":          newLabels,
+				"label_diff":      labelDiff(series.Metric, newLabels),
+				"sample_count":    len(series.Values),
+				"histogram_count": len(series.Histograms),
 			})
 			continue
 		}
 
-		// Convert and send samples
-		if err := b.sendSamples(ctx, newLabels, series.Values, rateLimiter); err != nil {
-			return fmt.Errorf("sending samples: %w", err)
+		// The _sum and _count replicas of a summary family are the source
+		// pair scaled by one shared factor, so they stay monotonic and keep
+		// their ratio; generated or rearranged values would break both
+		values := series.Values
+		if b.valuePattern != nil && !paired {
+			values = generateValues(values, b.valuePattern(seriesSeed))
+		}
+		if mode := b.config.Benchmark.ValueArrangement; mode != "" && !paired {
+			rng := rand.New(rand.NewSource(replicaSeed(b.config.Benchmark.Seed, metricName, i)))
+			values = rearrangeValues(values, mode, i, rng)
+		}
+		if variation := b.config.Benchmark.ReplicaVariation; variation > 0 {
+			factor := replicaFactor(b.config.Benchmark.Seed, familyOrName, i, variation)
+			values = transformValues(values, func(v float64) float64 { return v * factor })
+		}
+		if jitter := b.config.Benchmark.ValueJitter; jitter > 0 {
+			rng := rand.New(rand.NewSource(seriesSeed))
+			if paired {
+				values = transformValues(values, coordinatedJitter(rng, jitter))
+			} else {
+				values = transformValues(values, jitterFunc(rng, jitter))
+			}
+		}
+		if b.counters != nil && b.valuesGenerated() && !paired && b.counters.isCounter(metricName) {
+			// Jitter and variation keep each value derived from its source
+			// sample, so real resets can be told from generated drops
+			source := series.Values
+			if b.valuePattern != nil || b.config.Benchmark.ValueArrangement != "" || b.config.Source.Type == SourceSynthetic {
+				source = nil
+			}
+			values = b.counters.enforce(metricName, newLabels, values, source)
+		}
+
+		// Queue the replica for sending
+		err := b.writes.enqueue(writeJob{
+			ctx:     ctx,
+			pending: pending,
+			send: func(ctx context.Context) error {
+				if err := b.sendSamples(ctx, newLabels, values, rateLimiter); err != nil {
+					return fmt.Errorf("sending samples: %w", err)
+				}
+				if len(series.Histograms) > 0 && b.config.Benchmark.SupportNativeHistograms {
+					if err := b.sendHistograms(ctx, newLabels, series.Histograms, rateLimiter); err != nil {
+						return fmt.Errorf("sending histograms: %w", err)
+					}
+				}
+				return nil
+			},
+		})
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// reserveSeries counts one more replica series, reporting false once
+// max_total_series is exhausted
+func (b *Benchmarker) reserveSeries(ctx context.Context) bool {
+	limit := b.config.Benchmark.MaxTotalSeries
+	if limit <= 0 {
+		return true
+	}
+	if b.replicas.Add(1) <= limit {
+		return true
+	}
+
+	b.seriesCapOnce.Do(func() {
+		log.WarnContext(ctx, "Reached max_total_series, no further series will be replicated", map[string]interface{}{
+			"max_total_series": limit,
+		})
+	})
+	return false
+}
+
+// labelDiff describes how a replica's labels differ from its source series.
+// Added labels are rendered as "+name=value", changed ones as
+// "~name=old->new" and removed ones as "-name=value", sorted by label name.
+func labelDiff(source, replica map[string]string) []string {
+	names := make([]string, 0, len(replica))
+	for name := range replica {
+		names = append(names, name)
+	}
+	for name := range source {
+		if _, ok := replica[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var diff []string
+	for _, name := range names {
+		value, kept := replica[name]
+		original, ok := source[name]
+		switch {
+		case !kept:
+			diff = append(diff, fmt.Sprintf("-%s=%s", name, original))
+		case !ok:
+			diff = append(diff, fmt.Sprintf("+%s=%s", name, value))
+		case original != value:
+			diff = append(diff, fmt.Sprintf("~%s=%s->%s", name, original, value))
+		}
+	}
+	return diff
+}
+
+// cycleLabel disambiguates replicas once the replication label combinations
+// are exhausted
+const cycleLabel = "benchmark_cycle"
+
 // generateLabelCombinations generates combinations of replication labels
 func (b *Benchmarker) generateLabelCombinations() []map[string]string {
 	if len(b.config.Replication) == 0 {
 		// Generate default combinations if no replication labels configured
-		combinations := make([]map[string]string, b.config.Benchmark.ReplicationFactor)
-		for i := 0; i < b.config.Benchmark.ReplicationFactor; i++ {
+		combinations := make([]map[string]string, b.config.ReplicationFactor())
+		for i := 0; i < b.config.ReplicationFactor(); i++ {
 			combinations[i] = map[string]string{
 				"benchmark_replica": fmt.Sprintf("replica-%d", i),
 			}
 		}
-		return combinations
+		return withSyntheticJobs(combinations, b.config.Benchmark.SyntheticJobs)
 	}
 
 	// Generate combinations from configured replication labels
@@ -323,12 +1235,12 @@ func (b *Benchmarker) generateLabelCombinations() []map[string]string {
 	for i, labelConfig := range processedLabels {
 		if labelConfig.Name == "benchmark_instance" && len(labelConfig.Values) == 0 {
 			// Auto-generate benchmark_instance values based on replication factor
-			autoValues := make([]string, b.config.Benchmark.ReplicationFactor)
-			for j := 0; j < b.config.Benchmark.ReplicationFactor; j++ {
+			autoValues := make([]string, b.config.ReplicationFactor())
+			for j := 0; j < b.config.ReplicationFactor(); j++ {
 				autoValues[j] = fmt.Sprintf("bench-%d", j+1)
 			}
 			processedLabels[i].Values = autoValues
-			logger.Debug("Auto-generated benchmark_instance values", map[string]interface{}{
+			log.Debug("Auto-generated benchmark_instance values", map[string]interface{}{
 				"count":  len(autoValues),
 				"values": autoValues,
 			})
@@ -343,17 +1255,27 @@ func (b *Benchmarker) generateLabelCombinations() []map[string]string {
 		}
 	}
 
-	// Generate combinations up to replication factor
-	maxCombinations := b.config.Benchmark.ReplicationFactor
-	if maxCombinations > totalCombinations {
-		maxCombinations = totalCombinations
+	factor := b.config.ReplicationFactor()
+	if b.config.AllCombinations() {
+		log.Info("Replicating every replication label combination", map[string]interface{}{
+			"replication_factor": factor,
+		})
+	}
+	if factor > totalCombinations {
+		log.Warn("Replication labels have fewer combinations than replication_factor, repeating them with a "+cycleLabel+" label", map[string]interface{}{
+			"combinations":       totalCombinations,
+			"replication_factor": factor,
+		})
 	}
 
-	for i := 0; i < maxCombinations; i++ {
+	// Take the first replication_factor entries of the cartesian product,
+	// decoding i in mixed radix with the first label varying fastest. Past
+	// the end of the product it starts over with a cycle counter label, so
+	// every replica is unique.
+	for i := 0; i < factor; i++ {
 		labelSet := make(map[string]string)
 
-		// Generate combination index for each label
-		combIndex := i
+		combIndex := i % totalCombinations
 		for _, labelConfig := range processedLabels {
 			if len(labelConfig.Values) > 0 {
 				valueIndex := combIndex % len(labelConfig.Values)
@@ -361,11 +1283,35 @@ func (b *Benchmarker) generateLabelCombinations() []map[string]string {
 				combIndex = combIndex / len(labelConfig.Values)
 			}
 		}
+		if cycle := i / totalCombinations; cycle > 0 {
+			labelSet[cycleLabel] = strconv.Itoa(cycle)
+		}
 
 		combinations = append(combinations, labelSet)
 	}
 
-	return combinations
+	return withSyntheticJobs(combinations, b.config.Benchmark.SyntheticJobs)
+}
+
+// withSyntheticJobs crosses every combination with the configured number of
+// synthetic job values, overriding the source series' job label
+func withSyntheticJobs(combinations []map[string]string, jobs config.SyntheticJobs) []map[string]string {
+	if jobs.Count <= 0 {
+		return combinations
+	}
+
+	crossed := make([]map[string]string, 0, len(combinations)*jobs.Count)
+	for _, labelSet := range combinations {
+		for j := 0; j < jobs.Count; j++ {
+			withJob := make(map[string]string, len(labelSet)+1)
+			for k, v := range labelSet {
+				withJob[k] = v
+			}
+			withJob["job"] = fmt.Sprintf("%s-%d", jobs.Prefix, j+1)
+			crossed = append(crossed, withJob)
+		}
+	}
+	return crossed
 }
 
 // sendSamples sends samples to Prometheus with rate limiting
@@ -374,35 +1320,68 @@ func (b *Benchmarker) sendSamples(ctx context.Context, labels map[string]string,
 		return nil
 	}
 
+	b.stats.series.Add(1)
+
+	return b.sendChunked(ctx, labels, len(values), rateLimiter, func(start, end int) error {
+		return b.remoteWriter.WriteSamples(ctx, labels, values[start:end])
+	})
+}
+
+// sendHistograms sends native histogram samples to Prometheus with rate
+// limiting, counting each histogram as one sample
+func (b *Benchmarker) sendHistograms(ctx context.Context, labels map[string]string, points []writer.HistogramPoint, rateLimiter *rate.Limiter) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	b.stats.series.Add(1)
+
+	return b.sendChunked(ctx, labels, len(points), rateLimiter, func(start, end int) error {
+		return b.remoteWriter.WriteHistograms(ctx, labels, points[start:end])
+	})
+}
+
+// sendChunked splits total samples into chunks no larger than the rate
+// limiter burst, waits for tokens and calls write for each chunk
+func (b *Benchmarker) sendChunked(ctx context.Context, labels map[string]string, total int, rateLimiter *rate.Limiter, write func(start, end int) error) error {
 	// If we have more samples than can fit in burst, send in chunks
 	burstSize := rateLimiter.Burst()
-	totalSamples := len(values)
 
-	for i := 0; i < totalSamples; i += burstSize {
+	for i := 0; i < total; i += burstSize {
 		end := i + burstSize
-		if end > totalSamples {
-			end = totalSamples
+		if end > total {
+			end = total
 		}
 
-		chunk := values[i:end]
-		chunkSize := len(chunk)
+		chunkSize := end - i
 
 		// Wait for rate limiter tokens for this chunk
 		if err := rateLimiter.WaitN(ctx, chunkSize); err != nil {
+			if _, ok := ctx.Deadline(); ok && ctx.Err() == nil {
+				// WaitN fails early when the wait would run past the deadline;
+				// block until then so the timeout is attributed to its context
+				<-ctx.Done()
+				err = ctx.Err()
+			}
 			return fmt.Errorf("rate limiting: %w", err)
 		}
 
-		logger.Debug("Sending sample chunk to Prometheus", map[string]interface{}{
+		log.DebugContext(ctx, "Sending sample chunk to Prometheus", map[string]interface{}{
 			"chunk_size":   chunkSize,
 			"chunk_num":    (i / burstSize) + 1,
-			"total_chunks": (totalSamples + burstSize - 1) / burstSize,
+			"total_chunks": (total + burstSize - 1) / burstSize,
 			"labels":       labels,
 		})
 
 		if b.remoteWriter != nil {
-			if err := b.remoteWriter.WriteSamples(ctx, labels, chunk); err != nil {
+			err := write(i, end)
+			if abortErr := b.writeProbe.err(); abortErr != nil {
+				return abortErr
+			}
+			if err != nil {
 				return fmt.Errorf("writing chunk %d: %w", (i/burstSize)+1, err)
 			}
+			b.stats.samples.Add(int64(chunkSize))
 		}
 	}
 
@@ -0,0 +1,526 @@
+package benchmarker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/config"
+	"promfire/internal/logger"
+	"promfire/internal/version"
+	"promfire/internal/writer"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// captureLogs records log entries at the given level and above until the
+// test ends
+func captureLogs(t *testing.T, level logger.LogLevel) *bytes.Buffer {
+	t.Helper()
+
+	var logs bytes.Buffer
+	logger.Init(level, "promfire")
+	logger.SetOutput(&logs)
+	t.Cleanup(func() { logger.SetOutput(io.Discard) })
+	return &logs
+}
+
+// loadTestConfig loads and validates a YAML config, writing the output
+// directory to a temporary one unless the config sets it
+func loadTestConfig(t *testing.T, text string) *config.Config {
+	t.Helper()
+
+	dir := t.TempDir()
+	if !strings.Contains(text, "\noutput:") {
+		text += fmt.Sprintf("output:\n  dir: %q\n", dir)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validating config: %v", err)
+	}
+	return cfg
+}
+
+// testConfig loads a config querying prom and writing to recv. benchmark
+// holds indented benchmark keys; extra is appended as top-level YAML.
+func testConfig(t *testing.T, prom *testutil.FakePrometheus, recv *testutil.FakeReceiver, benchmark, extra string) *config.Config {
+	t.Helper()
+
+	queryURL := "http://127.0.0.1:1"
+	if prom != nil {
+		queryURL = prom.URL
+	}
+	return loadTestConfig(t, fmt.Sprintf("prometheus:\n  query_url: %q\n  remote_write_url: %q\nbenchmark:\n%s%s",
+		queryURL, recv.WriteURL(), benchmark, extra))
+}
+
+// newTestBenchmarker builds a Benchmarker, failing the test on error
+func newTestBenchmarker(t *testing.T, cfg *config.Config, opts Options) *Benchmarker {
+	t.Helper()
+
+	b, err := NewBenchmarker(cfg, opts)
+	if err != nil {
+		t.Fatalf("creating benchmarker: %v", err)
+	}
+	return b
+}
+
+// sourceSeries returns a series of metric with one sample per minute
+// before now, valued 1..samples
+func sourceSeries(metric string, lbls map[string]string, samples int, now time.Time) testutil.Series {
+	series := testutil.Series{Labels: map[string]string{"__name__": metric}}
+	for k, v := range lbls {
+		series.Labels[k] = v
+	}
+	for i := 0; i < samples; i++ {
+		series.Samples = append(series.Samples, testutil.Sample{
+			TimestampMs: now.Add(-time.Duration(samples-i) * time.Minute).UnixMilli(),
+			Value:       float64(i + 1),
+		})
+	}
+	return series
+}
+
+// stallingSource yields its series for every metric, then blocks until the
+// context is done, like a query stuck on a slow target
+type stallingSource struct {
+	series []Series
+}
+
+func (s *stallingSource) Metrics(context.Context) ([]string, error) {
+	return []string{"stalled"}, nil
+}
+
+func (s *stallingSource) Series(ctx context.Context, _ string, _, _ time.Time, _ time.Duration, fn func(Series) error) error {
+	for _, series := range s.series {
+		if err := fn(series); err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunFlushesBufferedSeriesOnTotalTimeout(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, nil, recv, "  batch_size: 1000\n  replication_factor: 1\n  total_timeout_seconds: 1\n", "")
+	source := &stallingSource{}
+	for i := 0; i < 3; i++ {
+		source.series = append(source.series, Series{
+			Metric: map[string]string{"__name__": "stalled", "i": fmt.Sprint(i)},
+			Values: [][]interface{}{{float64(time.Now().Unix()), "1"}},
+		})
+	}
+
+	b := newTestBenchmarker(t, cfg, Options{Source: source})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := len(recv.Series()); got != 3 {
+		t.Errorf("received %d series after the timeout, want 3", got)
+	}
+	if got := recv.Requests(); got != 1 {
+		t.Errorf("requests = %d, want 1 flushed batch", got)
+	}
+}
+
+func TestGenerateLabelCombinationsWithoutLoadedConfig(t *testing.T) {
+	factor := 3
+	cfg := &config.Config{Replication: []config.ReplicationLabel{{Name: "region", Values: []string{"eu", "us", "ap"}}}}
+	cfg.Benchmark.ReplicationFactor = &factor
+
+	b := &Benchmarker{config: cfg}
+	if got := len(b.generateLabelCombinations()); got != 3 {
+		t.Errorf("got %d combinations, want 3", got)
+	}
+
+	cfg.Benchmark.ReplicationFactor = nil
+	if got := len(b.generateLabelCombinations()); got != 0 {
+		t.Errorf("got %d combinations for an unset factor, want 0", got)
+	}
+}
+
+func TestFilterMetricsIncludeAndExclude(t *testing.T) {
+	metrics := []string{"up", "node_cpu_seconds_total", "node_memory_bytes", "node_debug_info", "http_requests_total"}
+	tests := []struct {
+		name  string
+		extra string
+		want  []string
+	}{
+		{"no filters", "", metrics},
+		{"exclude only", "exclude_metrics: [\"^node_\"]\n", []string{"up", "http_requests_total"}},
+		{"include only", "include_metrics: [\"^node_\", \"^up$\"]\n", []string{"up", "node_cpu_seconds_total", "node_memory_bytes", "node_debug_info"}},
+		{
+			// A metric matching both an include and an exclude is dropped
+			"exclude wins over include",
+			"include_metrics: [\"^node_\"]\nexclude_metrics: [\"_debug_\", \"^http_\"]\n",
+			[]string{"node_cpu_seconds_total", "node_memory_bytes"},
+		},
+		{"include nothing", "include_metrics: [\"^missing$\"]\n", nil},
+	}
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, nil, recv, "  replication_factor: 1\n", tt.extra)
+			got := newTestBenchmarker(t, cfg, Options{}).filterMetrics(metrics)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("filtered metrics = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterBurstMatchesConfig(t *testing.T) {
+	tests := []struct {
+		benchmark string
+		want      int
+	}{
+		{"  samples_per_second: 500\n", 1000},
+		{"  samples_per_second: 500\n  burst_multiplier: 0.5\n", 250},
+		{"  samples_per_second: 500\n  burst_multiplier: 3\n  burst_samples: 40\n", 40},
+	}
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	for _, tt := range tests {
+		cfg := testConfig(t, nil, recv, "  replication_factor: 1\n"+tt.benchmark, "")
+		limiter := newTestBenchmarker(t, cfg, Options{}).newRateLimiter()
+		if got := limiter.Burst(); got != tt.want || got != cfg.Burst() {
+			t.Errorf("%q: limiter burst = %d, want %d", tt.benchmark, got, tt.want)
+		}
+		if got := limiter.Limit(); got != 500 {
+			t.Errorf("%q: limiter rate = %g, want samples_per_second 500", tt.benchmark, got)
+		}
+	}
+}
+
+func TestGenerateLabelCombinations2x3(t *testing.T) {
+	tests := []struct {
+		name   string
+		factor int
+		jobs   config.SyntheticJobs
+		want   []string
+	}{
+		{"every combination", 0, config.SyntheticJobs{}, []string{
+			"region=eu,zone=a", "region=us,zone=a",
+			"region=eu,zone=b", "region=us,zone=b",
+			"region=eu,zone=c", "region=us,zone=c",
+		}},
+		{"fewer than the product", 4, config.SyntheticJobs{}, []string{
+			"region=eu,zone=a", "region=us,zone=a",
+			"region=eu,zone=b", "region=us,zone=b",
+		}},
+		{"more than the product", 8, config.SyntheticJobs{}, []string{
+			"region=eu,zone=a", "region=us,zone=a",
+			"region=eu,zone=b", "region=us,zone=b",
+			"region=eu,zone=c", "region=us,zone=c",
+			"benchmark_cycle=1,region=eu,zone=a", "benchmark_cycle=1,region=us,zone=a",
+		}},
+		{"crossed with synthetic jobs", 0, config.SyntheticJobs{Count: 2, Prefix: "job"}, []string{
+			"job=job-1,region=eu,zone=a", "job=job-2,region=eu,zone=a",
+			"job=job-1,region=us,zone=a", "job=job-2,region=us,zone=a",
+			"job=job-1,region=eu,zone=b", "job=job-2,region=eu,zone=b",
+			"job=job-1,region=us,zone=b", "job=job-2,region=us,zone=b",
+			"job=job-1,region=eu,zone=c", "job=job-2,region=eu,zone=c",
+			"job=job-1,region=us,zone=c", "job=job-2,region=us,zone=c",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Replication: []config.ReplicationLabel{
+				{Name: "region", Values: []string{"eu", "us"}},
+				{Name: "zone", Values: []string{"a", "b", "c"}},
+			}}
+			factor := tt.factor
+			cfg.Benchmark.ReplicationFactor = &factor
+			cfg.Benchmark.SyntheticJobs = tt.jobs
+
+			b := &Benchmarker{config: cfg}
+			var got []string
+			for _, labelSet := range b.generateLabelCombinations() {
+				pairs := make([]string, 0, len(labelSet))
+				for k, v := range labelSet {
+					pairs = append(pairs, k+"="+v)
+				}
+				sort.Strings(pairs)
+				got = append(got, strings.Join(pairs, ","))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("combinations:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestRunWritesReplicasEndToEnd(t *testing.T) {
+	now := time.Now()
+	prom := testutil.NewFakePrometheus(
+		sourceSeries("up", map[string]string{"job": "a"}, 3, now),
+		sourceSeries("up", map[string]string{"job": "b"}, 3, now),
+		sourceSeries("requests_total", map[string]string{"job": "a"}, 2, now),
+	)
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, prom, recv, "  replication_factor: 2\n  timestamp_mode: preserve\n", "")
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// Series come sorted by their label sets
+	var got []string
+	for _, s := range recv.Series() {
+		got = append(got, fmt.Sprintf("%s{job=%s,benchmark_replica=%s} %d", s.Labels["__name__"], s.Labels["job"], s.Labels["benchmark_replica"], len(s.Samples)))
+	}
+	want := []string{
+		"requests_total{job=a,benchmark_replica=replica-0} 2",
+		"requests_total{job=a,benchmark_replica=replica-1} 2",
+		"up{job=a,benchmark_replica=replica-0} 3",
+		"up{job=b,benchmark_replica=replica-0} 3",
+		"up{job=a,benchmark_replica=replica-1} 3",
+		"up{job=b,benchmark_replica=replica-1} 3",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("received series:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Preserved samples match the source exactly
+	source := sourceSeries("up", map[string]string{"job": "b"}, 3, now)
+	samples := recv.Samples(map[string]string{"__name__": "up", "job": "b", "benchmark_replica": "replica-1"})
+	for i, s := range samples {
+		if s != source.Samples[i] {
+			t.Errorf("sample %d = %+v, want %+v", i, s, source.Samples[i])
+		}
+	}
+
+	stats := b.Stats()
+	if stats.Series != 6 || stats.Samples != 16 || stats.FailedBatches != 0 {
+		t.Errorf("stats = %d series, %d samples, %d failed batches, want 6, 16 and 0", stats.Series, stats.Samples, stats.FailedBatches)
+	}
+}
+
+func TestTruncateSeriesKeepsTail(t *testing.T) {
+	series := Series{
+		Values:     [][]interface{}{{1.0, "1"}, {2.0, "2"}, {3.0, "3"}, {4.0, "4"}},
+		Histograms: []writer.HistogramPoint{{Timestamp: 1.0}, {Timestamp: 2.0}, {Timestamp: 3.0}},
+	}
+
+	kept, truncated := truncateSeries(series, 2)
+	if !truncated {
+		t.Fatal("truncateSeries reported nothing truncated")
+	}
+	if got := fmt.Sprint(kept.Values); got != "[[3 3] [4 4]]" {
+		t.Errorf("kept values %s, want the latest two", got)
+	}
+	if len(kept.Histograms) != 2 || kept.Histograms[0].Timestamp != 2.0 || kept.Histograms[1].Timestamp != 3.0 {
+		t.Errorf("kept histograms %+v, want the latest two", kept.Histograms)
+	}
+
+	if _, truncated := truncateSeries(series, 4); truncated {
+		t.Error("truncateSeries truncated a series within the limit")
+	}
+}
+
+func TestRunMaxSamplesPerSeries(t *testing.T) {
+	now := time.Now()
+	source := sourceSeries("up", nil, 5, now)
+	prom := testutil.NewFakePrometheus(source)
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, prom, recv, "  replication_factor: 1\n  timestamp_mode: preserve\n  max_samples_per_series: 2\n", "")
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	got := recv.Samples(map[string]string{"__name__": "up", "benchmark_replica": "replica-0"})
+	if fmt.Sprint(got) != fmt.Sprint(source.Samples[3:]) {
+		t.Errorf("received samples %v, want the latest two %v", got, source.Samples[3:])
+	}
+}
+
+func TestRunCapsTotalSeriesAcrossMetrics(t *testing.T) {
+	now := time.Now()
+	var series []testutil.Series
+	for _, metric := range []string{"a", "b", "c"} {
+		for _, job := range []string{"x", "y"} {
+			series = append(series, sourceSeries(metric, map[string]string{"job": job}, 2, now))
+		}
+	}
+	prom := testutil.NewFakePrometheus(series...)
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	// 3 metrics of 2 series replicated twice would write 12 series
+	cfg := testConfig(t, prom, recv, "  replication_factor: 2\n  max_total_series: 5\n", "")
+	logs := captureLogs(t, logger.INFO)
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	metrics := map[string]bool{}
+	for _, s := range recv.Series() {
+		metrics[s.Labels["__name__"]] = true
+	}
+	if got := len(recv.Series()); got != 5 {
+		t.Errorf("received %d series, want the cap of 5", got)
+	}
+	// A single metric only has 4 replicas, so the cap spans metrics
+	if len(metrics) < 2 {
+		t.Errorf("received series of metrics %v, want the cap shared across metrics", metrics)
+	}
+	if !bytes.Contains(logs.Bytes(), []byte(`"skipped_series":7`)) {
+		t.Errorf("summary does not report 7 skipped series:\n%s", logs)
+	}
+}
+
+func TestRunLabelPrecedence(t *testing.T) {
+	prom := testutil.NewFakePrometheus(sourceSeries("up", map[string]string{
+		"job": "source", "env": "source", "region": "source",
+	}, 2, time.Now()))
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	// extra_labels override source labels, replication labels override both
+	cfg := testConfig(t, prom, recv,
+		"  replication_factor: 2\n  extra_labels:\n    env: extra\n    region: extra\n    team: extra\n",
+		"replication_labels:\n  - name: region\n    values: [r1, r2]\n")
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	var got []string
+	for _, s := range recv.Series() {
+		got = append(got, fmt.Sprintf("job=%s env=%s region=%s team=%s", s.Labels["job"], s.Labels["env"], s.Labels["region"], s.Labels["team"]))
+	}
+	want := []string{
+		"job=source env=extra region=r1 team=extra",
+		"job=source env=extra region=r2 team=extra",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("received series:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRunSendsUserAgentAndRunIDHeaders(t *testing.T) {
+	prom := testutil.NewFakePrometheus(sourceSeries("up", nil, 2, time.Now()))
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, prom, recv, "  replication_factor: 1\n", "")
+	cfg.Prometheus.RunIDHeader = true
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	for endpoint, headers := range map[string][]http.Header{"query": prom.Headers(), "remote write": recv.Headers()} {
+		if len(headers) == 0 {
+			t.Fatalf("%s endpoint received no requests", endpoint)
+		}
+		for _, h := range headers {
+			if got := h.Get("User-Agent"); got != "promfire/"+version.Version {
+				t.Errorf("%s User-Agent = %q, want promfire/%s", endpoint, got, version.Version)
+			}
+			if got := h.Get(RunIDHeader); got != b.RunID() {
+				t.Errorf("%s %s = %q, want the run id %q", endpoint, RunIDHeader, got, b.RunID())
+			}
+		}
+	}
+}
+
+func TestRunSendsCustomHeaders(t *testing.T) {
+	prom := testutil.NewFakePrometheus(sourceSeries("up", nil, 2, time.Now()))
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, prom, recv, "  replication_factor: 1\n", "")
+	cfg.Prometheus.Headers = map[string]string{"X-Scope-OrgID": "tenant-a", "X-Source": "promfire"}
+	cfg.Prometheus.QueryHeaders = map[string]string{"X-Query-Only": "yes"}
+	cfg.Prometheus.RemoteWriteHeaders = map[string]string{"X-Scope-OrgID": "tenant-b"}
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	tests := []struct {
+		endpoint string
+		headers  []http.Header
+		want     map[string]string
+	}{
+		{"query", prom.Headers(), map[string]string{"X-Scope-OrgID": "tenant-a", "X-Source": "promfire", "X-Query-Only": "yes"}},
+		{"remote write", recv.Headers(), map[string]string{"X-Scope-OrgID": "tenant-b", "X-Source": "promfire", "X-Query-Only": ""}},
+	}
+	for _, tt := range tests {
+		if len(tt.headers) == 0 {
+			t.Fatalf("%s endpoint received no requests", tt.endpoint)
+		}
+		for _, h := range tt.headers {
+			for name, want := range tt.want {
+				if got := h.Get(name); got != want {
+					t.Errorf("%s %s = %q, want %q", tt.endpoint, name, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRunLogsUnderBenchmarkerComponent(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	cfg := testConfig(t, nil, recv, "  replication_factor: 1\n  query_range: 5m\n  query_step: 1m\n",
+		"source:\n  type: synthetic\n  synthetic:\n    series_count: 2\n")
+	logs := captureLogs(t, logger.INFO)
+
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	components := map[string]string{}
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var entry logger.LogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		components[entry.Message] = entry.Component
+	}
+	for _, message := range []string{"Starting benchmark process", "Remote writer initialized"} {
+		if got := components[message]; got != "benchmarker" {
+			t.Errorf("%q logged with component %q, want benchmarker", message, got)
+		}
+	}
+}
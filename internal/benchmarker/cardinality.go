@@ -0,0 +1,100 @@
+package benchmarker
+
+import (
+	"context"
+	"time"
+
+	"promfire/internal/config"
+)
+
+// cardinalitySampleMetrics is how many metrics are counted to estimate the
+// average series per metric for cardinality_warn_threshold
+const cardinalitySampleMetrics = 5
+
+// seriesCountSource is implemented by sources that can count the series of a
+// metric more cheaply than calling Series
+type seriesCountSource interface {
+	CountSeries(ctx context.Context, metricName string, start, end time.Time) (int, error)
+}
+
+// CountSeries counts the series /api/v1/series returns for a metric instead of
+// range querying it
+func (s *prometheusSource) CountSeries(ctx context.Context, metricName string, start, end time.Time) (int, error) {
+	count := 0
+	err := s.b.querySeries(ctx, s.b.seriesQuery(metricName), start, end, func(map[string]string) {
+		count++
+	})
+	return count, err
+}
+
+// CountSeries returns the number of series discovery found for a metric
+func (s *seriesDiscoverySource) CountSeries(_ context.Context, metricName string, _, _ time.Time) (int, error) {
+	if m, ok := s.metrics[metricName]; ok {
+		return len(m.keys), nil
+	}
+	return 0, nil
+}
+
+// ProjectedActiveSeries projects the active series a run creates on the
+// target from the metric count, the average series per metric and the
+// replicas generated for every source series
+func ProjectedActiveSeries(metricCount int, avgSeriesPerMetric float64, replicas int) int64 {
+	return int64(float64(metricCount)*avgSeriesPerMetric+0.5) * int64(replicas)
+}
+
+// warnCardinality logs a warning when the projected active series of a run
+// over metrics exceed cardinality_warn_threshold. The series per metric are
+// averaged over a seeded sample of the metrics; the check is advisory and
+// never fails the run.
+func (b *Benchmarker) warnCardinality(ctx context.Context, metrics []string) {
+	threshold := b.config.Benchmark.CardinalityWarnThreshold
+	if threshold <= 0 || len(metrics) == 0 {
+		return
+	}
+
+	sample := sampleMetrics(metrics, config.SampleMetrics{Count: cardinalitySampleMetrics}, b.config.Benchmark.Seed)
+	end := time.Now()
+	start := end.Add(-b.config.QueryRange())
+	total := 0
+	for _, name := range sample {
+		count, err := b.countSeries(ctx, name, start, end)
+		if err != nil {
+			log.Warn("Could not sample series count for cardinality check", map[string]interface{}{
+				"metric": name,
+				"error":  err.Error(),
+			})
+			return
+		}
+		total += count
+	}
+
+	avg := float64(total) / float64(len(sample))
+	projected := ProjectedActiveSeries(len(metrics), avg, replicaCount(b.config))
+	fields := map[string]interface{}{
+		"projected_series":           projected,
+		"threshold":                  threshold,
+		"metrics":                    len(metrics),
+		"sampled_metrics":            len(sample),
+		"avg_series_per_metric":      avg,
+		"replicas_per_source_series": replicaCount(b.config),
+	}
+	if projected <= threshold {
+		log.Debug("Projected cardinality within threshold", fields)
+		return
+	}
+	log.Warn("Projected active series exceed cardinality_warn_threshold, the target may reject or drop series", fields)
+}
+
+// countSeries counts the series of a metric in [start, end], falling back to
+// iterating them when the source cannot count directly
+func (b *Benchmarker) countSeries(ctx context.Context, metricName string, start, end time.Time) (int, error) {
+	if counter, ok := b.source.(seriesCountSource); ok {
+		return counter.CountSeries(ctx, metricName, start, end)
+	}
+	count := 0
+	err := b.source.Series(ctx, metricName, start, end, b.config.QueryStep(), func(Series) error {
+		count++
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,68 @@
+package benchmarker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/logger"
+)
+
+func TestProjectedActiveSeries(t *testing.T) {
+	tests := []struct {
+		metrics  int
+		avg      float64
+		replicas int
+		want     int64
+	}{
+		{0, 12, 10, 0},
+		{100, 0, 10, 0},
+		{100, 12, 10, 12000},
+		{3, 2.5, 4, 32}, // 7.5 series round to 8 before replicating
+		{3, 2.4, 4, 28}, // 7.2 round to 7
+		{1000, 2500, 30, 75_000_000},
+	}
+	for _, tt := range tests {
+		if got := ProjectedActiveSeries(tt.metrics, tt.avg, tt.replicas); got != tt.want {
+			t.Errorf("ProjectedActiveSeries(%d, %g, %d) = %d, want %d", tt.metrics, tt.avg, tt.replicas, got, tt.want)
+		}
+	}
+}
+
+func TestRunWarnsAboveCardinalityThreshold(t *testing.T) {
+	now := time.Now()
+	var series []testutil.Series
+	for _, metric := range []string{"up", "requests_total"} {
+		for _, job := range []string{"a", "b"} {
+			series = append(series, sourceSeries(metric, map[string]string{"job": job}, 2, now))
+		}
+	}
+	prom := testutil.NewFakePrometheus(series...)
+	defer prom.Close()
+
+	// 2 metrics with 2 series each, replicated 3 times
+	for _, tt := range []struct {
+		threshold int
+		warned    bool
+	}{{11, true}, {12, false}} {
+		recv := testutil.NewFakeReceiver()
+		defer recv.Close()
+		cfg := testConfig(t, prom, recv, fmt.Sprintf("  replication_factor: 3\n  cardinality_warn_threshold: %d\n", tt.threshold), "")
+		logs := captureLogs(t, logger.INFO)
+
+		b := newTestBenchmarker(t, cfg, Options{})
+		if err := b.Run(context.Background()); err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		warned := bytes.Contains(logs.Bytes(), []byte("exceed cardinality_warn_threshold"))
+		if warned != tt.warned {
+			t.Errorf("threshold %d: warned = %v, want %v:\n%s", tt.threshold, warned, tt.warned, logs)
+		}
+		if warned && !bytes.Contains(logs.Bytes(), []byte(`"projected_series":12`)) {
+			t.Errorf("threshold %d: warning does not project 12 series:\n%s", tt.threshold, logs)
+		}
+	}
+}
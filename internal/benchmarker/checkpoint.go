@@ -0,0 +1,329 @@
+package benchmarker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// checkpointFile is the JSON form of a checkpoint
+type checkpointFile struct {
+	RunID     string    `json:"run_id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Metrics is the metric list of the run that wrote the checkpoint
+	Metrics []string `json:"metrics"`
+	// Completed are the metrics whose series have all been written
+	Completed []string `json:"completed"`
+	// Progress records how far unfinished metrics got
+	Progress map[string]seriesProgress `json:"progress,omitempty"`
+}
+
+// seriesProgress marks the first Series source series of a metric, in the
+// order the source streams them, as written. Last fingerprints the labels
+// of the last of them so a resumed run can tell whether the source still
+// streams the same series first.
+type seriesProgress struct {
+	Series int   `json:"series"`
+	Last   int64 `json:"last"`
+}
+
+// errResumeMismatch reports that a metric's source series changed since its
+// progress was checkpointed
+var errResumeMismatch = errors.New("source series changed since the checkpoint")
+
+// seriesFingerprint identifies a source series by its labels
+func seriesFingerprint(labels map[string]string) int64 {
+	return labelSetSeed(0, labels)
+}
+
+// checkpoint records the metrics a run has fully processed, and the
+// progress within metrics still running, rewriting its file after every
+// metric and every checkpoint_every_series series so a run that dies can be
+// resumed with -resume
+type checkpoint struct {
+	path  string
+	runID string
+
+	mu        sync.Mutex
+	metrics   []string
+	completed map[string]struct{}
+	progress  map[string]seriesProgress
+}
+
+// newCheckpoint returns a checkpoint at path for the given run, nil when
+// path is empty
+func newCheckpoint(path, runID string) *checkpoint {
+	if path == "" {
+		return nil
+	}
+	return &checkpoint{
+		path:      path,
+		runID:     runID,
+		completed: make(map[string]struct{}),
+		progress:  make(map[string]seriesProgress),
+	}
+}
+
+// resume loads the checkpoint file and returns metrics without the ones it
+// records as completed. A missing file resumes nothing. When the metric list
+// changed since the checkpoint was written, completed metrics that are still
+// listed are skipped and everything else, including new metrics, is
+// processed.
+func (c *checkpoint) resume(metrics []string) ([]string, error) {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Info("No checkpoint to resume from, processing all metrics", map[string]interface{}{
+			"path": c.path,
+		})
+		return metrics, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	var file checkpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing checkpoint %s: %w", c.path, err)
+	}
+
+	previous := make(map[string]struct{}, len(file.Metrics))
+	for _, name := range file.Metrics {
+		previous[name] = struct{}{}
+	}
+	current := make(map[string]struct{}, len(metrics))
+	added := 0
+	for _, name := range metrics {
+		current[name] = struct{}{}
+		if _, ok := previous[name]; !ok {
+			added++
+		}
+	}
+	removed := 0
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			removed++
+		}
+	}
+	if added > 0 || removed > 0 {
+		log.Warn("Metric list changed since the checkpoint, processing the new set", map[string]interface{}{
+			"path":            c.path,
+			"added_metrics":   added,
+			"removed_metrics": removed,
+		})
+	}
+
+	c.mu.Lock()
+	for _, name := range file.Completed {
+		if _, ok := current[name]; ok {
+			c.completed[name] = struct{}{}
+		}
+	}
+	for name, progress := range file.Progress {
+		if _, ok := current[name]; ok {
+			c.progress[name] = progress
+		}
+	}
+	partial := len(c.progress)
+	c.mu.Unlock()
+
+	remaining := make([]string, 0, len(metrics))
+	for _, name := range metrics {
+		if _, done := c.completed[name]; !done {
+			remaining = append(remaining, name)
+		}
+	}
+	log.Info("Resuming from checkpoint", map[string]interface{}{
+		"path":              c.path,
+		"previous_run_id":   file.RunID,
+		"completed_metrics": len(metrics) - len(remaining),
+		"remaining_metrics": len(remaining),
+		"partial_metrics":   partial,
+	})
+	return remaining, nil
+}
+
+// start records the metric list of the run and writes the initial file
+func (c *checkpoint) start(metrics []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = metrics
+	return c.write()
+}
+
+// complete marks a metric as fully processed and rewrites the file
+func (c *checkpoint) complete(metricName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.completed[metricName] = struct{}{}
+	delete(c.progress, metricName)
+	return c.write()
+}
+
+// resumePoint returns the progress of a metric recorded by an earlier run,
+// zero when there is none or without a checkpoint
+func (c *checkpoint) resumePoint(metricName string) seriesProgress {
+	if c == nil {
+		return seriesProgress{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.progress[metricName]
+}
+
+// advance records the progress within a metric and rewrites the file
+func (c *checkpoint) advance(metricName string, progress seriesProgress) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.progress[metricName] = progress
+	return c.write()
+}
+
+// restart forgets the progress within a metric that has to be processed
+// from its first series again
+func (c *checkpoint) restart(metricName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.progress[metricName]; !ok {
+		return nil
+	}
+	delete(c.progress, metricName)
+	return c.write()
+}
+
+// done reports whether every metric of the run has been completed
+func (c *checkpoint) done() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range c.metrics {
+		if _, ok := c.completed[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// remove deletes the checkpoint file once the run has finished
+func (c *checkpoint) remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// write replaces the checkpoint file with the current state. It must be
+// called with c.mu held.
+func (c *checkpoint) write() error {
+	completed := make([]string, 0, len(c.completed))
+	for name := range c.completed {
+		completed = append(completed, name)
+	}
+	sort.Strings(completed)
+
+	data, err := json.MarshalIndent(checkpointFile{
+		RunID:     c.runID,
+		UpdatedAt: time.Now().UTC(),
+		Metrics:   c.metrics,
+		Completed: completed,
+		Progress:  c.progress,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so a crash leaves either the old or the new file intact
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating checkpoint dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// completeMetric records the metric in the checkpoint once all of its
+// series are known to be written
+func (b *Benchmarker) completeMetric(ctx context.Context, metricName string, pending *metricWrites) {
+	if b.checkpoint == nil || !b.metricWritten(ctx, pending) {
+		return
+	}
+	if err := b.checkpoint.complete(metricName); err != nil {
+		log.WarnContext(ctx, "Failed to write checkpoint", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// advanceMetric waits for the writes queued so far by a metric and records
+// progress once they are known to be written. It reports false when a write
+// was lost, after which the metric's progress must not advance any more.
+func (b *Benchmarker) advanceMetric(ctx context.Context, metricName string, pending *metricWrites, progress seriesProgress) bool {
+	pending.wg.Wait()
+	if !b.metricWritten(ctx, pending) {
+		return false
+	}
+	if err := b.checkpoint.advance(metricName, progress); err != nil {
+		log.WarnContext(ctx, "Failed to write checkpoint", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return true
+}
+
+// metricWritten flushes the remote writer so the metric's buffered series
+// are sent, waits for the batches holding them, which other workers may be
+// sending, and reports whether none of its writes was lost. A metric wrongly
+// left out only costs reprocessing it on resume, so failures are logged
+// rather than returned.
+func (b *Benchmarker) metricWritten(ctx context.Context, pending *metricWrites) bool {
+	if n := pending.writeErrors(); n > 0 {
+		log.WarnContext(ctx, "Not checkpointing metric, some of its writes failed", map[string]interface{}{
+			"failed_writes": n,
+		})
+		return false
+	}
+	if b.remoteWriter != nil {
+		if err := b.remoteWriter.Flush(ctx); err != nil {
+			log.WarnContext(ctx, "Not checkpointing metric, flushing its series failed", map[string]interface{}{
+				"error": err.Error(),
+			})
+			return false
+		}
+	}
+	if n := pending.batches.Wait(); n > 0 {
+		log.WarnContext(ctx, "Not checkpointing metric, a batch holding its series failed", map[string]interface{}{
+			"failed_series": n,
+		})
+		return false
+	}
+	return true
+}
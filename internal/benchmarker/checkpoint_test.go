@@ -0,0 +1,330 @@
+package benchmarker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/config"
+)
+
+func TestCheckpointResumeWithChangedMetricList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	data, err := json.Marshal(checkpointFile{
+		RunID:     "previous",
+		Metrics:   []string{"a", "b", "c"},
+		Completed: []string{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newCheckpoint(path, "current")
+	remaining, err := c.resume([]string{"b", "c", "d"})
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	// a was removed, b is done, c is unfinished and d is new
+	if want := []string{"c", "d"}; !reflect.DeepEqual(remaining, want) {
+		t.Errorf("remaining = %v, want %v", remaining, want)
+	}
+
+	if err := c.start([]string{"b", "c", "d"}); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if c.done() {
+		t.Error("checkpoint done before c and d completed")
+	}
+	for _, name := range remaining {
+		if err := c.complete(name); err != nil {
+			t.Fatalf("complete %s: %v", name, err)
+		}
+	}
+	if !c.done() {
+		t.Error("checkpoint not done after completing every metric")
+	}
+}
+
+func TestCheckpointResumeWithoutFile(t *testing.T) {
+	c := newCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"), "run")
+	remaining, err := c.resume([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(remaining, want) {
+		t.Errorf("remaining = %v, want %v", remaining, want)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "checkpoint.json")
+
+	for _, content := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []byte(content)); err != nil {
+			t.Fatalf("writing %q: %v", content, err)
+		}
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "second" {
+		t.Errorf("file holds %q, want the last write", got)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want only the checkpoint", len(entries))
+	}
+}
+
+func TestWriteFileAtomicFailureKeepsDirectoryClean(t *testing.T) {
+	dir := t.TempDir()
+	// Renaming a file over a non-empty directory fails
+	path := filepath.Join(dir, "checkpoint.json")
+	if err := os.MkdirAll(filepath.Join(path, "child"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeFileAtomic(path, []byte("data")); err == nil {
+		t.Fatal("expected an error replacing a directory")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d entries, want the temporary file removed", len(entries))
+	}
+}
+
+func TestRunDoesNotCheckpointMetricWithFailedWrites(t *testing.T) {
+	now := time.Now()
+	prom := testutil.NewFakePrometheus(sourceSeries("up", map[string]string{"job": "a"}, 3, now))
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	recv.SetStatus(http.StatusBadRequest)
+
+	// Batches of one are sent by the replica writes, not the final flush
+	cfg := testConfig(t, prom, recv, "  batch_size: 1\n  replication_factor: 2\n", "")
+	cfg.Output.Checkpoint = true
+
+	b := newTestBenchmarker(t, cfg, Options{})
+	_ = b.Run(context.Background())
+
+	data, err := os.ReadFile(cfg.Output.CheckpointPath)
+	if err != nil {
+		t.Fatalf("reading checkpoint: %v", err)
+	}
+	var file checkpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Completed) != 0 {
+		t.Errorf("completed = %v, want no metric checkpointed after failed writes", file.Completed)
+	}
+}
+
+func TestRunDoesNotCheckpointMetricsSharingFailedBatch(t *testing.T) {
+	// The batch holding both metrics' series fails after a delay, once
+	// each metric's own writes and flush have returned
+	inFlight := make(chan struct{})
+	var once sync.Once
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(inFlight) })
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, nil, recv, "  batch_size: 2\n  replication_factor: 1\n  concurrency: 2\n",
+		fmt.Sprintf("output:\n  dir: %q\n  checkpoint: true\n", t.TempDir()))
+	cfg.Prometheus.RemoteWriteURL = srv.URL
+	b := newTestBenchmarker(t, cfg, Options{Source: &sharedBatchSource{inFlight: inFlight}})
+	_ = b.Run(context.Background())
+
+	if file := readCheckpoint(t, cfg.Output.CheckpointPath); len(file.Completed) != 0 {
+		t.Errorf("completed = %v, want neither metric checkpointed after their shared batch failed", file.Completed)
+	}
+}
+
+// sharedBatchSource streams one series for each of the metrics "a" and
+// "b". The series of "a" stays buffered until the series of "b" fills the
+// batch, so "a" only returns once that batch is being sent.
+type sharedBatchSource struct {
+	inFlight chan struct{}
+}
+
+func (s *sharedBatchSource) Metrics(context.Context) ([]string, error) {
+	return []string{"a", "b"}, nil
+}
+
+func (s *sharedBatchSource) Series(ctx context.Context, metric string, _, _ time.Time, _ time.Duration, fn func(Series) error) error {
+	series := Series{
+		Metric: map[string]string{"__name__": metric},
+		Values: [][]interface{}{{float64(time.Now().Unix()), "1"}},
+	}
+	if err := fn(series); err != nil {
+		return err
+	}
+	if metric == "a" {
+		select {
+		case <-s.inFlight:
+		case <-time.After(5 * time.Second):
+			return errors.New("shared batch never sent")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// cutOffSource streams its series but fails after the first cutOff of
+// them when cutOff is positive, like a run dying part way through a metric
+type cutOffSource struct {
+	series []Series
+	cutOff int
+}
+
+func (s *cutOffSource) Metrics(context.Context) ([]string, error) {
+	return []string{"up"}, nil
+}
+
+func (s *cutOffSource) Series(_ context.Context, _ string, _, _ time.Time, _ time.Duration, fn func(Series) error) error {
+	for i, series := range s.series {
+		if s.cutOff > 0 && i == s.cutOff {
+			return errors.New("connection reset")
+		}
+		if err := fn(series); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// progressSeries returns n single-sample series of the metric "up"
+func progressSeries(n int) []Series {
+	series := make([]Series, n)
+	for i := range series {
+		series[i] = Series{
+			Metric: map[string]string{"__name__": "up", "instance": fmt.Sprintf("host-%d", i)},
+			Values: [][]interface{}{{float64(time.Now().Unix()), "1"}},
+		}
+	}
+	return series
+}
+
+// readCheckpoint decodes the checkpoint file at path
+func readCheckpoint(t *testing.T, path string) checkpointFile {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading checkpoint: %v", err)
+	}
+	var file checkpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// progressConfig checkpoints every 2 series, sending every replica in a
+// request of its own
+func progressConfig(t *testing.T, recv *testutil.FakeReceiver, dir string) *config.Config {
+	t.Helper()
+
+	return testConfig(t, nil, recv, "  batch_size: 1\n  replication_factor: 1\n",
+		fmt.Sprintf("output:\n  dir: %q\n  checkpoint: true\n  checkpoint_every_series: 2\n", dir))
+}
+
+func TestRunResumesWithinMetric(t *testing.T) {
+	dir := t.TempDir()
+	series := progressSeries(6)
+
+	// The first run dies after 5 series, 4 of them checkpointed
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	cfg := progressConfig(t, recv, dir)
+	b := newTestBenchmarker(t, cfg, Options{Source: &cutOffSource{series: series, cutOff: 5}})
+	_ = b.Run(context.Background())
+
+	file := readCheckpoint(t, cfg.Output.CheckpointPath)
+	want := seriesProgress{Series: 4, Last: seriesFingerprint(series[3].Metric)}
+	if len(file.Completed) != 0 || file.Progress["up"] != want {
+		t.Fatalf("checkpoint completed %v with progress %+v, want no metric and %+v", file.Completed, file.Progress, want)
+	}
+
+	// The resumed run only writes the series after the checkpoint
+	resumed := testutil.NewFakeReceiver()
+	defer resumed.Close()
+	cfg = progressConfig(t, resumed, dir)
+	b = newTestBenchmarker(t, cfg, Options{Source: &cutOffSource{series: series}, Resume: true})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+
+	var got []string
+	for _, s := range resumed.Series() {
+		got = append(got, s.Labels["instance"])
+	}
+	if want := []string{"host-4", "host-5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed run wrote %v, want %v", got, want)
+	}
+	if _, err := os.Stat(cfg.Output.CheckpointPath); !os.IsNotExist(err) {
+		t.Errorf("checkpoint still exists after the resumed run completed: %v", err)
+	}
+}
+
+func TestRunRestartsMetricWhenSourceChanged(t *testing.T) {
+	dir := t.TempDir()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	cfg := progressConfig(t, recv, dir)
+
+	// The checkpointed 4th series is not the one the source streams now
+	if err := writeFileAtomic(cfg.Output.CheckpointPath, mustJSON(t, checkpointFile{
+		Metrics:  []string{"up"},
+		Progress: map[string]seriesProgress{"up": {Series: 4, Last: seriesFingerprint(map[string]string{"__name__": "up", "instance": "gone"})}},
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	b := newTestBenchmarker(t, cfg, Options{Source: &cutOffSource{series: progressSeries(6)}, Resume: true})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := len(recv.Series()); got != 6 {
+		t.Errorf("wrote %d series, want all 6 after the source changed", got)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,69 @@
+package benchmarker
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// queryWindow is one sub-range of a chunked range query
+type queryWindow struct {
+	start, end time.Time
+}
+
+// queryWindows splits [start, end] into consecutive windows spanning at most
+// chunk. Window lengths are a multiple of step and each window starts one
+// step after the previous one ends, so every evaluation timestamp of the
+// unchunked query is queried exactly once. A chunk of 0 disables splitting.
+func queryWindows(start, end time.Time, step, chunk time.Duration) []queryWindow {
+	span := chunk / step * step
+	if chunk <= 0 || span <= 0 || end.Sub(start) <= span {
+		return []queryWindow{{start: start, end: end}}
+	}
+
+	var windows []queryWindow
+	for from := start; !from.After(end); from = from.Add(span + step) {
+		to := from.Add(span)
+		if to.After(end) {
+			to = end
+		}
+		windows = append(windows, queryWindow{start: from, end: to})
+	}
+	return windows
+}
+
+// seriesMerger joins the series returned by several chunk queries. Series
+// with the same label set are concatenated in chunk order; a series missing
+// from some chunks simply has fewer samples.
+type seriesMerger struct {
+	index  map[string]int
+	series []Series
+}
+
+func newSeriesMerger() *seriesMerger {
+	return &seriesMerger{index: make(map[string]int)}
+}
+
+// add merges one series of a chunk
+func (m *seriesMerger) add(s Series) error {
+	key := labels.FromMap(s.Metric).String()
+	i, ok := m.index[key]
+	if !ok {
+		m.index[key] = len(m.series)
+		m.series = append(m.series, s)
+		return nil
+	}
+	m.series[i].Values = append(m.series[i].Values, s.Values...)
+	m.series[i].Histograms = append(m.series[i].Histograms, s.Histograms...)
+	return nil
+}
+
+// each calls fn for every merged series in the order they were first seen
+func (m *seriesMerger) each(fn func(Series) error) error {
+	for _, s := range m.series {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package benchmarker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueryWindows(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	tests := []struct {
+		name        string
+		end         time.Duration
+		step, chunk time.Duration
+		want        []queryWindow
+	}{
+		{
+			name: "splits exactly", end: 41 * time.Minute, step: time.Minute, chunk: 20 * time.Minute,
+			want: []queryWindow{{at(0), at(20 * time.Minute)}, {at(21 * time.Minute), at(41 * time.Minute)}},
+		},
+		{
+			name: "shorter last window", end: 50 * time.Minute, step: time.Minute, chunk: 20 * time.Minute,
+			want: []queryWindow{
+				{at(0), at(20 * time.Minute)},
+				{at(21 * time.Minute), at(41 * time.Minute)},
+				{at(42 * time.Minute), at(50 * time.Minute)},
+			},
+		},
+		{
+			name: "chunk rounded down to steps", end: 5 * time.Minute, step: time.Minute, chunk: 150 * time.Second,
+			want: []queryWindow{{at(0), at(2 * time.Minute)}, {at(3 * time.Minute), at(5 * time.Minute)}},
+		},
+		{
+			name: "chunk smaller than step", end: time.Hour, step: time.Minute, chunk: 30 * time.Second,
+			want: []queryWindow{{at(0), at(time.Hour)}},
+		},
+		{
+			name: "range within one chunk", end: 10 * time.Minute, step: time.Minute, chunk: 20 * time.Minute,
+			want: []queryWindow{{at(0), at(10 * time.Minute)}},
+		},
+		{
+			name: "chunking disabled", end: time.Hour, step: time.Minute, chunk: 0,
+			want: []queryWindow{{at(0), at(time.Hour)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := queryWindows(start, at(tt.end), tt.step, tt.chunk)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("queryWindows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryWindowsEvaluateEachTimestampOnce(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		end, step, chunk time.Duration
+	}{
+		{time.Hour, time.Minute, 7 * time.Minute},
+		{time.Hour, 15 * time.Second, 10 * time.Minute},
+		{59 * time.Minute, time.Minute, 20 * time.Minute},
+		{6 * time.Hour, 5 * time.Minute, time.Hour},
+	}
+	for _, tt := range tests {
+		end := start.Add(tt.end)
+		seen := make(map[time.Time]int)
+		for _, w := range queryWindows(start, end, tt.step, tt.chunk) {
+			for ts := w.start; !ts.After(w.end); ts = ts.Add(tt.step) {
+				seen[ts]++
+			}
+		}
+
+		want := 0
+		for ts := start; !ts.After(end); ts = ts.Add(tt.step) {
+			want++
+			if seen[ts] != 1 {
+				t.Errorf("range %v step %v chunk %v: timestamp %v evaluated %d times, want once", tt.end, tt.step, tt.chunk, ts.Sub(start), seen[ts])
+			}
+		}
+		if len(seen) != want {
+			t.Errorf("range %v step %v chunk %v: evaluated %d timestamps, want %d", tt.end, tt.step, tt.chunk, len(seen), want)
+		}
+	}
+}
+
+func TestSeriesMergerJoinsChunks(t *testing.T) {
+	a := map[string]string{"__name__": "up", "job": "a"}
+	b := map[string]string{"__name__": "up", "job": "b"}
+	sample := func(ts float64) []interface{} { return []interface{}{ts, "1"} }
+
+	// a is missing from the middle chunk, b only appears in the last one
+	chunks := [][]Series{
+		{{Metric: a, Values: [][]interface{}{sample(1), sample(2)}}},
+		{},
+		{
+			{Metric: b, Values: [][]interface{}{sample(5), sample(6)}},
+			{Metric: a, Values: [][]interface{}{sample(5), sample(6)}},
+		},
+	}
+	m := newSeriesMerger()
+	for _, chunk := range chunks {
+		for _, s := range chunk {
+			if err := m.add(s); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var got []Series
+	if err := m.each(func(s Series) error {
+		got = append(got, s)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []Series{
+		{Metric: a, Values: [][]interface{}{sample(1), sample(2), sample(5), sample(6)}},
+		{Metric: b, Values: [][]interface{}{sample(5), sample(6)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged series = %v, want %v", got, want)
+	}
+}
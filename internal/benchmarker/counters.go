@@ -0,0 +1,163 @@
+package benchmarker
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"promfire/internal/writer"
+)
+
+// counterTracker keeps replicated counters non-decreasing when their values
+// are generated, jittered or rearranged, which would otherwise read as
+// counter resets and corrupt rate() on the backend. It remembers the last
+// value written per replica series of the metrics in progress, so source
+// series that map onto the same replica, e.g. after label drops, continue
+// from each other instead of dropping back.
+type counterTracker struct {
+	mu       sync.Mutex
+	metadata map[string]writer.MetricMetadata
+	// last holds the last value per replica series key, by metric name
+	last   map[string]map[string]float64
+	raised atomic.Int64
+}
+
+func newCounterTracker() *counterTracker {
+	return &counterTracker{last: make(map[string]map[string]float64)}
+}
+
+// forget drops the state of a metric once all its series are replicated
+func (c *counterTracker) forget(metricName string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.last, metricName)
+}
+
+// setMetadata sets the fetched metric metadata used to detect counters
+func (c *counterTracker) setMetadata(metadata map[string]writer.MetricMetadata) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metadata = metadata
+}
+
+// isCounter reports whether a metric is a counter by its metadata type or,
+// without metadata, by the _total suffix. The _count, _sum and _bucket
+// series of classic histograms and summaries count as counters too.
+func (c *counterTracker) isCounter(metricName string) bool {
+	c.mu.Lock()
+	metadata := c.metadata
+	c.mu.Unlock()
+
+	if m, ok := metadata[metricName]; ok {
+		return m.Type == "counter"
+	}
+	for _, suffix := range []string{"_count", "_sum", "_bucket"} {
+		base, ok := strings.CutSuffix(metricName, suffix)
+		if !ok {
+			continue
+		}
+		if m, ok := metadata[base]; ok {
+			return m.Type == "histogram" || m.Type == "summary"
+		}
+	}
+	return strings.HasSuffix(metricName, "_total")
+}
+
+// enforce returns a copy of a series' values raised where needed to never
+// fall below the previous value. A write starting below the last value
+// written for the series is offset to continue from it, keeping its
+// increases intact. source holds the values the series was derived from
+// sample by sample, nil when they were generated or rearranged; where the
+// source value drops, the counter really reset and the drop is kept.
+func (c *counterTracker) enforce(metricName string, seriesLabels map[string]string, values, source [][]interface{}) [][]interface{} {
+	key := labels.FromMap(seriesLabels).String()
+
+	c.mu.Lock()
+	last, seen := c.last[metricName][key]
+	c.mu.Unlock()
+
+	var offset, prevSource float64
+	var raised int64
+	started, sourceSeen := false, false
+	out := make([][]interface{}, len(values))
+	for i, value := range values {
+		out[i] = value
+		v, ok := sampleFloat(value)
+		if !ok {
+			continue
+		}
+
+		if len(source) == len(values) {
+			if s, ok := sampleFloat(source[i]); ok {
+				if sourceSeen && s < prevSource {
+					// A real reset starts the counter over
+					offset, seen = 0, false
+				}
+				prevSource, sourceSeen = s, true
+			}
+		}
+
+		if !started && seen && v < last {
+			offset = last - v
+		}
+		started = true
+		v += offset
+		if seen && v < last {
+			v = last
+			raised++
+		}
+		out[i] = []interface{}{value[0], formatFloat(v)}
+		last, seen = v, true
+	}
+
+	if seen {
+		c.mu.Lock()
+		if c.last[metricName] == nil {
+			c.last[metricName] = make(map[string]float64)
+		}
+		c.last[metricName][key] = last
+		c.mu.Unlock()
+	}
+	c.raised.Add(raised)
+	return out
+}
+
+// sampleFloat parses the value of a [timestamp, value] pair
+func sampleFloat(value []interface{}) (float64, bool) {
+	if len(value) != 2 {
+		return 0, false
+	}
+	s, ok := value[1].(string)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+// valuesGenerated reports whether replica values are generated or reordered
+// rather than copied from the source, so counters may decrease. Replica
+// variation scales a whole series and keeps it monotonic.
+func (b *Benchmarker) valuesGenerated() bool {
+	return b.valuePattern != nil ||
+		b.config.Benchmark.ValueJitter > 0 ||
+		b.config.Benchmark.ValueArrangement != "" ||
+		b.config.Source.Type == SourceSynthetic
+}
+
+// report logs how many counter samples were raised to stay monotonic
+func (c *counterTracker) report() {
+	if c == nil {
+		return
+	}
+	if raised := c.raised.Load(); raised > 0 {
+		log.Info("Counter samples were raised to keep replicas monotonic", map[string]interface{}{
+			"raised_samples": raised,
+		})
+	}
+}
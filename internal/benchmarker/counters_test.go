@@ -0,0 +1,152 @@
+package benchmarker
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/writer"
+)
+
+// counterValues returns samples one second apart valued vs
+func counterValues(vs ...float64) [][]interface{} {
+	values := make([][]interface{}, len(vs))
+	for i, v := range vs {
+		values[i] = []interface{}{float64(1700000000 + i), strconv.FormatFloat(v, 'g', -1, 64)}
+	}
+	return values
+}
+
+// floats returns the parsed sample values
+func floats(t *testing.T, values [][]interface{}) []float64 {
+	t.Helper()
+	out := make([]float64, len(values))
+	for i, value := range values {
+		v, ok := sampleFloat(value)
+		if !ok {
+			t.Fatalf("sample %d = %v is not a float", i, value)
+		}
+		out[i] = v
+	}
+	return out
+}
+
+func equalFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCounterDetection(t *testing.T) {
+	c := newCounterTracker()
+	c.setMetadata(map[string]writer.MetricMetadata{
+		"requests":        {Type: "counter"},
+		"queue_total":     {Type: "gauge"},
+		"request_seconds": {Type: "histogram"},
+		"temperature":     {Type: "gauge"},
+	})
+	for name, want := range map[string]bool{
+		"requests":               true,
+		"queue_total":            false,
+		"request_seconds_bucket": true,
+		"request_seconds_count":  true,
+		"temperature":            false,
+		"errors_total":           true,
+		"memory_bytes":           false,
+	} {
+		if got := c.isCounter(name); got != want {
+			t.Errorf("isCounter(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCounterEnforce(t *testing.T) {
+	lbls := map[string]string{"__name__": "requests_total"}
+	tests := []struct {
+		name           string
+		values, source []float64
+		want           []float64
+	}{
+		{"jittered drop is raised", []float64{10, 12, 11, 15}, []float64{10, 11, 12, 13}, []float64{10, 12, 12, 15}},
+		{"real reset is kept", []float64{10, 12, 1.1, 3}, []float64{10, 11, 1, 3}, []float64{10, 12, 1.1, 3}},
+		{"generated drop is raised", []float64{5, 3, 8}, nil, []float64{5, 5, 8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var source [][]interface{}
+			if tt.source != nil {
+				source = counterValues(tt.source...)
+			}
+			got := floats(t, newCounterTracker().enforce("requests_total", lbls, counterValues(tt.values...), source))
+			if !equalFloats(got, tt.want) {
+				t.Errorf("enforce = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterEnforceContinuesAndForgets(t *testing.T) {
+	c := newCounterTracker()
+	lbls := map[string]string{"__name__": "requests_total", "replica": "0"}
+	c.enforce("requests_total", lbls, counterValues(1, 5, 9), nil)
+
+	// A second source series written to the same replica continues from it
+	got := floats(t, c.enforce("requests_total", lbls, counterValues(2, 4), nil))
+	if want := []float64{9, 11}; !equalFloats(got, want) {
+		t.Errorf("second write = %v, want it offset to %v", got, want)
+	}
+
+	c.forget("requests_total")
+	if len(c.last) != 0 {
+		t.Fatalf("state of %d metrics kept after forget, want none", len(c.last))
+	}
+	got = floats(t, c.enforce("requests_total", lbls, counterValues(2, 4), nil))
+	if want := []float64{2, 4}; !equalFloats(got, want) {
+		t.Errorf("write after forget = %v, want %v unchanged", got, want)
+	}
+}
+
+func TestRunKeepsCountersMonotonicAndGaugesJittered(t *testing.T) {
+	now := time.Now()
+	prom := testutil.NewFakePrometheus(
+		sourceSeries("requests_total", nil, 30, now),
+		sourceSeries("temperature", nil, 30, now),
+	)
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, prom, recv,
+		"  replication_factor: 1\n  timestamp_mode: preserve\n  value_jitter: 0.5\n  enforce_counter_monotonicity: true\n", "")
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	decreases := map[string]int{}
+	for _, s := range recv.Series() {
+		name := s.Labels["__name__"]
+		for i := 1; i < len(s.Samples); i++ {
+			if s.Samples[i].Value < s.Samples[i-1].Value {
+				decreases[name]++
+			}
+		}
+	}
+	if decreases["requests_total"] != 0 {
+		t.Errorf("counter decreased %d times, want a monotonic replica", decreases["requests_total"])
+	}
+	if decreases["temperature"] == 0 {
+		t.Error("gauge never decreased, want its jitter kept")
+	}
+	if len(b.counters.last) != 0 {
+		t.Errorf("counter state of %d metrics kept after the run, want none", len(b.counters.last))
+	}
+}
@@ -0,0 +1,226 @@
+package benchmarker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"promfire/internal/config"
+)
+
+// seriesDiscoverySource replicates only the series that /api/v1/series
+// returns for the discovery_matchers selectors. Each metric is then range
+// queried once per selector that matched it, restricted to the selector's
+// matchers, and results outside the discovered set are skipped.
+type seriesDiscoverySource struct {
+	*prometheusSource
+	selectors [][]*labels.Matcher
+	metrics   map[string]*discoveredMetric
+}
+
+// discoveredMetric holds the series keys found for a metric and the
+// selectors that found them
+type discoveredMetric struct {
+	keys      map[string]struct{}
+	selectors []int
+}
+
+func newSeriesDiscoverySource(cfg *config.Config, b *Benchmarker) (*seriesDiscoverySource, error) {
+	selectors, err := cfg.DiscoverySelectors()
+	if err != nil {
+		return nil, err
+	}
+	return &seriesDiscoverySource{
+		prometheusSource: &prometheusSource{b: b},
+		selectors:        selectors,
+	}, nil
+}
+
+// Metrics runs series discovery and returns the names of the metrics found.
+// Every selector is queried once per query_chunk_hours window of the query
+// range, so each response only covers part of a long range, and series
+// found by several selectors or windows are counted once.
+func (s *seriesDiscoverySource) Metrics(ctx context.Context) ([]string, error) {
+	end := time.Now()
+	start := end.Add(-s.b.config.QueryRange())
+	windows := queryWindows(start, end, s.b.config.QueryStep(), s.b.config.QueryChunk())
+
+	s.metrics = make(map[string]*discoveredMetric)
+	total, duplicates := 0, 0
+	for i, selector := range s.selectors {
+		for _, w := range windows {
+			err := s.b.querySeries(ctx, selectorString(selector), w.start, w.end, func(labelSet map[string]string) {
+				name := labelSet[labels.MetricName]
+				if name == "" {
+					return
+				}
+				m, ok := s.metrics[name]
+				if !ok {
+					m = &discoveredMetric{keys: make(map[string]struct{})}
+					s.metrics[name] = m
+				}
+				if len(m.selectors) == 0 || m.selectors[len(m.selectors)-1] != i {
+					m.selectors = append(m.selectors, i)
+				}
+				key := labels.FromMap(labelSet).String()
+				if _, seen := m.keys[key]; seen {
+					duplicates++
+					return
+				}
+				m.keys[key] = struct{}{}
+				total++
+			})
+			if err != nil {
+				return nil, fmt.Errorf("discovering series for %s: %w", selectorString(selector), err)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(s.metrics))
+	for name := range s.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	log.Info("Series discovery completed", map[string]interface{}{
+		"selectors":         len(s.selectors),
+		"series":            total,
+		"duplicate_results": duplicates,
+		"metrics":           len(names),
+	})
+	return names, nil
+}
+
+func (s *seriesDiscoverySource) Series(ctx context.Context, metricName string, start, end time.Time, step time.Duration, fn func(Series) error) error {
+	m, ok := s.metrics[metricName]
+	if !ok {
+		return nil
+	}
+
+	emitted := make(map[string]struct{}, len(m.keys))
+	for _, i := range m.selectors {
+		query := s.seriesQuery(metricName, s.selectors[i])
+		err := s.b.streamMetricRange(ctx, query, start, end, step, func(series Series) error {
+			key := labels.FromMap(series.Metric).String()
+			if _, ok := m.keys[key]; !ok {
+				return nil
+			}
+			if _, ok := emitted[key]; ok {
+				return nil
+			}
+			emitted[key] = struct{}{}
+			return fn(series)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seriesQuery builds the range query for metricName under a discovery
+// selector, also applying series_selector
+func (s *seriesDiscoverySource) seriesQuery(metricName string, selector []*labels.Matcher) string {
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName)}
+	for _, m := range selector {
+		if m.Name != labels.MetricName {
+			matchers = append(matchers, m)
+		}
+	}
+	return selectorString(append(matchers, s.b.seriesMatchers...))
+}
+
+// selectorString renders matchers as a PromQL series selector
+func selectorString(matchers []*labels.Matcher) string {
+	parts := make([]string, len(matchers))
+	for i, m := range matchers {
+		parts[i] = m.String()
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// querySeries calls fn for every label set /api/v1/series returns for a
+// selector in [start, end], decoding the response as it is read
+func (b *Benchmarker) querySeries(ctx context.Context, selector string, start, end time.Time, fn func(map[string]string)) error {
+	params := url.Values{}
+	params.Set("match[]", selector)
+	params.Set("start", formatQueryTime(start))
+	params.Set("end", formatQueryTime(end))
+
+	req, err := b.newQueryRequest(ctx, fmt.Sprintf("%s/api/v1/series?%s", b.config.Prometheus.QueryURL, params.Encode()))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	release, err := b.queries.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return decodeSeriesResponse(resp.Body, fn)
+}
+
+// decodeSeriesResponse stream-decodes a /api/v1/series response, calling fn
+// for each label set in data
+func decodeSeriesResponse(r io.Reader, fn func(map[string]string)) error {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	var status, errorType, errorMsg string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("reading response key: %w", err)
+		}
+
+		switch key {
+		case "status":
+			err = dec.Decode(&status)
+		case "errorType":
+			err = dec.Decode(&errorType)
+		case "error":
+			err = dec.Decode(&errorMsg)
+		case "data":
+			var ok bool
+			if ok, err = expectDelimOrNull(dec, '['); err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			for dec.More() {
+				var labelSet map[string]string
+				if err := dec.Decode(&labelSet); err != nil {
+					return fmt.Errorf("decoding series: %w", err)
+				}
+				fn(labelSet)
+			}
+			_, err = dec.Token()
+		default:
+			err = skipValue(dec)
+		}
+		if err != nil {
+			return fmt.Errorf("decoding %v: %w", key, err)
+		}
+	}
+
+	if status != "success" {
+		return fmt.Errorf("series query failed: status=%q errorType=%q error=%q", status, errorType, errorMsg)
+	}
+	return nil
+}
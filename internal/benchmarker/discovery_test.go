@@ -0,0 +1,27 @@
+package benchmarker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeSeriesResponse(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body string
+		want int
+	}{
+		"series": {`{"status":"success","data":[{"__name__":"up","job":"a"},{"__name__":"up","job":"b"}]}`, 2},
+		"null":   {`{"status":"success","data":null}`, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got int
+			err := decodeSeriesResponse(strings.NewReader(tc.body), func(map[string]string) { got++ })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %d label sets, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package benchmarker
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// dryRunSampler collects a fixed number of example replicas per metric
+// instead of logging every one in dry-run mode. The examples kept are the
+// label sets with the lowest hashes, so the selection is the same whatever
+// order series arrive in.
+type dryRunSampler struct {
+	size     int
+	runLabel string
+
+	mu      sync.Mutex
+	metrics map[string]*dryRunMetric
+	totals  dryRunCounts
+}
+
+// dryRunCounts aggregates what a dry run would have written
+type dryRunCounts struct {
+	SourceSeries  int64 `json:"source_series"`
+	ReplicaSeries int64 `json:"replica_series"`
+	Samples       int64 `json:"samples"`
+}
+
+// dryRunMetric holds the counts and examples of a single metric
+type dryRunMetric struct {
+	dryRunCounts
+	examples []dryRunExample
+}
+
+// dryRunExample is one sampled replica
+type dryRunExample struct {
+	hash      uint64
+	Labels    map[string]string `json:"labels"`
+	LabelDiff []string          `json:"label_diff"`
+}
+
+// newDryRunSampler returns nil when size is 0, leaving every replica logged
+func newDryRunSampler(size int, runLabel string) *dryRunSampler {
+	if size <= 0 {
+		return nil
+	}
+	return &dryRunSampler{
+		size:     size,
+		runLabel: runLabel,
+		metrics:  make(map[string]*dryRunMetric),
+	}
+}
+
+// addSource counts a source series of metricName
+func (s *dryRunSampler) addSource(metricName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metric(metricName).SourceSeries++
+	s.totals.SourceSeries++
+}
+
+// addReplica counts a replica and keeps it if it is among the examples
+func (s *dryRunSampler) addReplica(metricName string, source, replica map[string]string, samples int) {
+	hashed := replica
+	if _, ok := replica[s.runLabel]; ok && s.runLabel != "" {
+		// The run id differs on every run and would change the selection
+		hashed = make(map[string]string, len(replica))
+		for k, v := range replica {
+			if k != s.runLabel {
+				hashed[k] = v
+			}
+		}
+	}
+	hash := uint64(labelSetSeed(0, hashed))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.metric(metricName)
+	m.ReplicaSeries++
+	m.Samples += int64(samples)
+	s.totals.ReplicaSeries++
+	s.totals.Samples += int64(samples)
+
+	if len(m.examples) == s.size && hash >= m.examples[len(m.examples)-1].hash {
+		return
+	}
+	at := sort.Search(len(m.examples), func(i int) bool { return m.examples[i].hash > hash })
+	m.examples = append(m.examples, dryRunExample{})
+	copy(m.examples[at+1:], m.examples[at:])
+	m.examples[at] = dryRunExample{hash: hash, Labels: replica, LabelDiff: labelDiff(source, replica)}
+	if len(m.examples) > s.size {
+		m.examples = m.examples[:s.size]
+	}
+}
+
+// metric returns the entry of metricName, creating it; s.mu must be held
+func (s *dryRunSampler) metric(metricName string) *dryRunMetric {
+	m, ok := s.metrics[metricName]
+	if !ok {
+		m = &dryRunMetric{}
+		s.metrics[metricName] = m
+	}
+	return m
+}
+
+// logMetric logs the examples and counts of a finished metric and releases them
+func (s *dryRunSampler) logMetric(ctx context.Context, metricName string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	m, ok := s.metrics[metricName]
+	delete(s.metrics, metricName)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	log.InfoContext(ctx, "DRY RUN: Sample of replicated series", map[string]interface{}{
+		"metric_name":    metricName,
+		"source_series":  m.SourceSeries,
+		"replica_series": m.ReplicaSeries,
+		"samples":        m.Samples,
+		"examples":       m.examples,
+	})
+}
+
+// logSummary logs the totals across all metrics
+func (s *dryRunSampler) logSummary() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	totals := s.totals
+	s.mu.Unlock()
+
+	log.Info("DRY RUN: Summary", map[string]interface{}{
+		"source_series":  totals.SourceSeries,
+		"replica_series": totals.ReplicaSeries,
+		"samples":        totals.Samples,
+	})
+}
@@ -0,0 +1,32 @@
+package benchmarker
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/logger"
+)
+
+func TestDryRunSampleReleasesFailedMetric(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	cfg := testConfig(t, nil, recv, "  replication_factor: 2\n", "")
+	logs := captureLogs(t, logger.INFO)
+
+	// The query of the only metric fails after 3 series
+	b := newTestBenchmarker(t, cfg, Options{
+		DryRun:       true,
+		DryRunSample: 2,
+		Source:       &cutOffSource{series: progressSeries(6), cutOff: 3},
+	})
+	_ = b.Run(context.Background())
+
+	if n := len(b.dryRunSampler.metrics); n != 0 {
+		t.Errorf("dry run sampler still holds %d metrics, want the failed one released", n)
+	}
+	if !bytes.Contains(logs.Bytes(), []byte(`"source_series":3`)) {
+		t.Errorf("failed metric was not logged with its 3 streamed series:\n%s", logs)
+	}
+}
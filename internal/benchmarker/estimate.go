@@ -0,0 +1,76 @@
+package benchmarker
+
+import (
+	"context"
+	"fmt"
+
+	"promfire/internal/config"
+)
+
+// estimatedBytesPerSample is the approximate compressed wire size of one
+// sample in a remote write request. Protobuf encodes a sample in about 18
+// bytes and coordinated timestamps and repeated values compress around 4x.
+const estimatedBytesPerSample = 4
+
+// Estimate is the projected volume of a run
+type Estimate struct {
+	Metrics         int64 `json:"metrics"`
+	Replicas        int64 `json:"replicas"`
+	Series          int64 `json:"series"`
+	PointsPerSeries int64 `json:"points_per_series"`
+	Samples         int64 `json:"samples"`
+	Bytes           int64 `json:"bytes"`
+}
+
+// EstimateVolume projects the series, samples and wire bytes a run over
+// metricCount metrics would produce. The series count per metric is only
+// known after querying, so the estimate assumes one series per metric.
+func EstimateVolume(cfg *config.Config, metricCount int) Estimate {
+	points := pointsPerSeries(cfg.QueryRange(), cfg.SampleStep())
+	replicas := int64(replicaCount(cfg))
+	series := int64(metricCount) * replicas
+	samples := series * points
+
+	return Estimate{
+		Metrics:         int64(metricCount),
+		Replicas:        replicas,
+		Series:          series,
+		PointsPerSeries: points,
+		Samples:         samples,
+		Bytes:           samples * estimatedBytesPerSample,
+	}
+}
+
+// replicaCount returns how many replicas generateLabelCombinations produces
+// for every source series
+func replicaCount(cfg *config.Config) int {
+	jobs := 1
+	if cfg.Benchmark.SyntheticJobs.Count > 0 {
+		jobs = cfg.Benchmark.SyntheticJobs.Count
+	}
+	return cfg.ReplicationFactor() * jobs
+}
+
+// Estimate discovers, filters and samples metrics, then logs the projected volume of
+// a run without querying any ranges or writing anything
+func (b *Benchmarker) Estimate(ctx context.Context) (Estimate, error) {
+	metrics, err := b.source.Metrics(ctx)
+	if err != nil {
+		return Estimate{}, fmt.Errorf("discovering metrics: %w", err)
+	}
+
+	filtered := sampleMetrics(b.filterMetrics(metrics), b.config.Benchmark.SampleMetrics, b.config.Benchmark.Seed)
+	estimate := EstimateVolume(b.config, len(filtered))
+
+	log.Info("Estimated run volume", map[string]interface{}{
+		"metrics":           estimate.Metrics,
+		"excluded_metrics":  len(metrics) - len(filtered),
+		"replicas":          estimate.Replicas,
+		"series":            estimate.Series,
+		"points_per_series": estimate.PointsPerSeries,
+		"samples":           estimate.Samples,
+		"estimated_bytes":   estimate.Bytes,
+	})
+	b.warnCardinality(ctx, filtered)
+	return estimate, nil
+}
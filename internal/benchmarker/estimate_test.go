@@ -0,0 +1,72 @@
+package benchmarker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+)
+
+func TestEstimateVolume(t *testing.T) {
+	const combined = "  replication_factor: 0\n  synthetic_jobs:\n    count: 3\n" +
+		"replication_labels:\n  - name: region\n    values: [eu, us]\n  - name: zone\n    values: [a, b]\n"
+	tests := []struct {
+		name     string
+		yaml     string
+		metrics  int
+		replicas int
+		want     Estimate
+	}{
+		{
+			name:     "replication labels combined with synthetic jobs",
+			yaml:     "benchmark:\n  query_range: 10m\n  query_step: 1m\n" + combined,
+			metrics:  2,
+			replicas: 12,
+			want:     Estimate{Metrics: 2, Replicas: 12, Series: 24, PointsPerSeries: 11, Samples: 264, Bytes: 264 * estimatedBytesPerSample},
+		},
+		{
+			name:     "range and step",
+			yaml:     "benchmark:\n  query_range: 1h\n  query_step: 15s\n  replication_factor: 2\n",
+			metrics:  5,
+			replicas: 2,
+			want:     Estimate{Metrics: 5, Replicas: 2, Series: 10, PointsPerSeries: 241, Samples: 2410, Bytes: 2410 * estimatedBytesPerSample},
+		},
+		{
+			name:     "no metrics",
+			yaml:     "benchmark:\n  query_range: 1h\n  query_step: 15s\n  replication_factor: 2\n",
+			metrics:  0,
+			replicas: 2,
+			want:     Estimate{Replicas: 2, PointsPerSeries: 241},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadTestConfig(t, tt.yaml)
+			if got := replicaCount(cfg); got != tt.replicas {
+				t.Errorf("replicaCount = %d, want %d", got, tt.replicas)
+			}
+			if got := EstimateVolume(cfg, tt.metrics); got != tt.want {
+				t.Errorf("EstimateVolume = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicaCountMatchesRun(t *testing.T) {
+	now := time.Now()
+	prom := testutil.NewFakePrometheus(sourceSeries("up", map[string]string{"job": "a"}, 3, now))
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, prom, recv, "  replication_factor: 0\n  synthetic_jobs:\n    count: 3\n",
+		"replication_labels:\n  - name: region\n    values: [eu, us]\n  - name: zone\n    values: [a, b]\n")
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got, want := len(recv.Series()), replicaCount(cfg); got != want {
+		t.Errorf("run wrote %d replicas of the series, replicaCount = %d", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package benchmarker
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// errFailureRatioExceeded is returned by Run when more operations failed than
+// max_failure_ratio allows
+var errFailureRatioExceeded = errors.New("failure ratio exceeds max_failure_ratio")
+
+// failureSummary counts the metric and replica errors of a run by category.
+// Write errors are the remote writer's failed batches and guard drops its
+// series dropped whole by the future or age guard.
+type failureSummary struct {
+	queries  atomic.Int64 // metrics whose query failed
+	timeouts atomic.Int64 // metrics skipped after per_metric_timeout_seconds
+	parses   atomic.Int64 // replicas skipped without a valid sample
+}
+
+// failureRatio returns failures per operation, 0 without operations
+func failureRatio(failures, operations int64) float64 {
+	if operations <= 0 {
+		return 0
+	}
+	return float64(failures) / float64(operations)
+}
+
+// exceedsFailureRatio reports whether failures out of operations are more
+// than limit allows. A limit of 0 fails on any failure.
+func exceedsFailureRatio(failures, operations int64, limit float64) bool {
+	return failures > 0 && failureRatio(failures, operations) > limit
+}
+
+// checkFailures logs the error breakdown of a run over metrics and returns
+// an error wrapping errFailureRatioExceeded when max_failure_ratio is set
+// and exceeded
+func (b *Benchmarker) checkFailures(metrics int64) error {
+	s := &b.failures
+	var batches, failedBatches, guarded int64
+	if b.remoteWriter != nil {
+		batches = b.remoteWriter.Batches()
+		failedBatches = b.remoteWriter.FailedBatches()
+		guarded = b.remoteWriter.GuardedSeries()
+	}
+
+	// Operations are metric queries, batches and replicas that never made
+	// it into a batch; each failure counts once. Guard drops follow the
+	// configured policy and are reported without counting as failures.
+	failures := s.queries.Load() + s.timeouts.Load() + s.parses.Load() + failedBatches
+	operations := metrics + batches + s.parses.Load()
+	ratio := failureRatio(failures, operations)
+
+	fields := map[string]interface{}{
+		"query_errors":   s.queries.Load(),
+		"query_timeouts": s.timeouts.Load(),
+		"write_errors":   failedBatches,
+		"parse_errors":   s.parses.Load(),
+		"guard_drops":    guarded,
+		"operations":     operations,
+		"failure_ratio":  ratio,
+	}
+	if failures == 0 {
+		log.Debug("Run error summary", fields)
+	} else {
+		log.Warn("Run error summary", fields)
+	}
+
+	limit := b.config.Benchmark.MaxFailureRatio
+	if limit == nil || !exceedsFailureRatio(failures, operations, *limit) {
+		return nil
+	}
+	return fmt.Errorf("%w: %d of %d operations failed (ratio %.3f, max %g)", errFailureRatioExceeded, failures, operations, ratio, *limit)
+}
@@ -0,0 +1,205 @@
+package benchmarker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/config"
+)
+
+func TestExceedsFailureRatioBoundary(t *testing.T) {
+	tests := []struct {
+		name                 string
+		failures, operations int64
+		limit                float64
+		want                 bool
+	}{
+		{"no operations", 0, 0, 0, false},
+		{"zero limit without failures", 0, 10, 0, false},
+		{"zero limit with one failure", 1, 10, 0, true},
+		{"at the limit", 1, 10, 0.1, false},
+		{"just above the limit", 2, 19, 0.1, true},
+		{"just below the limit", 9, 91, 0.1, false},
+		{"every operation failed with limit 1", 5, 5, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exceedsFailureRatio(tt.failures, tt.operations, tt.limit); got != tt.want {
+				t.Errorf("exceedsFailureRatio(%d, %d, %g) = %v, want %v", tt.failures, tt.operations, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFailures(t *testing.T) {
+	limit := 0.25
+	b := &Benchmarker{config: &config.Config{}}
+	b.config.Benchmark.MaxFailureRatio = &limit
+
+	// 1 of 4 metrics failed: exactly at the limit
+	b.failures.queries.Add(1)
+	if err := b.checkFailures(4); err != nil {
+		t.Errorf("at the limit: %v", err)
+	}
+
+	b.failures.timeouts.Add(1)
+	if err := b.checkFailures(4); !errors.Is(err, errFailureRatioExceeded) {
+		t.Errorf("above the limit: got %v, want errFailureRatioExceeded", err)
+	}
+
+	b.config.Benchmark.MaxFailureRatio = nil
+	if err := b.checkFailures(4); err != nil {
+		t.Errorf("without max_failure_ratio: %v", err)
+	}
+}
+
+func TestRunOnceChecksFailureRatio(t *testing.T) {
+	prom := testutil.NewFakePrometheus(sourceSeries("up", nil, 3, time.Now()))
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	recv.SetStatus(http.StatusBadRequest)
+
+	cfg := testConfig(t, prom, recv, "  batch_size: 1\n  replication_factor: 2\n  max_failure_ratio: 0.5\n", "")
+	b := newTestBenchmarker(t, cfg, Options{})
+
+	_, err := b.RunOnce(context.Background(), "up")
+	if !errors.Is(err, errFailureRatioExceeded) {
+		t.Errorf("got %v, want errFailureRatioExceeded after every write failed", err)
+	}
+}
+
+// staticSource serves fixed series for every metric
+type staticSource struct {
+	series []Series
+}
+
+func (s *staticSource) Metrics(context.Context) ([]string, error) {
+	return []string{"up"}, nil
+}
+
+func (s *staticSource) Series(_ context.Context, _ string, _, _ time.Time, _ time.Duration, fn func(Series) error) error {
+	for _, series := range s.series {
+		if err := fn(series); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestGuardDropsAreNotParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+		guard  string
+	}{
+		{"future", time.Hour, "  future_samples:\n    policy: drop\n"},
+		{"old", -time.Hour, "  old_samples:\n    policy: drop\n    max_sample_age: 1m\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recv := testutil.NewFakeReceiver()
+			defer recv.Close()
+
+			ts := float64(time.Now().Add(tt.offset).Unix())
+			source := &staticSource{series: []Series{{
+				Metric: map[string]string{"__name__": "up"},
+				Values: [][]interface{}{{ts, "1"}, {ts + 60, "2"}},
+			}}}
+			cfg := testConfig(t, nil, recv, "  replication_factor: 2\n  timestamp_mode: preserve\n  max_failure_ratio: 0\n"+tt.guard, "")
+			b := newTestBenchmarker(t, cfg, Options{Source: source})
+
+			if _, err := b.RunOnce(context.Background(), "up"); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if got := b.failures.parses.Load(); got != 0 {
+				t.Errorf("parse errors = %d, want guard drops kept out of them", got)
+			}
+			if got := b.remoteWriter.GuardedSeries(); got != 2 {
+				t.Errorf("guarded series = %d, want 2", got)
+			}
+		})
+	}
+}
+
+// injectedFailureRun runs n single-sample series through a receiver
+// failing the requests selected by pattern, one request per series
+func injectedFailureRun(t *testing.T, n int, pattern testutil.FailurePattern, benchmark string) (*Benchmarker, *testutil.FakeReceiver) {
+	t.Helper()
+
+	now := time.Now()
+	var series []testutil.Series
+	for i := 0; i < n; i++ {
+		series = append(series, sourceSeries("up", map[string]string{"job": fmt.Sprint(i)}, 1, now))
+	}
+	prom := testutil.NewFakePrometheus(series...)
+	t.Cleanup(prom.Close)
+	recv := testutil.NewFakeReceiver()
+	t.Cleanup(recv.Close)
+	recv.SetFailures(pattern)
+
+	cfg := testConfig(t, prom, recv, "  replication_factor: 1\n  batch_size: 1\n"+benchmark, "")
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	return b, recv
+}
+
+func TestRunRetriesInjectedFailures(t *testing.T) {
+	b, recv := injectedFailureRun(t, 3,
+		testutil.FailurePattern{FirstK: 2, Statuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}},
+		"  retry:\n    max_retries: 3\n    initial_backoff_ms: 1\n")
+
+	if got := len(recv.Series()); got != 3 {
+		t.Errorf("received %d series, want all 3 after retries", got)
+	}
+	if recv.Requests() != 5 || recv.Failed() != 2 {
+		t.Errorf("requests = %d with %d failed, want 5 with 2 failed", recv.Requests(), recv.Failed())
+	}
+	if stats := b.Stats(); stats.FailedBatches != 0 {
+		t.Errorf("failed batches = %d, want 0 once retries succeeded", stats.FailedBatches)
+	}
+	if codes := b.remoteWriter.Stats().StatusCodes; codes[http.StatusTooManyRequests] != 1 || codes[http.StatusServiceUnavailable] != 1 {
+		t.Errorf("status codes = %v, want one 429 and one 503", codes)
+	}
+}
+
+func TestRunCountsInjectedFailures(t *testing.T) {
+	b, recv := injectedFailureRun(t, 4, testutil.FailurePattern{EveryN: 2, Statuses: []int{http.StatusInternalServerError}}, "")
+
+	if got := len(recv.Series()); got != 2 {
+		t.Errorf("received %d series, want the 2 of accepted requests", got)
+	}
+	if stats := b.Stats(); stats.FailedBatches != 2 {
+		t.Errorf("failed batches = %d, want every 2nd of 4", stats.FailedBatches)
+	}
+}
+
+func TestRunOpensBreakerOnInjectedFailures(t *testing.T) {
+	b, recv := injectedFailureRun(t, 5, testutil.FailurePattern{FirstK: 100},
+		"  circuit_breaker:\n    failure_threshold: 2\n    cooldown_seconds: 60\n")
+
+	if got := recv.Requests(); got != 2 {
+		t.Errorf("requests = %d, want 2 before the breaker opened", got)
+	}
+	if stats := b.Stats(); stats.FailedBatches != 5 {
+		t.Errorf("failed batches = %d, want all 5 including those rejected by the breaker", stats.FailedBatches)
+	}
+}
+
+func TestRunFailsSeededRandomRequests(t *testing.T) {
+	var failed []int
+	for i := 0; i < 2; i++ {
+		_, recv := injectedFailureRun(t, 20, testutil.FailurePattern{Percent: 50, Seed: 7}, "")
+		failed = append(failed, recv.Failed())
+	}
+	if failed[0] == 0 || failed[0] == 20 || failed[0] != failed[1] {
+		t.Errorf("failed requests = %v, want the same partial count for the same seed", failed)
+	}
+}
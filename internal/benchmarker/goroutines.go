@@ -0,0 +1,72 @@
+package benchmarker
+
+import (
+	"runtime"
+	"time"
+)
+
+// goroutineLimiter caps the number of goroutines the benchmarker spawns
+// through a shared semaphore; a nil semaphore means unlimited
+type goroutineLimiter struct {
+	slots chan struct{}
+}
+
+func newGoroutineLimiter(max int) *goroutineLimiter {
+	if max <= 0 {
+		return &goroutineLimiter{}
+	}
+	return &goroutineLimiter{slots: make(chan struct{}, max)}
+}
+
+// tryGo starts fn in a new goroutine if a slot is free and reports whether it did
+func (l *goroutineLimiter) tryGo(fn func()) bool {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			return false
+		}
+	}
+
+	go func() {
+		if l.slots != nil {
+			defer func() { <-l.slots }()
+		}
+		fn()
+	}()
+	return true
+}
+
+// goroutineSettleTimeout bounds how long checkGoroutineLeaks waits for
+// goroutines that are still in the middle of exiting
+const goroutineSettleTimeout = 2 * time.Second
+
+// checkGoroutineLeaks waits briefly for the goroutine count to return to
+// baseline and warns with the excess if it does not
+func (b *Benchmarker) checkGoroutineLeaks(baseline int) {
+	// Idle keep-alive connections each hold transport goroutines
+	b.client.CloseIdleConnections()
+	if b.remoteWriter != nil {
+		b.remoteWriter.CloseIdleConnections()
+	}
+
+	deadline := time.Now().Add(goroutineSettleTimeout)
+	current := runtime.NumGoroutine()
+	for current > baseline && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		current = runtime.NumGoroutine()
+	}
+
+	if current > baseline {
+		buf := make([]byte, 64<<10)
+		n := runtime.Stack(buf, true)
+		log.Warn("Goroutines leaked after run", map[string]interface{}{
+			"baseline": baseline,
+			"current":  current,
+			"leaked":   current - baseline,
+		})
+		log.Debug("Goroutine dump", map[string]interface{}{
+			"stacks": string(buf[:n]),
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package benchmarker
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/logger"
+)
+
+func TestGoroutineLimiterRefusesWhenFull(t *testing.T) {
+	limiter := newGoroutineLimiter(2)
+	hold := make(chan struct{})
+	done := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		if !limiter.tryGo(func() { <-hold; done <- struct{}{} }) {
+			t.Fatalf("tryGo %d refused with a free slot", i)
+		}
+	}
+
+	ran := false
+	if limiter.tryGo(func() { ran = true }) {
+		t.Fatal("tryGo started a goroutine with every slot taken")
+	}
+
+	// A slot frees up once one of the goroutines returns
+	close(hold)
+	<-done
+	<-done
+	started := make(chan struct{})
+	deadline := time.Now().Add(time.Second)
+	for !limiter.tryGo(func() { close(started) }) {
+		if time.Now().After(deadline) {
+			t.Fatal("tryGo still refused after the goroutines returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	<-started
+	if ran {
+		t.Error("refused fn ran")
+	}
+}
+
+func TestGoroutineLimiterUnlimited(t *testing.T) {
+	limiter := newGoroutineLimiter(0)
+	hold := make(chan struct{})
+	defer close(hold)
+	for i := 0; i < 100; i++ {
+		if !limiter.tryGo(func() { <-hold }) {
+			t.Fatalf("unlimited tryGo refused goroutine %d", i)
+		}
+	}
+}
+
+func TestRunReturnsToGoroutineBaseline(t *testing.T) {
+	now := time.Now()
+	prom := testutil.NewFakePrometheus(
+		sourceSeries("up", map[string]string{"job": "a"}, 5, now),
+		sourceSeries("up", map[string]string{"job": "b"}, 5, now),
+		sourceSeries("requests_total", map[string]string{"job": "a"}, 5, now),
+	)
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	logs := captureLogs(t, logger.WARN)
+
+	cfg := testConfig(t, prom, recv,
+		"  batch_size: 1\n  replication_factor: 2\n  concurrency: 2\n  max_goroutines: 8\n  check_goroutine_leaks: true\n", "")
+	b := newTestBenchmarker(t, cfg, Options{})
+
+	baseline := runtime.NumGoroutine()
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if got := len(recv.Series()); got != 6 {
+		t.Errorf("received %d series, want 6", got)
+	}
+
+	// Server goroutines of closed connections may still be exiting
+	deadline := time.Now().Add(goroutineSettleTimeout)
+	current := runtime.NumGoroutine()
+	for current > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		current = runtime.NumGoroutine()
+	}
+	if current > baseline {
+		t.Errorf("goroutines = %d after the run, want the baseline of %d", current, baseline)
+	}
+	if strings.Contains(logs.String(), "Goroutines leaked") {
+		t.Errorf("run warned about leaked goroutines:\n%s", logs)
+	}
+}
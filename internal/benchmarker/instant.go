@@ -0,0 +1,109 @@
+package benchmarker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"promfire/internal/writer"
+)
+
+// queryInstant runs an instant query at endTime and streams every series
+// with its single sample repeated, or walked, samples_per_second times per
+// second from startTime to endTime, so an instantaneous snapshot is
+// replicated like a dense range
+func (b *Benchmarker) queryInstant(ctx context.Context, query string, startTime, endTime time.Time, fn func(Series) error) error {
+	step := b.config.SampleStep()
+	walkStep := b.config.Benchmark.InstantWalkStep
+	return b.retryQuery(ctx, query, func(fn func(Series) error) error {
+		return b.queryInstantOnce(ctx, query, endTime, fn)
+	}, func(series Series) error {
+		var walk ValueGenerator
+		if start, err := strconv.ParseFloat(sampleValue(series.Value), 64); err == nil && walkStep > 0 {
+			walk = randomWalkPattern(start, walkStep)(labelSetSeed(b.config.Benchmark.Seed, series.Metric))
+		}
+		return fn(expandInstant(series, startTime, endTime, step, walk))
+	})
+}
+
+// sampleValue returns the value of a [timestamp, value] pair, empty if it
+// has none
+func sampleValue(sample []interface{}) string {
+	if len(sample) != 2 {
+		return ""
+	}
+	v, _ := sample[1].(string)
+	return v
+}
+
+// queryInstantOnce runs a single instant query and streams its series to fn
+func (b *Benchmarker) queryInstantOnce(ctx context.Context, query string, at time.Time, fn func(Series) error) error {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", formatQueryTime(at))
+
+	queryURL := fmt.Sprintf("%s/api/v1/query?%s", b.config.Prometheus.QueryURL, params.Encode())
+
+	req, err := b.newQueryRequest(ctx, queryURL)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	release, err := b.queries.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return &queryError{err: fmt.Errorf("making request: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	head := &headBuffer{limit: 256}
+	if err := decodeQueryResponse(io.TeeReader(resp.Body, head), fn); err != nil {
+		return &queryError{statusCode: resp.StatusCode, body: head.String(), err: err}
+	}
+	return nil
+}
+
+// expandInstant turns the single sample of an instant query result into
+// one point per step from startTime to endTime, all carrying its value, or
+// the values of walk when set. Series that already hold a range are
+// returned unchanged.
+func expandInstant(series Series, startTime, endTime time.Time, step time.Duration, walk ValueGenerator) Series {
+	if len(series.Value) != 2 && series.Histogram == nil {
+		return series
+	}
+
+	points := int(pointsPerSeries(endTime.Sub(startTime), step))
+	at := func(i int) time.Time {
+		return endTime.Add(-time.Duration(points-1-i) * step)
+	}
+	timestamp := func(i int) float64 {
+		return float64(at(i).UnixMilli()) / 1000
+	}
+
+	expanded := Series{Metric: series.Metric}
+	if len(series.Value) == 2 {
+		expanded.Values = make([][]interface{}, points)
+		for i := range expanded.Values {
+			value := series.Value[1]
+			if walk != nil {
+				value = formatFloat(walk.Next(at(i)))
+			}
+			expanded.Values[i] = []interface{}{timestamp(i), value}
+		}
+	}
+	if series.Histogram != nil {
+		expanded.Histograms = make([]writer.HistogramPoint, points)
+		for i := range expanded.Histograms {
+			expanded.Histograms[i] = writer.HistogramPoint{Timestamp: timestamp(i), Histogram: series.Histogram.Histogram}
+		}
+	}
+	return expanded
+}
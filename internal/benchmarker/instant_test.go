@@ -0,0 +1,86 @@
+package benchmarker
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+)
+
+func TestExpandInstantRepeatsAtStep(t *testing.T) {
+	end := time.UnixMilli(1700000000000)
+	series := Series{Metric: map[string]string{"__name__": "up"}, Value: []interface{}{1700000000.0, "7"}}
+
+	expanded := expandInstant(series, end.Add(-time.Second), end, 100*time.Millisecond, nil)
+	if len(expanded.Values) != 11 {
+		t.Fatalf("got %d samples, want 11 over one second at 100ms", len(expanded.Values))
+	}
+	for i, v := range expanded.Values {
+		want := float64(end.Add(-time.Duration(10-i)*100*time.Millisecond).UnixMilli()) / 1000
+		if v[0] != want || v[1] != "7" {
+			t.Errorf("sample %d = %v, want [%v 7]", i, v, want)
+		}
+	}
+}
+
+func TestExpandInstantWalksValue(t *testing.T) {
+	end := time.UnixMilli(1700000000000)
+	series := Series{Metric: map[string]string{"__name__": "temperature"}, Value: []interface{}{1700000000.0, "20"}}
+
+	walk := randomWalkPattern(20, 0.5)(1)
+	expanded := expandInstant(series, end.Add(-time.Second), end, 10*time.Millisecond, walk)
+	if len(expanded.Values) != 101 {
+		t.Fatalf("got %d samples, want 101", len(expanded.Values))
+	}
+	prev, distinct := 20.0, 0
+	for i, v := range expanded.Values {
+		value, err := strconv.ParseFloat(v[1].(string), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && value != 20 {
+			t.Errorf("walk starts at %g, want the instant value 20", value)
+		}
+		if d := value - prev; d > 0.5 || d < -0.5 {
+			t.Errorf("sample %d moved by %g, want at most the step 0.5", i, d)
+		}
+		if value != prev {
+			distinct++
+		}
+		prev = value
+	}
+	if distinct < 90 {
+		t.Errorf("only %d of 100 steps changed the value, want a walk", distinct)
+	}
+}
+
+func TestRunInstantQueryAtSamplesPerSecond(t *testing.T) {
+	now := time.Now()
+	prom := testutil.NewFakePrometheus(sourceSeries("up", map[string]string{"job": "a"}, 3, now))
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, prom, recv,
+		"  replication_factor: 1\n  query_type: instant\n  query_range: 2s\n  query_step: 1s\n  samples_per_second: 100\n  burst_samples: 1000\n  timestamp_mode: preserve\n", "")
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	series := recv.Series()
+	if len(series) != 1 {
+		t.Fatalf("received %d series, want 1", len(series))
+	}
+	samples := series[0].Samples
+	if len(samples) != 201 {
+		t.Fatalf("received %d samples, want 201 over 2s at 100 per second", len(samples))
+	}
+	for i := 1; i < len(samples); i++ {
+		if gap := samples[i].TimestampMs - samples[i-1].TimestampMs; gap != 10 {
+			t.Fatalf("samples %d and %d are %dms apart, want 10ms", i-1, i, gap)
+		}
+	}
+}
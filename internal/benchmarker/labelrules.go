@@ -0,0 +1,25 @@
+package benchmarker
+
+import "promfire/internal/config"
+
+// applyLabelRules drops and then renames labels of a replica in place.
+// Renames are applied simultaneously, so swapping two labels works.
+func applyLabelRules(labels map[string]string, rules config.LabelRules) {
+	for _, name := range rules.Drop {
+		delete(labels, name)
+	}
+	if len(rules.Rename) == 0 {
+		return
+	}
+
+	renamed := make(map[string]string, len(rules.Rename))
+	for from, to := range rules.Rename {
+		if value, ok := labels[from]; ok {
+			renamed[to] = value
+			delete(labels, from)
+		}
+	}
+	for name, value := range renamed {
+		labels[name] = value
+	}
+}
@@ -0,0 +1,57 @@
+package benchmarker
+
+import (
+	"reflect"
+	"testing"
+
+	"promfire/internal/config"
+)
+
+func TestApplyLabelRulesOrdering(t *testing.T) {
+	source := map[string]string{"__name__": "up", "instance": "host:9100", "pod": "p-1", "node": "n-1"}
+	tests := []struct {
+		name  string
+		rules config.LabelRules
+		want  map[string]string
+	}{
+		{
+			"drop then rename",
+			config.LabelRules{Drop: []string{"pod"}, Rename: map[string]string{"instance": "target"}},
+			map[string]string{"__name__": "up", "target": "host:9100", "node": "n-1"},
+		},
+		{
+			// Drop runs first, so a rename of a dropped label finds nothing
+			"rename of a dropped label",
+			config.LabelRules{Drop: []string{"pod"}, Rename: map[string]string{"pod": "workload"}},
+			map[string]string{"__name__": "up", "instance": "host:9100", "node": "n-1"},
+		},
+		{
+			// and a label dropped to make room can be replaced by a rename
+			"rename onto a dropped label",
+			config.LabelRules{Drop: []string{"node"}, Rename: map[string]string{"pod": "node"}},
+			map[string]string{"__name__": "up", "instance": "host:9100", "node": "p-1"},
+		},
+		{
+			"rename replaces an existing label",
+			config.LabelRules{Rename: map[string]string{"pod": "node"}},
+			map[string]string{"__name__": "up", "instance": "host:9100", "node": "p-1"},
+		},
+		{
+			"simultaneous renames swap labels",
+			config.LabelRules{Rename: map[string]string{"pod": "node", "node": "pod"}},
+			map[string]string{"__name__": "up", "instance": "host:9100", "pod": "n-1", "node": "p-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := make(map[string]string, len(source))
+			for k, v := range source {
+				labels[k] = v
+			}
+			applyLabelRules(labels, tt.rules)
+			if !reflect.DeepEqual(labels, tt.want) {
+				t.Errorf("labels = %v, want %v", labels, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package benchmarker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"promfire/internal/writer"
+)
+
+// loadMetadata fetches metric metadata when it is sent with the writes or
+// needed to detect counters for enforce_counter_monotonicity. Generated
+// HELP and UNIT text of the given metrics is only sent, so counter detection
+// keeps relying on the source types.
+func (b *Benchmarker) loadMetadata(ctx context.Context, metrics []string) {
+	send := b.config.Benchmark.IncludeMetadata && b.remoteWriter != nil
+	if !send && b.counters == nil {
+		return
+	}
+
+	metadata := b.fetchMetadata(ctx)
+	if send {
+		sent := metadata
+		if generate := b.config.Benchmark.GenerateMetadata; generate.Enabled {
+			sent = generateMetadata(generate, metrics, metadata)
+		}
+		b.remoteWriter.SetMetadata(sent)
+	}
+	if b.counters != nil {
+		b.counters.setMetadata(metadata)
+	}
+}
+
+// fetchMetadata fetches the metadata of all metrics from the series source.
+// Failures, or a source without metadata, yield an empty map, so every
+// metric is sent with UNKNOWN type.
+func (b *Benchmarker) fetchMetadata(ctx context.Context) map[string]writer.MetricMetadata {
+	src, ok := b.source.(metadataSource)
+	if !ok {
+		if !b.config.Benchmark.GenerateMetadata.Enabled {
+			log.Warn("Series source has no metric metadata, sending UNKNOWN types")
+		}
+		return map[string]writer.MetricMetadata{}
+	}
+
+	metadata, err := src.Metadata(ctx)
+	if err != nil {
+		log.Warn("Failed to fetch metric metadata, sending UNKNOWN types", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return map[string]writer.MetricMetadata{}
+	}
+
+	log.Info("Metric metadata fetched", map[string]interface{}{
+		"metrics": len(metadata),
+	})
+	return metadata
+}
+
+func (b *Benchmarker) queryMetadata(ctx context.Context) (map[string]writer.MetricMetadata, error) {
+	queryURL := fmt.Sprintf("%s/api/v1/metadata", b.config.Prometheus.QueryURL)
+
+	req, err := b.newQueryRequest(ctx, queryURL)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	release, err := b.queries.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	var result struct {
+		Status string                             `json:"status"`
+		Data   map[string][]writer.MetricMetadata `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parsing response: %w", err)
+	}
+
+	if result.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s", string(body))
+	}
+
+	// A metric can have several entries when targets disagree; use the first
+	metadata := make(map[string]writer.MetricMetadata, len(result.Data))
+	for name, entries := range result.Data {
+		if len(entries) > 0 {
+			metadata[name] = entries[0]
+		}
+	}
+	return metadata, nil
+}
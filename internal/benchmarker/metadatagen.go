@@ -0,0 +1,119 @@
+package benchmarker
+
+import (
+	"hash/fnv"
+	"strings"
+
+	"promfire/internal/config"
+	"promfire/internal/writer"
+)
+
+// unitSuffixes maps metric name suffixes to the unit they imply, following
+// the Prometheus naming conventions
+var unitSuffixes = []struct{ suffix, unit string }{
+	{"_seconds", "seconds"},
+	{"_milliseconds", "milliseconds"},
+	{"_bytes", "bytes"},
+	{"_ratio", "ratio"},
+	{"_percent", "percent"},
+	{"_celsius", "celsius"},
+	{"_meters", "meters"},
+	{"_volts", "volts"},
+	{"_amperes", "amperes"},
+	{"_joules", "joules"},
+	{"_grams", "grams"},
+	{"_hertz", "hertz"},
+}
+
+// helpTemplates phrase the HELP text of each metric type around the
+// metric's subject
+var helpTemplates = map[string]string{
+	"counter":   "Total number of %s observed since the process started.",
+	"gauge":     "Current value of %s as last measured.",
+	"histogram": "Distribution of %s, bucketed by value.",
+	"summary":   "Summary of %s with streaming quantiles.",
+	"unknown":   "Metric tracking %s.",
+}
+
+// helpFiller pads generated HELP text up to the configured length
+var helpFiller = strings.Fields("measured per instance and aggregated by the exporter " +
+	"across all scraped targets including retries timeouts and partial responses " +
+	"for capacity planning alerting dashboards and long term storage")
+
+// generateMetadata fills in HELP and UNIT for every metric in metrics, keyed
+// by its type. Without overwrite only metrics missing them from the source
+// are filled in. Unknown types are inferred from the metric name.
+func generateMetadata(cfg config.GenerateMetadata, metrics []string, known map[string]writer.MetricMetadata) map[string]writer.MetricMetadata {
+	generated := make(map[string]writer.MetricMetadata, len(known))
+	for name, md := range known {
+		generated[name] = md
+	}
+
+	for _, name := range metrics {
+		md := generated[name]
+		if md.Type == "" || strings.EqualFold(md.Type, "unknown") {
+			md.Type = inferMetricType(name)
+		}
+		if md.Unit == "" || cfg.Overwrite {
+			md.Unit = inferUnit(name)
+		}
+		if md.Help == "" || cfg.Overwrite {
+			md.Help = helpText(name, md.Type, md.Unit, cfg.HelpLength)
+		}
+		generated[name] = md
+	}
+	return generated
+}
+
+// inferMetricType guesses the type of a metric from its name suffix
+func inferMetricType(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_total"):
+		return "counter"
+	case strings.HasSuffix(name, "_bucket"):
+		return "histogram"
+	default:
+		return "gauge"
+	}
+}
+
+// inferUnit returns the unit implied by the metric name, empty if none
+func inferUnit(name string) string {
+	name = strings.TrimSuffix(name, "_total")
+	for _, s := range unitSuffixes {
+		if strings.HasSuffix(name, s.suffix) {
+			return s.unit
+		}
+	}
+	return ""
+}
+
+// helpText phrases the HELP text of a metric, padded with filler words and
+// cut to length bytes when length is positive. The filler starts at an
+// offset derived from the name, so metrics of one length still differ.
+func helpText(name, metricType, unit string, length int) string {
+	subject := strings.TrimSuffix(strings.TrimSuffix(name, "_total"), "_"+unit)
+	subject = strings.ReplaceAll(subject, "_", " ")
+	if unit != "" {
+		subject += " in " + unit
+	}
+
+	template, ok := helpTemplates[strings.ToLower(metricType)]
+	if !ok {
+		template = helpTemplates["unknown"]
+	}
+	help := strings.Replace(template, "%s", subject, 1)
+	if length <= 0 {
+		return help
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	var b strings.Builder
+	b.WriteString(help)
+	for i := int(h.Sum32() % uint32(len(helpFiller))); b.Len() < length; i++ {
+		b.WriteByte(' ')
+		b.WriteString(helpFiller[i%len(helpFiller)])
+	}
+	return b.String()[:length]
+}
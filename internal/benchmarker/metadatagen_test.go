@@ -0,0 +1,80 @@
+package benchmarker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/config"
+	"promfire/internal/writer"
+)
+
+func TestGenerateMetadataByType(t *testing.T) {
+	known := map[string]writer.MetricMetadata{
+		"http_requests_total": {Type: "counter", Help: "Requests served."},
+		"queue_depth":         {Type: "gauge"},
+	}
+	metrics := []string{"http_requests_total", "queue_depth", "request_duration_seconds_bucket", "heap_bytes"}
+
+	got := generateMetadata(config.GenerateMetadata{Enabled: true}, metrics, known)
+	want := map[string]writer.MetricMetadata{
+		"http_requests_total":             {Type: "counter", Help: "Requests served."},
+		"queue_depth":                     {Type: "gauge", Help: "Current value of queue depth as last measured."},
+		"request_duration_seconds_bucket": {Type: "histogram", Help: "Distribution of request duration seconds bucket, bucketed by value."},
+		"heap_bytes":                      {Type: "gauge", Unit: "bytes", Help: "Current value of heap in bytes as last measured."},
+	}
+	for name, md := range want {
+		if got[name] != md {
+			t.Errorf("%s = %+v, want %+v", name, got[name], md)
+		}
+	}
+	if known["queue_depth"].Help != "" {
+		t.Error("generating metadata modified the source metadata")
+	}
+
+	got = generateMetadata(config.GenerateMetadata{Enabled: true, Overwrite: true}, metrics, known)
+	if help := got["http_requests_total"].Help; help != "Total number of http requests observed since the process started." {
+		t.Errorf("overwritten counter help = %q", help)
+	}
+}
+
+func TestGenerateMetadataHelpLength(t *testing.T) {
+	for _, length := range []int{10, 200, 4096} {
+		a := helpText("cpu_usage_ratio", "gauge", "ratio", length)
+		b := helpText("memory_usage_ratio", "gauge", "ratio", length)
+		if len(a) != length || len(b) != length {
+			t.Errorf("help lengths = %d and %d, want %d", len(a), len(b), length)
+		}
+		// Past the template, the filler differs between metrics
+		if length > 100 && a == b {
+			t.Errorf("help of two metrics at length %d is identical: %q", length, a)
+		}
+	}
+}
+
+func TestRunSendsGeneratedMetadata(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	cfg := testConfig(t, nil, recv,
+		"  replication_factor: 1\n  query_range: 2m\n  query_step: 1m\n  include_metadata: true\n  generate_metadata:\n    enabled: true\n    help_length: 300\n",
+		"source:\n  type: synthetic\n  synthetic:\n    metric_name: request_duration_seconds\n    series_count: 2\n")
+
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	metadata := recv.Metadata()
+	if len(metadata) != 1 {
+		t.Fatalf("received %d metadata entries, want 1: %+v", len(metadata), metadata)
+	}
+	md := metadata[0]
+	if md.MetricFamilyName != "request_duration_seconds" || md.Type != prompb.MetricMetadata_GAUGE || md.Unit != "seconds" {
+		t.Errorf("metadata = %s %s %q, want a gauge in seconds", md.MetricFamilyName, md.Type, md.Unit)
+	}
+	if len(md.Help) != 300 || !strings.HasPrefix(md.Help, "Current value of request duration in seconds") {
+		t.Errorf("help = %q (%d bytes), want 300 bytes about request duration", md.Help, len(md.Help))
+	}
+}
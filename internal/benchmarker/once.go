@@ -0,0 +1,90 @@
+package benchmarker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"promfire/internal/logger"
+)
+
+// RunOnce queries, replicates and writes a single metric, bypassing
+// discovery and exclusion filters, and returns what this call wrote
+func (b *Benchmarker) RunOnce(ctx context.Context, metricName string) (Stats, error) {
+	log.Info("Processing single metric", map[string]interface{}{
+		"metric_name": metricName,
+	})
+
+	before := b.Stats()
+	b.stats.start()
+
+	endTime := time.Now()
+	startTime := endTime.Add(-b.config.QueryRange())
+	if b.remoteWriter != nil {
+		b.remoteWriter.SetShiftOrigin(endTime)
+	}
+	b.loadMetadata(ctx, []string{metricName})
+
+	rateLimiter := b.newRateLimiter()
+
+	b.writes = newWriteQueue(b.config.Benchmark.WriteQueue.Depth, b.concurrency, &b.failures)
+	b.writes.start(b.goroutines, 1)
+	b.stats.totalMetrics.Add(1)
+
+	logCtx := logger.WithFields(ctx, map[string]interface{}{
+		"metric_name": metricName,
+	})
+	metricCtx, cancel := b.metricContext(logCtx)
+	err := b.processMetric(metricCtx, metricName, startTime, endTime, b.config.QueryStep(), rateLimiter)
+	cancel()
+	b.stats.metrics.Add(1)
+	b.writes.close()
+
+	if err != nil {
+		err = fmt.Errorf("%s: %w", metricName, err)
+	}
+	if b.remoteWriter != nil {
+		if flushErr := b.flushWriter(); flushErr != nil {
+			if err == nil {
+				err = fmt.Errorf("flushing buffered series: %w", flushErr)
+			} else {
+				log.Error("Error flushing buffered series", map[string]interface{}{
+					"error": flushErr.Error(),
+				})
+			}
+		}
+		if err == nil {
+			err = b.writeProbe.err()
+		}
+	}
+	b.stats.finish()
+	if err == nil {
+		err = b.checkFailures(b.stats.metrics.Load())
+	}
+
+	b.dryRunSampler.logSummary()
+	b.reportFutureSamples()
+	b.reportDuplicateSamples()
+	b.reportOldSamples()
+	b.reportUnorderedSeries()
+	b.reportSeriesCap()
+	b.counters.report()
+
+	after := b.Stats()
+	stats := Stats{
+		Series:        after.Series - before.Series,
+		Samples:       after.Samples - before.Samples,
+		BytesSent:     after.BytesSent - before.BytesSent,
+		FailedBatches: after.FailedBatches - before.FailedBatches,
+		Elapsed:       after.Elapsed,
+	}
+	log.Info("Single metric completed", map[string]interface{}{
+		"metric_name":    metricName,
+		"series":         stats.Series,
+		"samples":        stats.Samples,
+		"bytes_sent":     stats.BytesSent,
+		"failed_batches": stats.FailedBatches,
+		"elapsed_ms":     stats.Elapsed.Milliseconds(),
+	})
+	return stats, err
+}
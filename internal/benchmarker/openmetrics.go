@@ -0,0 +1,211 @@
+package benchmarker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// familySuffixes are the sample name suffixes of each metric type, so the
+// _bucket, _sum and _count series of `# TYPE foo histogram` get its metadata
+var familySuffixes = map[string][]string{
+	"counter":        {"_total", "_created"},
+	"histogram":      {"_bucket", "_sum", "_count", "_created"},
+	"gaugehistogram": {"_bucket", "_gsum", "_gcount"},
+	"summary":        {"_sum", "_count", "_created"},
+	"info":           {"_info"},
+}
+
+// readOpenMetrics loads a Prometheus or OpenMetrics text exposition. Every
+// sample line becomes a one-sample series, so classic histogram _bucket,
+// _sum and _count lines are replayed like any other series. # TYPE, # HELP
+// and # UNIT comments become the metadata of the family and its series.
+// Exemplars are dropped; malformed lines are logged and skipped.
+func (s *fileSource) readOpenMetrics(r io.Reader, path string) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 1<<20), 1<<30)
+
+	skipped := 0
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, "#") {
+			s.readComment(text)
+			continue
+		}
+
+		metric, value, err := parseSampleLine(text)
+		if err != nil {
+			skipped++
+			log.Warn("Skipping malformed exposition line", map[string]interface{}{
+				"file":  path,
+				"line":  line,
+				"error": err.Error(),
+			})
+			continue
+		}
+		s.add(Series{Metric: metric, Values: [][]interface{}{{0, value}}})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading series file: %w", err)
+	}
+
+	if skipped > 0 {
+		log.Warn("Skipped malformed lines in exposition file", map[string]interface{}{
+			"file":    path,
+			"skipped": skipped,
+		})
+	}
+	s.inheritFamilyMetadata()
+	return nil
+}
+
+// inheritFamilyMetadata gives series without their own metadata the metadata
+// of the family they belong to by their type's suffix
+func (s *fileSource) inheritFamilyMetadata() {
+	for _, name := range s.metrics {
+		if _, ok := s.metadata[name]; ok {
+			continue
+		}
+		for family, md := range s.metadata {
+			for _, suffix := range familySuffixes[md.Type] {
+				if name == family+suffix {
+					s.metadata[name] = md
+				}
+			}
+		}
+	}
+}
+
+// readComment records # TYPE, # HELP and # UNIT metadata; other comments,
+// including # EOF, are ignored
+func (s *fileSource) readComment(text string) {
+	fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(text, "#")), " ", 3)
+	if len(fields) < 3 {
+		return
+	}
+	kind, name, rest := fields[0], fields[1], fields[2]
+
+	md := s.metadata[name]
+	switch kind {
+	case "TYPE":
+		md.Type = rest
+	case "HELP":
+		md.Help = rest
+	case "UNIT":
+		md.Unit = rest
+	default:
+		return
+	}
+	s.metadata[name] = md
+}
+
+// parseSampleLine parses `name{label="value",...} value [timestamp]`. The
+// timestamp is ignored because samples are replayed at the current time.
+func parseSampleLine(text string) (map[string]string, string, error) {
+	end := strings.IndexAny(text, "{ \t")
+	if end <= 0 {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	name := text[:end]
+	if !model.IsValidMetricName(model.LabelValue(name)) {
+		return nil, "", fmt.Errorf("invalid metric name %q", name)
+	}
+
+	metric := map[string]string{labels.MetricName: name}
+	rest := text[end:]
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		if rest, err = parseLabels(rest[1:], metric); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// An OpenMetrics exemplar follows the value and timestamp after " # "
+	rest, _, _ = strings.Cut(rest, " # ")
+	fields := strings.Fields(rest)
+	if len(fields) < 1 || len(fields) > 2 {
+		return nil, "", fmt.Errorf("expected a value and an optional timestamp, got %q", strings.TrimSpace(rest))
+	}
+	if _, err := strconv.ParseFloat(fields[0], 64); err != nil {
+		return nil, "", fmt.Errorf("invalid value %q", fields[0])
+	}
+	return metric, fields[0], nil
+}
+
+// parseLabels parses the label pairs after an opening brace into metric and
+// returns the text after the closing brace
+func parseLabels(text string, metric map[string]string) (string, error) {
+	for {
+		text = strings.TrimLeft(text, " \t")
+		if strings.HasPrefix(text, "}") {
+			return text[1:], nil
+		}
+
+		eq := strings.IndexByte(text, '=')
+		if eq <= 0 {
+			return "", fmt.Errorf("malformed label set")
+		}
+		name := strings.TrimSpace(text[:eq])
+		if !model.LabelName(name).IsValid() {
+			return "", fmt.Errorf("invalid label name %q", name)
+		}
+
+		text = strings.TrimLeft(text[eq+1:], " \t")
+		value, n, err := unquoteLabelValue(text)
+		if err != nil {
+			return "", fmt.Errorf("label %q: %w", name, err)
+		}
+		metric[name] = value
+
+		text = strings.TrimLeft(text[n:], " \t")
+		switch {
+		case strings.HasPrefix(text, ","):
+			text = text[1:]
+		case strings.HasPrefix(text, "}"):
+		default:
+			return "", fmt.Errorf("expected , or } after label %q", name)
+		}
+	}
+}
+
+// unquoteLabelValue decodes a double-quoted label value with \\, \" and \n
+// escapes, returning the value and the number of bytes consumed
+func unquoteLabelValue(text string) (string, int, error) {
+	if !strings.HasPrefix(text, `"`) {
+		return "", 0, fmt.Errorf("value must be quoted")
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			i++
+			if i == len(text) {
+				return "", 0, fmt.Errorf("unterminated escape")
+			}
+			switch text[i] {
+			case '\\':
+				b.WriteByte('\\')
+			case '"':
+				b.WriteByte('"')
+			case 'n':
+				b.WriteByte('\n')
+			default:
+				return "", 0, fmt.Errorf("invalid escape \\%c", text[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated value")
+}
@@ -0,0 +1,99 @@
+package benchmarker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"promfire/internal/logger"
+)
+
+// loadExposition parses text as an OpenMetrics file source
+func loadExposition(t *testing.T, text string) *fileSource {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "metrics.txt")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := newFileSource(path, FileFormatOpenMetrics, nil)
+	if err != nil {
+		t.Fatalf("loading exposition: %v", err)
+	}
+	return src
+}
+
+func TestReadOpenMetricsSkipsMalformedLines(t *testing.T) {
+	logs := captureLogs(t, logger.WARN)
+
+	src := loadExposition(t, strings.Join([]string{
+		`valid_total{job="a"} 1`,
+		`1invalid_name 2`,
+		`missing_value{job="a"}`,
+		`bad_value NaNx`,
+		`unterminated{job="a} 3`,
+		`unquoted{job=a} 4`,
+		`too_many 1 2 3`,
+		`also_valid 5 1700000000000`,
+	}, "\n"))
+
+	if got, want := src.metrics, []string{"valid_total", "also_valid"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("metrics = %v, want %v", got, want)
+	}
+	if got := strings.Count(logs.String(), "Skipping malformed exposition line"); got != 6 {
+		t.Errorf("logged %d malformed lines, want 6:\n%s", got, logs.String())
+	}
+	if !strings.Contains(logs.String(), "Skipped malformed lines in exposition file") {
+		t.Error("missing the skipped lines summary")
+	}
+}
+
+func TestReadOpenMetricsExemplars(t *testing.T) {
+	src := loadExposition(t, strings.Join([]string{
+		`# TYPE rpc_seconds histogram`,
+		`rpc_seconds_bucket{le="0.1"} 8 # {trace_id="abc"} 0.05 1700000000.000`,
+		`rpc_seconds_bucket{le="+Inf"} 10 1700000000 # {trace_id="def"} 0.7`,
+		`rpc_seconds_count 10`,
+		`# EOF`,
+	}, "\n"))
+
+	series := src.series["rpc_seconds_bucket"]
+	if len(series) != 2 {
+		t.Fatalf("got %d bucket series, want 2 with their exemplars dropped", len(series))
+	}
+	if got := series[0].Values[0][1]; got != "8" {
+		t.Errorf("bucket value = %v, want 8", got)
+	}
+	if got := series[1].Metric["le"]; got != "+Inf" {
+		t.Errorf("le = %q, want +Inf", got)
+	}
+}
+
+func TestReadOpenMetricsFamilyMetadata(t *testing.T) {
+	src := loadExposition(t, strings.Join([]string{
+		`# HELP rpc_seconds RPC latency.`,
+		`# TYPE rpc_seconds histogram`,
+		`rpc_seconds_bucket{le="+Inf"} 10`,
+		`rpc_seconds_sum 3.5`,
+		`rpc_seconds_count 10`,
+		`# TYPE requests counter`,
+		`requests_total 7`,
+		`# TYPE temperature gauge`,
+		`temperature_sum 1`,
+	}, "\n"))
+
+	for _, name := range []string{"rpc_seconds_bucket", "rpc_seconds_sum", "rpc_seconds_count"} {
+		md := src.metadata[name]
+		if md.Type != "histogram" || md.Help != "RPC latency." {
+			t.Errorf("%s metadata = %+v, want the rpc_seconds histogram family", name, md)
+		}
+	}
+	if got := src.metadata["requests_total"].Type; got != "counter" {
+		t.Errorf("requests_total type = %q, want counter", got)
+	}
+	// _sum is not a gauge series suffix
+	if md, ok := src.metadata["temperature_sum"]; ok {
+		t.Errorf("temperature_sum metadata = %+v, want none", md)
+	}
+}
@@ -0,0 +1,97 @@
+package benchmarker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+// PreflightResult is the outcome of one connectivity check
+type PreflightResult struct {
+	Check   string        `json:"check"`
+	OK      bool          `json:"ok"`
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type preflightCheck struct {
+	name string
+	run  func(context.Context) error
+}
+
+// Preflight checks that the query API answers an instant query and that the
+// remote write endpoint accepts an empty write request, logging each result.
+// It reports false if any check failed. The query check is skipped for a
+// file source and the remote write check in dry-run mode.
+func (b *Benchmarker) Preflight(ctx context.Context) ([]PreflightResult, bool) {
+	var checks []preflightCheck
+	if src, ok := b.source.(pingSource); ok {
+		checks = append(checks, preflightCheck{name: "query", run: src.Ping})
+	}
+	if b.remoteWriter != nil {
+		checks = append(checks, preflightCheck{name: "remote_write", run: b.remoteWriter.Ping})
+	}
+
+	results := make([]PreflightResult, 0, len(checks))
+	ok := true
+	for _, check := range checks {
+		start := time.Now()
+		err := check.run(ctx)
+		result := PreflightResult{
+			Check:   check.name,
+			OK:      err == nil,
+			Latency: time.Since(start),
+		}
+
+		fields := map[string]interface{}{
+			"check":      result.Check,
+			"latency_ms": result.Latency.Milliseconds(),
+		}
+		if err != nil {
+			ok = false
+			result.Error = err.Error()
+			fields["error"] = result.Error
+			log.Error("Preflight check failed", fields)
+		} else {
+			log.Info("Preflight check ok", fields)
+		}
+		results = append(results, result)
+	}
+
+	return results, ok
+}
+
+// pingQuery runs the instant query vector(1) against the query API
+func (b *Benchmarker) pingQuery(ctx context.Context) error {
+	queryURL := fmt.Sprintf("%s/api/v1/query?query=%s", b.config.Prometheus.QueryURL, url.QueryEscape("vector(1)"))
+
+	req, err := b.newQueryRequest(ctx, queryURL)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("making request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	var result struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("parsing response (status %d): %w", resp.StatusCode, err)
+	}
+	if result.Status != "success" {
+		return fmt.Errorf("query failed: %s", string(body))
+	}
+	return nil
+}
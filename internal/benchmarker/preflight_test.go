@@ -0,0 +1,132 @@
+package benchmarker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"promfire/internal/benchmarker/testutil"
+)
+
+// checkResults maps the results of a preflight by check name
+func checkResults(results []PreflightResult) map[string]PreflightResult {
+	byCheck := make(map[string]PreflightResult, len(results))
+	for _, r := range results {
+		byCheck[r.Check] = r
+	}
+	return byCheck
+}
+
+func TestPreflightHealthyEndpoints(t *testing.T) {
+	prom := testutil.NewFakePrometheus()
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	b := newTestBenchmarker(t, testConfig(t, prom, recv, "", ""), Options{})
+	results, ok := b.Preflight(context.Background())
+	if !ok {
+		t.Fatalf("preflight failed with healthy endpoints: %+v", results)
+	}
+	byCheck := checkResults(results)
+	for _, check := range []string{"query", "remote_write"} {
+		if r, found := byCheck[check]; !found || !r.OK || r.Error != "" {
+			t.Errorf("%s check = %+v, want ok", check, r)
+		}
+	}
+	if got := recv.Requests(); got != 1 {
+		t.Errorf("remote write requests = %d, want the one probe", got)
+	}
+}
+
+func TestPreflightFailingQueryAPI(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"error status", `{"status":"error","errorType":"bad_data","error":"parse error"}`},
+		{"non-JSON body", "<html>bad gateway</html>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			recv := testutil.NewFakeReceiver()
+			defer recv.Close()
+
+			cfg := testConfig(t, nil, recv, "", "")
+			cfg.Prometheus.QueryURL = srv.URL
+			b := newTestBenchmarker(t, cfg, Options{})
+			results, ok := b.Preflight(context.Background())
+			if ok {
+				t.Fatalf("preflight ok with a failing query API: %+v", results)
+			}
+			byCheck := checkResults(results)
+			if r := byCheck["query"]; r.OK || r.Error == "" {
+				t.Errorf("query check = %+v, want failed with an error", r)
+			}
+			if r := byCheck["remote_write"]; !r.OK {
+				t.Errorf("remote_write check = %+v, want ok", r)
+			}
+		})
+	}
+}
+
+func TestPreflightFailingRemoteWrite(t *testing.T) {
+	prom := testutil.NewFakePrometheus()
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	recv.SetStatus(http.StatusInternalServerError)
+
+	b := newTestBenchmarker(t, testConfig(t, prom, recv, "", ""), Options{})
+	results, ok := b.Preflight(context.Background())
+	if ok {
+		t.Fatalf("preflight ok with a failing remote write endpoint: %+v", results)
+	}
+	byCheck := checkResults(results)
+	if r := byCheck["remote_write"]; r.OK || r.Error == "" {
+		t.Errorf("remote_write check = %+v, want failed with an error", r)
+	}
+	if r := byCheck["query"]; !r.OK {
+		t.Errorf("query check = %+v, want ok", r)
+	}
+}
+
+func TestPreflightSkipsFileSourceQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "series.ndjson")
+	if err := os.WriteFile(path, []byte(`{"metric":{"__name__":"up"},"values":[[1700000000,"1"]]}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	b := newTestBenchmarker(t, testConfig(t, nil, recv, "", fmt.Sprintf("source:\n  type: file\n  path: %q\n", path)), Options{})
+	results, ok := b.Preflight(context.Background())
+	if !ok || len(results) != 1 || results[0].Check != "remote_write" {
+		t.Errorf("preflight = %+v, %v, want only an ok remote_write check", results, ok)
+	}
+}
+
+func TestPreflightSkipsRemoteWriteInDryRun(t *testing.T) {
+	prom := testutil.NewFakePrometheus()
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	recv.SetStatus(http.StatusInternalServerError)
+
+	b := newTestBenchmarker(t, testConfig(t, prom, recv, "", ""), Options{DryRun: true})
+	results, ok := b.Preflight(context.Background())
+	if !ok || len(results) != 1 || results[0].Check != "query" {
+		t.Errorf("preflight = %+v, %v, want only an ok query check", results, ok)
+	}
+	if got := recv.Requests(); got != 0 {
+		t.Errorf("remote write requests = %d, want none in dry-run mode", got)
+	}
+}
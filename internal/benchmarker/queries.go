@@ -0,0 +1,72 @@
+package benchmarker
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// queryLimiter caps the number of in-flight source queries across all
+// workers; a nil semaphore means unlimited. A streamed range query holds its
+// slot until the response body has been fully consumed.
+type queryLimiter struct {
+	slots   chan struct{}
+	waits   atomic.Int64
+	waitDur atomic.Int64
+}
+
+func newQueryLimiter(max int) *queryLimiter {
+	if max <= 0 {
+		return &queryLimiter{}
+	}
+	return &queryLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire blocks until a query slot is free and returns its release function
+func (l *queryLimiter) acquire(ctx context.Context) (func(), error) {
+	if l.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	// Announce the first wait so a binding limit is visible at info level
+	fields := map[string]interface{}{
+		"max_concurrent_queries": cap(l.slots),
+	}
+	if l.waits.Add(1) == 1 {
+		log.Info("Waiting for a query slot, max_concurrent_queries is binding", fields)
+	} else {
+		log.Debug("Waiting for a query slot", fields)
+	}
+
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+		l.waitDur.Add(int64(time.Since(start)))
+		return l.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *queryLimiter) release() {
+	<-l.slots
+}
+
+// reportQueryWaits logs how often queries waited on max_concurrent_queries
+func (b *Benchmarker) reportQueryWaits() {
+	waits := b.queries.waits.Load()
+	if waits == 0 {
+		return
+	}
+	log.Info("Queries waited on max_concurrent_queries", map[string]interface{}{
+		"max_concurrent_queries": b.config.Benchmark.MaxConcurrentQueries,
+		"waits":                  waits,
+		"total_wait_ms":          time.Duration(b.queries.waitDur.Load()).Milliseconds(),
+	})
+}
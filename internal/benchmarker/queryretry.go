@@ -0,0 +1,143 @@
+package benchmarker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// errQueryFailed marks responses in which Prometheus reported the query
+// itself as failed, e.g. a parse error or an exceeded sample limit
+var errQueryFailed = errors.New("query failed")
+
+// queryRetryBackoff is the delay before the first query retry, growing
+// linearly with every further attempt
+const queryRetryBackoff = 500 * time.Millisecond
+
+// queryError is a query that failed after the request was sent,
+// carrying the HTTP status and the start of the response body. A zero
+// status means no response was received.
+type queryError struct {
+	statusCode int
+	body       string
+	err        error
+}
+
+func (e *queryError) Error() string {
+	if e.statusCode == 0 {
+		return e.err.Error()
+	}
+	if errors.Is(e.err, errQueryFailed) {
+		return fmt.Sprintf("HTTP %d: %v", e.statusCode, e.err)
+	}
+	return fmt.Sprintf("HTTP %d: %v (response starts with %q)", e.statusCode, e.err, e.body)
+}
+
+func (e *queryError) Unwrap() error {
+	return e.err
+}
+
+// transient reports whether retrying the query may succeed: the connection
+// failed, the response was cut off or the server was overloaded, as opposed
+// to Prometheus rejecting the query
+func (e *queryError) transient() bool {
+	if e.statusCode == http.StatusTooManyRequests || e.statusCode >= 500 {
+		return true
+	}
+	return !errors.Is(e.err, errQueryFailed)
+}
+
+// callbackError carries an error returned by the series callback through
+// the retry loop, which must not retry it
+type callbackError struct {
+	err error
+}
+
+func (e *callbackError) Error() string {
+	return e.err.Error()
+}
+
+// headBuffer keeps the first limit bytes written to it
+type headBuffer struct {
+	limit int
+	buf   []byte
+}
+
+func (h *headBuffer) Write(p []byte) (int, error) {
+	if room := h.limit - len(h.buf); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		h.buf = append(h.buf, p[:room]...)
+	}
+	return len(p), nil
+}
+
+func (h *headBuffer) String() string {
+	return string(h.buf)
+}
+
+// queryRange runs a range query and streams its series to fn, retrying up to
+// query_retries times on transient failures. Series streamed before a
+// response broke off are not passed to fn again by the retry.
+func (b *Benchmarker) queryRange(ctx context.Context, query string, startTime, endTime time.Time, step time.Duration, fn func(Series) error) error {
+	return b.retryQuery(ctx, query, func(fn func(Series) error) error {
+		return b.queryRangeOnce(ctx, query, startTime, endTime, step, fn)
+	}, fn)
+}
+
+// retryQuery calls run, which sends query once and streams its series, until
+// it succeeds or fails permanently, like queryRange
+func (b *Benchmarker) retryQuery(ctx context.Context, query string, run func(fn func(Series) error) error, fn func(Series) error) error {
+	retries := b.config.QueryRetries()
+
+	var seen map[string]struct{}
+	if retries > 0 {
+		seen = make(map[string]struct{})
+	}
+	once := func(series Series) error {
+		if seen != nil {
+			key := labels.FromMap(series.Metric).String()
+			if _, ok := seen[key]; ok {
+				return nil
+			}
+			seen[key] = struct{}{}
+		}
+		if err := fn(series); err != nil {
+			return &callbackError{err: err}
+		}
+		return nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := run(once)
+		var cbErr *callbackError
+		if errors.As(err, &cbErr) {
+			return cbErr.err
+		}
+		var qErr *queryError
+		if err == nil || attempt >= retries || ctx.Err() != nil || !errors.As(err, &qErr) || !qErr.transient() {
+			return err
+		}
+
+		delay := time.Duration(attempt+1) * queryRetryBackoff
+		log.WarnContext(ctx, "Query failed, retrying", map[string]interface{}{
+			"query":    query,
+			"attempt":  attempt + 1,
+			"error":    err.Error(),
+			"delay_ms": delay.Milliseconds(),
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package benchmarker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+)
+
+// truncatingPrometheus serves the metric "up" with three series. The first
+// failFirst range queries break off after the first series by closing the
+// connection mid-response.
+type truncatingPrometheus struct {
+	*httptest.Server
+	failFirst int32
+	queries   atomic.Int32
+}
+
+func newTruncatingPrometheus(failFirst int32, now time.Time) *truncatingPrometheus {
+	p := &truncatingPrometheus{failFirst: failFirst}
+	series := make([]string, 3)
+	for i := range series {
+		series[i] = fmt.Sprintf(`{"metric":{"__name__":"up","instance":"host-%d"},"values":[[%d,"1"],[%d,"2"]]}`,
+			i, now.Add(-2*time.Minute).Unix(), now.Add(-time.Minute).Unix())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/label/__name__/values", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"status":"success","data":["up"]}`)
+	})
+	mux.HandleFunc("/api/v1/query_range", func(w http.ResponseWriter, _ *http.Request) {
+		head := `{"status":"success","data":{"resultType":"matrix","result":[`
+		if p.queries.Add(1) <= p.failFirst {
+			fmt.Fprint(w, head+series[0]+`,{"metric":{"__name__":"up","inst`)
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		fmt.Fprint(w, head+strings.Join(series, ",")+`]}}`)
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// queryRetryConfig points a run at prom with the given query_retries
+func queryRetryConfig(prom *truncatingPrometheus, recv *testutil.FakeReceiver, retries int) string {
+	return fmt.Sprintf("prometheus:\n  query_url: %q\n  remote_write_url: %q\n  query_retries: %d\nbenchmark:\n  replication_factor: 1\n  timestamp_mode: preserve\n",
+		prom.URL, recv.WriteURL(), retries)
+}
+
+func TestQueryRetryAfterConnectionClosedEarly(t *testing.T) {
+	now := time.Now()
+	prom := newTruncatingPrometheus(1, now)
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := loadTestConfig(t, queryRetryConfig(prom, recv, 2))
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if got := prom.queries.Load(); got != 2 {
+		t.Errorf("sent %d range queries, want the truncated one retried once", got)
+	}
+	series := recv.Series()
+	if len(series) != 3 {
+		t.Fatalf("received %d series, want all 3", len(series))
+	}
+	// The series streamed before the connection closed is not replayed
+	for _, s := range series {
+		if len(s.Samples) != 2 {
+			t.Errorf("series %s received %d samples, want its 2 written once", s.Labels["instance"], len(s.Samples))
+		}
+	}
+}
+
+func TestQueryWithoutRetriesFailsOnTruncatedResponse(t *testing.T) {
+	prom := newTruncatingPrometheus(1, time.Now())
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := loadTestConfig(t, queryRetryConfig(prom, recv, 0))
+	b := newTestBenchmarker(t, cfg, Options{})
+	_ = b.Run(context.Background())
+
+	if got := prom.queries.Load(); got != 1 {
+		t.Errorf("sent %d range queries, want no retry with query_retries 0", got)
+	}
+	if got := b.failures.queries.Load(); got != 1 {
+		t.Errorf("%d failed queries counted, want the truncated one", got)
+	}
+}
+
+func TestQueryErrorCarriesStatusAndBody(t *testing.T) {
+	err := &queryError{statusCode: http.StatusBadGateway, body: "<html>bad gateway", err: fmt.Errorf("decoding response: unexpected EOF")}
+	if !err.transient() {
+		t.Error("502 is not treated as transient")
+	}
+	for _, want := range []string{"HTTP 502", "<html>bad gateway", "unexpected EOF"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	failed := &queryError{statusCode: http.StatusBadRequest, err: fmt.Errorf("%w: parse error", errQueryFailed)}
+	if failed.transient() {
+		t.Error("a query Prometheus rejected is treated as transient")
+	}
+}
@@ -0,0 +1,162 @@
+package benchmarker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"promfire/internal/writer"
+)
+
+// writeJob is a single replica write queued by a metric worker
+type writeJob struct {
+	ctx     context.Context
+	send    func(ctx context.Context) error
+	pending *metricWrites
+}
+
+// metricWrites tracks the queued writes of one metric so its worker can wait
+// for them, stop early on an error that must end the metric and tell whether
+// any write was lost. Writes return once their series are buffered, so
+// batches follows the series on to the shared batches that send them.
+type metricWrites struct {
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	err     error
+	errors  int
+	batches writer.WriteTracker
+}
+
+// fail records the first error that ends the metric
+func (m *metricWrites) fail(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err == nil {
+		m.err = err
+	}
+}
+
+// failed returns the error that ended the metric, if any
+func (m *metricWrites) failed() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// writeFailed records a replica write that was lost without ending the metric
+func (m *metricWrites) writeFailed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors++
+}
+
+// writeErrors returns the number of replica writes that were lost
+func (m *metricWrites) writeErrors() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.errors
+}
+
+// wait blocks until all queued writes of the metric are done
+func (m *metricWrites) wait() error {
+	m.wg.Wait()
+	return m.failed()
+}
+
+// writeQueue decouples querying from writing: metric workers enqueue replica
+// writes and a pool of senders drains them, so on shutdown everything already
+// queued is still flushed. Without free goroutine slots writes run inline.
+// With adaptive concurrency the gate bounds how many senders write at once.
+//
+// errgroup does not fit: its group shares one context and its first error
+// ends every goroutine, while here a fatal error only ends its own metric
+// and a lost write is counted against the metric rather than failing it.
+// The senders are one pool shared by all metric workers, which block on the
+// full queue for back-pressure, and start through the goroutine limiter,
+// falling back to inline writes instead of waiting for a SetLimit slot.
+type writeQueue struct {
+	jobs     chan writeJob
+	senders  sync.WaitGroup
+	inline   bool
+	gate     *concurrencyController
+	failures *failureSummary
+}
+
+// newWriteQueue returns a queue buffering up to depth replica writes between
+// the metric workers decoding query responses and the senders writing them out
+func newWriteQueue(depth int, gate *concurrencyController, failures *failureSummary) *writeQueue {
+	return &writeQueue{jobs: make(chan writeJob, depth), gate: gate, failures: failures}
+}
+
+// start launches up to n senders through the goroutine limiter. It must be
+// called before anything is enqueued.
+func (q *writeQueue) start(limiter *goroutineLimiter, n int) {
+	started := 0
+	for i := 0; i < n; i++ {
+		q.senders.Add(1)
+		ok := limiter.tryGo(func() {
+			defer q.senders.Done()
+			for job := range q.jobs {
+				q.run(job)
+			}
+		})
+		if !ok {
+			q.senders.Done()
+			break
+		}
+		started++
+	}
+
+	if started == 0 {
+		q.inline = true
+		log.Debug("No goroutine slots for write senders, writing inline")
+	}
+}
+
+// enqueue queues job for sending, blocking while the queue is full
+func (q *writeQueue) enqueue(job writeJob) error {
+	job.pending.wg.Add(1)
+	if q.inline {
+		q.run(job)
+		return nil
+	}
+
+	select {
+	case q.jobs <- job:
+		return nil
+	case <-job.ctx.Done():
+		job.pending.wg.Done()
+		return job.ctx.Err()
+	}
+}
+
+// close stops accepting jobs and waits until every queued job has been sent
+func (q *writeQueue) close() {
+	close(q.jobs)
+	q.senders.Wait()
+}
+
+func (q *writeQueue) run(job writeJob) {
+	defer job.pending.wg.Done()
+
+	if err := q.gate.acquire(job.ctx); err != nil {
+		job.pending.fail(err)
+		return
+	}
+	err := job.send(job.ctx)
+	q.gate.release()
+	switch {
+	case err == nil:
+	case errors.Is(err, errTargetRejectsWrites) || errors.Is(err, writer.ErrUnorderedSamples) ||
+		errors.Is(err, context.DeadlineExceeded) || job.ctx.Err() != nil:
+		job.pending.fail(err)
+	default:
+		job.pending.writeFailed()
+		if errors.Is(err, writer.ErrNoValidSamples) {
+			q.failures.parses.Add(1)
+		}
+		log.ErrorContext(job.ctx, "Error replicating series", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
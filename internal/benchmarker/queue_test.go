@@ -0,0 +1,168 @@
+package benchmarker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteQueueSendsConcurrently(t *testing.T) {
+	const senders = 4
+	q := newWriteQueue(0, nil, &failureSummary{})
+	q.start(newGoroutineLimiter(0), senders)
+
+	// Every send blocks until all senders are busy at once
+	var started sync.WaitGroup
+	started.Add(senders)
+	release := make(chan struct{})
+	pending := &metricWrites{}
+	for i := 0; i < senders; i++ {
+		err := q.enqueue(writeJob{ctx: context.Background(), pending: pending, send: func(context.Context) error {
+			started.Done()
+			<-release
+			return nil
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("fewer than %d sends ran at once", senders)
+	}
+	close(release)
+	if err := pending.wait(); err != nil {
+		t.Fatal(err)
+	}
+	q.close()
+}
+
+func TestWriteQueueAppliesBackPressure(t *testing.T) {
+	q := newWriteQueue(1, nil, &failureSummary{})
+	q.start(newGoroutineLimiter(0), 1)
+	defer q.close()
+
+	// One job is being sent, one fills the buffer, the third must block
+	release := make(chan struct{})
+	sending := make(chan struct{}, 2)
+	pending := &metricWrites{}
+	blocked := func(context.Context) error {
+		sending <- struct{}{}
+		<-release
+		return nil
+	}
+	for i := 0; i < 2; i++ {
+		if err := q.enqueue(writeJob{ctx: context.Background(), pending: pending, send: blocked}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-sending
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := q.enqueue(writeJob{ctx: ctx, pending: pending, send: blocked})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("enqueue into a full queue = %v, want it to block until the deadline", err)
+	}
+
+	close(release)
+	if err := pending.wait(); err != nil {
+		t.Fatalf("wait = %v, want the abandoned enqueue not to count as pending", err)
+	}
+}
+
+func TestWriteQueueCloseFlushesQueuedWrites(t *testing.T) {
+	q := newWriteQueue(100, nil, &failureSummary{})
+	q.start(newGoroutineLimiter(0), 2)
+
+	var sent atomic.Int64
+	pending := &metricWrites{}
+	for i := 0; i < 100; i++ {
+		err := q.enqueue(writeJob{ctx: context.Background(), pending: pending, send: func(context.Context) error {
+			time.Sleep(100 * time.Microsecond)
+			sent.Add(1)
+			return nil
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.close()
+	if got := sent.Load(); got != 100 {
+		t.Errorf("sent %d writes before close returned, want all 100", got)
+	}
+}
+
+func TestWriteQueuePropagatesErrors(t *testing.T) {
+	failures := &failureSummary{}
+	q := newWriteQueue(10, nil, failures)
+	q.start(newGoroutineLimiter(0), 3)
+	defer q.close()
+
+	fatal := &metricWrites{}
+	lossy := &metricWrites{}
+	sends := []struct {
+		pending *metricWrites
+		err     error
+	}{
+		{lossy, fmt.Errorf("status 400")},
+		{lossy, nil},
+		{lossy, fmt.Errorf("status 400")},
+		{fatal, nil},
+		{fatal, fmt.Errorf("writing: %w", errTargetRejectsWrites)},
+		{fatal, nil},
+	}
+	for _, s := range sends {
+		err := s.err
+		if err := q.enqueue(writeJob{ctx: context.Background(), pending: s.pending, send: func(context.Context) error { return err }}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := lossy.wait(); err != nil {
+		t.Errorf("lossy metric ended with %v, want lost writes to be counted only", err)
+	}
+	if got := lossy.writeErrors(); got != 2 {
+		t.Errorf("lossy metric lost %d writes, want 2", got)
+	}
+	if err := fatal.wait(); !errors.Is(err, errTargetRejectsWrites) {
+		t.Errorf("fatal metric ended with %v, want errTargetRejectsWrites", err)
+	}
+}
+
+func TestWriteQueueWritesInlineWithoutSlots(t *testing.T) {
+	limiter := newGoroutineLimiter(1)
+	hold := make(chan struct{})
+	defer close(hold)
+	limiter.tryGo(func() { <-hold })
+
+	q := newWriteQueue(1, nil, &failureSummary{})
+	q.start(limiter, 2)
+	defer q.close()
+	if !q.inline {
+		t.Fatal("queue started senders without free goroutine slots")
+	}
+
+	sent := false
+	pending := &metricWrites{}
+	if err := q.enqueue(writeJob{ctx: context.Background(), pending: pending, send: func(context.Context) error {
+		sent = true
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if !sent {
+		t.Error("inline enqueue returned before the write was sent")
+	}
+}
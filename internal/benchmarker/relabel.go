@@ -0,0 +1,58 @@
+package benchmarker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"promfire/internal/config"
+)
+
+// relabelRule is a config.RelabelRule with its regex compiled
+type relabelRule struct {
+	config.RelabelRule
+	regex *regexp.Regexp
+}
+
+func compileRelabelRules(rules []config.RelabelRule) ([]relabelRule, error) {
+	compiled := make([]relabelRule, 0, len(rules))
+	for i, rule := range rules {
+		regex, err := rule.CompiledRegex()
+		if err != nil {
+			return nil, fmt.Errorf("relabel[%d]: %w", i, err)
+		}
+		compiled = append(compiled, relabelRule{RelabelRule: rule, regex: regex})
+	}
+	return compiled, nil
+}
+
+// relabel applies rules to labels in place, reporting false if a drop rule
+// matched and the replica must be skipped
+func relabel(labels map[string]string, rules []relabelRule) bool {
+	for _, rule := range rules {
+		values := make([]string, len(rule.SourceLabels))
+		for i, name := range rule.SourceLabels {
+			values[i] = labels[name]
+		}
+		value := strings.Join(values, rule.Separator)
+
+		switch rule.Action {
+		case "drop":
+			if rule.regex.MatchString(value) {
+				return false
+			}
+		case "replace":
+			match := rule.regex.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			result := string(rule.regex.ExpandString(nil, rule.Replacement, value, match))
+			if result == "" {
+				delete(labels, rule.TargetLabel)
+			} else {
+				labels[rule.TargetLabel] = result
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package benchmarker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"promfire/internal/writer"
+)
+
+// reportCompression logs the metrics dominating wire bytes and optionally
+// writes the full per-metric compression report to the output directory
+func (b *Benchmarker) reportCompression() error {
+	metrics := b.remoteWriter.CompressionByMetric()
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	top := metrics
+	if len(top) > 5 {
+		top = top[:5]
+	}
+	log.Info("Top metrics by compressed bytes", map[string]interface{}{
+		"metrics": top,
+	})
+
+	if !b.config.Output.CompressionReport {
+		return nil
+	}
+
+	if err := os.MkdirAll(b.config.Output.Dir, 0o755); err != nil {
+		return fmt.Errorf("creating output dir: %w", err)
+	}
+
+	path := filepath.Join(b.config.Output.Dir, "compression.ndjson")
+	if err := writeCompressionReport(path, metrics); err != nil {
+		return err
+	}
+
+	log.Info("Compression report written", map[string]interface{}{
+		"path":    path,
+		"metrics": len(metrics),
+	})
+	return nil
+}
+
+// writeCompressionReport writes one JSON line per metric to path
+func writeCompressionReport(path string, metrics []writer.MetricCompression) (err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("closing compression report: %w", closeErr)
+		}
+	}()
+
+	enc := json.NewEncoder(f)
+	for _, m := range metrics {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportFutureSamples warns when timestamp generation ran ahead of wall-clock time
+func (b *Benchmarker) reportFutureSamples() {
+	if b.remoteWriter == nil {
+		return
+	}
+	clamped, dropped := b.remoteWriter.FutureSamples()
+	if clamped == 0 && dropped == 0 {
+		return
+	}
+	log.Warn("Samples were timestamped in the future, timestamp generation is drifting ahead of wall-clock", map[string]interface{}{
+		"clamped_samples": clamped,
+		"dropped_samples": dropped,
+		"tolerance_ms":    b.config.Benchmark.FutureSamples.ToleranceMs,
+	})
+}
+
+// reportDuplicateSamples logs how many source samples shared a timestamp
+// and were resolved by the duplicate_timestamps policy
+func (b *Benchmarker) reportDuplicateSamples() {
+	if b.remoteWriter == nil {
+		return
+	}
+	if resolved := b.remoteWriter.DuplicateSamples(); resolved > 0 {
+		log.Info("Source samples with duplicate timestamps were resolved", map[string]interface{}{
+			"samples": resolved,
+			"policy":  b.config.Benchmark.DuplicateTimestamps,
+		})
+	}
+}
+
+// reportOldSamples warns when samples fell behind the max_sample_age window
+func (b *Benchmarker) reportOldSamples() {
+	if b.remoteWriter == nil {
+		return
+	}
+	clamped, dropped := b.remoteWriter.OldSamples()
+	if clamped == 0 && dropped == 0 {
+		return
+	}
+	log.Warn("Samples were older than max_sample_age", map[string]interface{}{
+		"clamped_samples": clamped,
+		"dropped_samples": dropped,
+		"max_sample_age":  b.config.Benchmark.OldSamples.MaxSampleAge,
+	})
+}
+
+// reportUnorderedSeries warns when the ordering check dropped series
+func (b *Benchmarker) reportUnorderedSeries() {
+	if b.remoteWriter == nil {
+		return
+	}
+	if dropped := b.remoteWriter.UnorderedSeries(); dropped > 0 {
+		log.Warn("Series with out-of-order samples were dropped", map[string]interface{}{
+			"dropped_series": dropped,
+		})
+	}
+}
+
+// reportSeriesCap warns about replicas skipped because of max_total_series
+// and reports series truncated to max_samples_per_series
+func (b *Benchmarker) reportSeriesCap() {
+	if skipped := b.cappedSeries.Load(); skipped > 0 {
+		log.Warn("Series were not replicated because max_total_series was reached", map[string]interface{}{
+			"max_total_series": b.config.Benchmark.MaxTotalSeries,
+			"skipped_series":   skipped,
+		})
+	}
+	if truncated := b.truncatedSeries.Load(); truncated > 0 {
+		log.Info("Series were truncated to their most recent samples", map[string]interface{}{
+			"max_samples_per_series": b.config.Benchmark.MaxSamplesPerSeries,
+			"truncated_series":       truncated,
+		})
+	}
+}
+
+// ComplianceCheck probes the remote write endpoint with crafted requests and
+// logs the resulting compliance profile of the target
+func (b *Benchmarker) ComplianceCheck(ctx context.Context) ([]writer.ComplianceResult, error) {
+	if b.remoteWriter == nil {
+		return nil, fmt.Errorf("compliance check requires a remote writer (not available in dry-run mode)")
+	}
+
+	results := b.remoteWriter.ComplianceCheck(ctx)
+	for _, r := range results {
+		fields := map[string]interface{}{
+			"probe":       r.Probe,
+			"expect":      r.Expect,
+			"status_code": r.StatusCode,
+			"accepted":    r.Accepted,
+			"as_expected": r.AsExpected,
+			"latency_ms":  r.Latency.Milliseconds(),
+		}
+		if r.Error != "" {
+			fields["error"] = r.Error
+		}
+		if r.Body != "" {
+			fields["body"] = r.Body
+		}
+		log.Info("Compliance probe result", fields)
+	}
+
+	return results, ctx.Err()
+}
+
+// pointsPerSeries returns how many points a range query returns per series
+// for the given range and step
+func pointsPerSeries(queryRange, step time.Duration) int64 {
+	if step <= 0 {
+		return 0
+	}
+	return int64(queryRange/step) + 1
+}
+
+// logProjectedPoints logs the expected data volume derived from the query
+// range, step and replica count as a sanity check before the run. The
+// number of series per metric is only known after querying, so the total is
+// a lower bound assuming one series per metric.
+func (b *Benchmarker) logProjectedPoints(metricCount int) {
+	points := pointsPerSeries(b.config.QueryRange(), b.config.SampleStep())
+	replicas := int64(replicaCount(b.config))
+
+	log.Info("Projected data volume", map[string]interface{}{
+		"points_per_series":            points,
+		"replicas":                     replicas,
+		"points_per_source_series":     points * replicas,
+		"metrics":                      metricCount,
+		"projected_total_points_lower": points * replicas * int64(metricCount),
+	})
+}
@@ -0,0 +1,52 @@
+package benchmarker
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+
+	"promfire/internal/config"
+)
+
+// sampleMetrics selects the sample_metrics subset of metrics, keeping their
+// order. Every metric is ranked by a hash of the seed and its name and the
+// lowest ranked ones are kept, so a seed selects the same metrics on every
+// run and mostly the same ones when metrics come and go.
+func sampleMetrics(metrics []string, cfg config.SampleMetrics, seed int64) []string {
+	count := cfg.Count
+	if cfg.Percent > 0 {
+		count = int(math.Ceil(float64(len(metrics)) * cfg.Percent / 100))
+	}
+	if count <= 0 || count >= len(metrics) {
+		return metrics
+	}
+
+	ranks := make(map[string]uint64, len(metrics))
+	ranked := make([]string, len(metrics))
+	copy(ranked, metrics)
+	for _, name := range ranked {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(strconv.FormatInt(seed, 10)))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(name))
+		ranks[name] = h.Sum64()
+	}
+	sort.Slice(ranked, func(i, j int) bool { return ranks[ranked[i]] < ranks[ranked[j]] })
+
+	chosen := make(map[string]bool, count)
+	for _, name := range ranked[:count] {
+		chosen[name] = true
+	}
+	sampled := make([]string, 0, count)
+	for _, name := range metrics {
+		if chosen[name] {
+			sampled = append(sampled, name)
+		}
+	}
+
+	log.Debug("Sampled metrics", map[string]interface{}{
+		"metrics": sampled,
+	})
+	return sampled
+}
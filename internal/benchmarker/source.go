@@ -0,0 +1,203 @@
+package benchmarker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"promfire/internal/config"
+	"promfire/internal/writer"
+)
+
+// Source types selectable with source.type
+const (
+	SourcePrometheus = "prometheus"
+	SourceFile       = "file"
+	SourceSynthetic  = "synthetic"
+)
+
+// SeriesSource provides the metrics and series that a run replicates
+type SeriesSource interface {
+	// Metrics returns the names of all metrics the source can provide
+	Metrics(ctx context.Context) ([]string, error)
+	// Series calls fn for every series of metricName in [start, end] at step
+	Series(ctx context.Context, metricName string, start, end time.Time, step time.Duration, fn func(Series) error) error
+}
+
+// metadataSource is implemented by sources that can provide metric metadata
+type metadataSource interface {
+	Metadata(ctx context.Context) (map[string]writer.MetricMetadata, error)
+}
+
+// pingSource is implemented by sources with a connectivity check for Preflight
+type pingSource interface {
+	Ping(ctx context.Context) error
+}
+
+// newSeriesSource builds the source selected in the config
+func newSeriesSource(cfg *config.Config, b *Benchmarker) (SeriesSource, error) {
+	switch cfg.Source.Type {
+	case SourcePrometheus:
+		if cfg.Benchmark.Discovery == config.DiscoverySeries {
+			return newSeriesDiscoverySource(cfg, b)
+		}
+		return &prometheusSource{b: b}, nil
+	case SourceFile:
+		return newFileSource(cfg.Source.Path, cfg.Source.Format, b.seriesMatchers)
+	case SourceSynthetic:
+		return newSyntheticSource(cfg.Source.Synthetic, cfg.Benchmark.Seed, b.valuePattern), nil
+	}
+	return nil, fmt.Errorf("unknown source type %q", cfg.Source.Type)
+}
+
+// prometheusSource queries the Prometheus HTTP API
+type prometheusSource struct {
+	b *Benchmarker
+}
+
+func (s *prometheusSource) Metrics(ctx context.Context) ([]string, error) {
+	return s.b.discoverMetrics(ctx)
+}
+
+func (s *prometheusSource) Series(ctx context.Context, metricName string, start, end time.Time, step time.Duration, fn func(Series) error) error {
+	return s.b.streamMetricRange(ctx, s.b.seriesQuery(metricName), start, end, step, fn)
+}
+
+func (s *prometheusSource) Metadata(ctx context.Context) (map[string]writer.MetricMetadata, error) {
+	return s.b.queryMetadata(ctx)
+}
+
+func (s *prometheusSource) Ping(ctx context.Context) error {
+	return s.b.pingQuery(ctx)
+}
+
+// Formats of the file source
+const (
+	FileFormatNDJSON      = "ndjson"
+	FileFormatOpenMetrics = "openmetrics"
+)
+
+// fileSource replays series from a file, which is either newline-delimited
+// JSON with one series per line, in the same form as a query_range result
+// entry:
+//
+//	{"metric":{"__name__":"up","job":"node"},"values":[[1700000000,"1"]]}
+//
+// or a Prometheus/OpenMetrics text exposition such as a captured /metrics
+// scrape, whose samples are replayed at the current wall-clock time.
+//
+// The whole file is loaded up front. Series are replayed as-is, so the query
+// range and step do not apply; series_selector matchers still filter them.
+type fileSource struct {
+	matchers []*labels.Matcher
+	metrics  []string
+	series   map[string][]Series
+	metadata map[string]writer.MetricMetadata
+	// liveTimestamps replaces sample timestamps with the time of replay
+	liveTimestamps bool
+}
+
+func newFileSource(path, format string, matchers []*labels.Matcher) (*fileSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening series file: %w", err)
+	}
+	defer f.Close()
+
+	src := &fileSource{
+		matchers: matchers,
+		series:   make(map[string][]Series),
+		metadata: make(map[string]writer.MetricMetadata),
+	}
+	switch format {
+	case FileFormatOpenMetrics:
+		src.liveTimestamps = true
+		err = src.readOpenMetrics(f, path)
+	default:
+		err = src.readNDJSON(f, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+// readNDJSON loads one JSON series per line
+func (s *fileSource) readNDJSON(r io.Reader, path string) error {
+	scanner := bufio.NewScanner(r)
+	// Long series don't fit the default 64KiB line limit
+	scanner.Buffer(make([]byte, 0, 1<<20), 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var series Series
+		if err := json.Unmarshal(scanner.Bytes(), &series); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if series.Metric[labels.MetricName] == "" {
+			return fmt.Errorf("%s:%d: series has no %s label", path, line, labels.MetricName)
+		}
+		s.add(series)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading series file: %w", err)
+	}
+	return nil
+}
+
+// add stores a series unless the series_selector matchers reject it
+func (s *fileSource) add(series Series) {
+	if !matchesAll(s.matchers, series.Metric) {
+		return
+	}
+	name := series.Metric[labels.MetricName]
+	if _, ok := s.series[name]; !ok {
+		s.metrics = append(s.metrics, name)
+	}
+	s.series[name] = append(s.series[name], series)
+}
+
+func (s *fileSource) Metrics(context.Context) ([]string, error) {
+	return s.metrics, nil
+}
+
+func (s *fileSource) Series(ctx context.Context, metricName string, _, _ time.Time, _ time.Duration, fn func(Series) error) error {
+	now := float64(time.Now().UnixMilli()) / 1000
+	for _, series := range s.series[metricName] {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if s.liveTimestamps {
+			values := make([][]interface{}, len(series.Values))
+			for i, v := range series.Values {
+				values[i] = []interface{}{now, v[1]}
+			}
+			series.Values = values
+		}
+		if err := fn(series); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSource) Metadata(context.Context) (map[string]writer.MetricMetadata, error) {
+	return s.metadata, nil
+}
+
+// matchesAll reports whether a label set satisfies every matcher
+func matchesAll(matchers []*labels.Matcher, metric map[string]string) bool {
+	for _, m := range matchers {
+		if !m.Matches(metric[m.Name]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package benchmarker
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Stats summarizes what a run has written so far
+type Stats struct {
+	Series        int64         `json:"series"`
+	Samples       int64         `json:"samples"`
+	BytesSent     int64         `json:"bytes_sent"`
+	FailedBatches int64         `json:"failed_batches"`
+	Elapsed       time.Duration `json:"elapsed"`
+}
+
+// runStats holds the counters behind Stats, updated concurrently by workers
+type runStats struct {
+	metrics      atomic.Int64
+	totalMetrics atomic.Int64
+	series       atomic.Int64
+	samples      atomic.Int64
+	startedAt    atomic.Int64 // unix nanoseconds
+	endedAt      atomic.Int64 // unix nanoseconds, 0 while running
+}
+
+// start marks the beginning of a run
+func (s *runStats) start() {
+	s.startedAt.Store(time.Now().UnixNano())
+	s.endedAt.Store(0)
+}
+
+// finish marks the end of a run
+func (s *runStats) finish() {
+	s.endedAt.Store(time.Now().UnixNano())
+}
+
+// elapsed returns the run duration, measured up to now while still running
+func (s *runStats) elapsed() time.Duration {
+	started := s.startedAt.Load()
+	if started == 0 {
+		return 0
+	}
+	ended := s.endedAt.Load()
+	if ended == 0 {
+		ended = time.Now().UnixNano()
+	}
+	return time.Duration(ended - started)
+}
+
+// Stats returns a snapshot of the run statistics
+func (b *Benchmarker) Stats() Stats {
+	stats := Stats{
+		Series:  b.stats.series.Load(),
+		Samples: b.stats.samples.Load(),
+		Elapsed: b.stats.elapsed(),
+	}
+	if b.remoteWriter != nil {
+		stats.BytesSent = b.remoteWriter.BytesSent()
+		stats.FailedBatches = b.remoteWriter.FailedBatches()
+	}
+	return stats
+}
+
+// logStats logs the run summary as a structured entry
+func (b *Benchmarker) logStats() {
+	stats := b.Stats()
+	samplesPerSecond := 0.0
+	if stats.Elapsed > 0 {
+		samplesPerSecond = float64(stats.Samples) / stats.Elapsed.Seconds()
+	}
+
+	fields := map[string]interface{}{
+		"series":             stats.Series,
+		"samples":            stats.Samples,
+		"bytes_sent":         stats.BytesSent,
+		"failed_batches":     stats.FailedBatches,
+		"elapsed_seconds":    stats.Elapsed.Seconds(),
+		"samples_per_second": samplesPerSecond,
+	}
+	if b.remoteWriter != nil {
+		requests := b.remoteWriter.Stats()
+		fields["requests"] = requests.Requests
+		fields["status_codes"] = requests.StatusCodes
+		fields["transport_errors"] = requests.TransportErrors
+		fields["latency_p50_ms"] = requests.P50.Milliseconds()
+		fields["latency_p90_ms"] = requests.P90.Milliseconds()
+		fields["latency_p99_ms"] = requests.P99.Milliseconds()
+		fields["latency_max_ms"] = requests.Max.Milliseconds()
+		if requests.Requests > 0 {
+			fields["bytes_per_request"] = requests.Bytes / requests.Requests
+		}
+	}
+
+	if b.concurrency != nil {
+		fields["write_concurrency"] = b.concurrency.current()
+	}
+
+	log.Info("Benchmark summary", fields)
+}
+
+// startProgressLogger logs progress every statsInterval until the returned
+// stop function is called or ctx is cancelled. The stop function waits for
+// the logging goroutine to exit.
+func (b *Benchmarker) startProgressLogger(ctx context.Context) func() {
+	if b.statsInterval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	started := b.goroutines.tryGo(func() {
+		defer close(stopped)
+		ticker := time.NewTicker(b.statsInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				b.logProgress()
+			}
+		}
+	})
+	if !started {
+		log.Warn("Progress logging disabled, max_goroutines reached")
+		return func() {}
+	}
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// logProgress logs the current progress with throughput and estimated time remaining
+func (b *Benchmarker) logProgress() {
+	processed := b.stats.metrics.Load()
+	total := b.stats.totalMetrics.Load()
+	samples := b.stats.samples.Load()
+	elapsed := b.stats.elapsed()
+
+	fields := map[string]interface{}{
+		"metrics_processed": processed,
+		"metrics_total":     total,
+		"samples_written":   samples,
+		"elapsed_seconds":   elapsed.Seconds(),
+	}
+	if elapsed > 0 {
+		fields["samples_per_second"] = float64(samples) / elapsed.Seconds()
+	}
+	if processed > 0 && total > processed {
+		remaining := time.Duration(float64(elapsed) / float64(processed) * float64(total-processed))
+		fields["eta_seconds"] = remaining.Seconds()
+	}
+
+	log.Info("Benchmark progress", fields)
+}
@@ -0,0 +1,166 @@
+package benchmarker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"promfire/internal/writer"
+)
+
+// Series is a single time series from a Prometheus range query result.
+// Native histogram series carry Histograms instead of Values.
+type Series struct {
+	Metric     map[string]string       `json:"metric"`
+	Values     [][]interface{}         `json:"values"`
+	Histograms []writer.HistogramPoint `json:"histograms"`
+	// Value and Histogram hold the single sample of an instant query result
+	Value     []interface{}          `json:"value,omitempty"`
+	Histogram *writer.HistogramPoint `json:"histogram,omitempty"`
+}
+
+// decodeQueryResponse stream-decodes a Prometheus query API response and
+// calls fn for every series in data.result as soon as it has been parsed,
+// so only one series is held in memory at a time
+func decodeQueryResponse(r io.Reader, fn func(Series) error) error {
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	var status, errorType, errorMsg string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("reading response key: %w", err)
+		}
+
+		switch key {
+		case "status":
+			if err := dec.Decode(&status); err != nil {
+				return fmt.Errorf("decoding status: %w", err)
+			}
+		case "errorType":
+			if err := dec.Decode(&errorType); err != nil {
+				return fmt.Errorf("decoding errorType: %w", err)
+			}
+		case "error":
+			if err := dec.Decode(&errorMsg); err != nil {
+				return fmt.Errorf("decoding error: %w", err)
+			}
+		case "data":
+			if err := decodeResultData(dec, fn); err != nil {
+				return err
+			}
+		default:
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+		}
+	}
+
+	if status != "success" {
+		return fmt.Errorf("%w: status=%q errorType=%q error=%q", errQueryFailed, status, errorType, errorMsg)
+	}
+	return nil
+}
+
+// decodeResultData walks the data object and streams each element of its
+// result array. Scalar and string results, which Prometheus sends after their
+// resultType as a single [timestamp, value] pair, become one series without
+// labels.
+func decodeResultData(dec *json.Decoder, fn func(Series) error) error {
+	if ok, err := expectDelimOrNull(dec, '{'); !ok {
+		return err
+	}
+
+	var resultType string
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("reading data key: %w", err)
+		}
+
+		if key == "resultType" {
+			if err := dec.Decode(&resultType); err != nil {
+				return fmt.Errorf("decoding resultType: %w", err)
+			}
+			continue
+		}
+		if key != "result" {
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if resultType == "scalar" || resultType == "string" {
+			var value []interface{}
+			if err := dec.Decode(&value); err != nil {
+				return fmt.Errorf("decoding %s result: %w", resultType, err)
+			}
+			if err := fn(Series{Metric: map[string]string{}, Value: value}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if ok, err := expectDelimOrNull(dec, '['); err != nil {
+			return err
+		} else if !ok {
+			continue
+		}
+		for dec.More() {
+			var series Series
+			if err := dec.Decode(&series); err != nil {
+				return fmt.Errorf("decoding series: %w", err)
+			}
+			if err := fn(series); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(dec, '}')
+}
+
+// expectDelim consumes the next token and checks that it is the given delimiter
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("parsing response: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("parsing response: expected %q, got %v", delim, tok)
+	}
+	return nil
+}
+
+// expectDelimOrNull consumes the next token like expectDelim but also
+// accepts null, reporting whether the delimiter was found
+func expectDelimOrNull(dec *json.Decoder, delim json.Delim) (bool, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return false, fmt.Errorf("parsing response: %w", err)
+	}
+	if tok == nil {
+		return false, nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return false, fmt.Errorf("parsing response: expected %q, got %v", delim, tok)
+	}
+	return true, nil
+}
+
+// skipValue discards the next JSON value without keeping it in memory
+func skipValue(dec *json.Decoder) error {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return fmt.Errorf("skipping value: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package benchmarker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// decodeAll decodes a query response and returns the series it streamed
+func decodeAll(t *testing.T, body string) ([]Series, error) {
+	t.Helper()
+
+	var series []Series
+	err := decodeQueryResponse(strings.NewReader(body), func(s Series) error {
+		series = append(series, s)
+		return nil
+	})
+	return series, err
+}
+
+func TestDecodeQueryResponseMatrix(t *testing.T) {
+	series, err := decodeAll(t, `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"__name__":"up","job":"a"},"values":[[1700000000,"1"],[1700000015,"0"]]},
+		{"metric":{"__name__":"up","job":"b"},"values":[[1700000000,"1"]]}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("got %d series, want 2", len(series))
+	}
+	if series[0].Metric["job"] != "a" || len(series[0].Values) != 2 {
+		t.Errorf("first series = %+v", series[0])
+	}
+}
+
+func TestDecodeQueryResponseNullData(t *testing.T) {
+	for name, body := range map[string]string{
+		"data":   `{"status":"success","data":null}`,
+		"result": `{"status":"success","data":{"resultType":"matrix","result":null}}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			series, err := decodeAll(t, body)
+			if err != nil {
+				t.Fatalf("decoding null %s: %v", name, err)
+			}
+			if len(series) != 0 {
+				t.Errorf("got %d series, want none", len(series))
+			}
+		})
+	}
+}
+
+func TestDecodeQueryResponseError(t *testing.T) {
+	_, err := decodeAll(t, `{"status":"error","errorType":"bad_data","error":"parse error","data":null}`)
+	if err == nil || !strings.Contains(err.Error(), "parse error") {
+		t.Fatalf("err = %v, want the query error", err)
+	}
+}
+
+func TestDecodeQueryResponseVector(t *testing.T) {
+	series, err := decodeAll(t, `{"status":"success","data":{"resultType":"vector","result":[
+		{"metric":{"__name__":"up","job":"a"},"value":[1700000000.5,"1"]},
+		{"metric":{"__name__":"up","job":"b"},"value":[1700000000.5,"0"]}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 {
+		t.Fatalf("got %d series, want 2", len(series))
+	}
+	if series[1].Metric["job"] != "b" || len(series[1].Values) != 0 || sampleValue(series[1].Value) != "0" {
+		t.Errorf("second series = %+v, want its single value", series[1])
+	}
+}
+
+func TestDecodeQueryResponseScalar(t *testing.T) {
+	series, err := decodeAll(t, `{"status":"success","data":{"resultType":"scalar","result":[1700000000.5,"42"]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 {
+		t.Fatalf("got %d series, want 1", len(series))
+	}
+	if len(series[0].Metric) != 0 || sampleValue(series[0].Value) != "42" {
+		t.Errorf("scalar series = %+v, want a single unlabeled value 42", series[0])
+	}
+}
+
+// largeQueryResponse returns a matrix response of series series with
+// samples samples each, like a high-cardinality metric over a day
+func largeQueryResponse(series, samples int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"status":"success","data":{"resultType":"matrix","result":[`)
+	for i := 0; i < series; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"metric":{"__name__":"http_requests_total","instance":"host-%d:9090","job":"api","code":"200"},"values":[`, i)
+		for j := 0; j < samples; j++ {
+			if j > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, `[%d,"%d"]`, 1700000000+j*60, i*samples+j)
+		}
+		b.WriteString(`]}`)
+	}
+	b.WriteString(`]}}`)
+	return b.Bytes()
+}
+
+// liveHeap returns the bytes of reachable heap objects
+func liveHeap() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// BenchmarkDecodeQueryResponse compares stream-decoding a large response
+// against buffering and unmarshalling it whole. peak-heap-B is the most
+// reachable memory held while decoding on top of the raw body: the buffered
+// decode keeps every series alive, the streamed one a single series.
+func BenchmarkDecodeQueryResponse(b *testing.B) {
+	const series = 2000
+	body := largeQueryResponse(series, 1440)
+
+	b.Run("streamed", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		var peak uint64
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			base := liveHeap()
+			b.StartTimer()
+
+			decoded := 0
+			err := decodeQueryResponse(bytes.NewReader(body), func(Series) error {
+				decoded++
+				if decoded%(series/4) == 0 {
+					b.StopTimer()
+					if live := liveHeap(); live > base && live-base > peak {
+						peak = live - base
+					}
+					b.StartTimer()
+				}
+				return nil
+			})
+			if err != nil || decoded != series {
+				b.Fatalf("decoded %d series: %v", decoded, err)
+			}
+		}
+		b.ReportMetric(float64(peak), "peak-heap-B")
+	})
+
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		var peak uint64
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			base := liveHeap()
+			b.StartTimer()
+
+			data, err := io.ReadAll(bytes.NewReader(body))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var resp struct {
+				Status string `json:"status"`
+				Data   struct {
+					Result []Series `json:"result"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(data, &resp); err != nil || len(resp.Data.Result) != series {
+				b.Fatalf("decoded %d series: %v", len(resp.Data.Result), err)
+			}
+
+			b.StopTimer()
+			if live := liveHeap(); live > base && live-base > peak {
+				peak = live - base
+			}
+			runtime.KeepAlive(data)
+			runtime.KeepAlive(resp)
+			b.StartTimer()
+		}
+		b.ReportMetric(float64(peak), "peak-heap-B")
+	})
+}
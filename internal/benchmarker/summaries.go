@@ -0,0 +1,53 @@
+package benchmarker
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// summaryFamilies maps the _sum and _count series of classic summary and
+// histogram families to their family name. Replicating the two halves with
+// independent values would break rate(x_sum) / rate(x_count), so their
+// replicas are derived from the family instead of the metric name.
+type summaryFamilies map[string]string
+
+// detectSummaryFamilies finds families among discovered metric names: every
+// base name for which both base_sum and base_count exist
+func detectSummaryFamilies(metrics []string) summaryFamilies {
+	names := make(map[string]struct{}, len(metrics))
+	for _, name := range metrics {
+		names[name] = struct{}{}
+	}
+
+	families := make(summaryFamilies)
+	for _, name := range metrics {
+		base, ok := strings.CutSuffix(name, "_sum")
+		if !ok {
+			continue
+		}
+		if _, ok := names[base+"_count"]; ok {
+			families[name] = base
+			families[base+"_count"] = base
+		}
+	}
+	return families
+}
+
+// familyLabels returns a replica's labels with the metric name replaced by
+// its family, so the _sum and _count replicas of a series share a seed
+func familyLabels(replicaLabels map[string]string, family string) map[string]string {
+	out := make(map[string]string, len(replicaLabels))
+	for k, v := range replicaLabels {
+		out[k] = v
+	}
+	out["__name__"] = family
+	return out
+}
+
+// coordinatedJitter returns a transform scaling every sample by one factor
+// in [1-jitter, 1+jitter] drawn from rng. Both halves of a family draw the
+// same factor, so the pair stays monotonic and keeps its ratio.
+func coordinatedJitter(rng *rand.Rand, jitter float64) func(float64) float64 {
+	factor := 1 + jitter*(2*rng.Float64()-1)
+	return func(v float64) float64 { return v * factor }
+}
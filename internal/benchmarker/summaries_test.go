@@ -0,0 +1,80 @@
+package benchmarker
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+)
+
+func TestDetectSummaryFamilies(t *testing.T) {
+	got := detectSummaryFamilies([]string{"rpc_seconds_sum", "rpc_seconds_count", "lonely_sum", "up", "http_count"})
+	want := summaryFamilies{"rpc_seconds_sum": "rpc_seconds", "rpc_seconds_count": "rpc_seconds"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("families = %v, want %v", got, want)
+	}
+}
+
+func TestSummaryPairReplicasStayCoordinated(t *testing.T) {
+	now := time.Now()
+	count := sourceSeries("rpc_seconds_count", map[string]string{"job": "api"}, 5, now)
+	sum := sourceSeries("rpc_seconds_sum", map[string]string{"job": "api"}, 5, now)
+	for i := range sum.Samples {
+		sum.Samples[i].Value = 2 * count.Samples[i].Value
+	}
+	gauge := sourceSeries("temperature", map[string]string{"job": "api"}, 5, now)
+	prom := testutil.NewFakePrometheus(count, sum, gauge)
+	defer prom.Close()
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	cfg := testConfig(t, prom, recv, `  replication_factor: 3
+  timestamp_mode: preserve
+  seed: 7
+  replica_variation: 0.3
+  value_jitter: 0.2
+  value_arrangement: shuffle
+  value_pattern:
+    type: sine
+    start: 100
+    amplitude: 20
+`, "")
+	b := newTestBenchmarker(t, cfg, Options{})
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	factors := make(map[float64]bool)
+	for i := 0; i < 3; i++ {
+		replica := map[string]string{"job": "api", "benchmark_replica": fmt.Sprintf("replica-%d", i)}
+		replica["__name__"] = "rpc_seconds_count"
+		counts := recv.Samples(replica)
+		replica["__name__"] = "rpc_seconds_sum"
+		sums := recv.Samples(replica)
+		if len(counts) != 5 || len(sums) != 5 {
+			t.Fatalf("replica %d: got %d count and %d sum samples, want 5 each", i, len(counts), len(sums))
+		}
+
+		for j := range counts {
+			if sums[j].Value != 2*counts[j].Value {
+				t.Errorf("replica %d sample %d: sum %g is not twice count %g", i, j, sums[j].Value, counts[j].Value)
+			}
+			if j > 0 && counts[j].Value < counts[j-1].Value {
+				t.Errorf("replica %d: count decreased at sample %d", i, j)
+			}
+		}
+		factors[counts[0].Value/count.Samples[0].Value] = true
+	}
+	if len(factors) < 2 {
+		t.Error("all replicas of the pair share one factor, want them varied")
+	}
+
+	// Unpaired metrics still get the value pattern
+	replica := map[string]string{"__name__": "temperature", "job": "api", "benchmark_replica": "replica-0"}
+	if samples := recv.Samples(replica); len(samples) == 0 || samples[0].Value == gauge.Samples[0].Value {
+		t.Errorf("temperature samples = %v, want generated values", samples)
+	}
+}
@@ -0,0 +1,142 @@
+package benchmarker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"promfire/internal/config"
+	"promfire/internal/writer"
+)
+
+// syntheticSource generates random walk series, or series of the configured
+// value_pattern, without any source Prometheus. Series are spread round-robin
+// across MetricCount metrics and sampled at every query step over the query
+// range.
+//
+// With native_histogram set every series carries native histogram samples
+// of the configured schema instead of values.
+//
+// With a churn rate every series slot lives for 1/churn_rate steps before it
+// is replaced by a series with a new series_id, the slots staggered so that
+// churn_rate of all series are replaced on every step.
+type syntheticSource struct {
+	cfg        config.Synthetic
+	seed       int64
+	generators valueGeneratorFunc
+}
+
+// newSyntheticSource generates series with generators, falling back to a
+// random walk of walk_start and walk_step when it is nil
+func newSyntheticSource(cfg config.Synthetic, seed int64, generators valueGeneratorFunc) *syntheticSource {
+	if generators == nil {
+		generators = randomWalkPattern(cfg.WalkStart, cfg.WalkStep)
+	}
+	return &syntheticSource{cfg: cfg, seed: seed, generators: generators}
+}
+
+func (s *syntheticSource) Metrics(context.Context) ([]string, error) {
+	if s.cfg.MetricCount == 1 {
+		return []string{s.cfg.MetricName}, nil
+	}
+	metrics := make([]string, s.cfg.MetricCount)
+	for i := range metrics {
+		metrics[i] = fmt.Sprintf("%s_%d", s.cfg.MetricName, i+1)
+	}
+	return metrics, nil
+}
+
+func (s *syntheticSource) Series(ctx context.Context, metricName string, start, end time.Time, step time.Duration, fn func(Series) error) error {
+	metric, ok := s.metricIndex(metricName)
+	if !ok {
+		return nil
+	}
+
+	steps := int(end.Sub(start)/step) + 1
+	for slot := metric; slot < s.cfg.SeriesCount; slot += s.cfg.MetricCount {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var series Series
+		var gen ValueGenerator
+		var hist *histogramGenerator
+		generation := -1
+		for k := 0; k < steps; k++ {
+			if g := s.generation(slot, k); g != generation {
+				if len(series.Values)+len(series.Histograms) > 0 {
+					if err := fn(series); err != nil {
+						return err
+					}
+				}
+				generation = g
+				series = Series{Metric: s.labels(metricName, slot, g)}
+				seed := labelSetSeed(s.seed, series.Metric)
+				if s.cfg.NativeHistogram != nil {
+					hist = newHistogramGenerator(*s.cfg.NativeHistogram, seed)
+				} else {
+					gen = s.generators(seed)
+				}
+			}
+
+			ts := start.Add(time.Duration(k) * step)
+			timestamp := float64(ts.UnixMilli()) / 1000
+			if hist != nil {
+				series.Histograms = append(series.Histograms, writer.HistogramPoint{Timestamp: timestamp, Histogram: hist.Next()})
+				continue
+			}
+			series.Values = append(series.Values, []interface{}{
+				timestamp,
+				formatFloat(gen.Next(ts)),
+			})
+		}
+		if len(series.Values)+len(series.Histograms) > 0 {
+			if err := fn(series); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// metricIndex returns the position of metricName among the generated metrics
+func (s *syntheticSource) metricIndex(metricName string) (int, bool) {
+	if s.cfg.MetricCount == 1 {
+		return 0, metricName == s.cfg.MetricName
+	}
+	for i := 0; i < s.cfg.MetricCount; i++ {
+		if metricName == fmt.Sprintf("%s_%d", s.cfg.MetricName, i+1) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// generation returns how many times a slot has churned by the given step
+func (s *syntheticSource) generation(slot, step int) int {
+	if s.cfg.ChurnRate <= 0 {
+		return 0
+	}
+	lifetime := 1 / s.cfg.ChurnRate
+	offset := float64(slot) * lifetime / float64(s.cfg.SeriesCount)
+	return int(math.Floor((float64(step) + offset) / lifetime))
+}
+
+// labels builds the label set of one generation of a slot. Extra labels
+// label_1..label_N cycle through 10, 20, ... values so their cardinality
+// grows with the label index.
+func (s *syntheticSource) labels(metricName string, slot, generation int) map[string]string {
+	labels := map[string]string{
+		"__name__":  metricName,
+		"series_id": strconv.Itoa(slot),
+	}
+	if generation > 0 {
+		labels["series_id"] = fmt.Sprintf("%d-%d", slot, generation)
+	}
+	for j := 1; j <= s.cfg.LabelsPerSeries; j++ {
+		labels[fmt.Sprintf("label_%d", j)] = fmt.Sprintf("value-%d", slot%(10*j))
+	}
+	return labels
+}
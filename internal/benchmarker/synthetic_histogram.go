@@ -0,0 +1,77 @@
+package benchmarker
+
+import (
+	"math"
+	"math/rand"
+
+	"promfire/internal/config"
+	"promfire/internal/writer"
+)
+
+// Query API bucket boundary rules of the generated buckets
+const (
+	boundaryOpenLeft   = 0
+	boundaryClosedBoth = 3
+)
+
+// histogramGenerator accumulates observations into a native histogram of
+// the configured schema. Points use the query API bucket form, from which
+// the writer recovers the schema and bucket indexes. The populated buckets
+// are the indexes 1..buckets, index i spanning (base^(i-1), base^i] with
+// base = 2^(2^-schema).
+type histogramGenerator struct {
+	cfg    config.SyntheticHistogram
+	bounds []float64
+	counts []float64
+	zero   float64
+	count  float64
+	sum    float64
+	rng    *rand.Rand
+}
+
+func newHistogramGenerator(cfg config.SyntheticHistogram, seed int64) *histogramGenerator {
+	// Exp2 keeps the bounds exact powers of two for schemas <= 0
+	width := math.Pow(2, -float64(cfg.SchemaOrDefault()))
+	bounds := make([]float64, cfg.Buckets+1)
+	for i := range bounds {
+		bounds[i] = math.Exp2(float64(i) * width)
+	}
+	return &histogramGenerator{
+		cfg:    cfg,
+		bounds: bounds,
+		counts: make([]float64, cfg.Buckets),
+		rng:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Next observes one step of values and returns the cumulative histogram.
+// Observations are spread evenly across the buckets, the remainder landing
+// in random ones, each valued at its bucket's geometric midpoint.
+func (g *histogramGenerator) Next() writer.APIHistogram {
+	zero := int(math.Round(float64(g.cfg.Observations) * g.cfg.ZeroFraction))
+	rest := g.cfg.Observations - zero
+	share := rest / len(g.counts)
+	for i := range g.counts {
+		g.observe(i, share)
+	}
+	for k := 0; k < rest%len(g.counts); k++ {
+		g.observe(g.rng.Intn(len(g.counts)), 1)
+	}
+	g.zero += float64(zero)
+	g.count += float64(g.cfg.Observations)
+
+	buckets := make([][]interface{}, 0, len(g.counts)+1)
+	if threshold := g.cfg.ZeroThresholdOrDefault(); threshold > 0 || g.zero > 0 {
+		buckets = append(buckets, []interface{}{float64(boundaryClosedBoth), formatFloat(-threshold), formatFloat(threshold), formatFloat(g.zero)})
+	}
+	for i, c := range g.counts {
+		buckets = append(buckets, []interface{}{float64(boundaryOpenLeft), formatFloat(g.bounds[i]), formatFloat(g.bounds[i+1]), formatFloat(c)})
+	}
+	return writer.APIHistogram{Count: formatFloat(g.count), Sum: formatFloat(g.sum), Buckets: buckets}
+}
+
+// observe adds n observations to bucket i
+func (g *histogramGenerator) observe(i, n int) {
+	g.counts[i] += float64(n)
+	g.sum += float64(n) * math.Sqrt(g.bounds[i]*g.bounds[i+1])
+}
@@ -0,0 +1,74 @@
+package benchmarker
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"promfire/internal/benchmarker/testutil"
+)
+
+func TestSyntheticNativeHistograms(t *testing.T) {
+	tests := []struct {
+		name          string
+		histogram     string
+		schema        int32
+		buckets       int
+		zeroThreshold float64
+	}{
+		{"defaults", "", 3, 10, 2.938735877055719e-39},
+		{"high resolution", "schema: 8\n      buckets: 40\n      zero_threshold: 0.001\n", 8, 40, 0.001},
+		{"low resolution", "schema: -4\n      buckets: 5\n      zero_threshold: 0\n      zero_fraction: 0.1\n", -4, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recv := testutil.NewFakeReceiver()
+			defer recv.Close()
+
+			histogram := "{}"
+			if tt.histogram != "" {
+				histogram = "\n      " + tt.histogram
+			}
+			cfg := testConfig(t, nil, recv,
+				"  replication_factor: 1\n  support_native_histograms: true\n  query_range: 5m\n  query_step: 1m\n",
+				"source:\n  type: synthetic\n  synthetic:\n    series_count: 2\n    native_histogram: "+histogram+"\n")
+			b := newTestBenchmarker(t, cfg, Options{})
+			if err := b.Run(context.Background()); err != nil {
+				t.Fatalf("run: %v", err)
+			}
+
+			series := recv.TimeSeries()
+			if len(series) == 0 {
+				t.Fatal("received no series")
+			}
+			for _, ts := range series {
+				if len(ts.Samples) != 0 || len(ts.Histograms) == 0 {
+					t.Errorf("series has %d float samples and %d histograms, want only histograms", len(ts.Samples), len(ts.Histograms))
+				}
+				var prevCount float64
+				for _, h := range ts.Histograms {
+					if h.Schema != tt.schema {
+						t.Fatalf("schema = %d, want %d", h.Schema, tt.schema)
+					}
+					if h.ZeroThreshold != tt.zeroThreshold {
+						t.Errorf("zero threshold = %g, want %g", h.ZeroThreshold, tt.zeroThreshold)
+					}
+					if len(h.PositiveSpans) != 1 || h.PositiveSpans[0].Offset != 1 || int(h.PositiveSpans[0].Length) != tt.buckets {
+						t.Fatalf("positive spans = %+v, want one span of %d buckets from index 1", h.PositiveSpans, tt.buckets)
+					}
+
+					// Bucket and zero counts add up to the growing total
+					total := h.GetZeroCountFloat()
+					for _, c := range h.PositiveCounts {
+						total += c
+					}
+					count := h.GetCountFloat()
+					if math.Abs(total-count) > 1e-9 || count <= prevCount {
+						t.Errorf("count = %g, bucket total %g, previous count %g", count, total, prevCount)
+					}
+					prevCount = count
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package testutil
+
+import (
+	"math/rand"
+	"net/http"
+)
+
+// FailurePattern makes a FakeReceiver reject chosen write requests, numbered
+// from 1 in arrival order. A request fails when any rule selects it.
+type FailurePattern struct {
+	// FirstK fails the first K requests
+	FirstK int
+	// EveryN fails every Nth request
+	EveryN int
+	// Percent fails this percentage of the requests at random, drawn from
+	// a generator seeded with Seed so runs fail the same requests
+	Percent float64
+	Seed    int64
+	// Statuses are cycled through by the failed requests, default 503
+	Statuses []int
+}
+
+// failureInjector applies a FailurePattern. It is not safe for concurrent
+// use; FakeReceiver calls it under its lock.
+type failureInjector struct {
+	pattern FailurePattern
+	rng     *rand.Rand
+	failed  int
+}
+
+func newFailureInjector(p FailurePattern) *failureInjector {
+	return &failureInjector{pattern: p, rng: rand.New(rand.NewSource(p.Seed))}
+}
+
+// status returns the status to fail request n with, or 0 to accept it
+func (f *failureInjector) status(n int) int {
+	p := f.pattern
+	fail := n <= p.FirstK || (p.EveryN > 0 && n%p.EveryN == 0)
+	// Draw for every request so the random failures don't shift with the
+	// other rules
+	if p.Percent > 0 && f.rng.Float64()*100 < p.Percent {
+		fail = true
+	}
+	if !fail {
+		return 0
+	}
+
+	f.failed++
+	if len(p.Statuses) == 0 {
+		return http.StatusServiceUnavailable
+	}
+	return p.Statuses[(f.failed-1)%len(p.Statuses)]
+}
@@ -0,0 +1,295 @@
+// Package testutil provides in-process fakes of the endpoints a benchmark run
+// talks to: a Prometheus query API serving fixed series and a remote write
+// receiver recording what it was sent. Point prometheus.query_url at
+// FakePrometheus.URL and prometheus.remote_write_url at FakeReceiver.WriteURL
+// to assert end-to-end which series and samples a run wrote.
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"promfire/internal/config"
+)
+
+// lookbackDelta is how far back an instant query looks for the latest sample,
+// matching the Prometheus default
+const lookbackDelta = 5 * time.Minute
+
+// Sample is a single float sample
+type Sample struct {
+	TimestampMs int64
+	Value       float64
+}
+
+// Series is a label set with its samples in timestamp order
+type Series struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+// Metadata is the HELP/TYPE/UNIT metadata of a metric
+type Metadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// FakePrometheus serves the parts of the Prometheus HTTP API the benchmarker
+// queries from a fixed set of series. Range queries return the stored samples
+// in [start, end] unaligned to the step, so tests know exactly which samples a
+// run read.
+type FakePrometheus struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	series   []Series
+	metadata map[string]Metadata
+	requests map[string]int
+	headers  []http.Header
+}
+
+// NewFakePrometheus starts a fake Prometheus serving series. Close it when done.
+func NewFakePrometheus(series ...Series) *FakePrometheus {
+	p := &FakePrometheus{
+		series:   series,
+		metadata: make(map[string]Metadata),
+		requests: make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/label/__name__/values", p.handleNames)
+	mux.HandleFunc("/api/v1/query_range", p.handleQueryRange)
+	mux.HandleFunc("/api/v1/query", p.handleQuery)
+	mux.HandleFunc("/api/v1/series", p.handleSeries)
+	mux.HandleFunc("/api/v1/metadata", p.handleMetadata)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("unknown endpoint %s", r.URL.Path))
+	})
+
+	p.Server = httptest.NewServer(p.count(mux))
+	return p
+}
+
+// SetMetadata sets the metadata /api/v1/metadata returns for a metric
+func (p *FakePrometheus) SetMetadata(metric string, metadata Metadata) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metadata[metric] = metadata
+}
+
+// Requests returns how many requests were made to an API path, e.g.
+// "/api/v1/query_range"
+func (p *FakePrometheus) Requests(path string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests[path]
+}
+
+// Headers returns the headers of every request received, in order
+func (p *FakePrometheus) Headers() []http.Header {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]http.Header(nil), p.headers...)
+}
+
+// count wraps next to record requests per path and their headers
+func (p *FakePrometheus) count(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.requests[r.URL.Path]++
+		p.headers = append(p.headers, r.Header.Clone())
+		p.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *FakePrometheus) handleNames(w http.ResponseWriter, _ *http.Request) {
+	seen := make(map[string]bool)
+	var names []string
+	for _, s := range p.snapshot() {
+		if name := s.Labels[labels.MetricName]; name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	writeAPIData(w, names)
+}
+
+func (p *FakePrometheus) handleQueryRange(w http.ResponseWriter, r *http.Request) {
+	matchers, err := config.ParseSelector(r.FormValue("query"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", err.Error())
+		return
+	}
+	start, err := parseTime(r.FormValue("start"), time.Time{})
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Sprintf("invalid start: %v", err))
+		return
+	}
+	end, err := parseTime(r.FormValue("end"), time.Time{})
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Sprintf("invalid end: %v", err))
+		return
+	}
+
+	result := []map[string]interface{}{}
+	for _, s := range p.matching(matchers) {
+		var values [][]interface{}
+		for _, sample := range s.Samples {
+			if sample.TimestampMs >= start.UnixMilli() && sample.TimestampMs <= end.UnixMilli() {
+				values = append(values, apiSample(sample))
+			}
+		}
+		if len(values) > 0 {
+			result = append(result, map[string]interface{}{"metric": s.Labels, "values": values})
+		}
+	}
+	writeAPIData(w, map[string]interface{}{"resultType": "matrix", "result": result})
+}
+
+// handleQuery answers instant queries with the latest sample of each
+// matching series within the lookback window. The preflight's vector(1)
+// probe is answered as well, as it isn't a series selector.
+func (p *FakePrometheus) handleQuery(w http.ResponseWriter, r *http.Request) {
+	at, err := parseTime(r.FormValue("time"), time.Now())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", fmt.Sprintf("invalid time: %v", err))
+		return
+	}
+
+	query := r.FormValue("query")
+	if query == "vector(1)" {
+		sample := Sample{TimestampMs: at.UnixMilli(), Value: 1}
+		result := []map[string]interface{}{{"metric": map[string]string{}, "value": apiSample(sample)}}
+		writeAPIData(w, map[string]interface{}{"resultType": "vector", "result": result})
+		return
+	}
+
+	matchers, err := config.ParseSelector(query)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", err.Error())
+		return
+	}
+
+	result := []map[string]interface{}{}
+	for _, s := range p.matching(matchers) {
+		for i := len(s.Samples) - 1; i >= 0; i-- {
+			sample := s.Samples[i]
+			if sample.TimestampMs > at.UnixMilli() {
+				continue
+			}
+			if sample.TimestampMs >= at.Add(-lookbackDelta).UnixMilli() {
+				result = append(result, map[string]interface{}{"metric": s.Labels, "value": apiSample(sample)})
+			}
+			break
+		}
+	}
+	writeAPIData(w, map[string]interface{}{"resultType": "vector", "result": result})
+}
+
+func (p *FakePrometheus) handleSeries(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", err.Error())
+		return
+	}
+	selectors := r.Form["match[]"]
+	if len(selectors) == 0 {
+		writeAPIError(w, http.StatusBadRequest, "bad_data", "no match[] parameter provided")
+		return
+	}
+
+	seen := make(map[string]bool)
+	data := []map[string]string{}
+	for _, selector := range selectors {
+		matchers, err := config.ParseSelector(selector)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "bad_data", err.Error())
+			return
+		}
+		for _, s := range p.matching(matchers) {
+			key := labels.FromMap(s.Labels).String()
+			if !seen[key] {
+				seen[key] = true
+				data = append(data, s.Labels)
+			}
+		}
+	}
+	writeAPIData(w, data)
+}
+
+func (p *FakePrometheus) handleMetadata(w http.ResponseWriter, _ *http.Request) {
+	p.mu.Lock()
+	data := make(map[string][]Metadata, len(p.metadata))
+	for name, m := range p.metadata {
+		data[name] = []Metadata{m}
+	}
+	p.mu.Unlock()
+	writeAPIData(w, data)
+}
+
+// snapshot returns the served series
+func (p *FakePrometheus) snapshot() []Series {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.series
+}
+
+// matching returns the series whose labels satisfy all matchers
+func (p *FakePrometheus) matching(matchers []*labels.Matcher) []Series {
+	var matched []Series
+	for _, s := range p.snapshot() {
+		ok := true
+		for _, m := range matchers {
+			if !m.Matches(s.Labels[m.Name]) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// parseTime parses a Unix seconds query parameter, returning fallback when
+// it is empty
+func parseTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(math.Round(seconds * 1000))), nil
+}
+
+// apiSample renders a sample as the API's [unix_seconds, "value"] pair
+func apiSample(s Sample) []interface{} {
+	return []interface{}{float64(s.TimestampMs) / 1000, strconv.FormatFloat(s.Value, 'f', -1, 64)}
+}
+
+func writeAPIData(w http.ResponseWriter, data interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "success", "data": data})
+}
+
+func writeAPIError(w http.ResponseWriter, code int, errorType, msg string) {
+	writeJSON(w, code, map[string]interface{}{"status": "error", "errorType": errorType, "error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,230 @@
+package testutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// WritePath is the path FakeReceiver accepts remote write requests on
+const WritePath = "/api/v1/write"
+
+// snappyStreamMagic starts a snappy framed stream (the stream identifier chunk)
+var snappyStreamMagic = []byte{0xff, 0x06, 0x00, 0x00, 's', 'N', 'a', 'P', 'p', 'Y'}
+
+// FakeReceiver is a remote write receiver that decodes snappy or gzip
+// compressed protobuf requests and records the series it was sent
+type FakeReceiver struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	status     int
+	requests   int
+	failures   *failureInjector
+	discard    bool
+	timeSeries []prompb.TimeSeries
+	metadata   []prompb.MetricMetadata
+	headers    []http.Header
+}
+
+// NewFakeReceiver starts a fake remote write receiver. Close it when done.
+func NewFakeReceiver() *FakeReceiver {
+	r := &FakeReceiver{status: http.StatusNoContent}
+	mux := http.NewServeMux()
+	mux.HandleFunc(WritePath, r.handleWrite)
+	r.Server = httptest.NewServer(mux)
+	return r
+}
+
+// WriteURL returns the URL to use as remote_write_url
+func (r *FakeReceiver) WriteURL() string {
+	return r.URL + WritePath
+}
+
+// SetStatus makes the receiver answer decoded requests with code, without
+// recording them unless it is a 2xx
+func (r *FakeReceiver) SetStatus(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = code
+}
+
+// SetFailures makes the receiver reject the requests selected by p from
+// the next request on, counting requests from 1 again
+func (r *FakeReceiver) SetFailures(p FailurePattern) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = newFailureInjector(p)
+	r.requests = 0
+}
+
+// Failed returns the number of requests rejected by the failure pattern
+func (r *FakeReceiver) Failed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures == nil {
+		return 0
+	}
+	return r.failures.failed
+}
+
+// SetDiscard stops recording accepted series, so long runs against the
+// receiver don't grow its memory
+func (r *FakeReceiver) SetDiscard(discard bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.discard = discard
+}
+
+// Requests returns the number of write requests received, including rejected ones
+func (r *FakeReceiver) Requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+// Headers returns the headers of every write request received, in order
+func (r *FakeReceiver) Headers() []http.Header {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]http.Header(nil), r.headers...)
+}
+
+// TimeSeries returns every recorded time series in the order received
+func (r *FakeReceiver) TimeSeries() []prompb.TimeSeries {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]prompb.TimeSeries(nil), r.timeSeries...)
+}
+
+// Metadata returns the recorded metric metadata in the order received
+func (r *FakeReceiver) Metadata() []prompb.MetricMetadata {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]prompb.MetricMetadata(nil), r.metadata...)
+}
+
+// Series returns the recorded float samples merged per label set, with
+// series sorted by labels and samples by timestamp
+func (r *FakeReceiver) Series() []Series {
+	merged := make(map[string]*Series)
+	var keys []string
+	for _, ts := range r.TimeSeries() {
+		lbls := make(map[string]string, len(ts.Labels))
+		for _, l := range ts.Labels {
+			lbls[l.Name] = l.Value
+		}
+		key := labels.FromMap(lbls).String()
+		s, ok := merged[key]
+		if !ok {
+			s = &Series{Labels: lbls}
+			merged[key] = s
+			keys = append(keys, key)
+		}
+		for _, sample := range ts.Samples {
+			s.Samples = append(s.Samples, Sample{TimestampMs: sample.Timestamp, Value: sample.Value})
+		}
+	}
+
+	sort.Strings(keys)
+	series := make([]Series, 0, len(keys))
+	for _, key := range keys {
+		s := merged[key]
+		sort.SliceStable(s.Samples, func(i, j int) bool { return s.Samples[i].TimestampMs < s.Samples[j].TimestampMs })
+		series = append(series, *s)
+	}
+	return series
+}
+
+// Samples returns the recorded samples of the series with exactly lbls
+func (r *FakeReceiver) Samples(lbls map[string]string) []Sample {
+	key := labels.FromMap(lbls).String()
+	for _, s := range r.Series() {
+		if labels.FromMap(s.Labels).String() == key {
+			return s.Samples
+		}
+	}
+	return nil
+}
+
+func (r *FakeReceiver) handleWrite(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.requests++
+	n := r.requests
+	r.headers = append(r.headers, req.Header.Clone())
+	r.mu.Unlock()
+
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "application/x-protobuf" {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", ct), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	writeReq, err := decodeWriteRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	status := r.status
+	if r.failures != nil {
+		if injected := r.failures.status(n); injected != 0 {
+			status = injected
+		}
+	}
+	if status >= 200 && status < 300 && !r.discard {
+		r.timeSeries = append(r.timeSeries, writeReq.Timeseries...)
+		r.metadata = append(r.metadata, writeReq.Metadata...)
+	}
+	r.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+// decodeWriteRequest decompresses and unmarshals a remote write request body
+// according to its Content-Encoding
+func decodeWriteRequest(req *http.Request) (*prompb.WriteRequest, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading body: %w", err)
+	}
+
+	var data []byte
+	switch encoding := req.Header.Get("Content-Encoding"); encoding {
+	case "snappy":
+		if bytes.HasPrefix(body, snappyStreamMagic) {
+			data, err = io.ReadAll(snappy.NewReader(bytes.NewReader(body)))
+		} else {
+			data, err = snappy.Decode(nil, body)
+		}
+	case "gzip":
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(body)); err == nil {
+			data, err = io.ReadAll(zr)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decompressing body: %w", err)
+	}
+
+	var writeReq prompb.WriteRequest
+	if err := writeReq.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("unmarshaling write request: %w", err)
+	}
+	return &writeReq, nil
+}
@@ -0,0 +1,153 @@
+package benchmarker
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"promfire/internal/config"
+)
+
+// ValueGenerator produces the sample values of one series, called once per
+// sample in timestamp order
+type ValueGenerator interface {
+	Next(ts time.Time) float64
+}
+
+// valueGeneratorFunc builds the generator of a series from its seed
+type valueGeneratorFunc func(seed int64) ValueGenerator
+
+// newValuePattern returns the generator factory configured by value_pattern,
+// or nil when source values are kept
+func newValuePattern(cfg *config.Config) valueGeneratorFunc {
+	pattern := cfg.Benchmark.ValuePattern
+	period := cfg.ValuePatternPeriod()
+
+	switch pattern.Type {
+	case config.ValuePatternConstant:
+		return func(int64) ValueGenerator { return constantValue(pattern.Start) }
+	case config.ValuePatternLinear:
+		return func(int64) ValueGenerator { return &linearRamp{start: pattern.Start, slope: pattern.Slope} }
+	case config.ValuePatternSine:
+		return func(seed int64) ValueGenerator {
+			rng := rand.New(rand.NewSource(seed))
+			return &sineWave{midline: pattern.Start, amplitude: pattern.Amplitude, period: period, phase: 2 * math.Pi * rng.Float64()}
+		}
+	case config.ValuePatternRandomWalk:
+		return randomWalkPattern(pattern.Start, pattern.Step)
+	case config.ValuePatternGaussian:
+		return func(seed int64) ValueGenerator {
+			return &gaussianNoise{mean: pattern.Start, stddev: pattern.StdDev, rng: rand.New(rand.NewSource(seed))}
+		}
+	}
+	return nil
+}
+
+// randomWalkPattern returns random walks starting at start that move by up
+// to ±step on every sample
+func randomWalkPattern(start, step float64) valueGeneratorFunc {
+	return func(seed int64) ValueGenerator {
+		return &randomWalk{value: start, step: step, rng: rand.New(rand.NewSource(seed))}
+	}
+}
+
+// constantValue repeats a single value
+type constantValue float64
+
+func (c constantValue) Next(time.Time) float64 { return float64(c) }
+
+// linearRamp changes by slope per second from start at the first sample
+type linearRamp struct {
+	start, slope float64
+	origin       time.Time
+	started      bool
+}
+
+func (r *linearRamp) Next(ts time.Time) float64 {
+	if !r.started {
+		r.origin, r.started = ts, true
+	}
+	return r.start + r.slope*ts.Sub(r.origin).Seconds()
+}
+
+// sineWave oscillates around midline by amplitude over period in wall-clock
+// time, offset by phase
+type sineWave struct {
+	midline, amplitude float64
+	period             time.Duration
+	phase              float64
+}
+
+func (w *sineWave) Next(ts time.Time) float64 {
+	angle := 2*math.Pi*float64(ts.UnixNano()%int64(w.period))/float64(w.period) + w.phase
+	return w.midline + w.amplitude*math.Sin(angle)
+}
+
+// randomWalk starts at value and moves by a uniform step in [-step, step]
+// on every following sample
+type randomWalk struct {
+	value, step float64
+	rng         *rand.Rand
+	started     bool
+}
+
+func (w *randomWalk) Next(time.Time) float64 {
+	if w.started {
+		w.value += w.step * (2*w.rng.Float64() - 1)
+	}
+	w.started = true
+	return w.value
+}
+
+// gaussianNoise draws independent normally distributed values
+type gaussianNoise struct {
+	mean, stddev float64
+	rng          *rand.Rand
+}
+
+func (g *gaussianNoise) Next(time.Time) float64 {
+	return g.mean + g.stddev*g.rng.NormFloat64()
+}
+
+// generateValues returns a copy of values with every sample value replaced by
+// the next value of gen at its timestamp; unparseable entries are kept as-is
+func generateValues(values [][]interface{}, gen ValueGenerator) [][]interface{} {
+	out := make([][]interface{}, len(values))
+	for i, value := range values {
+		out[i] = value
+		if len(value) != 2 {
+			continue
+		}
+		ts, ok := sampleTime(value[0])
+		if !ok {
+			continue
+		}
+		out[i] = []interface{}{value[0], formatFloat(gen.Next(ts))}
+	}
+	return out
+}
+
+// sampleTime parses a query API timestamp in float seconds
+func sampleTime(v interface{}) (time.Time, bool) {
+	var seconds float64
+	switch t := v.(type) {
+	case float64:
+		seconds = t
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		seconds = f
+	default:
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(math.Round(seconds * 1000))), true
+}
+
+// formatFloat formats a sample value the way the Prometheus query API does,
+// so generated values read like queried ones
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
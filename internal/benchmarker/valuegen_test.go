@@ -0,0 +1,147 @@
+package benchmarker
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"promfire/internal/config"
+)
+
+// patternValues draws n values one minute apart from the generator that
+// pattern builds for seed
+func patternValues(t *testing.T, pattern config.ValuePattern, seed int64, n int) []float64 {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Benchmark.ValuePattern = pattern
+	newGen := newValuePattern(cfg)
+	if newGen == nil {
+		t.Fatalf("no generator for value pattern %q", pattern.Type)
+	}
+	gen := newGen(seed)
+	start := time.Unix(1_700_000_000, 0)
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = gen.Next(start.Add(time.Duration(i) * time.Minute))
+	}
+	return values
+}
+
+// meanStdDev returns the mean and standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	var sum, squares float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+func TestValuePatternConstant(t *testing.T) {
+	for i, v := range patternValues(t, config.ValuePattern{Type: config.ValuePatternConstant, Start: 42}, 1, 100) {
+		if v != 42 {
+			t.Fatalf("value %d = %g, want 42", i, v)
+		}
+	}
+}
+
+func TestValuePatternLinear(t *testing.T) {
+	values := patternValues(t, config.ValuePattern{Type: config.ValuePatternLinear, Start: 10, Slope: 0.5}, 1, 100)
+	for i, v := range values {
+		// 0.5 per second is 30 per minute from the first sample
+		if want := 10 + 30*float64(i); v != want {
+			t.Fatalf("value %d = %g, want %g", i, v, want)
+		}
+	}
+}
+
+func TestValuePatternSine(t *testing.T) {
+	pattern := config.ValuePattern{Type: config.ValuePatternSine, Start: 100, Amplitude: 10, Period: "1h"}
+	values := patternValues(t, pattern, 1, 24*60)
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		low, high = math.Min(low, v), math.Max(high, v)
+	}
+	if low < 90-1e-9 || high > 110+1e-9 || low > 90.1 || high < 109.9 {
+		t.Errorf("values range over [%g, %g], want the full wave over [90, 110]", low, high)
+	}
+	// A day holds whole periods, so the wave averages out to its midline
+	if mean, _ := meanStdDev(values); math.Abs(mean-100) > 1e-6 {
+		t.Errorf("mean = %g, want the midline 100", mean)
+	}
+	for i := 60; i < len(values); i++ {
+		if math.Abs(values[i]-values[i-60]) > 1e-9 {
+			t.Fatalf("value %d = %g differs from %g a period earlier", i, values[i], values[i-60])
+		}
+	}
+
+	// Series start at their own phase
+	if other := patternValues(t, pattern, 2, 1); other[0] == values[0] {
+		t.Errorf("seeds 1 and 2 both start at %g, want their own phases", other[0])
+	}
+}
+
+func TestValuePatternRandomWalk(t *testing.T) {
+	pattern := config.ValuePattern{Type: config.ValuePatternRandomWalk, Start: 50, Step: 2}
+	values := patternValues(t, pattern, 1, 1000)
+	if values[0] != 50 {
+		t.Errorf("walk starts at %g, want 50", values[0])
+	}
+	var moved bool
+	for i := 1; i < len(values); i++ {
+		d := math.Abs(values[i] - values[i-1])
+		if d > 2 {
+			t.Fatalf("walk moved %g from value %d to %d, want at most the step 2", d, i-1, i)
+		}
+		moved = moved || d > 0
+	}
+	if !moved {
+		t.Error("walk never moved")
+	}
+
+	again := patternValues(t, pattern, 1, 1000)
+	other := patternValues(t, pattern, 2, 1000)
+	if again[999] != values[999] {
+		t.Errorf("seed 1 walked to %g and then %g, want it reproducible", values[999], again[999])
+	}
+	if other[999] == values[999] {
+		t.Errorf("seeds 1 and 2 both walked to %g, want their own walks", values[999])
+	}
+}
+
+func TestValuePatternGaussian(t *testing.T) {
+	values := patternValues(t, config.ValuePattern{Type: config.ValuePatternGaussian, Start: 20, StdDev: 5}, 1, 20000)
+	mean, stddev := meanStdDev(values)
+	if math.Abs(mean-20) > 0.15 {
+		t.Errorf("mean = %g, want about 20", mean)
+	}
+	if math.Abs(stddev-5) > 0.15 {
+		t.Errorf("stddev = %g, want about 5", stddev)
+	}
+
+	// About 68% of values fall within one standard deviation
+	within := 0
+	for _, v := range values {
+		if math.Abs(v-20) <= 5 {
+			within++
+		}
+	}
+	if share := float64(within) / float64(len(values)); math.Abs(share-0.6827) > 0.02 {
+		t.Errorf("%.1f%% of values within one stddev, want about 68.3%%", 100*share)
+	}
+}
+
+func TestGenerateValuesFormat(t *testing.T) {
+	values := generateValues([][]interface{}{{1700000000.0, "1"}, {"bad", "1"}}, constantValue(1234567.5))
+	if got := values[0][1]; got != "1234567.5" {
+		t.Errorf("generated value = %v, want 1234567.5 without an exponent", got)
+	}
+	if got := values[1][1]; got != "1" {
+		t.Errorf("unparseable sample = %v, want it kept", got)
+	}
+}
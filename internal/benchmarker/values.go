@@ -0,0 +1,119 @@
+package benchmarker
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// replicaSeed derives a deterministic PRNG seed from the global seed, the
+// metric name and the replica index
+func replicaSeed(seed int64, metricName string, replica int) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.FormatInt(seed, 10)))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(metricName))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(strconv.Itoa(replica)))
+	return int64(h.Sum64())
+}
+
+// replicaFactor returns a stable multiplicative factor in [1-variation, 1+variation]
+// for a replica, so replicas differ from each other but reproducibly across runs
+func replicaFactor(seed int64, metricName string, replica int, variation float64) float64 {
+	rng := rand.New(rand.NewSource(replicaSeed(seed, metricName, replica)))
+	return 1 + variation*(2*rng.Float64()-1)
+}
+
+// labelSetSeed derives a deterministic PRNG seed from the global seed and a
+// replica series' labels, so every replica keeps its own random stream even
+// if the order of series or combinations changes
+func labelSetSeed(seed int64, labelSet map[string]string) int64 {
+	names := make([]string, 0, len(labelSet))
+	for name := range labelSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strconv.FormatInt(seed, 10)))
+	for _, name := range names {
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(name))
+		_, _ = h.Write([]byte{'='})
+		_, _ = h.Write([]byte(labelSet[name]))
+	}
+	return int64(h.Sum64())
+}
+
+// jitterFunc returns a value transform that perturbs every sample by an
+// independent factor in [1-jitter, 1+jitter] drawn from rng
+func jitterFunc(rng *rand.Rand, jitter float64) func(float64) float64 {
+	return func(v float64) float64 {
+		return v * (1 + jitter*(2*rng.Float64()-1))
+	}
+}
+
+// transformValues returns a copy of values with every parseable sample value
+// passed through fn; timestamps and unparseable entries are kept as-is
+func transformValues(values [][]interface{}, fn func(float64) float64) [][]interface{} {
+	out := make([][]interface{}, len(values))
+	for i, value := range values {
+		out[i] = value
+		if len(value) != 2 {
+			continue
+		}
+		s, ok := value[1].(string)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			continue
+		}
+		out[i] = []interface{}{value[0], formatFloat(fn(v))}
+	}
+	return out
+}
+
+// Value arrangements that reorder a replica's sample values over its
+// unchanged timestamps
+const (
+	arrangeRotate  = "rotate"
+	arrangeShuffle = "shuffle"
+)
+
+// rearrangeValues returns a copy of values whose sample values are moved to
+// other timestamps of the same series: "rotate" shifts them circularly by the
+// replica index and "shuffle" permutes them with rng. The value distribution
+// is preserved, only its temporal arrangement changes.
+func rearrangeValues(values [][]interface{}, mode string, replica int, rng *rand.Rand) [][]interface{} {
+	n := len(values)
+	if n < 2 {
+		return values
+	}
+
+	perm := make([]int, n)
+	switch mode {
+	case arrangeRotate:
+		for i := range perm {
+			perm[i] = (i + replica) % n
+		}
+	case arrangeShuffle:
+		perm = rng.Perm(n)
+	default:
+		return values
+	}
+
+	out := make([][]interface{}, n)
+	for i, value := range values {
+		out[i] = value
+		src := values[perm[i]]
+		if len(value) != 2 || len(src) != 2 {
+			continue
+		}
+		out[i] = []interface{}{value[0], src[1]}
+	}
+	return out
+}
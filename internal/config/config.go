@@ -2,33 +2,556 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"gopkg.in/yaml.v2"
+	"promfire/internal/version"
 )
 
+// headerNamePattern matches an RFC 7230 token, the allowed HTTP header names
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
 // Config represents the application configuration
 type Config struct {
 	Prometheus     Prometheus         `yaml:"prometheus"`
+	Source         Source             `yaml:"source"`
 	Benchmark      Benchmark          `yaml:"benchmark"`
 	Replication    []ReplicationLabel `yaml:"replication_labels"`
 	ExcludeMetrics []string           `yaml:"exclude_metrics"`
+	IncludeMetrics []string           `yaml:"include_metrics"`
 	LogLevel       string             `yaml:"log_level,omitempty"`
+	LogFormat      string             `yaml:"log_format"`
+	Output         Output             `yaml:"output"`
+	// LogSampleRate writes 1 in N entries of each repeated message below
+	// ERROR; 0 or 1 writes every entry
+	LogSampleRate int `yaml:"log_sample_rate"`
+	// LogFile writes logs to this file instead of stdout, rotating it once it
+	// reaches LogMaxSizeMB (default 100, 0 never rotates) and keeping
+	// LogMaxBackups (default 3, 0 keeps none)
+	LogFile       string `yaml:"log_file"`
+	LogMaxSizeMB  *int   `yaml:"log_max_size_mb"`
+	LogMaxBackups *int   `yaml:"log_max_backups"`
+	// Loopback writes to an in-process receiver instead of remote_write_url
+	Loopback Loopback `yaml:"loopback"`
+
+	// Compiled include/exclude patterns, cached by MetricFilters
+	includeRegexes  []*regexp.Regexp
+	excludeRegexes  []*regexp.Regexp
+	filtersCompiled bool
+}
+
+// Loopback runs an in-process remote write receiver that decodes and
+// discards writes while failing the requests selected by Failures, to
+// exercise retries, backoff and the circuit breaker deterministically
+type Loopback struct {
+	Enabled  bool             `yaml:"enabled"`
+	Failures LoopbackFailures `yaml:"failures"`
+}
+
+// LoopbackFailures selects the loopback requests to fail, numbered from 1;
+// a request fails when any rule selects it. Percent draws from a generator
+// seeded with Seed, and failed requests cycle through Statuses (default 503).
+type LoopbackFailures struct {
+	FirstK   int     `yaml:"first_k"`
+	EveryN   int     `yaml:"every_n"`
+	Percent  float64 `yaml:"percent"`
+	Seed     int64   `yaml:"seed"`
+	Statuses []int   `yaml:"statuses"`
+}
+
+// Source selects where the replicated series are read from
+type Source struct {
+	// Type is "prometheus" (default) to query prometheus.query_url, "file"
+	// to replay series from Path or "synthetic" to generate random walks
+	Type string `yaml:"type"`
+	// Path is the file read by the file source
+	Path string `yaml:"path"`
+	// Format of the file: "ndjson" (default) with one JSON series per line,
+	// or "openmetrics" for a Prometheus/OpenMetrics text exposition
+	Format string `yaml:"format"`
+	// Synthetic configures the generated series of the "synthetic" type
+	Synthetic Synthetic `yaml:"synthetic"`
+}
+
+// Synthetic configures generated random walk series. Each series starts at
+// WalkStart and moves by up to ±WalkStep on every query step.
+type Synthetic struct {
+	// MetricName names the metric, suffixed with _1.._N if MetricCount > 1
+	MetricName      string `yaml:"metric_name"`
+	MetricCount     int    `yaml:"metric_count"`
+	SeriesCount     int    `yaml:"series_count"`
+	LabelsPerSeries int    `yaml:"labels_per_series"`
+	// ChurnRate is the fraction of series replaced by new ones on each step
+	ChurnRate float64 `yaml:"churn_rate"`
+	WalkStart float64 `yaml:"walk_start"`
+	WalkStep  float64 `yaml:"walk_step"`
+	// NativeHistogram generates native histogram series instead of random
+	// walks when set
+	NativeHistogram *SyntheticHistogram `yaml:"native_histogram"`
+}
+
+// SyntheticHistogram shapes generated native histograms. Every series
+// observes Observations values per step into Buckets consecutive buckets
+// starting at the bucket with lower bound 1, plus ZeroFraction of them into
+// the zero bucket, with counts accumulating like a scraped histogram.
+type SyntheticHistogram struct {
+	// Schema is the resolution, -4..8: bucket bounds grow by 2^(2^-schema)
+	// (default 3, a growth factor of about 1.09)
+	Schema *int `yaml:"schema"`
+	// Buckets is the number of populated regular buckets (default 10)
+	Buckets int `yaml:"buckets"`
+	// ZeroThreshold is the width of the zero bucket, at most 1 (default
+	// 2^-128 like client_golang); 0 counts only exact zeros
+	ZeroThreshold *float64 `yaml:"zero_threshold"`
+	// ZeroFraction is the fraction of observations in the zero bucket
+	ZeroFraction float64 `yaml:"zero_fraction"`
+	// Observations is the number of values observed per step (default 100)
+	Observations int `yaml:"observations"`
+}
+
+// Defaults of the native histogram settings left unset
+const (
+	defaultHistogramSchema = 3
+	// defaultZeroThreshold is 2^-128, the zero bucket of client_golang
+	defaultZeroThreshold = 2.938735877055719e-39
+)
+
+// SchemaOrDefault returns the schema, the default of 3 when unset
+func (h SyntheticHistogram) SchemaOrDefault() int {
+	if h.Schema == nil {
+		return defaultHistogramSchema
+	}
+	return *h.Schema
+}
+
+// ZeroThresholdOrDefault returns the zero bucket width, 2^-128 when unset
+func (h SyntheticHistogram) ZeroThresholdOrDefault() float64 {
+	if h.ZeroThreshold == nil {
+		return defaultZeroThreshold
+	}
+	return *h.ZeroThreshold
 }
 
 // Prometheus contains Prometheus connection settings
 type Prometheus struct {
-	QueryURL       string `yaml:"query_url"`
-	RemoteWriteURL string `yaml:"remote_write_url"`
+	QueryURL        string          `yaml:"query_url"`
+	RemoteWriteURL  string          `yaml:"remote_write_url"`
+	QueryAuth       QueryAuth       `yaml:"query_auth"`
+	RemoteWriteAuth RemoteWriteAuth `yaml:"remote_write_auth"`
+	TLS             TLS             `yaml:"tls"`
+	// Headers are set on every query and remote write request, e.g.
+	// X-Scope-OrgID for multi-tenant backends. QueryHeaders and
+	// RemoteWriteHeaders add to or override them per request type.
+	Headers            map[string]string `yaml:"headers"`
+	QueryHeaders       map[string]string `yaml:"query_headers"`
+	RemoteWriteHeaders map[string]string `yaml:"remote_write_headers"`
+	// UserAgent is sent with every request (default "promfire/<version>");
+	// a User-Agent entry in the headers above takes precedence
+	UserAgent string `yaml:"user_agent"`
+	// RunIDHeader adds an X-Promfire-Run-ID header carrying the run id to
+	// every request
+	RunIDHeader bool `yaml:"run_id_header"`
+	// MaxRequestBytes caps the uncompressed protobuf size of a remote write
+	// request, e.g. to stay under a receiver's max_recv_msg_size; 0 is unlimited
+	MaxRequestBytes int `yaml:"max_request_bytes"`
+	// RemoteWriteEncoding is the payload compression, "snappy" (default) or "gzip"
+	RemoteWriteEncoding string `yaml:"remote_write_encoding"`
+	// SnappyFramed sends snappy payloads in the framed stream format instead
+	// of the block format the remote write spec requires, for receivers that
+	// decode them with a snappy stream reader
+	SnappyFramed bool `yaml:"snappy_framed"`
+	// WriteProtocol is "remote_write" (default) or "otlp" to send OTLP
+	// metrics to remote_write_url, e.g. .../api/v1/otlp/v1/metrics. OTLP
+	// payloads are uncompressed unless remote_write_encoding is "gzip".
+	WriteProtocol string `yaml:"write_protocol"`
+	// Client timeouts in seconds; 0 disables the timeout. The query timeout
+	// bounds waiting for the response headers and for each read of the
+	// streamed body rather than the whole response.
+	QueryTimeoutSeconds       *int `yaml:"query_timeout_seconds"`
+	RemoteWriteTimeoutSeconds *int `yaml:"remote_write_timeout_seconds"`
+	// QueryRetries is how often a range query is retried after a dropped
+	// connection, a truncated response or a 429/5xx (default 2, 0 disables)
+	QueryRetries *int `yaml:"query_retries"`
+	// Connection pool of the query and remote write clients. MaxIdleConns
+	// keep-alive connections (default 100) are kept per client and host,
+	// MaxConnsPerHost caps all connections per host (0 is unlimited) and
+	// IdleConnTimeout is a duration (default "90s").
+	MaxIdleConns    int    `yaml:"max_idle_conns"`
+	MaxConnsPerHost int    `yaml:"max_conns_per_host"`
+	IdleConnTimeout string `yaml:"idle_conn_timeout"`
+}
+
+// TLS contains TLS settings shared by the query and remote write clients
+type TLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// RemoteWriteAuth contains remote write credentials, either HTTP basic auth
+// or AWS SigV4 request signing
+type RemoteWriteAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	SigV4    *SigV4 `yaml:"sigv4"`
+}
+
+// SigV4 contains AWS credentials for signing remote write requests, e.g. to
+// Amazon Managed Service for Prometheus. Unset keys are read from the
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment
+// variables.
+type SigV4 struct {
+	Region       string `yaml:"region"`
+	AccessKey    string `yaml:"access_key"`
+	SecretKey    string `yaml:"secret_key"`
+	SessionToken string `yaml:"session_token"`
+	// Service is the signing service name, "aps" by default
+	Service string `yaml:"service"`
+}
+
+// QueryAuth contains authentication settings for the Prometheus query API.
+// BearerTokenFile is re-read on every request so rotated tokens are picked up.
+type QueryAuth struct {
+	BearerToken     string `yaml:"bearer_token"`
+	BearerTokenFile string `yaml:"bearer_token_file"`
 }
 
 // Benchmark contains benchmarking parameters
 type Benchmark struct {
-	ReplicationFactor int `yaml:"replication_factor"`
-	QueryRangeHours   int `yaml:"query_range_hours"`
-	QueryStepSeconds  int `yaml:"query_step_seconds"`
-	SamplesPerSecond  int `yaml:"samples_per_second"`
-	BatchSize         int `yaml:"batch_size"`
+	// ReplicationFactor is the number of replicas per source series, 2 by
+	// default. 0 uses every combination of the replication_labels values;
+	// read the resolved count through Config.ReplicationFactor.
+	ReplicationFactor *int `yaml:"replication_factor"`
+	// QueryRange and QueryStep are Go durations such as "36h" or "30s".
+	// They take precedence over the integer fields below.
+	QueryRange string `yaml:"query_range"`
+	QueryStep  string `yaml:"query_step"`
+	// QueryChunkHours splits longer query ranges into several queries of at
+	// most this many hours, avoiding the source's max_samples limit; 0 disables
+	QueryChunkHours int `yaml:"query_chunk_hours"`
+	// Deprecated: use QueryRange and QueryStep
+	QueryRangeHours  int `yaml:"query_range_hours"`
+	QueryStepSeconds int `yaml:"query_step_seconds"`
+	SamplesPerSecond int `yaml:"samples_per_second"`
+	BatchSize        int `yaml:"batch_size"`
+	// BurstMultiplier sizes the rate limiter burst as a multiple of
+	// samples_per_second (default 2.0, i.e. two seconds worth of samples).
+	// BurstSamples, when set, overrides it with an absolute burst size.
+	// Samples are sent in chunks of at most the burst size.
+	BurstMultiplier float64 `yaml:"burst_multiplier"`
+	BurstSamples    int     `yaml:"burst_samples"`
+	// Concurrency is the number of metrics processed in parallel
+	Concurrency int `yaml:"concurrency"`
+	// PerMetricTimeoutSeconds bounds querying and replicating a single
+	// metric; a metric that runs over is skipped. 0 means no timeout.
+	PerMetricTimeoutSeconds int `yaml:"per_metric_timeout_seconds"`
+	// TotalTimeoutSeconds bounds the whole run; 0 means no timeout
+	TotalTimeoutSeconds int `yaml:"total_timeout_seconds"`
+	// ShutdownTimeoutSeconds is how long in-flight work may take to finish
+	// after the first interrupt before the run is cancelled (default 30)
+	ShutdownTimeoutSeconds int `yaml:"shutdown_timeout_seconds"`
+	// SeriesSelector restricts each metric's range query to matching series,
+	// e.g. {job="node",instance=~"prod.*"}; empty queries all series
+	SeriesSelector string `yaml:"series_selector"`
+	// Discovery is "names" (default) to replicate every series of every
+	// metric name, or "series" to only replicate the series returned by
+	// /api/v1/series for DiscoveryMatchers
+	Discovery string `yaml:"discovery"`
+	// QueryType is "range" (default) to replicate the query range, or
+	// "instant" to query only the latest sample of each series and repeat
+	// it samples_per_second times per second of the query range
+	QueryType string `yaml:"query_type"`
+	// InstantWalkStep walks the repeated value of an instant query by up to
+	// ±InstantWalkStep per sample instead of repeating it; 0 repeats it
+	InstantWalkStep float64 `yaml:"instant_walk_step"`
+	// DiscoveryMatchers are the series selectors of series discovery, e.g.
+	// up{job="node"} or {__name__=~"http_.*",env="prod"}
+	DiscoveryMatchers []string `yaml:"discovery_matchers"`
+	// MaxGoroutines caps all goroutines spawned by the benchmarker; 0 is unlimited
+	MaxGoroutines int `yaml:"max_goroutines"`
+	// WriteQueue sizes the buffer and sender pool between the metric
+	// workers querying the source and the writes to the target
+	WriteQueue WriteQueue `yaml:"write_queue"`
+	// MaxConcurrentQueries caps in-flight queries against the source across
+	// all workers; 0 is unlimited
+	MaxConcurrentQueries int `yaml:"max_concurrent_queries"`
+	// CheckGoroutineLeaks warns if the goroutine count does not return to
+	// its pre-run baseline after a run
+	CheckGoroutineLeaks bool `yaml:"check_goroutine_leaks"`
+	// EarlyAbortBatches is the number of initial batches that must all be
+	// rejected with 404/405 before the run is aborted; -1 disables the check
+	EarlyAbortBatches int   `yaml:"early_abort_batches"`
+	Retry             Retry `yaml:"retry"`
+	// CircuitBreaker fails batches fast while the remote write endpoint is down
+	CircuitBreaker CircuitBreaker `yaml:"circuit_breaker"`
+	// NormalizeLabelNames replaces characters that are illegal in Prometheus
+	// label names (e.g. dots from OTel sources) with underscores
+	NormalizeLabelNames bool          `yaml:"normalize_label_names"`
+	FutureSamples       FutureSamples `yaml:"future_samples"`
+	OldSamples          OldSamples    `yaml:"old_samples"`
+	// TimestampMode is "coordinated" (fresh increasing timestamps), "preserve"
+	// (original timestamps) or "shift" (original spacing, moved to end near now)
+	TimestampMode string `yaml:"timestamp_mode"`
+	// TimeAcceleration compresses the spacing of preserve and shift mode
+	// timestamps by this factor, e.g. 10 replays a day of history as 2.4h
+	// ending at the end of the query range; default 1
+	TimeAcceleration float64 `yaml:"time_acceleration"`
+	// DuplicateTimestamps resolves source samples sharing a timestamp in
+	// preserve and shift mode: "drop" keeps the first, "keep_last" the last
+	// and "nudge" moves each duplicate 1ms later. Unset sends them as they are.
+	DuplicateTimestamps string `yaml:"duplicate_timestamps"`
+	// TimestampResolution is "ms" (default) or "s" for coordinated timestamps
+	// aligned to whole seconds
+	TimestampResolution string `yaml:"timestamp_resolution"`
+	// TimestampIncrement is the gap between coordinated timestamps generated
+	// faster than wall-clock time: a duration (default "1ms") or "step" to
+	// space samples by query_step like real scrapes
+	TimestampIncrement string `yaml:"timestamp_increment"`
+	// SupportNativeHistograms replicates native histogram series as histograms
+	// instead of skipping them
+	SupportNativeHistograms bool `yaml:"support_native_histograms"`
+	// OrderingCheck validates that every series is strictly timestamp-ordered
+	// before sending: "fail" aborts the run, "drop" skips the series and "off"
+	// disables the check. Unset means "fail" at debug log level, else "off".
+	OrderingCheck string `yaml:"ordering_check"`
+	// GenerateExemplars attaches synthetic exemplars with a random trace_id
+	// to ExemplarFraction (default 0.1) of all samples
+	GenerateExemplars bool    `yaml:"generate_exemplars"`
+	ExemplarFraction  float64 `yaml:"exemplar_fraction"`
+	// IncludeMetadata queries /api/v1/metadata during discovery and sends
+	// HELP/TYPE/UNIT metadata with the first batch of each metric
+	IncludeMetadata bool `yaml:"include_metadata"`
+	// GenerateMetadata fills in HELP and UNIT text for the metadata sent
+	// with include_metadata
+	GenerateMetadata GenerateMetadata `yaml:"generate_metadata"`
+	// RunLabel names a label set to the run id on every replicated series,
+	// overriding any source label of the same name; empty disables it
+	RunLabel string `yaml:"run_label"`
+	// MaxTotalSeries caps the number of replica series generated per run
+	// across all metrics; 0 is unlimited
+	MaxTotalSeries int64 `yaml:"max_total_series"`
+	// MaxSamplesPerSeries keeps only the most recent samples of each source
+	// series before it is replicated, bounding memory for long high-resolution
+	// ranges; 0 is unlimited
+	MaxSamplesPerSeries int `yaml:"max_samples_per_series"`
+	// AdaptiveRate adjusts samples_per_second during the run based on how
+	// the target responds
+	AdaptiveRate AdaptiveRate `yaml:"adaptive_rate"`
+	// AdaptiveConcurrency adjusts the replica writes in flight during the
+	// run based on error and latency feedback
+	AdaptiveConcurrency AdaptiveConcurrency `yaml:"adaptive_concurrency"`
+	// LatencyWarnMs warns during the run when the p99 remote write request
+	// latency exceeds this many milliseconds; 0 disables the warning
+	LatencyWarnMs int `yaml:"latency_warn_ms"`
+	// LabelRules drops or renames source labels on every replicated series
+	LabelRules LabelRules `yaml:"label_rules"`
+	// Relabel rewrites replica labels like Prometheus relabel_configs,
+	// applied in order after the replication labels are added
+	Relabel []RelabelRule `yaml:"relabel"`
+	// SyntheticJobs spreads every replica across a number of generated job
+	// label values
+	SyntheticJobs SyntheticJobs `yaml:"synthetic_jobs"`
+	// ExtraLabels are set on every replicated series, overriding source
+	// labels of the same name; replication labels take precedence over them
+	ExtraLabels map[string]string `yaml:"extra_labels"`
+	// SampleMetrics processes only a seeded subset of the filtered metrics
+	SampleMetrics SampleMetrics `yaml:"sample_metrics"`
+	// MaxFailureRatio fails the run when more than this fraction of metric
+	// queries and remote write batches failed, e.g. 0.01; 0 fails on any
+	// error and leaving it unset never fails a run for errors it survived
+	MaxFailureRatio *float64 `yaml:"max_failure_ratio"`
+	// CardinalityWarnThreshold logs a warning before the run when the
+	// projected active series exceed it, e.g. a tenant's series limit;
+	// 0 disables the check
+	CardinalityWarnThreshold int64 `yaml:"cardinality_warn_threshold"`
+	// Seed makes all randomized value transformations and metric sampling
+	// reproducible
+	Seed int64 `yaml:"seed"`
+	// ReplicaVariation scales each replica's values by a stable factor in
+	// [1-variation, 1+variation] derived from (seed, metric, replica index)
+	ReplicaVariation float64 `yaml:"replica_variation"`
+	// ValueJitter perturbs every sample of a replica by an independent factor
+	// in [1-jitter, 1+jitter], seeded from (seed, replica series labels)
+	ValueJitter float64 `yaml:"value_jitter"`
+	// ValueArrangement reorders each replica's values over its timestamps:
+	// "rotate" shifts them circularly by replica index, "shuffle" permutes
+	// them reproducibly under the seed; empty keeps the source order
+	ValueArrangement string `yaml:"value_arrangement"`
+	// ValuePattern replaces the values of replicas and synthetic series with
+	// generated ones, e.g. a linear ramp to model counters
+	ValuePattern ValuePattern `yaml:"value_pattern"`
+	// EnforceCounterMonotonicity keeps counters, detected by metadata type or
+	// the _total suffix, non-decreasing per series when their values are
+	// generated, jittered or rearranged
+	EnforceCounterMonotonicity bool `yaml:"enforce_counter_monotonicity"`
+}
+
+// ValuePattern generates sample values of a given shape, seeded per series
+// from (seed, series labels). Start is the constant value, the ramp and walk
+// start, the sine midline and the gaussian mean; an empty Type keeps the
+// source values.
+type ValuePattern struct {
+	Type  string  `yaml:"type"`
+	Start float64 `yaml:"start"`
+	// Slope is the linear ramp's change per second
+	Slope float64 `yaml:"slope"`
+	// Amplitude and Period, a duration defaulting to "1h", shape the sine
+	// wave; every series starts at its own seeded phase
+	Amplitude float64 `yaml:"amplitude"`
+	Period    string  `yaml:"period"`
+	// Step bounds each random walk move to ±Step, default 1
+	Step float64 `yaml:"step"`
+	// StdDev is the gaussian standard deviation, default 1
+	StdDev float64 `yaml:"stddev"`
+}
+
+// FutureSamples controls handling of samples timestamped beyond now + tolerance.
+// Policy is "clamp" or "drop"; leaving it empty disables the check.
+type FutureSamples struct {
+	Policy      string `yaml:"policy"`
+	ToleranceMs int    `yaml:"tolerance_ms"`
+}
+
+// OldSamples controls handling of samples timestamped before now - max_sample_age,
+// e.g. outside the backend's out_of_order_time_window. MaxSampleAge is a
+// duration; leaving it empty disables the check. Policy is "drop" (default)
+// or "clamp".
+type OldSamples struct {
+	Policy       string `yaml:"policy"`
+	MaxSampleAge string `yaml:"max_sample_age"`
+}
+
+// WriteQueue bounds the replica writes buffered between querying and
+// writing; a full queue blocks the metric workers. Depth defaults to 64 and
+// Senders, the goroutines draining it, to concurrency.
+type WriteQueue struct {
+	Depth   int `yaml:"depth"`
+	Senders int `yaml:"senders"`
+}
+
+// SampleMetrics selects Count metrics, or Percent percent of them rounded
+// up, out of those left after filtering. Setting neither processes all
+// metrics; Percent takes precedence when both are set.
+type SampleMetrics struct {
+	Count   int     `yaml:"count"`
+	Percent float64 `yaml:"percent"`
+}
+
+// GenerateMetadata generates HELP text phrased by metric type and a UNIT
+// inferred from the metric name for metrics whose source metadata lacks
+// them, or for every metric with Overwrite. HelpLength pads or cuts the HELP
+// text to that many bytes to stress metadata storage; 0 keeps its natural
+// length.
+type GenerateMetadata struct {
+	Enabled    bool `yaml:"enabled"`
+	Overwrite  bool `yaml:"overwrite"`
+	HelpLength int  `yaml:"help_length"`
+}
+
+// SyntheticJobs multiplies replicas across Count job values named
+// "<prefix>-1" to "<prefix>-<count>"; 0 disables the dimension
+type SyntheticJobs struct {
+	Count  int    `yaml:"count"`
+	Prefix string `yaml:"prefix"`
+}
+
+// LabelRules rewrites the source labels of replicated series. Drop is
+// applied first, then all renames at once, so renames never see each
+// other's results. A renamed label replaces an existing label of the same name.
+type LabelRules struct {
+	Drop   []string          `yaml:"drop"`
+	Rename map[string]string `yaml:"rename"`
+}
+
+// AdaptiveRate configures AIMD rate control starting at samples_per_second:
+// every 10 successful requests add IncreaseSamplesPerSecond, and a 429 or 503
+// response multiplies the rate by DecreaseFactor, within the min/max bounds.
+// Defaults are a tenth, ten times and a twentieth of samples_per_second and a
+// factor of 0.5.
+type AdaptiveRate struct {
+	Enabled                  bool    `yaml:"enabled"`
+	MinSamplesPerSecond      int     `yaml:"min_samples_per_second"`
+	MaxSamplesPerSecond      int     `yaml:"max_samples_per_second"`
+	IncreaseSamplesPerSecond int     `yaml:"increase_samples_per_second"`
+	DecreaseFactor           float64 `yaml:"decrease_factor"`
+}
+
+// AdaptiveConcurrency ramps the replica writes in flight, and with them the
+// remote write requests, from MinConcurrency up to MaxConcurrency: every 10
+// successful requests whose mean latency stays within LatencyTolerance times
+// the lowest mean seen add one, and a 429, 5xx or transport error or a rising
+// latency multiplies it by DecreaseFactor. Defaults are 1,
+// write_queue.senders, 1.5 and 0.5.
+type AdaptiveConcurrency struct {
+	Enabled          bool    `yaml:"enabled"`
+	MinConcurrency   int     `yaml:"min_concurrency"`
+	MaxConcurrency   int     `yaml:"max_concurrency"`
+	LatencyTolerance float64 `yaml:"latency_tolerance"`
+	DecreaseFactor   float64 `yaml:"decrease_factor"`
+}
+
+// RelabelRule is a minimal Prometheus relabel_config. The "replace" action
+// (default) sets TargetLabel to Replacement expanded with the Regex capture
+// groups, removing it if the result is empty; "drop" skips the replica when
+// Regex matches. Both match the SourceLabels values joined by Separator.
+type RelabelRule struct {
+	SourceLabels []string `yaml:"source_labels"`
+	// Separator defaults to ";"
+	Separator string `yaml:"separator"`
+	// Regex is anchored at both ends and defaults to "(.*)"
+	Regex       string `yaml:"regex"`
+	TargetLabel string `yaml:"target_label"`
+	// Replacement defaults to "$1"
+	Replacement string `yaml:"replacement"`
+	Action      string `yaml:"action"`
+}
+
+// CompiledRegex returns the anchored regular expression of the rule
+func (r RelabelRule) CompiledRegex() (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + r.Regex + ")$")
+}
+
+// Retry contains remote write retry settings for 429 and 5xx responses
+type Retry struct {
+	MaxRetries       int `yaml:"max_retries"`
+	InitialBackoffMs int `yaml:"initial_backoff_ms"`
+	// MaxDelayMs caps the backoff delay; 0 means uncapped
+	MaxDelayMs int `yaml:"max_delay_ms"`
+	// Jitter is the jitter algorithm: "full" (default), "equal" or "none"
+	Jitter string `yaml:"jitter"`
+}
+
+// CircuitBreaker opens after FailureThreshold consecutive batches fail with a
+// transport error or a 429/5xx after retries, rejecting batches for
+// CooldownSeconds (default 30) before probing the endpoint again. A
+// FailureThreshold of 0 disables the breaker.
+type CircuitBreaker struct {
+	FailureThreshold int `yaml:"failure_threshold"`
+	CooldownSeconds  int `yaml:"cooldown_seconds"`
+}
+
+// Output contains settings for files written after a run
+type Output struct {
+	Dir               string `yaml:"dir"`
+	Manifest          bool   `yaml:"manifest"`
+	CompressionReport bool   `yaml:"compression_report"`
+	// Checkpoint records completed metrics in CheckpointPath (default
+	// <dir>/checkpoint.json) after each metric, so -resume can skip them
+	Checkpoint     bool   `yaml:"checkpoint"`
+	CheckpointPath string `yaml:"checkpoint_path"`
+	// CheckpointEverySeries also records the progress within a metric after
+	// every that many source series, so -resume skips the series already
+	// written; 0 checkpoints whole metrics only
+	CheckpointEverySeries int `yaml:"checkpoint_every_series"`
 }
 
 // ReplicationLabel contains label replication configuration
@@ -37,28 +560,158 @@ type ReplicationLabel struct {
 	Values []string `yaml:"values"`
 }
 
-// LoadConfig loads configuration from a YAML file
+// LoadOptions adjusts how LoadConfigWithOptions parses the config file
+type LoadOptions struct {
+	// AllowUnknownFields ignores keys that don't map to a config field, e.g.
+	// options of a newer version, instead of failing
+	AllowUnknownFields bool
+	// ExpandRemoteEnv expands ${VAR} references in a config fetched from a
+	// URL too. It is off by default so a remote config can't read the local
+	// environment, e.g. into headers sent elsewhere.
+	ExpandRemoteEnv bool
+}
+
+// LoadConfig loads configuration from a YAML file, rejecting unknown keys.
+// The path may also be "-" to read stdin or an http(s) URL to fetch.
 func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	return LoadConfigWithOptions(path, LoadOptions{})
+}
+
+// LoadConfigWithOptions loads configuration from a YAML file, stdin or a URL
+// like LoadConfig
+func LoadConfigWithOptions(path string, opts LoadOptions) (*Config, error) {
+	data, err := readConfig(path)
 	if err != nil {
-		return nil, fmt.Errorf("reading config file: %w", err)
+		return nil, err
+	}
+
+	unmarshal := yaml.UnmarshalStrict
+	if opts.AllowUnknownFields {
+		unmarshal = yaml.Unmarshal
 	}
 
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("parsing config: %w", err)
 	}
 
+	if !isConfigURL(path) || opts.ExpandRemoteEnv {
+		if err := expandEnvRefs(reflect.ValueOf(&config).Elem(), ""); err != nil {
+			return nil, fmt.Errorf("expanding config: %w", err)
+		}
+	}
+
 	// Set defaults
 	config.setDefaults()
 
 	return &config, nil
 }
 
+var envRefPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnvRefs expands the environment references in every string of the
+// parsed config below v, including slice elements and map values. Only
+// parsed values are expanded, so references in comments are ignored. path
+// is the yaml path of v, used to name the field of an unset variable.
+func expandEnvRefs(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.String:
+		expanded, err := expandEnv(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetString(expanded)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return expandEnvRefs(v.Elem(), path)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := expandEnvRefs(v.Field(i), name); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := expandEnvRefs(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			expanded, err := expandEnv(iter.Value().String())
+			if err != nil {
+				return fmt.Errorf("%s.%v: %w", path, iter.Key(), err)
+			}
+			v.SetMapIndex(iter.Key(), reflect.ValueOf(expanded).Convert(v.Type().Elem()))
+		}
+	}
+	return nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} references with the value
+// of the environment variable and $$ with a literal $, failing if a
+// referenced variable without a default is not set
+func expandEnv(value string) (string, error) {
+	var missing []string
+	expanded := envRefPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		match := envRefPattern.FindStringSubmatch(ref)
+		v, ok := os.LookupEnv(match[1])
+		if !ok {
+			if match[2] != "" {
+				return match[3]
+			}
+			missing = append(missing, match[1])
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
 // setDefaults sets default values for unspecified configuration
 func (c *Config) setDefaults() {
-	if c.Benchmark.ReplicationFactor == 0 {
-		c.Benchmark.ReplicationFactor = 2
+	if c.Benchmark.GenerateExemplars && c.Benchmark.ExemplarFraction == 0 {
+		c.Benchmark.ExemplarFraction = 0.1
+	}
+	if c.Benchmark.ShutdownTimeoutSeconds == 0 {
+		c.Benchmark.ShutdownTimeoutSeconds = 30
+	}
+	if c.LogFormat == "" {
+		c.LogFormat = "json"
+	}
+	if c.LogMaxSizeMB == nil {
+		size := 100
+		c.LogMaxSizeMB = &size
+	}
+	if c.LogMaxBackups == nil {
+		backups := 3
+		c.LogMaxBackups = &backups
+	}
+	if c.Benchmark.ReplicationFactor == nil {
+		factor := 2
+		c.Benchmark.ReplicationFactor = &factor
 	}
 	if c.Benchmark.QueryRangeHours == 0 {
 		c.Benchmark.QueryRangeHours = 24
@@ -72,24 +725,703 @@ func (c *Config) setDefaults() {
 	if c.Benchmark.BatchSize == 0 {
 		c.Benchmark.BatchSize = 100
 	}
+	if c.Benchmark.BurstMultiplier == 0 {
+		c.Benchmark.BurstMultiplier = 2.0
+	}
+	if c.Benchmark.Concurrency == 0 {
+		c.Benchmark.Concurrency = 1
+	}
+	if c.Benchmark.WriteQueue.Depth == 0 {
+		c.Benchmark.WriteQueue.Depth = 64
+	}
+	if c.Benchmark.WriteQueue.Senders == 0 {
+		c.Benchmark.WriteQueue.Senders = c.Benchmark.Concurrency
+	}
+	if adaptive := &c.Benchmark.AdaptiveConcurrency; adaptive.Enabled {
+		if adaptive.MinConcurrency == 0 {
+			adaptive.MinConcurrency = 1
+		}
+		if adaptive.MaxConcurrency == 0 {
+			adaptive.MaxConcurrency = c.Benchmark.WriteQueue.Senders
+		}
+		if adaptive.LatencyTolerance == 0 {
+			adaptive.LatencyTolerance = 1.5
+		}
+		if adaptive.DecreaseFactor == 0 {
+			adaptive.DecreaseFactor = 0.5
+		}
+	}
+	if c.Benchmark.EarlyAbortBatches == 0 {
+		c.Benchmark.EarlyAbortBatches = 3
+	}
+	if c.Benchmark.Retry.InitialBackoffMs == 0 {
+		c.Benchmark.Retry.InitialBackoffMs = 100
+	}
+	if c.Benchmark.TimestampMode == "" {
+		c.Benchmark.TimestampMode = "coordinated"
+	}
+	if c.Benchmark.TimeAcceleration == 0 {
+		c.Benchmark.TimeAcceleration = 1
+	}
+	if c.Benchmark.CircuitBreaker.FailureThreshold > 0 && c.Benchmark.CircuitBreaker.CooldownSeconds == 0 {
+		c.Benchmark.CircuitBreaker.CooldownSeconds = 30
+	}
+	if c.Benchmark.OldSamples.MaxSampleAge != "" && c.Benchmark.OldSamples.Policy == "" {
+		c.Benchmark.OldSamples.Policy = "drop"
+	}
+	if c.Benchmark.TimestampIncrement == "" {
+		c.Benchmark.TimestampIncrement = "1ms"
+	}
+	if c.Benchmark.TimestampResolution == "" {
+		c.Benchmark.TimestampResolution = "ms"
+	}
+	if c.Prometheus.QueryTimeoutSeconds == nil {
+		timeout := 120
+		c.Prometheus.QueryTimeoutSeconds = &timeout
+	}
+	if c.Prometheus.QueryRetries == nil {
+		retries := 2
+		c.Prometheus.QueryRetries = &retries
+	}
+	if c.Prometheus.RemoteWriteTimeoutSeconds == nil {
+		timeout := 30
+		c.Prometheus.RemoteWriteTimeoutSeconds = &timeout
+	}
+	if c.Prometheus.MaxIdleConns == 0 {
+		c.Prometheus.MaxIdleConns = 100
+	}
+	if c.Prometheus.IdleConnTimeout == "" {
+		c.Prometheus.IdleConnTimeout = "90s"
+	}
+	if c.Prometheus.WriteProtocol == "" {
+		c.Prometheus.WriteProtocol = "remote_write"
+	}
+	if c.Prometheus.UserAgent == "" {
+		c.Prometheus.UserAgent = "promfire/" + version.Version
+	}
+	if c.Prometheus.RemoteWriteEncoding == "" {
+		c.Prometheus.RemoteWriteEncoding = "snappy"
+	}
+	if c.Benchmark.SyntheticJobs.Prefix == "" {
+		c.Benchmark.SyntheticJobs.Prefix = "bench-job"
+	}
+	if c.Benchmark.Retry.Jitter == "" {
+		c.Benchmark.Retry.Jitter = "full"
+	}
+	if c.Benchmark.Discovery == "" {
+		c.Benchmark.Discovery = DiscoveryNames
+	}
+	if c.Benchmark.QueryType == "" {
+		c.Benchmark.QueryType = QueryTypeRange
+	}
+	if c.Benchmark.ValuePattern.Step == 0 {
+		c.Benchmark.ValuePattern.Step = 1
+	}
+	if c.Benchmark.ValuePattern.StdDev == 0 {
+		c.Benchmark.ValuePattern.StdDev = 1
+	}
+	if c.Source.Type == "" {
+		c.Source.Type = "prometheus"
+	}
+	if c.Source.Format == "" {
+		c.Source.Format = "ndjson"
+	}
+	if adaptive := &c.Benchmark.AdaptiveRate; adaptive.Enabled {
+		if adaptive.MinSamplesPerSecond == 0 {
+			adaptive.MinSamplesPerSecond = max(c.Benchmark.SamplesPerSecond/10, 1)
+		}
+		if adaptive.MaxSamplesPerSecond == 0 {
+			adaptive.MaxSamplesPerSecond = c.Benchmark.SamplesPerSecond * 10
+		}
+		if adaptive.IncreaseSamplesPerSecond == 0 {
+			adaptive.IncreaseSamplesPerSecond = max(c.Benchmark.SamplesPerSecond/20, 1)
+		}
+		if adaptive.DecreaseFactor == 0 {
+			adaptive.DecreaseFactor = 0.5
+		}
+	}
+	for i := range c.Benchmark.Relabel {
+		rule := &c.Benchmark.Relabel[i]
+		if rule.Separator == "" {
+			rule.Separator = ";"
+		}
+		if rule.Regex == "" {
+			rule.Regex = "(.*)"
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = "$1"
+		}
+		if rule.Action == "" {
+			rule.Action = "replace"
+		}
+	}
+	if c.Source.Synthetic.MetricName == "" {
+		c.Source.Synthetic.MetricName = "promfire_synthetic"
+	}
+	if c.Source.Synthetic.MetricCount == 0 {
+		c.Source.Synthetic.MetricCount = 1
+	}
+	if c.Source.Synthetic.SeriesCount == 0 {
+		c.Source.Synthetic.SeriesCount = 100
+	}
+	if c.Source.Synthetic.WalkStep == 0 {
+		c.Source.Synthetic.WalkStep = 1
+	}
+	if h := c.Source.Synthetic.NativeHistogram; h != nil {
+		if h.Schema == nil {
+			schema := defaultHistogramSchema
+			h.Schema = &schema
+		}
+		if h.Buckets == 0 {
+			h.Buckets = 10
+		}
+		if h.ZeroThreshold == nil {
+			threshold := defaultZeroThreshold
+			h.ZeroThreshold = &threshold
+		}
+		if h.Observations == 0 {
+			h.Observations = 100
+		}
+	}
 	if c.Prometheus.QueryURL == "" {
 		c.Prometheus.QueryURL = "http://localhost:9090"
 	}
 	if c.Prometheus.RemoteWriteURL == "" {
 		c.Prometheus.RemoteWriteURL = "http://localhost:9090/api/v1/write"
 	}
+	if c.Output.Dir == "" {
+		c.Output.Dir = "output"
+	}
+	if c.Output.CheckpointPath == "" {
+		c.Output.CheckpointPath = filepath.Join(c.Output.Dir, "checkpoint.json")
+	}
+}
+
+// QueryTimeout returns the query client timeout, 0 meaning no timeout
+func (c *Config) QueryTimeout() time.Duration {
+	if c.Prometheus.QueryTimeoutSeconds == nil {
+		return 0
+	}
+	return time.Duration(*c.Prometheus.QueryTimeoutSeconds) * time.Second
+}
+
+// ReplicationFactor returns the number of replicas per source series,
+// resolving replication_factor 0 to the number of combinations of the
+// replication label values
+func (c *Config) ReplicationFactor() int {
+	if c.Benchmark.ReplicationFactor == nil {
+		return 0
+	}
+	if !c.AllCombinations() {
+		return *c.Benchmark.ReplicationFactor
+	}
+	combinations := 1
+	for _, label := range c.Replication {
+		combinations *= len(label.Values)
+	}
+	return combinations
+}
+
+// AllCombinations reports whether replication_factor 0 asks for every
+// combination of the replication label values
+func (c *Config) AllCombinations() bool {
+	return c.Benchmark.ReplicationFactor != nil && *c.Benchmark.ReplicationFactor == 0
+}
+
+// QueryRetries returns how often a failed range query is retried
+func (c *Config) QueryRetries() int {
+	if c.Prometheus.QueryRetries == nil {
+		return 0
+	}
+	return *c.Prometheus.QueryRetries
+}
+
+// LogMaxSize returns the size in MiB at which log_file is rotated, 0
+// meaning never
+func (c *Config) LogMaxSize() int {
+	if c.LogMaxSizeMB == nil {
+		return 0
+	}
+	return *c.LogMaxSizeMB
+}
+
+// LogBackups returns how many rotated log files are kept
+func (c *Config) LogBackups() int {
+	if c.LogMaxBackups == nil {
+		return 0
+	}
+	return *c.LogMaxBackups
+}
+
+// RemoteWriteTimeout returns the remote write client timeout, 0 meaning no timeout
+func (c *Config) RemoteWriteTimeout() time.Duration {
+	if c.Prometheus.RemoteWriteTimeoutSeconds == nil {
+		return 0
+	}
+	return time.Duration(*c.Prometheus.RemoteWriteTimeoutSeconds) * time.Second
+}
+
+// QueryHeaders returns the extra headers of query API requests
+func (c *Config) QueryHeaders() map[string]string {
+	return mergeHeaders(c.baseHeaders(), c.Prometheus.QueryHeaders)
+}
+
+// RemoteWriteHeaders returns the extra headers of remote write requests
+func (c *Config) RemoteWriteHeaders() map[string]string {
+	return mergeHeaders(c.baseHeaders(), c.Prometheus.RemoteWriteHeaders)
+}
+
+// baseHeaders returns the headers shared by all requests
+func (c *Config) baseHeaders() map[string]string {
+	if c.Prometheus.UserAgent == "" {
+		return c.Prometheus.Headers
+	}
+	return mergeHeaders(map[string]string{"User-Agent": c.Prometheus.UserAgent}, c.Prometheus.Headers)
+}
+
+// mergeHeaders returns base with override applied on top
+func mergeHeaders(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for name, value := range base {
+		merged[name] = value
+	}
+	for name, value := range override {
+		merged[name] = value
+	}
+	return merged
+}
+
+// validateHeaders rejects header names that are not RFC 7230 tokens and
+// values containing line breaks
+func validateHeaders(field string, headers map[string]string) error {
+	for name, value := range headers {
+		if !headerNamePattern.MatchString(name) {
+			return fmt.Errorf("%s: invalid header name %q", field, name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%s: value of header %q must not contain line breaks", field, name)
+		}
+	}
+	return nil
+}
+
+// QueryRange returns how far back each metric is queried
+func (c *Config) QueryRange() time.Duration {
+	d, _ := c.queryRange()
+	return d
+}
+
+// QueryStep returns the resolution of each range query
+func (c *Config) QueryStep() time.Duration {
+	d, _ := c.queryStep()
+	return d
+}
+
+// maxInstantSamples caps the samples generated per series from an instant
+// query, which grow with query_range times samples_per_second
+const maxInstantSamples = 1_000_000
+
+// SampleStep returns the spacing of the generated points of a series: the
+// query step, or with query_type instant 1/samples_per_second rounded down
+// to the millisecond precision of remote write, at least 1ms
+func (c *Config) SampleStep() time.Duration {
+	if c.Benchmark.QueryType != QueryTypeInstant {
+		return c.QueryStep()
+	}
+	if c.Benchmark.SamplesPerSecond <= 0 {
+		return time.Second
+	}
+	step := (time.Second / time.Duration(c.Benchmark.SamplesPerSecond)).Truncate(time.Millisecond)
+	if step < time.Millisecond {
+		step = time.Millisecond
+	}
+	return step
+}
+
+// QueryChunk returns the longest range fetched by a single query, 0 meaning unchunked
+func (c *Config) QueryChunk() time.Duration {
+	return time.Duration(c.Benchmark.QueryChunkHours) * time.Hour
+}
+
+func (c *Config) queryRange() (time.Duration, error) {
+	return durationOr("query_range", c.Benchmark.QueryRange, time.Duration(c.Benchmark.QueryRangeHours)*time.Hour)
+}
+
+func (c *Config) queryStep() (time.Duration, error) {
+	return durationOr("query_step", c.Benchmark.QueryStep, time.Duration(c.Benchmark.QueryStepSeconds)*time.Second)
+}
+
+// durationOr parses value as a duration, or returns fallback when it is empty
+func durationOr(field, value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", field, value, err)
+	}
+	return d, nil
+}
+
+// PerMetricTimeout returns the time budget of a single metric, 0 meaning no timeout
+func (c *Config) PerMetricTimeout() time.Duration {
+	return time.Duration(c.Benchmark.PerMetricTimeoutSeconds) * time.Second
+}
+
+// TotalTimeout returns the time budget of the whole run, 0 meaning no timeout
+func (c *Config) TotalTimeout() time.Duration {
+	return time.Duration(c.Benchmark.TotalTimeoutSeconds) * time.Second
+}
+
+// ShutdownTimeout returns how long a drain may take before the run is cancelled
+func (c *Config) ShutdownTimeout() time.Duration {
+	return time.Duration(c.Benchmark.ShutdownTimeoutSeconds) * time.Second
+}
+
+// ExemplarFraction returns the fraction of samples that get an exemplar,
+// 0 when exemplar generation is disabled
+func (c *Config) ExemplarFraction() float64 {
+	if !c.Benchmark.GenerateExemplars {
+		return 0
+	}
+	return c.Benchmark.ExemplarFraction
+}
+
+// Burst returns the rate limiter burst size in samples
+func (c *Config) Burst() int {
+	if c.Benchmark.BurstSamples > 0 {
+		return c.Benchmark.BurstSamples
+	}
+	return int(float64(c.Benchmark.SamplesPerSecond) * c.Benchmark.BurstMultiplier)
+}
+
+// TimestampResolution returns the resolution of coordinated timestamps
+func (c *Config) TimestampResolution() time.Duration {
+	if c.Benchmark.TimestampResolution == "s" {
+		return time.Second
+	}
+	return time.Millisecond
+}
+
+// IdleConnTimeout returns how long the clients keep idle connections open
+func (c *Config) IdleConnTimeout() time.Duration {
+	d, err := time.ParseDuration(c.Prometheus.IdleConnTimeout)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// MaxSampleAge returns how far behind wall-clock time samples may be, 0
+// meaning no limit
+func (c *Config) MaxSampleAge() time.Duration {
+	d, err := time.ParseDuration(c.Benchmark.OldSamples.MaxSampleAge)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// TimestampIncrementStep is the timestamp_increment value that spaces
+// coordinated timestamps by query_step
+const TimestampIncrementStep = "step"
+
+// TimestampIncrement returns the gap between coordinated timestamps generated
+// faster than wall-clock time
+func (c *Config) TimestampIncrement() time.Duration {
+	if c.Benchmark.TimestampIncrement == TimestampIncrementStep {
+		return c.QueryStep()
+	}
+	d, err := time.ParseDuration(c.Benchmark.TimestampIncrement)
+	if err != nil {
+		return time.Millisecond
+	}
+	return d
+}
+
+// SeriesMatchers parses series_selector into label matchers, returning nil
+// when no selector is configured. The metric name is added per query, so the
+// selector must not match on __name__.
+func (c *Config) SeriesMatchers() ([]*labels.Matcher, error) {
+	if c.Benchmark.SeriesSelector == "" {
+		return nil, nil
+	}
+
+	matchers, err := ParseSelector(c.Benchmark.SeriesSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid series_selector %q: %w", c.Benchmark.SeriesSelector, err)
+	}
+	for _, m := range matchers {
+		if m.Name == labels.MetricName {
+			return nil, fmt.Errorf("series_selector must not match on %s, the metric name is added per query", labels.MetricName)
+		}
+	}
+	return matchers, nil
+}
+
+// Discovery modes selectable with benchmark.discovery
+const (
+	DiscoveryNames  = "names"
+	DiscoverySeries = "series"
+)
+
+// Value patterns selectable with benchmark.value_pattern.type
+const (
+	ValuePatternConstant   = "constant"
+	ValuePatternLinear     = "linear"
+	ValuePatternSine       = "sine"
+	ValuePatternRandomWalk = "random_walk"
+	ValuePatternGaussian   = "gaussian"
+)
+
+// ValuePatternPeriod returns the period of the sine value pattern
+func (c *Config) ValuePatternPeriod() time.Duration {
+	d, _ := durationOr("value_pattern.period", c.Benchmark.ValuePattern.Period, time.Hour)
+	return d
+}
+
+// Query types selectable with benchmark.query_type
+const (
+	QueryTypeRange   = "range"
+	QueryTypeInstant = "instant"
+)
+
+// DiscoverySelectors parses discovery_matchers into one matcher set per
+// selector
+func (c *Config) DiscoverySelectors() ([][]*labels.Matcher, error) {
+	selectors := make([][]*labels.Matcher, 0, len(c.Benchmark.DiscoveryMatchers))
+	for _, selector := range c.Benchmark.DiscoveryMatchers {
+		matchers, err := ParseSelector(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid discovery_matchers entry %q: %w", selector, err)
+		}
+		selectors = append(selectors, matchers)
+	}
+	return selectors, nil
+}
+
+// MetricFilters returns the compiled include_metrics and exclude_metrics
+// patterns, compiling them on first use and failing on the first invalid one
+func (c *Config) MetricFilters() (include, exclude []*regexp.Regexp, err error) {
+	if c.filtersCompiled {
+		return c.includeRegexes, c.excludeRegexes, nil
+	}
+
+	include, err = compilePatterns("include_metrics", c.IncludeMetrics)
+	if err != nil {
+		return nil, nil, err
+	}
+	exclude, err = compilePatterns("exclude_metrics", c.ExcludeMetrics)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.includeRegexes, c.excludeRegexes, c.filtersCompiled = include, exclude, true
+	return include, exclude, nil
+}
+
+// compilePatterns compiles regex patterns, naming the offending pattern on error
+func compilePatterns(field string, patterns []string) ([]*regexp.Regexp, error) {
+	regexes := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern %q: %w", field, pattern, err)
+		}
+		regexes = append(regexes, regex)
+	}
+	return regexes, nil
+}
+
+// validate rejects rules that would remove the metric name or map two
+// labels onto the same name
+func (r LabelRules) validate() error {
+	dropped := make(map[string]bool, len(r.Drop))
+	for _, name := range r.Drop {
+		if name == model.MetricNameLabel {
+			return fmt.Errorf("label_rules.drop must not contain %s", model.MetricNameLabel)
+		}
+		dropped[name] = true
+	}
+
+	targets := make(map[string]string, len(r.Rename))
+	for from, to := range r.Rename {
+		if from == model.MetricNameLabel || to == model.MetricNameLabel {
+			return fmt.Errorf("label_rules.rename must not rename to or from %s", model.MetricNameLabel)
+		}
+		if !model.LabelName(to).IsValid() {
+			return fmt.Errorf("label_rules.rename: %q is not a valid label name", to)
+		}
+		if dropped[from] {
+			return fmt.Errorf("label_rules: %q is both dropped and renamed", from)
+		}
+		if other, ok := targets[to]; ok {
+			return fmt.Errorf("label_rules.rename: %q and %q are both renamed to %q", other, from, to)
+		}
+		targets[to] = from
+	}
+	return nil
+}
+
+// validate checks the pattern type and its parameters
+func (p ValuePattern) validate() error {
+	switch p.Type {
+	case "", ValuePatternConstant, ValuePatternLinear, ValuePatternRandomWalk, ValuePatternGaussian:
+	case ValuePatternSine:
+		period, err := durationOr("value_pattern.period", p.Period, time.Hour)
+		if err != nil {
+			return err
+		}
+		if period <= 0 {
+			return fmt.Errorf("value_pattern.period must be positive")
+		}
+	default:
+		return fmt.Errorf("value_pattern.type must be one of %s, %s, %s, %s, %s, got %q",
+			ValuePatternConstant, ValuePatternLinear, ValuePatternSine, ValuePatternRandomWalk, ValuePatternGaussian, p.Type)
+	}
+	if p.Step < 0 {
+		return fmt.Errorf("value_pattern.step must not be negative")
+	}
+	if p.StdDev < 0 {
+		return fmt.Errorf("value_pattern.stddev must not be negative")
+	}
+	return nil
+}
+
+// validate checks the schema range and that the bucket bounds stay finite
+func (h SyntheticHistogram) validate() error {
+	schema := h.SchemaOrDefault()
+	if schema < -4 || schema > 8 {
+		return fmt.Errorf("source.synthetic.native_histogram.schema must be between -4 and 8, got %d", schema)
+	}
+	if h.Buckets < 1 {
+		return fmt.Errorf("source.synthetic.native_histogram.buckets must be at least 1")
+	}
+	// The highest upper bound is 2^(buckets * 2^-schema)
+	if float64(h.Buckets)*math.Pow(2, -float64(schema)) >= 1024 {
+		return fmt.Errorf("source.synthetic.native_histogram.buckets %d overflows float64 bounds at schema %d", h.Buckets, schema)
+	}
+	if threshold := h.ZeroThresholdOrDefault(); threshold < 0 || threshold > 1 {
+		return fmt.Errorf("source.synthetic.native_histogram.zero_threshold must be between 0 and 1, the lowest bucket bound")
+	}
+	if h.ZeroFraction < 0 || h.ZeroFraction > 1 {
+		return fmt.Errorf("source.synthetic.native_histogram.zero_fraction must be between 0 and 1")
+	}
+	if h.Observations < 1 {
+		return fmt.Errorf("source.synthetic.native_histogram.observations must be at least 1")
+	}
+	return nil
 }
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
-	if c.Benchmark.ReplicationFactor < 1 {
-		return fmt.Errorf("replication_factor must be at least 1")
+	if _, err := c.SeriesMatchers(); err != nil {
+		return err
 	}
-	if c.Benchmark.QueryRangeHours < 1 {
-		return fmt.Errorf("query_range_hours must be at least 1")
+	if _, _, err := c.MetricFilters(); err != nil {
+		return err
 	}
-	if c.Benchmark.QueryStepSeconds < 1 {
-		return fmt.Errorf("query_step_seconds must be at least 1")
+	if c.Benchmark.QueryType == QueryTypeInstant && c.Source.Type != "prometheus" {
+		return fmt.Errorf("query_type %q requires the prometheus source", QueryTypeInstant)
+	}
+	switch c.Source.Type {
+	case "prometheus":
+		switch c.Benchmark.Discovery {
+		case DiscoveryNames:
+		case DiscoverySeries:
+			if len(c.Benchmark.DiscoveryMatchers) == 0 {
+				return fmt.Errorf("discovery_matchers must not be empty with series discovery")
+			}
+			if _, err := c.DiscoverySelectors(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("discovery must be %q or %q, got %q", DiscoveryNames, DiscoverySeries, c.Benchmark.Discovery)
+		}
+		if c.Benchmark.QueryType != QueryTypeRange && c.Benchmark.QueryType != QueryTypeInstant {
+			return fmt.Errorf("query_type must be %q or %q, got %q", QueryTypeRange, QueryTypeInstant, c.Benchmark.QueryType)
+		}
+		if c.Benchmark.QueryType == QueryTypeInstant {
+			if samples := int64(c.QueryRange()/c.SampleStep()) + 1; samples > maxInstantSamples {
+				return fmt.Errorf("query_type %q generates %d samples per series over query_range %s at samples_per_second %d, more than %d; shorten query_range",
+					QueryTypeInstant, samples, c.QueryRange(), c.Benchmark.SamplesPerSecond, maxInstantSamples)
+			}
+		}
+		if c.Benchmark.InstantWalkStep < 0 {
+			return fmt.Errorf("instant_walk_step must not be negative")
+		}
+	case "file":
+		if c.Source.Path == "" {
+			return fmt.Errorf("source.path is required for the file source")
+		}
+		if c.Source.Format != "ndjson" && c.Source.Format != "openmetrics" {
+			return fmt.Errorf("source.format must be \"ndjson\" or \"openmetrics\", got %q", c.Source.Format)
+		}
+	case "synthetic":
+		synthetic := c.Source.Synthetic
+		if synthetic.MetricCount < 1 {
+			return fmt.Errorf("source.synthetic.metric_count must be at least 1")
+		}
+		if synthetic.SeriesCount < 1 {
+			return fmt.Errorf("source.synthetic.series_count must be at least 1")
+		}
+		if synthetic.LabelsPerSeries < 0 {
+			return fmt.Errorf("source.synthetic.labels_per_series must not be negative")
+		}
+		if synthetic.ChurnRate < 0 || synthetic.ChurnRate > 1 {
+			return fmt.Errorf("source.synthetic.churn_rate must be between 0 and 1")
+		}
+		if synthetic.WalkStep < 0 {
+			return fmt.Errorf("source.synthetic.walk_step must not be negative")
+		}
+		if h := synthetic.NativeHistogram; h != nil {
+			if err := h.validate(); err != nil {
+				return err
+			}
+			if !c.Benchmark.SupportNativeHistograms {
+				return fmt.Errorf("source.synthetic.native_histogram requires support_native_histograms")
+			}
+		}
+	default:
+		return fmt.Errorf("source.type must be one of prometheus, file, synthetic, got %q", c.Source.Type)
+	}
+	if c.Benchmark.ReplicationFactor == nil {
+		return fmt.Errorf("replication_factor is not set")
+	}
+	if c.AllCombinations() {
+		if len(c.Replication) == 0 {
+			return fmt.Errorf("replication_factor 0 uses all replication_labels combinations and requires replication_labels")
+		}
+		for _, label := range c.Replication {
+			if len(label.Values) == 0 {
+				return fmt.Errorf("replication_factor 0 requires values for every replication label, %q has none", label.Name)
+			}
+		}
+	} else if *c.Benchmark.ReplicationFactor < 1 {
+		return fmt.Errorf("replication_factor must be at least 1, or 0 for all replication_labels combinations")
+	}
+	queryRange, err := c.queryRange()
+	if err != nil {
+		return err
+	}
+	queryStep, err := c.queryStep()
+	if err != nil {
+		return err
+	}
+	if queryRange <= 0 {
+		return fmt.Errorf("query range must be positive")
+	}
+	if queryStep <= 0 {
+		return fmt.Errorf("query step must be positive")
+	}
+	if c.Benchmark.QueryChunkHours < 0 {
+		return fmt.Errorf("query_chunk_hours must not be negative")
+	}
+	if queryRange <= queryStep {
+		return fmt.Errorf("query range (%s) must be greater than query step (%s)", queryRange, queryStep)
 	}
 	if c.Benchmark.SamplesPerSecond < 1 {
 		return fmt.Errorf("samples_per_second must be at least 1")
@@ -97,5 +1429,294 @@ func (c *Config) Validate() error {
 	if c.Benchmark.BatchSize < 1 {
 		return fmt.Errorf("batch_size must be at least 1")
 	}
+	if c.Benchmark.Retry.MaxRetries < 0 {
+		return fmt.Errorf("retry.max_retries must not be negative")
+	}
+	if c.Benchmark.Retry.InitialBackoffMs < 1 {
+		return fmt.Errorf("retry.initial_backoff_ms must be at least 1")
+	}
+	if c.Benchmark.Retry.MaxDelayMs < 0 {
+		return fmt.Errorf("retry.max_delay_ms must not be negative")
+	}
+	switch c.Benchmark.Retry.Jitter {
+	case "full", "equal", "none":
+	default:
+		return fmt.Errorf("retry.jitter must be one of full, equal, none, got %q", c.Benchmark.Retry.Jitter)
+	}
+	if c.Prometheus.QueryAuth.BearerToken != "" && c.Prometheus.QueryAuth.BearerTokenFile != "" {
+		return fmt.Errorf("query_auth: only one of bearer_token and bearer_token_file may be set")
+	}
+	if c.Prometheus.RemoteWriteAuth.Password != "" && c.Prometheus.RemoteWriteAuth.Username == "" {
+		return fmt.Errorf("remote_write_auth: password requires a username")
+	}
+	if err := validateHeaders("headers", c.Prometheus.Headers); err != nil {
+		return err
+	}
+	if err := validateHeaders("query_headers", c.Prometheus.QueryHeaders); err != nil {
+		return err
+	}
+	if err := validateHeaders("remote_write_headers", c.Prometheus.RemoteWriteHeaders); err != nil {
+		return err
+	}
+	if sigv4 := c.Prometheus.RemoteWriteAuth.SigV4; sigv4 != nil {
+		if c.Prometheus.RemoteWriteAuth.Username != "" {
+			return fmt.Errorf("remote_write_auth: sigv4 and basic auth are mutually exclusive")
+		}
+		if sigv4.Region == "" {
+			return fmt.Errorf("remote_write_auth.sigv4: region is required")
+		}
+		if (sigv4.AccessKey == "") != (sigv4.SecretKey == "") {
+			return fmt.Errorf("remote_write_auth.sigv4: access_key and secret_key must be set together")
+		}
+	}
+	if c.Benchmark.BurstSamples < 0 {
+		return fmt.Errorf("burst_samples must not be negative")
+	}
+	if c.Burst() < 1 {
+		return fmt.Errorf("rate limiter burst must be at least 1 (burst_multiplier=%g, burst_samples=%d)",
+			c.Benchmark.BurstMultiplier, c.Benchmark.BurstSamples)
+	}
+	if c.Benchmark.Concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1")
+	}
+	if c.Prometheus.QueryRetries != nil && *c.Prometheus.QueryRetries < 0 {
+		return fmt.Errorf("query_retries must not be negative")
+	}
+	if c.Prometheus.QueryTimeoutSeconds != nil && *c.Prometheus.QueryTimeoutSeconds < 0 {
+		return fmt.Errorf("query_timeout_seconds must not be negative")
+	}
+	if c.Prometheus.RemoteWriteTimeoutSeconds != nil && *c.Prometheus.RemoteWriteTimeoutSeconds < 0 {
+		return fmt.Errorf("remote_write_timeout_seconds must not be negative")
+	}
+	if c.Prometheus.RemoteWriteEncoding != "snappy" && c.Prometheus.RemoteWriteEncoding != "gzip" {
+		return fmt.Errorf("remote_write_encoding must be \"snappy\" or \"gzip\", got %q", c.Prometheus.RemoteWriteEncoding)
+	}
+	if c.Prometheus.SnappyFramed && c.Prometheus.RemoteWriteEncoding != "snappy" {
+		return fmt.Errorf("snappy_framed requires remote_write_encoding \"snappy\"")
+	}
+	if (c.Prometheus.TLS.CertFile == "") != (c.Prometheus.TLS.KeyFile == "") {
+		return fmt.Errorf("tls: cert_file and key_file must be set together")
+	}
+	if c.Benchmark.FutureSamples.Policy != "" && c.Benchmark.FutureSamples.Policy != "clamp" && c.Benchmark.FutureSamples.Policy != "drop" {
+		return fmt.Errorf("future_samples.policy must be \"clamp\" or \"drop\", got %q", c.Benchmark.FutureSamples.Policy)
+	}
+	if old := c.Benchmark.OldSamples; old.MaxSampleAge != "" {
+		if old.Policy != "clamp" && old.Policy != "drop" {
+			return fmt.Errorf("old_samples.policy must be \"clamp\" or \"drop\", got %q", old.Policy)
+		}
+		if d, err := time.ParseDuration(old.MaxSampleAge); err != nil || d <= 0 {
+			return fmt.Errorf("old_samples.max_sample_age must be a positive duration, got %q", old.MaxSampleAge)
+		}
+	}
+	for name := range c.Benchmark.ExtraLabels {
+		if !model.LabelName(name).IsValid() || name == labels.MetricName {
+			return fmt.Errorf("extra_labels: invalid label name %q", name)
+		}
+	}
+	if c.Benchmark.RunLabel != "" && !model.LabelName(c.Benchmark.RunLabel).IsValid() {
+		return fmt.Errorf("run_label must be a valid label name, got %q", c.Benchmark.RunLabel)
+	}
+	if adaptive := c.Benchmark.AdaptiveRate; adaptive.Enabled {
+		if adaptive.MinSamplesPerSecond < 1 || adaptive.MaxSamplesPerSecond < adaptive.MinSamplesPerSecond {
+			return fmt.Errorf("adaptive_rate: need 1 <= min_samples_per_second <= max_samples_per_second")
+		}
+		if adaptive.IncreaseSamplesPerSecond < 1 {
+			return fmt.Errorf("adaptive_rate.increase_samples_per_second must be at least 1")
+		}
+		if adaptive.DecreaseFactor <= 0 || adaptive.DecreaseFactor >= 1 {
+			return fmt.Errorf("adaptive_rate.decrease_factor must be between 0 and 1")
+		}
+	}
+	if c.Benchmark.GenerateMetadata.Enabled {
+		if !c.Benchmark.IncludeMetadata {
+			return fmt.Errorf("generate_metadata requires include_metadata")
+		}
+		if c.Benchmark.GenerateMetadata.HelpLength < 0 {
+			return fmt.Errorf("generate_metadata.help_length must not be negative")
+		}
+	}
+	if c.Output.CheckpointEverySeries < 0 {
+		return fmt.Errorf("output.checkpoint_every_series must not be negative")
+	}
+	if failures := c.Loopback.Failures; c.Loopback.Enabled {
+		if failures.FirstK < 0 || failures.EveryN < 0 {
+			return fmt.Errorf("loopback.failures: first_k and every_n must not be negative")
+		}
+		if failures.Percent < 0 || failures.Percent > 100 {
+			return fmt.Errorf("loopback.failures.percent must be between 0 and 100")
+		}
+		for _, status := range failures.Statuses {
+			if status < 400 || status > 599 {
+				return fmt.Errorf("loopback.failures.statuses must be 4xx or 5xx, got %d", status)
+			}
+		}
+	}
+	if adaptive := c.Benchmark.AdaptiveConcurrency; adaptive.Enabled {
+		if adaptive.MinConcurrency < 1 || adaptive.MaxConcurrency < adaptive.MinConcurrency {
+			return fmt.Errorf("adaptive_concurrency: need 1 <= min_concurrency <= max_concurrency")
+		}
+		if adaptive.MaxConcurrency > c.Benchmark.WriteQueue.Senders {
+			return fmt.Errorf("adaptive_concurrency.max_concurrency %d exceeds the %d write_queue.senders", adaptive.MaxConcurrency, c.Benchmark.WriteQueue.Senders)
+		}
+		if adaptive.LatencyTolerance < 1 {
+			return fmt.Errorf("adaptive_concurrency.latency_tolerance must be at least 1")
+		}
+		if adaptive.DecreaseFactor <= 0 || adaptive.DecreaseFactor >= 1 {
+			return fmt.Errorf("adaptive_concurrency.decrease_factor must be between 0 and 1")
+		}
+	}
+	if c.Benchmark.LatencyWarnMs < 0 {
+		return fmt.Errorf("latency_warn_ms must not be negative")
+	}
+	if c.Benchmark.MaxTotalSeries < 0 {
+		return fmt.Errorf("max_total_series must not be negative")
+	}
+	if c.Benchmark.MaxSamplesPerSeries < 0 {
+		return fmt.Errorf("max_samples_per_series must not be negative")
+	}
+	if err := c.Benchmark.LabelRules.validate(); err != nil {
+		return err
+	}
+	for i, rule := range c.Benchmark.Relabel {
+		if _, err := rule.CompiledRegex(); err != nil {
+			return fmt.Errorf("relabel[%d]: invalid regex %q: %w", i, rule.Regex, err)
+		}
+		switch rule.Action {
+		case "replace":
+			if !model.LabelName(rule.TargetLabel).IsValid() {
+				return fmt.Errorf("relabel[%d]: replace requires a valid target_label, got %q", i, rule.TargetLabel)
+			}
+		case "drop":
+		default:
+			return fmt.Errorf("relabel[%d]: action must be \"replace\" or \"drop\", got %q", i, rule.Action)
+		}
+		if len(rule.SourceLabels) == 0 {
+			return fmt.Errorf("relabel[%d]: source_labels must not be empty", i)
+		}
+	}
+	if c.Benchmark.SyntheticJobs.Count < 0 {
+		return fmt.Errorf("synthetic_jobs.count must not be negative")
+	}
+	switch c.Benchmark.OrderingCheck {
+	case "", "off", "fail", "drop":
+	default:
+		return fmt.Errorf("ordering_check must be one of off, fail, drop, got %q", c.Benchmark.OrderingCheck)
+	}
+	if c.Benchmark.FutureSamples.ToleranceMs < 0 {
+		return fmt.Errorf("future_samples.tolerance_ms must not be negative")
+	}
+	switch c.Benchmark.TimestampMode {
+	case "coordinated", "preserve", "shift":
+	default:
+		return fmt.Errorf("timestamp_mode must be one of coordinated, preserve, shift, got %q", c.Benchmark.TimestampMode)
+	}
+	if accel := c.Benchmark.TimeAcceleration; accel <= 0 {
+		return fmt.Errorf("time_acceleration must be greater than 0, got %g", accel)
+	} else if accel != 1 {
+		if c.Benchmark.TimestampMode == "coordinated" {
+			return fmt.Errorf("time_acceleration requires timestamp_mode preserve or shift")
+		}
+		if float64(queryStep)/accel < float64(time.Millisecond) {
+			return fmt.Errorf("time_acceleration %g squeezes query step %s below 1ms", accel, queryStep)
+		}
+	}
+	switch c.Benchmark.DuplicateTimestamps {
+	case "":
+	case "drop", "keep_last", "nudge":
+		if c.Benchmark.TimestampMode == "coordinated" {
+			return fmt.Errorf("duplicate_timestamps requires timestamp_mode preserve or shift")
+		}
+	default:
+		return fmt.Errorf("duplicate_timestamps must be one of drop, keep_last, nudge, got %q", c.Benchmark.DuplicateTimestamps)
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		return fmt.Errorf("log_format must be \"json\" or \"text\", got %q", c.LogFormat)
+	}
+	if c.LogSampleRate < 0 {
+		return fmt.Errorf("log_sample_rate must not be negative")
+	}
+	if c.LogMaxSizeMB != nil && *c.LogMaxSizeMB < 0 {
+		return fmt.Errorf("log_max_size_mb must not be negative")
+	}
+	if c.LogMaxBackups != nil && *c.LogMaxBackups < 0 {
+		return fmt.Errorf("log_max_backups must not be negative")
+	}
+	if c.Benchmark.PerMetricTimeoutSeconds < 0 {
+		return fmt.Errorf("per_metric_timeout_seconds must not be negative")
+	}
+	if c.Benchmark.ShutdownTimeoutSeconds < 0 {
+		return fmt.Errorf("shutdown_timeout_seconds must not be negative")
+	}
+	if c.Benchmark.TotalTimeoutSeconds < 0 {
+		return fmt.Errorf("total_timeout_seconds must not be negative")
+	}
+	if r := c.Benchmark.MaxFailureRatio; r != nil && (*r < 0 || *r > 1) {
+		return fmt.Errorf("max_failure_ratio must be between 0 and 1, got %g", *r)
+	}
+	if c.Benchmark.CardinalityWarnThreshold < 0 {
+		return fmt.Errorf("cardinality_warn_threshold must not be negative")
+	}
+	if c.Benchmark.SampleMetrics.Count < 0 || c.Benchmark.SampleMetrics.Percent < 0 || c.Benchmark.SampleMetrics.Percent > 100 {
+		return fmt.Errorf("sample_metrics: count must not be negative and percent must be between 0 and 100")
+	}
+	if c.Benchmark.CircuitBreaker.FailureThreshold < 0 || c.Benchmark.CircuitBreaker.CooldownSeconds < 0 {
+		return fmt.Errorf("circuit_breaker: failure_threshold and cooldown_seconds must not be negative")
+	}
+	if c.Prometheus.WriteProtocol != "remote_write" && c.Prometheus.WriteProtocol != "otlp" {
+		return fmt.Errorf("write_protocol must be \"remote_write\" or \"otlp\", got %q", c.Prometheus.WriteProtocol)
+	}
+	if c.Prometheus.SnappyFramed && c.Prometheus.WriteProtocol == "otlp" {
+		return fmt.Errorf("snappy_framed is not supported with write_protocol \"otlp\"")
+	}
+	if c.Prometheus.MaxIdleConns < 0 || c.Prometheus.MaxConnsPerHost < 0 {
+		return fmt.Errorf("max_idle_conns and max_conns_per_host must not be negative")
+	}
+	if d, err := time.ParseDuration(c.Prometheus.IdleConnTimeout); err != nil || d <= 0 {
+		return fmt.Errorf("idle_conn_timeout must be a positive duration, got %q", c.Prometheus.IdleConnTimeout)
+	}
+	if c.Prometheus.MaxRequestBytes < 0 {
+		return fmt.Errorf("max_request_bytes must not be negative")
+	}
+	if c.Benchmark.WriteQueue.Depth < 0 || c.Benchmark.WriteQueue.Senders < 0 {
+		return fmt.Errorf("write_queue: depth and senders must not be negative")
+	}
+	if c.Benchmark.MaxConcurrentQueries < 0 {
+		return fmt.Errorf("max_concurrent_queries must not be negative")
+	}
+	if c.Benchmark.MaxGoroutines < 0 {
+		return fmt.Errorf("max_goroutines must not be negative")
+	}
+	if c.Benchmark.TimestampResolution != "ms" && c.Benchmark.TimestampResolution != "s" {
+		return fmt.Errorf("timestamp_resolution must be \"ms\" or \"s\", got %q", c.Benchmark.TimestampResolution)
+	}
+	if inc := c.Benchmark.TimestampIncrement; inc != TimestampIncrementStep {
+		d, err := time.ParseDuration(inc)
+		if err != nil {
+			return fmt.Errorf("timestamp_increment must be a duration or %q, got %q", TimestampIncrementStep, inc)
+		}
+		if d < time.Millisecond || d%time.Millisecond != 0 {
+			return fmt.Errorf("timestamp_increment must be a whole number of milliseconds, got %q", inc)
+		}
+	}
+	if c.Benchmark.ReplicaVariation < 0 || c.Benchmark.ReplicaVariation >= 1 {
+		return fmt.Errorf("replica_variation must be in [0, 1)")
+	}
+	if c.Benchmark.ExemplarFraction < 0 || c.Benchmark.ExemplarFraction > 1 {
+		return fmt.Errorf("exemplar_fraction must be in [0, 1]")
+	}
+	if c.Benchmark.ValueJitter < 0 || c.Benchmark.ValueJitter >= 1 {
+		return fmt.Errorf("value_jitter must be in [0, 1)")
+	}
+	switch c.Benchmark.ValueArrangement {
+	case "", "rotate", "shuffle":
+	default:
+		return fmt.Errorf("value_arrangement must be \"rotate\" or \"shuffle\", got %q", c.Benchmark.ValueArrangement)
+	}
+	if err := c.Benchmark.ValuePattern.validate(); err != nil {
+		return err
+	}
+	if c.Benchmark.EarlyAbortBatches < -1 {
+		return fmt.Errorf("early_abort_batches must be -1 (disabled) or positive")
+	}
 	return nil
 }
@@ -0,0 +1,502 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes YAML to a temporary file and returns its path
+func writeConfig(t *testing.T, text string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadConfig loads YAML through LoadConfig, failing the test on error
+func loadConfig(t *testing.T, text string) *Config {
+	t.Helper()
+
+	cfg, err := LoadConfig(writeConfig(t, text))
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	return cfg
+}
+
+func TestReplicationFactorAllCombinations(t *testing.T) {
+	cfg := loadConfig(t, `
+benchmark:
+  replication_factor: 0
+replication_labels:
+  - name: region
+    values: [eu, us, ap]
+  - name: zone
+    values: [a, b]
+`)
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.AllCombinations() {
+		t.Error("AllCombinations() = false for replication_factor 0")
+	}
+	if got := cfg.ReplicationFactor(); got != 6 {
+		t.Errorf("ReplicationFactor() = %d, want 6", got)
+	}
+}
+
+func TestReplicationFactorDefault(t *testing.T) {
+	cfg := loadConfig(t, "")
+	if got := cfg.ReplicationFactor(); got != 2 {
+		t.Errorf("ReplicationFactor() = %d, want the default 2", got)
+	}
+	if cfg.AllCombinations() {
+		t.Error("AllCombinations() = true for the default factor")
+	}
+}
+
+func TestReplicationFactorNil(t *testing.T) {
+	var cfg Config
+	if cfg.AllCombinations() || cfg.ReplicationFactor() != 0 {
+		t.Error("unset replication_factor must resolve to 0 without all combinations")
+	}
+
+	cfg = *loadConfig(t, "")
+	cfg.Benchmark.ReplicationFactor = nil
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "replication_factor") {
+		t.Errorf("Validate() with a nil factor = %v, want a replication_factor error", err)
+	}
+}
+
+func TestDuplicateTimestampsValidation(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		wantErr string
+	}{
+		{"benchmark:\n  timestamp_mode: preserve\n  duplicate_timestamps: nudge\n", ""},
+		{"benchmark:\n  timestamp_mode: shift\n  duplicate_timestamps: keep_last\n", ""},
+		{"benchmark:\n  duplicate_timestamps: drop\n", "requires timestamp_mode preserve or shift"},
+		{"benchmark:\n  timestamp_mode: preserve\n  duplicate_timestamps: first\n", "must be one of drop, keep_last, nudge"},
+	}
+	for _, tt := range tests {
+		err := loadConfig(t, tt.yaml).Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q: %v", tt.yaml, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q: err = %v, want %q", tt.yaml, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSyntheticNativeHistogramValidation(t *testing.T) {
+	const source = "source:\n  type: synthetic\n  synthetic:\n    native_histogram:\n      "
+	tests := []struct {
+		yaml    string
+		wantErr string
+	}{
+		{"benchmark:\n  support_native_histograms: true\n" + source + "schema: 0\n", ""},
+		{source + "schema: 0\n", "requires support_native_histograms"},
+		{"benchmark:\n  support_native_histograms: true\n" + source + "schema: 9\n", "schema must be between -4 and 8"},
+		{"benchmark:\n  support_native_histograms: true\n" + source + "schema: -4\n      buckets: 64\n", "overflows float64 bounds"},
+		{"benchmark:\n  support_native_histograms: true\n" + source + "zero_threshold: 2\n", "zero_threshold must be between 0 and 1"},
+	}
+	for _, tt := range tests {
+		err := loadConfig(t, tt.yaml).Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q: %v", tt.yaml, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q: err = %v, want %q", tt.yaml, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSyntheticNativeHistogramValidationWithoutDefaults(t *testing.T) {
+	cfg := loadConfig(t, "benchmark:\n  support_native_histograms: true\nsource:\n  type: synthetic\n  synthetic:\n    native_histogram:\n      buckets: 10\n")
+
+	// A config built in code skips the defaults of the unset pointers
+	h := cfg.Source.Synthetic.NativeHistogram
+	h.Schema, h.ZeroThreshold = nil, nil
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validating without schema and zero_threshold: %v", err)
+	}
+	if got := h.SchemaOrDefault(); got != 3 {
+		t.Errorf("schema = %d, want the default 3", got)
+	}
+	if got := h.ZeroThresholdOrDefault(); got != math.Exp2(-128) {
+		t.Errorf("zero threshold = %g, want the default 2^-128", got)
+	}
+
+	schema := 9
+	h.Schema = &schema
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "schema must be between -4 and 8") {
+		t.Errorf("err = %v, want the schema range error", err)
+	}
+}
+
+func TestLoopbackValidation(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		wantErr string
+	}{
+		{"loopback:\n  enabled: true\n  failures:\n    every_n: 3\n    statuses: [429, 503]\n", ""},
+		{"loopback:\n  enabled: true\n  failures:\n    percent: 101\n", "percent must be between 0 and 100"},
+		{"loopback:\n  enabled: true\n  failures:\n    statuses: [204]\n", "must be 4xx or 5xx, got 204"},
+		{"loopback:\n  failures:\n    first_k: -1\n", ""},
+	}
+	for _, tt := range tests {
+		err := loadConfig(t, tt.yaml).Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q: %v", tt.yaml, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q: err = %v, want %q", tt.yaml, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSnappyFramedValidation(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		wantErr string
+	}{
+		{"prometheus:\n  snappy_framed: true\n", ""},
+		{"prometheus:\n  snappy_framed: true\n  remote_write_encoding: gzip\n", "requires remote_write_encoding \"snappy\""},
+		{"prometheus:\n  snappy_framed: true\n  write_protocol: otlp\n", "not supported with write_protocol \"otlp\""},
+		{"prometheus:\n  write_protocol: otlp\n", ""},
+	}
+	for _, tt := range tests {
+		err := loadConfig(t, tt.yaml).Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q: %v", tt.yaml, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q: err = %v, want %q", tt.yaml, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBurstValidation(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		wantErr string
+	}{
+		{"benchmark:\n  samples_per_second: 10\n  burst_multiplier: 0.1\n", ""},
+		{"benchmark:\n  samples_per_second: 10\n  burst_multiplier: 0.05\n", "rate limiter burst must be at least 1"},
+		{"benchmark:\n  samples_per_second: 10\n  burst_multiplier: 0.05\n  burst_samples: 1\n", ""},
+		{"benchmark:\n  burst_samples: -1\n", "burst_samples must not be negative"},
+	}
+	for _, tt := range tests {
+		err := loadConfig(t, tt.yaml).Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%q: %v", tt.yaml, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%q: err = %v, want %q", tt.yaml, err, tt.wantErr)
+		}
+	}
+}
+
+func TestGenerateMetadataRequiresIncludeMetadata(t *testing.T) {
+	err := loadConfig(t, "benchmark:\n  generate_metadata:\n    enabled: true\n").Validate()
+	if err == nil || !strings.Contains(err.Error(), "requires include_metadata") {
+		t.Errorf("err = %v, want include_metadata required", err)
+	}
+	if err := loadConfig(t, "benchmark:\n  include_metadata: true\n  generate_metadata:\n    enabled: true\n").Validate(); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+}
+
+func TestLogRotationZeroValues(t *testing.T) {
+	tests := []struct {
+		yaml          string
+		size, backups int
+	}{
+		{"", 100, 3},
+		{"log_max_size_mb: 0\n", 0, 3},
+		{"log_max_backups: 0\n", 100, 0},
+		{"log_max_size_mb: 10\nlog_max_backups: 5\n", 10, 5},
+	}
+	for _, tt := range tests {
+		cfg := loadConfig(t, tt.yaml)
+		if got := cfg.LogMaxSize(); got != tt.size {
+			t.Errorf("%q: LogMaxSize() = %d, want %d", tt.yaml, got, tt.size)
+		}
+		if got := cfg.LogBackups(); got != tt.backups {
+			t.Errorf("%q: LogBackups() = %d, want %d", tt.yaml, got, tt.backups)
+		}
+	}
+}
+
+func TestExpandEnvRefs(t *testing.T) {
+	t.Setenv("PROMFIRE_TEST_URL", "http://prometheus:9090")
+	t.Setenv("PROMFIRE_TEST_TOKEN", "secret")
+
+	cfg := loadConfig(t, `
+# query_url: ${PROMFIRE_TEST_UNSET}
+prometheus:
+  query_url: ${PROMFIRE_TEST_URL}
+  headers:
+    Authorization: Bearer ${PROMFIRE_TEST_TOKEN}
+  remote_write_url: ${PROMFIRE_TEST_UNSET:-http://receiver:9090}/api/v1/write
+exclude_metrics:
+  - "^cost_$$"
+`)
+	if got := cfg.Prometheus.QueryURL; got != "http://prometheus:9090" {
+		t.Errorf("query_url = %q, want the environment value", got)
+	}
+	if got := cfg.Prometheus.Headers["Authorization"]; got != "Bearer secret" {
+		t.Errorf("header = %q, want the expanded map value", got)
+	}
+	if got := cfg.Prometheus.RemoteWriteURL; got != "http://receiver:9090/api/v1/write" {
+		t.Errorf("remote_write_url = %q, want the default", got)
+	}
+	if got := cfg.ExcludeMetrics[0]; got != "^cost_$" {
+		t.Errorf("exclude_metrics[0] = %q, want $$ as a literal $", got)
+	}
+}
+
+func TestExpandEnvRefsUnsetVariable(t *testing.T) {
+	_, err := LoadConfig(writeConfig(t, "prometheus:\n  query_url: ${PROMFIRE_TEST_UNSET}\n"))
+	if err == nil || !strings.Contains(err.Error(), "prometheus.query_url") || !strings.Contains(err.Error(), "PROMFIRE_TEST_UNSET") {
+		t.Errorf("LoadConfig() = %v, want an error naming the field and variable", err)
+	}
+}
+
+func TestRemoteWriteAuthPasswordFromEnv(t *testing.T) {
+	t.Setenv("PROM_PASSWORD", "s3cr$t")
+
+	cfg := loadConfig(t, "prometheus:\n  remote_write_auth:\n    username: bench\n    password: ${PROM_PASSWORD}\n")
+	if got := cfg.Prometheus.RemoteWriteAuth.Password; got != "s3cr$t" {
+		t.Errorf("password = %q, want the environment value without re-expanding its $", got)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	cfg = loadConfig(t, "prometheus:\n  remote_write_auth:\n    username: bench\n    password: pa$$word\n")
+	if got := cfg.Prometheus.RemoteWriteAuth.Password; got != "pa$word" {
+		t.Errorf("password = %q, want $$ as a literal $", got)
+	}
+
+	_, err := LoadConfig(writeConfig(t, "prometheus:\n  remote_write_auth:\n    username: bench\n    password: ${PROM_PASSWORD_UNSET}\n"))
+	if err == nil || !strings.Contains(err.Error(), "prometheus.remote_write_auth.password") {
+		t.Errorf("LoadConfig() = %v, want an error naming prometheus.remote_write_auth.password", err)
+	}
+}
+
+func TestExpandEnvRefsRemoteConfig(t *testing.T) {
+	t.Setenv("PROMFIRE_TEST_TOKEN", "secret")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("prometheus:\n  headers:\n    Authorization: ${PROMFIRE_TEST_TOKEN}\n"))
+	}))
+	defer srv.Close()
+
+	cfg, err := LoadConfig(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Prometheus.Headers["Authorization"]; got != "${PROMFIRE_TEST_TOKEN}" {
+		t.Errorf("remote header = %q, want it left unexpanded", got)
+	}
+
+	cfg, err = LoadConfigWithOptions(srv.URL, LoadOptions{ExpandRemoteEnv: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Prometheus.Headers["Authorization"]; got != "secret" {
+		t.Errorf("remote header with ExpandRemoteEnv = %q, want it expanded", got)
+	}
+}
+
+func TestQueryRangeAndStep(t *testing.T) {
+	tests := []struct {
+		yaml      string
+		wantRange time.Duration
+		wantStep  time.Duration
+		wantErr   string
+	}{
+		{"benchmark: {}\n", 24 * time.Hour, time.Minute, ""},
+		{"benchmark:\n  query_range: 90m\n  query_step: 30s\n", 90 * time.Minute, 30 * time.Second, ""},
+		{"benchmark:\n  query_range_hours: 2\n  query_step_seconds: 15\n", 2 * time.Hour, 15 * time.Second, ""},
+		// The durations take precedence over the deprecated int fields
+		{"benchmark:\n  query_range: 2.5h\n  query_range_hours: 5\n  query_step: 10s\n  query_step_seconds: 60\n", 150 * time.Minute, 10 * time.Second, ""},
+		{"benchmark:\n  query_range: 36h\n  query_step_seconds: 300\n", 36 * time.Hour, 5 * time.Minute, ""},
+		{"benchmark:\n  query_range: 36 hours\n", 0, 0, `invalid query_range "36 hours"`},
+		{"benchmark:\n  query_step: 1\n", 0, 0, `invalid query_step "1"`},
+		{"benchmark:\n  query_range: -1h\n", 0, 0, "query range must be positive"},
+		{"benchmark:\n  query_step: 0s\n", 0, 0, "query step must be positive"},
+		{"benchmark:\n  query_range: 30s\n  query_step: 1m\n", 0, 0, "query range (30s) must be greater than query step (1m0s)"},
+	}
+	for _, tt := range tests {
+		cfg := loadConfig(t, tt.yaml)
+		err := cfg.Validate()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: err = %v, want %q", tt.yaml, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.yaml, err)
+			continue
+		}
+		if cfg.QueryRange() != tt.wantRange || cfg.QueryStep() != tt.wantStep {
+			t.Errorf("%q: range %s and step %s, want %s and %s", tt.yaml, cfg.QueryRange(), cfg.QueryStep(), tt.wantRange, tt.wantStep)
+		}
+	}
+}
+
+func TestInstantSampleStep(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		step    time.Duration
+		wantErr string
+	}{
+		{"benchmark:\n  query_step: 15s\n", 15 * time.Second, ""},
+		{"benchmark:\n  query_type: instant\n  query_range: 1m\n  query_step: 1s\n  samples_per_second: 100\n", 10 * time.Millisecond, ""},
+		{"benchmark:\n  query_type: instant\n  query_range: 1m\n  query_step: 1s\n  samples_per_second: 3000\n", time.Millisecond, ""},
+		{"benchmark:\n  query_type: instant\n", time.Millisecond, "shorten query_range"},
+		{"benchmark:\n  query_type: instant\n  query_range: 1m\n  query_step: 1s\n  instant_walk_step: -1\n", time.Millisecond, "instant_walk_step"},
+	}
+	for _, tt := range tests {
+		cfg := loadConfig(t, tt.yaml)
+		if got := cfg.SampleStep(); got != tt.step {
+			t.Errorf("%q: SampleStep() = %s, want %s", tt.yaml, got, tt.step)
+		}
+		err := cfg.Validate()
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%q: Validate() = %v, want no error", tt.yaml, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%q: Validate() = %v, want an error containing %q", tt.yaml, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     string
+		wantErr  string
+	}{
+		{`up`, `[__name__="up"]`, ""},
+		{`up{job="node"}`, `[__name__="up" job="node"]`, ""},
+		{` { job = "node" , instance=~'prod.*', env!="dev", zone!~` + "`eu-.*`" + `, } `, `[job="node" instance=~"prod.*" env!="dev" zone!~"eu-.*"]`, ""},
+		{`node:cpu:rate5m{mode!='idle\'s'}`, `[__name__="node:cpu:rate5m" mode!="idle's"]`, ""},
+		{`{path="a\"b\\c"}`, `[path="a\"b\\c"]`, ""},
+		{`{}`, "", "at least one matcher"},
+		{`{job=~".*"}`, "", "does not match the empty string"},
+		{`{job="node"`, "", "expected"},
+		{`{job:x="node"}`, "", "matcher operator"},
+		{`{job=node}`, "", "quoted value"},
+		{`{job="node}`, "", "unterminated"},
+		{`{job=~"("}`, "", "invalid matcher job"},
+		{`up{job="a"} or down`, "", "unexpected"},
+	}
+	for _, tt := range tests {
+		matchers, err := ParseSelector(tt.selector)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseSelector(%s) = %v, want an error containing %q", tt.selector, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSelector(%s): %v", tt.selector, err)
+			continue
+		}
+		if got := fmt.Sprint(matchers); got != tt.want {
+			t.Errorf("ParseSelector(%s) = %s, want %s", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "replication_label:\n  - name: region\n    values: [a, b]\n")
+	_, err := LoadConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "replication_label") || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("LoadConfig() = %v, want an error naming the key replication_label and its line", err)
+	}
+
+	cfg, err := LoadConfigWithOptions(path, LoadOptions{AllowUnknownFields: true})
+	if err != nil {
+		t.Fatalf("LoadConfigWithOptions() with AllowUnknownFields = %v, want the key ignored", err)
+	}
+	if len(cfg.Replication) != 0 {
+		t.Errorf("replication = %+v, want the misspelled key ignored", cfg.Replication)
+	}
+}
+
+func TestLoadConfigFromStdin(t *testing.T) {
+	stdin, err := os.Open(writeConfig(t, "prometheus:\n  query_url: http://stdin:9090\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdin.Close()
+	defer func(orig *os.File) { os.Stdin = orig }(os.Stdin)
+	os.Stdin = stdin
+
+	cfg, err := LoadConfig(StdinPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Prometheus.QueryURL != "http://stdin:9090" {
+		t.Errorf("query_url = %q, want it read from stdin", cfg.Prometheus.QueryURL)
+	}
+}
+
+func TestLoadConfigFromURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{"yaml", http.StatusOK, "application/yaml", "prometheus:\n  query_url: http://remote:9090\n", ""},
+		{"no content type", http.StatusOK, "", "prometheus:\n  query_url: http://remote:9090\n", ""},
+		{"not found", http.StatusNotFound, "text/plain", "no such config\n", "returned HTTP 404: no such config"},
+		{"server error", http.StatusInternalServerError, "text/plain", "backend down", "returned HTTP 500: backend down"},
+		{"html", http.StatusOK, "text/html; charset=utf-8", "<html><body>Sign in</body></html>", "returned HTML instead of YAML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// A nil value stops net/http from sniffing a content type
+				w.Header()["Content-Type"] = nil
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			cfg, err := LoadConfig(srv.URL + "/promfire.yaml")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig() = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Prometheus.QueryURL != "http://remote:9090" {
+				t.Errorf("query_url = %q, want it fetched from the URL", cfg.Prometheus.QueryURL)
+			}
+		})
+	}
+}
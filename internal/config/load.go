@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"promfire/internal/version"
+)
+
+// StdinPath is the config path that reads the configuration from stdin
+const StdinPath = "-"
+
+// Limits for fetching the configuration from a URL
+const (
+	configFetchTimeout = 30 * time.Second
+	maxConfigBytes     = 10 << 20
+	configErrorBody    = 256
+)
+
+// readConfig returns the raw configuration at path, which is a file, "-"
+// for stdin, or an http(s) URL
+func readConfig(path string) ([]byte, error) {
+	switch {
+	case path == StdinPath:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("reading config from stdin: %w", err)
+		}
+		return data, nil
+	case isConfigURL(path):
+		return fetchConfig(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	return data, nil
+}
+
+// isConfigURL reports whether the config path is an http(s) URL to fetch
+func isConfigURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// fetchConfig downloads the configuration from a URL. Any YAML, JSON or
+// plain text content type is accepted, as well as none at all; HTML is
+// rejected since it is usually a login or error page.
+func fetchConfig(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating config request: %w", err)
+	}
+	req.Header.Set("User-Agent", "promfire/"+version.Version)
+	req.Header.Set("Accept", "application/yaml, application/json;q=0.9, text/plain;q=0.8, */*;q=0.1")
+
+	client := &http.Client{Timeout: configFetchTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, configErrorBody))
+		return nil, fmt.Errorf("fetching config: %s returned HTTP %d: %s", url, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("fetching config: invalid content type %q: %w", contentType, err)
+		}
+		if mediaType == "text/html" {
+			return nil, fmt.Errorf("fetching config: %s returned HTML instead of YAML", url)
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching config: reading body: %w", err)
+	}
+	if len(data) > maxConfigBytes {
+		return nil, fmt.Errorf("fetching config: body exceeds %d bytes", maxConfigBytes)
+	}
+	return data, nil
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// ParseSelector parses a PromQL series selector such as
+// up{job="node",instance=~"prod.*"} into its label matchers. It covers the
+// selector syntax only, metric name and braces with =, !=, =~ and !~
+// matchers on quoted values, without pulling in the PromQL parser.
+func ParseSelector(selector string) ([]*labels.Matcher, error) {
+	p := &selectorParser{input: selector}
+	p.skipSpace()
+
+	var matchers []*labels.Matcher
+	if name := p.name(true); name != "" {
+		m, err := labels.NewMatcher(labels.MatchEqual, labels.MetricName, name)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+		p.skipSpace()
+	}
+
+	if p.consume("{") {
+		for {
+			p.skipSpace()
+			if p.consume("}") {
+				break
+			}
+			m, err := p.matcher()
+			if err != nil {
+				return nil, err
+			}
+			matchers = append(matchers, m)
+
+			p.skipSpace()
+			if p.consume("}") {
+				break
+			}
+			if !p.consume(",") {
+				return nil, p.errorf("expected \",\" or \"}\"")
+			}
+		}
+		p.skipSpace()
+	}
+
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos:])
+	}
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("selector must contain at least one matcher")
+	}
+	for _, m := range matchers {
+		if !m.Matches("") {
+			return matchers, nil
+		}
+	}
+	return nil, fmt.Errorf("selector must contain at least one matcher that does not match the empty string")
+}
+
+// selectorParser scans a selector left to right
+type selectorParser struct {
+	input string
+	pos   int
+}
+
+// matcherOps are the matcher operators, two-character ones first so that
+// != is not read as =
+var matcherOps = []struct {
+	op   string
+	kind labels.MatchType
+}{
+	{"=~", labels.MatchRegexp},
+	{"!~", labels.MatchNotRegexp},
+	{"!=", labels.MatchNotEqual},
+	{"=", labels.MatchEqual},
+}
+
+// matcher parses one name, operator and quoted value
+func (p *selectorParser) matcher() (*labels.Matcher, error) {
+	name := p.name(false)
+	if name == "" {
+		return nil, p.errorf("expected a label name")
+	}
+	p.skipSpace()
+
+	kind, ok := labels.MatchType(0), false
+	for _, op := range matcherOps {
+		if p.consume(op.op) {
+			kind, ok = op.kind, true
+			break
+		}
+	}
+	if !ok {
+		return nil, p.errorf("expected a matcher operator after %q", name)
+	}
+	p.skipSpace()
+
+	value, err := p.quoted()
+	if err != nil {
+		return nil, err
+	}
+	m, err := labels.NewMatcher(kind, name, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid matcher %s: %w", name, err)
+	}
+	return m, nil
+}
+
+// name consumes a metric or, without colons, label name, returning "" if
+// there is none
+func (p *selectorParser) name(metric bool) string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '_' || metric && c == ':' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || p.pos > start && '0' <= c && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.input[start:p.pos]
+}
+
+// quoted consumes a double, single or backtick quoted string with Go
+// escapes, as PromQL accepts them
+func (p *selectorParser) quoted() (string, error) {
+	if p.pos >= len(p.input) {
+		return "", p.errorf("expected a quoted value")
+	}
+	quote := p.input[p.pos]
+	if quote != '"' && quote != '\'' && quote != '`' {
+		return "", p.errorf("expected a quoted value")
+	}
+
+	end := p.pos + 1
+	for ; end < len(p.input) && p.input[end] != quote; end++ {
+		if p.input[end] == '\\' && quote != '`' {
+			end++
+		}
+	}
+	if end >= len(p.input) {
+		return "", p.errorf("unterminated quoted value")
+	}
+
+	raw := p.input[p.pos : end+1]
+	if quote == '\'' {
+		// strconv.Unquote reads single quotes as a rune literal, so the
+		// value is requoted with double quotes
+		var b strings.Builder
+		b.WriteByte('"')
+		for i := 1; i < len(raw)-1; i++ {
+			switch {
+			case raw[i] == '\\' && raw[i+1] == '\'':
+				b.WriteByte('\'')
+				i++
+			case raw[i] == '\\':
+				b.WriteString(raw[i : i+2])
+				i++
+			case raw[i] == '"':
+				b.WriteString(`\"`)
+			default:
+				b.WriteByte(raw[i])
+			}
+		}
+		b.WriteByte('"')
+		raw = b.String()
+	}
+	value, err := strconv.Unquote(raw)
+	if err != nil {
+		return "", p.errorf("invalid quoted value %s", p.input[p.pos:end+1])
+	}
+	p.pos = end + 1
+	return value, nil
+}
+
+// consume advances past token if the input continues with it
+func (p *selectorParser) consume(token string) bool {
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *selectorParser) skipSpace() {
+	for p.pos < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("parse error at char %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,148 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// TLSOptions describes the TLS settings for outgoing connections
+type TLSOptions struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	InsecureSkipVerify bool
+}
+
+// NewTLSConfig builds a *tls.Config from the given options, failing if any
+// referenced file cannot be read
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CAFile != "" {
+		caPEM, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("ca_file %s contains no valid certificates", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// PoolOptions sizes the connection pool of a client. The zero value keeps
+// the defaults of http.DefaultTransport, which only keeps 2 idle
+// connections per host.
+type PoolOptions struct {
+	// MaxIdleConns is the number of idle keep-alive connections kept, in
+	// total and per host
+	MaxIdleConns int
+	// MaxConnsPerHost caps connections per host including active ones; 0 is
+	// unlimited
+	MaxConnsPerHost int
+	// IdleConnTimeout closes idle connections after this long
+	IdleConnTimeout time.Duration
+}
+
+// New creates an HTTP client with the given timeout, TLS configuration and
+// connection pool
+func New(timeout time.Duration, tlsConfig *tls.Config, pool PoolOptions) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: newTransport(tlsConfig, pool),
+	}
+}
+
+// NewStreaming creates an HTTP client for responses that are consumed while
+// they are processed. Unlike New the timeout does not bound the whole
+// exchange: it covers waiting for the response headers and every single read
+// of the body, so time the caller spends between reads, e.g. blocked on a
+// full write queue, never aborts the response.
+func NewStreaming(timeout time.Duration, tlsConfig *tls.Config, pool PoolOptions) *http.Client {
+	transport := newTransport(tlsConfig, pool)
+	if timeout <= 0 {
+		return &http.Client{Transport: transport}
+	}
+	transport.ResponseHeaderTimeout = timeout
+	return &http.Client{Transport: &readTimeoutTransport{next: transport, timeout: timeout}}
+}
+
+// newTransport clones http.DefaultTransport with the TLS configuration and
+// connection pool applied
+func newTransport(tlsConfig *tls.Config, pool PoolOptions) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if pool.MaxIdleConns > 0 {
+		transport.MaxIdleConns = pool.MaxIdleConns
+		transport.MaxIdleConnsPerHost = pool.MaxIdleConns
+	}
+	transport.MaxConnsPerHost = pool.MaxConnsPerHost
+	if pool.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = pool.IdleConnTimeout
+	}
+	return transport
+}
+
+// ErrReadTimeout is returned by a streaming response body whose read waited
+// longer than the client timeout for data
+var ErrReadTimeout = errors.New("timed out waiting for response data")
+
+// readTimeoutTransport bounds every read of a response body by timeout
+type readTimeoutTransport struct {
+	next    *http.Transport
+	timeout time.Duration
+}
+
+func (t *readTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &readTimeoutBody{ReadCloser: resp.Body, timeout: t.timeout}
+	return resp, nil
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport
+func (t *readTimeoutTransport) CloseIdleConnections() {
+	t.next.CloseIdleConnections()
+}
+
+// readTimeoutBody closes the body when a single read blocks for longer than
+// timeout, which unblocks the read
+type readTimeoutBody struct {
+	io.ReadCloser
+	timeout  time.Duration
+	timedOut atomic.Bool
+}
+
+func (b *readTimeoutBody) Read(p []byte) (int, error) {
+	timer := time.AfterFunc(b.timeout, func() {
+		b.timedOut.Store(true)
+		b.ReadCloser.Close()
+	})
+	n, err := b.ReadCloser.Read(p)
+	if !timer.Stop() && b.timedOut.Load() {
+		return n, fmt.Errorf("%w after %s", ErrReadTimeout, b.timeout)
+	}
+	return n, err
+}
@@ -0,0 +1,150 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStreamingClientAllowsSlowConsumer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, strings.Repeat("x", 64<<10))
+	}))
+	defer srv.Close()
+
+	client := NewStreaming(50*time.Millisecond, nil, PoolOptions{})
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// Pausing between reads longer than the timeout must not abort the body
+	buf := make([]byte, 16<<10)
+	total := 0
+	for {
+		n, err := resp.Body.Read(buf)
+		total += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read after %d bytes: %v", total, err)
+		}
+		time.Sleep(60 * time.Millisecond)
+	}
+	if total != 64<<10 {
+		t.Errorf("read %d bytes, want %d", total, 64<<10)
+	}
+}
+
+func TestStreamingClientTimesOutStalledBody(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "partial")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := NewStreaming(50*time.Millisecond, nil, PoolOptions{})
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.ReadAll(resp.Body); !errors.Is(err, ErrReadTimeout) {
+		t.Fatalf("reading a stalled body = %v, want ErrReadTimeout", err)
+	}
+}
+
+func TestStreamingClientTimesOutHeaders(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := NewStreaming(50*time.Millisecond, nil, PoolOptions{})
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("request without response headers succeeded")
+	}
+}
+
+// countingServer counts the connections clients open to it
+func countingServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	var opened atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			opened.Add(1)
+		}
+	}
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv, &opened
+}
+
+// sendRounds sends rounds of workers concurrent requests, waiting for each
+// round to finish so every connection goes idle in between, like batches
+// of remote writes
+func sendRounds(t *testing.T, client *http.Client, url string, workers, rounds int) {
+	t.Helper()
+
+	for r := 0; r < rounds; r++ {
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Post(url, "application/x-protobuf", nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestPoolKeepsConcurrentConnectionsAlive(t *testing.T) {
+	const workers, rounds = 16, 5
+
+	// The default pool keeps 2 idle connections, so every round after the
+	// first reconnects nearly all workers
+	srv, opened := countingServer(t)
+	sendRounds(t, New(0, nil, PoolOptions{}), srv.URL, workers, rounds)
+	if got := opened.Load(); got < workers*(rounds-1) {
+		t.Errorf("default pool opened %d connections, want the churn of at least %d", got, workers*(rounds-1))
+	}
+
+	srv, opened = countingServer(t)
+	sendRounds(t, New(0, nil, PoolOptions{MaxIdleConns: 100}), srv.URL, workers, rounds)
+	if got := opened.Load(); got != workers {
+		t.Errorf("tuned pool opened %d connections, want one per worker, %d", got, workers)
+	}
+
+	srv, opened = countingServer(t)
+	sendRounds(t, New(0, nil, PoolOptions{MaxIdleConns: 100, MaxConnsPerHost: 4}), srv.URL, workers, rounds)
+	if got := opened.Load(); got > 4 {
+		t.Errorf("pool capped at 4 connections per host opened %d", got)
+	}
+}
@@ -0,0 +1,112 @@
+package logger
+
+import "context"
+
+type fieldsKey struct{}
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx whose *Context logging functions write
+// through l instead of the global logger, e.g. to log with l's component
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// fromContext returns the logger stored in ctx by NewContext, or the
+// global logger
+func fromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return globalLogger
+}
+
+// WithFields returns a copy of ctx carrying fields that the *Context logging
+// functions add to every entry, merged with any fields already in ctx
+func WithFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	merged := make(map[string]interface{}, len(fields))
+	for k, v := range contextFields(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+func contextFields(ctx context.Context) map[string]interface{} {
+	fields, _ := ctx.Value(fieldsKey{}).(map[string]interface{})
+	return fields
+}
+
+// mergeFields combines the context fields with the entry's own fields, which
+// take precedence on conflicts
+func mergeFields(ctx context.Context, fields []map[string]interface{}) map[string]interface{} {
+	base := contextFields(ctx)
+	if len(fields) == 0 || len(fields[0]) == 0 {
+		return base
+	}
+	if len(base) == 0 {
+		return fields[0]
+	}
+
+	merged := make(map[string]interface{}, len(base)+len(fields[0]))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range fields[0] {
+		merged[k] = v
+	}
+	return merged
+}
+
+// Context-aware logging functions that include the fields stashed in ctx by
+// WithFields and write through the logger stored by NewContext, if any
+func DebugContext(ctx context.Context, message string, fields ...map[string]interface{}) {
+	l := fromContext(ctx)
+	if l == nil {
+		return
+	}
+	l.log(DEBUG, message, mergeFields(ctx, fields))
+}
+
+func InfoContext(ctx context.Context, message string, fields ...map[string]interface{}) {
+	l := fromContext(ctx)
+	if l == nil {
+		return
+	}
+	l.log(INFO, message, mergeFields(ctx, fields))
+}
+
+func WarnContext(ctx context.Context, message string, fields ...map[string]interface{}) {
+	l := fromContext(ctx)
+	if l == nil {
+		return
+	}
+	l.log(WARN, message, mergeFields(ctx, fields))
+}
+
+func ErrorContext(ctx context.Context, message string, fields ...map[string]interface{}) {
+	l := fromContext(ctx)
+	if l == nil {
+		return
+	}
+	l.log(ERROR, message, mergeFields(ctx, fields))
+}
+
+// Context-aware logging methods of scoped loggers
+func (l *Logger) DebugContext(ctx context.Context, message string, fields ...map[string]interface{}) {
+	l.log(DEBUG, message, mergeFields(ctx, fields))
+}
+
+func (l *Logger) InfoContext(ctx context.Context, message string, fields ...map[string]interface{}) {
+	l.log(INFO, message, mergeFields(ctx, fields))
+}
+
+func (l *Logger) WarnContext(ctx context.Context, message string, fields ...map[string]interface{}) {
+	l.log(WARN, message, mergeFields(ctx, fields))
+}
+
+func (l *Logger) ErrorContext(ctx context.Context, message string, fields ...map[string]interface{}) {
+	l.log(ERROR, message, mergeFields(ctx, fields))
+}
@@ -3,10 +3,14 @@ package logger
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -52,35 +56,94 @@ type LogEntry struct {
 	Caller    string                 `json:"caller,omitempty"`
 }
 
-// Logger provides structured JSON logging with configurable levels
+// Logger provides structured JSON logging with configurable levels. Loggers
+// created with With share the output, format and level of the global logger
+// and only differ in their component.
 type Logger struct {
-	level     LogLevel
 	component string
+	sink      *sink
 }
 
+// sink is the output shared by the global logger and all scoped loggers
+type sink struct {
+	// mu serializes writes so entries from concurrent workers never interleave
+	mu     sync.Mutex
+	ready  bool
+	level  LogLevel
+	out    io.Writer
+	format string
+
+	// sampleRate and seen implement SetSampleRate, counting entries per message
+	sampleRate uint64
+	seen       map[string]uint64
+}
+
+// Log output formats
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// output is shared by every logger; entries are dropped until Init
+var output = &sink{level: INFO, out: os.Stdout, format: FormatJSON}
+
 var globalLogger *Logger
 
 // Init initializes the global logger
 func Init(level LogLevel, component string) {
-	globalLogger = &Logger{
-		level:     level,
-		component: component,
-	}
+	output.mu.Lock()
+	output.ready = true
+	output.level = level
+	output.out = os.Stdout
+	output.format = FormatJSON
+	output.mu.Unlock()
+
+	globalLogger = &Logger{component: component, sink: output}
+}
+
+// With returns a logger writing entries with the given component, e.g. a
+// package-level logger for the writer. It may be created before Init.
+func With(component string) *Logger {
+	return &Logger{component: component, sink: output}
+}
+
+// With returns a logger sharing l's output that writes entries with the
+// given component instead of l's
+func (l *Logger) With(component string) *Logger {
+	return &Logger{component: component, sink: l.sink}
+}
+
+// Component returns the component l writes in every entry
+func (l *Logger) Component() string {
+	return l.component
+}
+
+// SetFormat switches between FormatJSON (default) and FormatText output
+func SetFormat(format string) {
+	output.mu.Lock()
+	output.format = format
+	output.mu.Unlock()
+}
+
+// SetOutput changes where log entries are written, stdout by default
+func SetOutput(w io.Writer) {
+	output.mu.Lock()
+	output.out = w
+	output.mu.Unlock()
 }
 
 // SetLevel changes the current log level
 func SetLevel(level LogLevel) {
-	if globalLogger != nil {
-		globalLogger.level = level
-	}
+	output.mu.Lock()
+	output.level = level
+	output.mu.Unlock()
 }
 
 // GetLevel returns the current log level
 func GetLevel() LogLevel {
-	if globalLogger != nil {
-		return globalLogger.level
-	}
-	return INFO
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	return output.level
 }
 
 // ParseLogLevel converts a string to a LogLevel
@@ -119,99 +182,172 @@ func getCaller() string {
 
 // log writes a structured log entry
 func (l *Logger) log(level LogLevel, message string, fields map[string]interface{}) {
-	if level < l.level {
+	out := l.sink
+	out.mu.Lock()
+	defer out.mu.Unlock()
+
+	if !out.ready || level < out.level || out.sampled(level, message) {
 		return
 	}
 
-	entry := LogEntry{
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-		Level:     level.String(),
-		Message:   message,
-		Component: l.component,
-		Fields:    fields,
-		Caller:    getCaller(),
-	}
+	var line string
+	if out.format == FormatText {
+		line = formatText(time.Now().UTC(), level, l.component, message, fields)
+	} else {
+		entry := LogEntry{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Level:     level.String(),
+			Message:   message,
+			Component: l.component,
+			Fields:    fields,
+			Caller:    getCaller(),
+		}
 
-	jsonData, err := json.Marshal(entry)
-	if err != nil {
-		// Fallback to standard logging if JSON marshal fails
-		log.Printf("ERROR: Failed to marshal log entry: %v", err)
-		return
+		jsonData, err := json.Marshal(entry)
+		if err != nil {
+			// Fallback to standard logging if JSON marshal fails
+			log.Printf("ERROR: Failed to marshal log entry: %v", err)
+			return
+		}
+		line = string(jsonData)
 	}
 
-	fmt.Println(string(jsonData))
+	fmt.Fprintln(out.out, line)
 
-	// Exit on fatal errors
+	// Exit on fatal errors while still holding the lock, so no other entry
+	// is written after the fatal one
 	if level == FATAL {
 		os.Exit(1)
 	}
 }
 
+// formatText renders an entry as a single human-readable line, e.g.
+// "2024-01-02T15:04:05 INFO [promfire] message key=value", with fields
+// sorted by key
+func formatText(ts time.Time, level LogLevel, component, message string, fields map[string]interface{}) string {
+	var b strings.Builder
+	b.WriteString(ts.Format("2006-01-02T15:04:05"))
+	b.WriteByte(' ')
+	b.WriteString(level.String())
+	if component != "" {
+		b.WriteString(" [")
+		b.WriteString(component)
+		b.WriteByte(']')
+	}
+	b.WriteByte(' ')
+	b.WriteString(message)
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.WriteByte(' ')
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(textValue(fields[k]))
+	}
+	return b.String()
+}
+
+// textValue renders a field value, quoting strings that contain spaces or
+// quotes and encoding composite values as JSON
+func textValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		if val == "" || strings.ContainsAny(val, " \t\n\"=") {
+			return strconv.Quote(val)
+		}
+		return val
+	case error:
+		return strconv.Quote(val.Error())
+	case fmt.Stringer:
+		return textValue(val.String())
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(val)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return strconv.Quote(fmt.Sprint(v))
+	}
+	return string(data)
+}
+
+// firstFields returns the optional fields argument of the logging functions
+func firstFields(fields []map[string]interface{}) map[string]interface{} {
+	if len(fields) > 0 {
+		return fields[0]
+	}
+	return nil
+}
+
 // Global logging functions
 func Trace(message string, fields ...map[string]interface{}) {
 	if globalLogger == nil {
 		return
 	}
-	var f map[string]interface{}
-	if len(fields) > 0 {
-		f = fields[0]
-	}
-	globalLogger.log(TRACE, message, f)
+	globalLogger.log(TRACE, message, firstFields(fields))
 }
 
 func Debug(message string, fields ...map[string]interface{}) {
 	if globalLogger == nil {
 		return
 	}
-	var f map[string]interface{}
-	if len(fields) > 0 {
-		f = fields[0]
-	}
-	globalLogger.log(DEBUG, message, f)
+	globalLogger.log(DEBUG, message, firstFields(fields))
 }
 
 func Info(message string, fields ...map[string]interface{}) {
 	if globalLogger == nil {
 		return
 	}
-	var f map[string]interface{}
-	if len(fields) > 0 {
-		f = fields[0]
-	}
-	globalLogger.log(INFO, message, f)
+	globalLogger.log(INFO, message, firstFields(fields))
 }
 
 func Warn(message string, fields ...map[string]interface{}) {
 	if globalLogger == nil {
 		return
 	}
-	var f map[string]interface{}
-	if len(fields) > 0 {
-		f = fields[0]
-	}
-	globalLogger.log(WARN, message, f)
+	globalLogger.log(WARN, message, firstFields(fields))
 }
 
 func Error(message string, fields ...map[string]interface{}) {
 	if globalLogger == nil {
 		return
 	}
-	var f map[string]interface{}
-	if len(fields) > 0 {
-		f = fields[0]
-	}
-	globalLogger.log(ERROR, message, f)
+	globalLogger.log(ERROR, message, firstFields(fields))
 }
 
 func Fatal(message string, fields ...map[string]interface{}) {
 	if globalLogger == nil {
 		return
 	}
-	var f map[string]interface{}
-	if len(fields) > 0 {
-		f = fields[0]
-	}
-	globalLogger.log(FATAL, message, f)
+	globalLogger.log(FATAL, message, firstFields(fields))
+}
+
+// Logging methods of scoped loggers
+func (l *Logger) Trace(message string, fields ...map[string]interface{}) {
+	l.log(TRACE, message, firstFields(fields))
+}
+
+func (l *Logger) Debug(message string, fields ...map[string]interface{}) {
+	l.log(DEBUG, message, firstFields(fields))
+}
+
+func (l *Logger) Info(message string, fields ...map[string]interface{}) {
+	l.log(INFO, message, firstFields(fields))
+}
+
+func (l *Logger) Warn(message string, fields ...map[string]interface{}) {
+	l.log(WARN, message, firstFields(fields))
+}
+
+func (l *Logger) Error(message string, fields ...map[string]interface{}) {
+	l.log(ERROR, message, firstFields(fields))
+}
+
+func (l *Logger) Fatal(message string, fields ...map[string]interface{}) {
+	l.log(FATAL, message, firstFields(fields))
 }
 
 // Convenience functions with formatting
@@ -237,4 +373,4 @@ func Errorf(format string, args ...interface{}) {
 
 func Fatalf(format string, args ...interface{}) {
 	Fatal(fmt.Sprintf(format, args...))
-}
\ No newline at end of file
+}
@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// captureOutput initializes the global logger at level and returns the
+// buffer it writes to, discarding output again after the test
+func captureOutput(t *testing.T, level LogLevel) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	Init(level, "promfire")
+	SetOutput(&buf)
+	t.Cleanup(func() {
+		SetOutput(io.Discard)
+		SetSampleRate(0)
+	})
+	return &buf
+}
+
+// entries decodes every JSON line in buf, failing the test on a line that
+// isn't a valid entry
+func entries(t *testing.T, buf *bytes.Buffer) []LogEntry {
+	t.Helper()
+
+	var decoded []LogEntry
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var entry LogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", scanner.Text(), err)
+		}
+		decoded = append(decoded, entry)
+	}
+	return decoded
+}
+
+func TestWithSetsComponent(t *testing.T) {
+	buf := captureOutput(t, INFO)
+
+	Info("global")
+	With("writer").Info("scoped")
+	With("writer").With("benchmarker").Warn("rescoped")
+	With("writer").Debug("below level")
+
+	got := entries(t, buf)
+	want := []struct{ message, component string }{
+		{"global", "promfire"},
+		{"scoped", "writer"},
+		{"rescoped", "benchmarker"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wrote %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Message != w.message || got[i].Component != w.component {
+			t.Errorf("entry %d = %q with component %q, want %q with %q", i, got[i].Message, got[i].Component, w.message, w.component)
+		}
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	buf := captureOutput(t, DEBUG)
+	SetSampleRate(3)
+
+	for i := 0; i < 7; i++ {
+		Debug("chunk sent", map[string]interface{}{"i": i})
+		With("writer").Info("batch sent", map[string]interface{}{"i": i})
+		Error("write failed", map[string]interface{}{"i": i})
+	}
+
+	counts := map[string][]float64{}
+	for _, entry := range entries(t, buf) {
+		counts[entry.Message] = append(counts[entry.Message], entry.Fields["i"].(float64))
+	}
+	want := map[string][]float64{
+		// 1 in 3 of each message, starting with the first, counted across
+		// scoped loggers too
+		"chunk sent": {0, 3, 6},
+		"batch sent": {0, 3, 6},
+		// Errors are never sampled
+		"write failed": {0, 1, 2, 3, 4, 5, 6},
+	}
+	for message, w := range want {
+		if fmt.Sprint(counts[message]) != fmt.Sprint(w) {
+			t.Errorf("%q written for i = %v, want %v", message, counts[message], w)
+		}
+	}
+}
+
+func TestSampleRateDisabled(t *testing.T) {
+	for _, rate := range []int{0, 1} {
+		buf := captureOutput(t, DEBUG)
+		SetSampleRate(rate)
+		for i := 0; i < 5; i++ {
+			Debug("chunk sent")
+		}
+		if got := len(entries(t, buf)); got != 5 {
+			t.Errorf("sample rate %d wrote %d of 5 entries, want all", rate, got)
+		}
+	}
+}
+
+// byteWriter writes every byte separately with a yield in between, so
+// unsynchronized writers would interleave mid-line
+type byteWriter struct {
+	buf *bytes.Buffer
+}
+
+func (w byteWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		w.buf.WriteByte(c)
+		runtime.Gosched()
+	}
+	return len(p), nil
+}
+
+func TestConcurrentLoggingWritesWholeLines(t *testing.T) {
+	const goroutines, perGoroutine = 20, 50
+	buf := captureOutput(t, INFO)
+	SetOutput(byteWriter{buf})
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			scoped := With(fmt.Sprintf("worker-%d", g))
+			for i := 0; i < perGoroutine; i++ {
+				scoped.Info("processed metric", map[string]interface{}{"worker": g, "i": i})
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	got := entries(t, buf)
+	if len(got) != goroutines*perGoroutine {
+		t.Fatalf("decoded %d entries, want %d", len(got), goroutines*perGoroutine)
+	}
+	for _, entry := range got {
+		if want := fmt.Sprintf("worker-%v", entry.Fields["worker"]); entry.Component != want {
+			t.Fatalf("entry with fields of %s has component %q, want them from one entry", want, entry.Component)
+		}
+	}
+}
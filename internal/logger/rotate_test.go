@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFileZeroMaxSizeNeverRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "promfire.log")
+	r, err := NewRotatingFile(path, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	line := strings.Repeat("x", 1<<10) + "\n"
+	for i := 0; i < 2<<10; i++ {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("backup exists with rotation disabled: %v", err)
+	}
+}
+
+func TestRotatingFileZeroBackupsKeepsNone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "promfire.log")
+	r, err := NewRotatingFile(path, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	line := strings.Repeat("x", 1<<19) + "\n"
+	for i := 0; i < 4; i++ {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("backup exists with log_max_backups 0: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 1<<20 {
+		t.Errorf("log file is %d bytes, want it rotated below 1 MiB", info.Size())
+	}
+}
@@ -0,0 +1,26 @@
+package logger
+
+// SetSampleRate writes only every n-th entry of each message below ERROR,
+// starting with the first, so repeated debug lines such as one per chunk
+// cannot flood the output. ERROR and FATAL entries are always written.
+// A rate of 0 or 1 writes every entry.
+func SetSampleRate(n int) {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	output.sampleRate = uint64(max(n, 0))
+	output.seen = nil
+}
+
+// sampled reports whether an entry is dropped by the sample rate. It must be
+// called with s.mu held.
+func (s *sink) sampled(level LogLevel, message string) bool {
+	if s.sampleRate <= 1 || level >= ERROR {
+		return false
+	}
+	if s.seen == nil {
+		s.seen = make(map[string]uint64)
+	}
+	n := s.seen[message]
+	s.seen[message] = n + 1
+	return n%s.sampleRate != 0
+}
@@ -0,0 +1,192 @@
+// Package selfmetrics exposes promfire's own metrics in the Prometheus text
+// exposition format so the load generator itself can be scraped.
+package selfmetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"promfire/internal/logger"
+	"promfire/internal/version"
+)
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	name, help string
+	value      atomic.Uint64
+}
+
+// Add increases the counter by n
+func (c *Counter) Add(n uint64) {
+	c.value.Add(n)
+}
+
+// Inc increases the counter by one
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+func (c *Counter) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.value.Load())
+}
+
+// HistogramSnapshot is the state of a histogram at one point in time;
+// Counts holds the cumulative count per upper bound in Bounds
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64
+	Sum    float64
+	Count  uint64
+}
+
+// Histogram exposes a histogram recorded elsewhere, so every observation is
+// counted once and only rendered here
+type Histogram struct {
+	name, help string
+
+	mu     sync.Mutex
+	source func() HistogramSnapshot
+}
+
+// SetSource makes the histogram render the snapshots returned by source
+func (h *Histogram) SetSource(source func() HistogramSnapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.source = source
+}
+
+// write omits the metric until its source is set
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	source := h.source
+	h.mu.Unlock()
+	if source == nil {
+		return
+	}
+
+	snap := source()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for i, bound := range snap.Bounds {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.name, formatFloat(bound), snap.Counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, snap.Count)
+	fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", h.name, formatFloat(snap.Sum), h.name, snap.Count)
+}
+
+// Info is a gauge fixed at 1 whose labels carry metadata, like the
+// build_info metrics of Prometheus itself, to join onto other metrics
+type Info struct {
+	name, help string
+
+	mu     sync.Mutex
+	labels map[string]string
+}
+
+// Set replaces the labels of the info metric
+func (i *Info) Set(labels map[string]string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.labels = labels
+}
+
+// write omits the metric until its labels are set
+func (i *Info) write(w io.Writer) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.labels == nil {
+		return
+	}
+
+	names := make([]string, 0, len(i.labels))
+	for name := range i.labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for j, name := range names {
+		pairs[j] = fmt.Sprintf("%s=\"%s\"", name, labelEscaper.Replace(i.labels[name]))
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s{%s} 1\n", i.name, i.help, i.name, i.name, strings.Join(pairs, ","))
+}
+
+// labelEscaper escapes label values for the text exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func newCounter(name, help string) *Counter {
+	return &Counter{name: name, help: help}
+}
+
+func newHistogram(name, help string) *Histogram {
+	return &Histogram{name: name, help: help}
+}
+
+func newInfo(name, help string, labels map[string]string) *Info {
+	return &Info{name: name, help: help, labels: labels}
+}
+
+// Metrics recorded by the writer and benchmarker
+var (
+	SamplesWritten = newCounter("promfire_samples_written_total", "Samples and histograms accepted by the remote write endpoint.")
+	BatchesFailed  = newCounter("promfire_batches_failed_total", "Remote write batches that failed after all retries.")
+	// RemoteWriteSeconds is set by the benchmarker to the latency histogram
+	// of its remote writer
+	RemoteWriteSeconds = newHistogram("promfire_remote_write_duration_seconds", "Duration of remote write requests, including failed attempts.")
+	MetricsProcessed   = newCounter("promfire_metrics_processed_total", "Metrics fully processed by the benchmarker.")
+	BuildInfo          = newInfo("promfire_build_info", "A metric with a constant '1' value labeled by the promfire version and Go version.",
+		map[string]string{"version": version.Version, "goversion": runtime.Version()})
+	// RunInfo is set by the benchmarker once the run id is known
+	RunInfo = newInfo("promfire_run_info", "A metric with a constant '1' value labeled by the run id, seed and key settings of the run.", nil)
+)
+
+// Handler serves all metrics in the Prometheus text exposition format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		SamplesWritten.write(w)
+		BatchesFailed.write(w)
+		RemoteWriteSeconds.write(w)
+		MetricsProcessed.write(w)
+		BuildInfo.write(w)
+		RunInfo.write(w)
+	})
+}
+
+// Serve exposes /metrics on addr until ctx is cancelled
+func Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Serving metrics", map[string]interface{}{
+		"addr": addr,
+	})
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package selfmetrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInfoMetrics(t *testing.T) {
+	info := newInfo("test_info", "Test info.", nil)
+	var out strings.Builder
+	info.write(&out)
+	if out.Len() != 0 {
+		t.Errorf("unset info metric wrote %q, want nothing", out.String())
+	}
+
+	info.Set(map[string]string{"run_id": "run-1", "seed": "42", "note": "a \"quoted\"\nvalue"})
+	info.write(&out)
+	want := "# HELP test_info Test info.\n# TYPE test_info gauge\n" +
+		`test_info{note="a \"quoted\"\nvalue",run_id="run-1",seed="42"} 1` + "\n"
+	if out.String() != want {
+		t.Errorf("info metric:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestHandlerExposesRunInfo(t *testing.T) {
+	RunInfo.Set(map[string]string{"run_id": "run-2"})
+	defer RunInfo.Set(nil)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{`promfire_run_info{run_id="run-2"} 1`, `promfire_build_info{goversion="`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics do not contain %s:\n%s", want, body)
+		}
+	}
+}
+
+func TestHistogramRendersSource(t *testing.T) {
+	h := newHistogram("test_seconds", "Test latency.")
+	var out strings.Builder
+	h.write(&out)
+	if out.Len() != 0 {
+		t.Errorf("histogram without source wrote %q, want nothing", out.String())
+	}
+
+	h.SetSource(func() HistogramSnapshot {
+		return HistogramSnapshot{Bounds: []float64{0.001, 0.002}, Counts: []uint64{1, 2}, Sum: 0.5, Count: 3}
+	})
+	h.write(&out)
+	want := "# HELP test_seconds Test latency.\n# TYPE test_seconds histogram\n" +
+		"test_seconds_bucket{le=\"0.001\"} 1\ntest_seconds_bucket{le=\"0.002\"} 2\ntest_seconds_bucket{le=\"+Inf\"} 3\n" +
+		"test_seconds_sum 0.5\ntest_seconds_count 3\n"
+	if out.String() != want {
+		t.Errorf("histogram:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,5 @@
+package version
+
+// Version is the promfire release, overridable at build time with
+// -ldflags "-X promfire/internal/version.Version=..."
+var Version = "1.0.0"
@@ -0,0 +1,132 @@
+package writer
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending when the circuit breaker has
+// opened after consecutive failed batches
+var ErrCircuitOpen = errors.New("remote write circuit breaker is open")
+
+// CircuitBreaker configures failing fast while the remote write endpoint is
+// down. After Threshold consecutive batches fail with a transport error or a
+// retryable status, batches are rejected for Cooldown; then a single batch
+// probes the endpoint and closes the breaker if it succeeds. A Threshold of 0
+// disables the breaker.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// Circuit breaker states
+const (
+	breakerClosed = "closed"
+	breakerOpen   = "open"
+	breakerProbe  = "half-open"
+)
+
+// circuitBreaker tracks consecutive batch failures; a nil breaker always
+// allows sending
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// newCircuitBreaker returns nil when the breaker is disabled
+func newCircuitBreaker(cfg CircuitBreaker) *circuitBreaker {
+	if cfg.Threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		threshold: cfg.Threshold,
+		cooldown:  cfg.Cooldown,
+		state:     breakerClosed,
+	}
+}
+
+// allow reports whether a batch may be sent. Once the cooldown has passed
+// an open breaker lets exactly one batch through as a probe, which must call
+// release when it finishes.
+func (cb *circuitBreaker) allow() (probe bool, err error) {
+	if cb == nil {
+		return false, nil
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case breakerOpen:
+		if time.Since(cb.openedAt) < cb.cooldown {
+			return false, ErrCircuitOpen
+		}
+		cb.transition(breakerProbe)
+		return true, nil
+	case breakerProbe:
+		return false, ErrCircuitOpen
+	}
+	return false, nil
+}
+
+// release reopens the breaker for another probe when the probing batch
+// ended without an outcome, e.g. because the run was cancelled
+func (cb *circuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == breakerProbe {
+		cb.openedAt = time.Now().Add(-cb.cooldown)
+		cb.state = breakerOpen
+	}
+}
+
+// open reports whether batches are currently being rejected, so retries of
+// a batch already in flight stop once other batches have opened the breaker
+func (cb *circuitBreaker) open() bool {
+	if cb == nil {
+		return false
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state == breakerOpen
+}
+
+// record feeds the outcome of a batch into the breaker; failed is true only
+// for outcomes that indicate the endpoint is unavailable
+func (cb *circuitBreaker) record(failed bool) {
+	if cb == nil {
+		return
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if !failed {
+		cb.failures = 0
+		if cb.state != breakerClosed {
+			cb.transition(breakerClosed)
+		}
+		return
+	}
+
+	cb.failures++
+	if cb.state == breakerProbe || (cb.state == breakerClosed && cb.failures >= cb.threshold) {
+		cb.openedAt = time.Now()
+		cb.transition(breakerOpen)
+	}
+}
+
+// transition changes the state and logs it; cb.mu must be held
+func (cb *circuitBreaker) transition(state string) {
+	log.Info("Remote write circuit breaker changed state", map[string]interface{}{
+		"from":                 cb.state,
+		"to":                   state,
+		"consecutive_failures": cb.failures,
+		"cooldown_ms":          cb.cooldown.Milliseconds(),
+	})
+	cb.state = state
+}
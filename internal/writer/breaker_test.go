@@ -0,0 +1,167 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+	"promfire/internal/benchmarker/testutil"
+)
+
+func TestCircuitBreakerOpensAtThreshold(t *testing.T) {
+	cb := newCircuitBreaker(CircuitBreaker{Threshold: 3, Cooldown: time.Hour})
+
+	for i := 0; i < 2; i++ {
+		cb.record(true)
+		if _, err := cb.allow(); err != nil {
+			t.Fatalf("breaker rejected after %d failures: %v", i+1, err)
+		}
+	}
+	cb.record(false)
+	cb.record(true)
+	cb.record(true)
+	if _, err := cb.allow(); err != nil {
+		t.Fatalf("a success did not reset the consecutive failures: %v", err)
+	}
+
+	cb.record(true)
+	if _, err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow after threshold = %v, want ErrCircuitOpen", err)
+	}
+	if !cb.open() {
+		t.Error("open() = false after threshold")
+	}
+}
+
+func TestCircuitBreakerCooldownAllowsOneProbe(t *testing.T) {
+	cb := newCircuitBreaker(CircuitBreaker{Threshold: 1, Cooldown: 20 * time.Millisecond})
+	cb.record(true)
+
+	if _, err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow during cooldown = %v, want ErrCircuitOpen", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	probe, err := cb.allow()
+	if err != nil || !probe {
+		t.Fatalf("allow after cooldown = (%v, %v), want a probe", probe, err)
+	}
+	if _, err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second batch during the probe = %v, want ErrCircuitOpen", err)
+	}
+
+	// A failed probe reopens the breaker for another cooldown
+	cb.record(true)
+	if cb.state != breakerOpen {
+		t.Fatalf("state after failed probe = %s, want %s", cb.state, breakerOpen)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if probe, err := cb.allow(); err != nil || !probe {
+		t.Fatalf("allow after second cooldown = (%v, %v), want a probe", probe, err)
+	}
+	cb.record(false)
+	if cb.state != breakerClosed {
+		t.Fatalf("state after successful probe = %s, want %s", cb.state, breakerClosed)
+	}
+}
+
+func TestCircuitBreakerReleasedProbeStaysOpen(t *testing.T) {
+	cb := newCircuitBreaker(CircuitBreaker{Threshold: 1, Cooldown: time.Millisecond})
+	cb.record(true)
+	time.Sleep(2 * time.Millisecond)
+
+	if probe, _ := cb.allow(); !probe {
+		t.Fatal("expected a probe")
+	}
+	cb.release()
+	if cb.state != breakerOpen {
+		t.Fatalf("state after release = %s, want %s", cb.state, breakerOpen)
+	}
+	if probe, err := cb.allow(); err != nil || !probe {
+		t.Fatalf("allow after release = (%v, %v), want an immediate probe", probe, err)
+	}
+}
+
+// testBatch is a single-sample batch for sendBatch
+func testBatch() []*prompb.TimeSeries {
+	return []*prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "m"}},
+		Samples: []prompb.Sample{{Value: 1, Timestamp: time.Now().UnixMilli()}},
+	}}
+}
+
+func TestCancelledBatchDoesNotFeedBreaker(t *testing.T) {
+	release := make(chan struct{})
+	stall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer stall.Close()
+	defer close(release)
+
+	for _, state := range []string{breakerClosed, breakerProbe} {
+		t.Run(state, func(t *testing.T) {
+			rw := NewRemoteWriter(stall.URL, 10, Options{CircuitBreaker: CircuitBreaker{Threshold: 2, Cooldown: time.Millisecond}})
+			defer rw.Close()
+			rw.breaker.failures = 1
+			if state == breakerProbe {
+				rw.breaker.failures = 2
+				rw.breaker.state = breakerOpen
+				rw.breaker.openedAt = time.Now().Add(-time.Second)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if err := rw.sendBatch(ctx, testBatch()); err == nil {
+				t.Fatal("cancelled batch succeeded")
+			}
+
+			switch state {
+			case breakerClosed:
+				if rw.breaker.state != breakerClosed || rw.breaker.failures != 1 {
+					t.Errorf("breaker = %s with %d failures, want closed with 1", rw.breaker.state, rw.breaker.failures)
+				}
+			case breakerProbe:
+				if rw.breaker.state != breakerOpen {
+					t.Errorf("breaker = %s after a cancelled probe, want %s", rw.breaker.state, breakerOpen)
+				}
+			}
+		})
+	}
+}
+
+func TestRetriesStopOnceBreakerOpens(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	recv.SetStatus(http.StatusServiceUnavailable)
+
+	var rw *RemoteWriter
+	var responses atomic.Int32
+	rw = NewRemoteWriter(recv.WriteURL(), 10, Options{
+		Retry:          RetryPolicy{MaxRetries: 5, InitialBackoff: time.Millisecond},
+		CircuitBreaker: CircuitBreaker{Threshold: 1, Cooldown: time.Hour},
+		// Another batch failing opens the breaker while this one retries
+		OnResponse: func(int, time.Duration) {
+			responses.Add(1)
+			rw.breaker.record(true)
+		},
+	})
+	defer rw.Close()
+
+	if err := rw.sendBatch(context.Background(), testBatch()); err == nil {
+		t.Fatal("batch to a failing endpoint succeeded")
+	}
+	if got := recv.Requests(); got != 1 {
+		t.Errorf("requests = %d, want 1: retries continued after the breaker opened", got)
+	}
+	if err := rw.sendBatch(context.Background(), testBatch()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("next batch = %v, want ErrCircuitOpen", err)
+	}
+	if got := recv.Requests(); got != 1 {
+		t.Errorf("requests = %d, want no request while open", got)
+	}
+}
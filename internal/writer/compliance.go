@@ -0,0 +1,200 @@
+package writer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// oversizedPayloadBytes is the uncompressed size of the oversized probe,
+// above the 4MiB gRPC/remote write default most receivers enforce
+const oversizedPayloadBytes = 16 << 20
+
+// ComplianceResult describes how the target responded to one crafted request
+type ComplianceResult struct {
+	Probe      string        `json:"probe"`
+	Expect     string        `json:"expect"`
+	StatusCode int           `json:"status_code"`
+	Accepted   bool          `json:"accepted"`
+	AsExpected bool          `json:"as_expected"`
+	Latency    time.Duration `json:"latency"`
+	Error      string        `json:"error,omitempty"`
+	Body       string        `json:"body,omitempty"`
+}
+
+// complianceProbe is a crafted request and whether a spec-compliant receiver should accept it
+type complianceProbe struct {
+	name         string
+	shouldAccept bool
+	// setup is an optional request sent first whose result is not reported
+	setup   func() ([]byte, error)
+	payload func() ([]byte, error)
+}
+
+// ComplianceCheck probes the remote write endpoint with a series of crafted
+// requests and reports how the target responds to each one. The probes write
+// a handful of promfire_compliance_probe samples to the target.
+func (rw *RemoteWriter) ComplianceCheck(ctx context.Context) []ComplianceResult {
+	now := time.Now()
+	probes := []complianceProbe{
+		{
+			name:         "valid",
+			shouldAccept: true,
+			payload: func() ([]byte, error) {
+				return rw.encodeProbe(probeSeries("valid", now, false))
+			},
+		},
+		{
+			name:         "unsorted_labels",
+			shouldAccept: false,
+			payload: func() ([]byte, error) {
+				return rw.encodeProbe(probeSeries("unsorted_labels", now, true))
+			},
+		},
+		{
+			name:         "oversized",
+			shouldAccept: false,
+			payload: func() ([]byte, error) {
+				return rw.encodeProbe(oversizedSeries(now)...)
+			},
+		},
+		{
+			name:         "bad_encoding",
+			shouldAccept: false,
+			payload: func() ([]byte, error) {
+				// Uncompressed protobuf while claiming a compressed encoding
+				req := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{probeSeries("bad_encoding", now, false)}}
+				return req.Marshal()
+			},
+		},
+		{
+			name:         "out_of_order",
+			shouldAccept: false,
+			setup: func() ([]byte, error) {
+				return rw.encodeProbe(probeSeries("out_of_order", now, false))
+			},
+			payload: func() ([]byte, error) {
+				return rw.encodeProbe(probeSeries("out_of_order", now.Add(-time.Hour), false))
+			},
+		},
+	}
+
+	results := make([]ComplianceResult, 0, len(probes))
+	for _, probe := range probes {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, rw.runProbe(ctx, probe))
+	}
+	return results
+}
+
+// runProbe sends a probe's optional setup request and then its payload
+func (rw *RemoteWriter) runProbe(ctx context.Context, probe complianceProbe) ComplianceResult {
+	result := ComplianceResult{
+		Probe:  probe.name,
+		Expect: "reject",
+	}
+	if probe.shouldAccept {
+		result.Expect = "accept"
+	}
+
+	if probe.setup != nil {
+		payload, err := probe.setup()
+		if err == nil {
+			_, err = rw.post(ctx, payload)
+		}
+		if err != nil {
+			result.Error = fmt.Sprintf("setup request failed: %v", err)
+			return result
+		}
+	}
+
+	payload, err := probe.payload()
+	if err != nil {
+		result.Error = fmt.Sprintf("building payload: %v", err)
+		return result
+	}
+
+	req, err := rw.newRequest(ctx, payload)
+	if err != nil {
+		result.Error = fmt.Sprintf("creating request: %v", err)
+		return result
+	}
+
+	start := time.Now()
+	resp, err := rw.client.Do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	result.StatusCode = resp.StatusCode
+	result.Body = strings.TrimSpace(string(body))
+	result.Accepted = resp.StatusCode >= 200 && resp.StatusCode < 300
+	result.AsExpected = result.Accepted == probe.shouldAccept
+	return result
+}
+
+// probeSeries builds a single-sample series identifying the probe
+func probeSeries(probe string, ts time.Time, unsorted bool) prompb.TimeSeries {
+	labels := []prompb.Label{
+		{Name: "__name__", Value: "promfire_compliance_probe"},
+		{Name: "probe", Value: probe},
+		{Name: "tool", Value: "promfire"},
+	}
+	if unsorted {
+		labels[0], labels[2] = labels[2], labels[0]
+	}
+	return prompb.TimeSeries{
+		Labels:  labels,
+		Samples: []prompb.Sample{{Timestamp: ts.UnixMilli(), Value: 1}},
+	}
+}
+
+// oversizedSeries builds series with random label values totalling roughly
+// oversizedPayloadBytes, so the payload does not shrink under compression
+func oversizedSeries(ts time.Time) []prompb.TimeSeries {
+	const valueBytes = 4096
+	count := oversizedPayloadBytes / (valueBytes * 2)
+
+	series := make([]prompb.TimeSeries, 0, count)
+	buf := make([]byte, valueBytes)
+	for i := 0; i < count; i++ {
+		_, _ = rand.Read(buf)
+		s := probeSeries("oversized", ts, false)
+		s.Labels = append(s.Labels, prompb.Label{Name: "zz_padding", Value: hex.EncodeToString(buf)})
+		series = append(series, s)
+	}
+	return series
+}
+
+// Ping posts an empty write request to check that the endpoint is reachable
+// and accepts remote write, without writing any samples
+func (rw *RemoteWriter) Ping(ctx context.Context) error {
+	payload, err := rw.encodeProbe()
+	if err != nil {
+		return fmt.Errorf("encoding probe: %w", err)
+	}
+	_, err = rw.post(ctx, payload)
+	return err
+}
+
+// encodeProbe marshals and compresses a write request with the writer's encoding
+func (rw *RemoteWriter) encodeProbe(series ...prompb.TimeSeries) ([]byte, error) {
+	req := &prompb.WriteRequest{Timeseries: series}
+	data, err := rw.marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return rw.compress(data)
+}
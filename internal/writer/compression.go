@@ -0,0 +1,86 @@
+package writer
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// MetricCompression reports wire size statistics for a single metric name
+type MetricCompression struct {
+	Metric            string  `json:"metric"`
+	UncompressedBytes int64   `json:"uncompressed_bytes"`
+	CompressedBytes   int64   `json:"compressed_bytes"`
+	Ratio             float64 `json:"ratio"`
+}
+
+// compressionTracker accumulates uncompressed vs compressed bytes per metric.
+// A batch's compressed size is attributed to its series in proportion to their
+// uncompressed size, since compression happens on the whole request.
+type compressionTracker struct {
+	mu      sync.Mutex
+	metrics map[string]*MetricCompression
+}
+
+func newCompressionTracker() *compressionTracker {
+	return &compressionTracker{
+		metrics: make(map[string]*MetricCompression),
+	}
+}
+
+// record attributes a sent batch's sizes to the metrics it contained
+func (c *compressionTracker) record(timeSeries []*prompb.TimeSeries, uncompressed, compressed int) {
+	if uncompressed == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, ts := range timeSeries {
+		name := metricName(ts.Labels)
+		size := ts.Size()
+
+		m, ok := c.metrics[name]
+		if !ok {
+			m = &MetricCompression{Metric: name}
+			c.metrics[name] = m
+		}
+		m.UncompressedBytes += int64(size)
+		m.CompressedBytes += int64(float64(compressed) * float64(size) / float64(uncompressed))
+	}
+}
+
+// snapshot returns per-metric statistics sorted by compressed bytes, largest first
+func (c *compressionTracker) snapshot() []MetricCompression {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]MetricCompression, 0, len(c.metrics))
+	for _, m := range c.metrics {
+		entry := *m
+		if entry.CompressedBytes > 0 {
+			entry.Ratio = float64(entry.UncompressedBytes) / float64(entry.CompressedBytes)
+		}
+		result = append(result, entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CompressedBytes != result[j].CompressedBytes {
+			return result[i].CompressedBytes > result[j].CompressedBytes
+		}
+		return result[i].Metric < result[j].Metric
+	})
+	return result
+}
+
+// metricName returns the __name__ label value of a series
+func metricName(labels []prompb.Label) string {
+	for _, l := range labels {
+		if l.Name == "__name__" {
+			return l.Value
+		}
+	}
+	return ""
+}
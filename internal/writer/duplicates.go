@@ -0,0 +1,57 @@
+package writer
+
+import "sync/atomic"
+
+// Duplicate timestamp policies for preserve and shift mode
+const (
+	DuplicatePolicyNone     = ""
+	DuplicatePolicyDrop     = "drop"
+	DuplicatePolicyKeepLast = "keep_last"
+	DuplicatePolicyNudge    = "nudge"
+)
+
+// duplicateAction says what to do with a sample sharing the timestamp of the
+// previous sample of its series
+type duplicateAction int
+
+const (
+	duplicateAppend duplicateAction = iota
+	duplicateReplace
+	duplicateSkip
+)
+
+// duplicatePolicy resolves source samples that share a timestamp, e.g. from
+// federation or recording rule quirks, which backends reject as duplicates
+// once original timestamps are kept. Coordinated timestamps never collide.
+type duplicatePolicy struct {
+	mode     string
+	resolved atomic.Int64
+}
+
+// resolve returns the timestamp of a sample following one sent at prev from
+// a source timestamp of prevSource, and what to do with it: drop keeps the
+// first sample at a timestamp, keep_last replaces the previous one and nudge
+// moves the sample 1ms after it. A sample is a duplicate when it shares the
+// previous source timestamp or lands on a nudged one; samples before prev
+// are otherwise left to the ordering check.
+func (d *duplicatePolicy) resolve(ts, prevSource, prev int64) (int64, duplicateAction) {
+	if d.mode == DuplicatePolicyNone || (ts != prevSource && ts != prev) {
+		return ts, duplicateAppend
+	}
+
+	d.resolved.Add(1)
+	switch d.mode {
+	case DuplicatePolicyDrop:
+		return prev, duplicateSkip
+	case DuplicatePolicyKeepLast:
+		return prev, duplicateReplace
+	default:
+		return prev + 1, duplicateAppend
+	}
+}
+
+// DuplicateSamples returns how many samples shared a timestamp with the
+// previous sample of their series and were resolved by the duplicate policy
+func (rw *RemoteWriter) DuplicateSamples() int64 {
+	return rw.duplicates.resolved.Load()
+}
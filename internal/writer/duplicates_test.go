@@ -0,0 +1,68 @@
+package writer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestDuplicateTimestampPolicies(t *testing.T) {
+	// 1000 appears twice and 2000 three times; 1001 collides with a nudge
+	values := [][]interface{}{
+		{1.0, "1"}, {1.0, "2"}, {1.001, "3"}, {2.0, "4"}, {2.0, "5"}, {2.0, "6"},
+	}
+	tests := []struct {
+		policy   string
+		want     []prompb.Sample
+		resolved int64
+	}{
+		{DuplicatePolicyNone, []prompb.Sample{
+			{Timestamp: 1000, Value: 1}, {Timestamp: 1000, Value: 2}, {Timestamp: 1001, Value: 3},
+			{Timestamp: 2000, Value: 4}, {Timestamp: 2000, Value: 5}, {Timestamp: 2000, Value: 6},
+		}, 0},
+		{DuplicatePolicyDrop, []prompb.Sample{
+			{Timestamp: 1000, Value: 1}, {Timestamp: 1001, Value: 3}, {Timestamp: 2000, Value: 4},
+		}, 3},
+		{DuplicatePolicyKeepLast, []prompb.Sample{
+			{Timestamp: 1000, Value: 2}, {Timestamp: 1001, Value: 3}, {Timestamp: 2000, Value: 6},
+		}, 3},
+		{DuplicatePolicyNudge, []prompb.Sample{
+			{Timestamp: 1000, Value: 1}, {Timestamp: 1001, Value: 2}, {Timestamp: 1002, Value: 3},
+			{Timestamp: 2000, Value: 4}, {Timestamp: 2001, Value: 5}, {Timestamp: 2002, Value: 6},
+		}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			rw := NewRemoteWriter("http://127.0.0.1:1", 10, Options{TimestampMode: TimestampModePreserve, DuplicateTimestamps: tt.policy})
+			defer rw.Close()
+
+			ts, err := rw.convertToTimeSeries(map[string]string{"__name__": "m"}, values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ts.Samples, tt.want) {
+				t.Errorf("samples = %v, want %v", ts.Samples, tt.want)
+			}
+			if got := rw.DuplicateSamples(); got != tt.resolved {
+				t.Errorf("resolved = %d, want %d", got, tt.resolved)
+			}
+		})
+	}
+}
+
+func TestDuplicateTimestampKeepLastHistogram(t *testing.T) {
+	rw := NewRemoteWriter("http://127.0.0.1:1", 10, Options{TimestampMode: TimestampModePreserve, DuplicateTimestamps: DuplicatePolicyKeepLast})
+	defer rw.Close()
+
+	point := func(count string) HistogramPoint {
+		return HistogramPoint{Timestamp: 1.0, Histogram: APIHistogram{Count: count, Sum: "1", Buckets: [][]interface{}{{3.0, "0.5", "1", count}}}}
+	}
+	ts, err := rw.convertHistogramsToTimeSeries(map[string]string{"__name__": "h"}, []HistogramPoint{point("1"), point("4")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.Histograms) != 1 || ts.Histograms[0].GetCountFloat() != 4 || ts.Histograms[0].Timestamp != 1000 {
+		t.Errorf("histograms = %v, want only the last at 1000", ts.Histograms)
+	}
+}
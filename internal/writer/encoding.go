@@ -0,0 +1,71 @@
+package writer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// Remote write content encodings
+const (
+	EncodingSnappy = "snappy"
+	EncodingGzip   = "gzip"
+)
+
+// marshal serializes a write request in the writer's protocol
+func (rw *RemoteWriter) marshal(req *prompb.WriteRequest) ([]byte, error) {
+	if rw.protocol == ProtocolOTLP {
+		return encodeOTLP(req.Timeseries, rw.metadata.lookup, rw.otlpStarts), nil
+	}
+	return req.Marshal()
+}
+
+// compress encodes a marshaled write request with the writer's content
+// encoding. OTLP payloads are sent uncompressed unless gzip is configured.
+func (rw *RemoteWriter) compress(data []byte) ([]byte, error) {
+	if rw.protocol == ProtocolOTLP && rw.encoding != EncodingGzip {
+		return data, nil
+	}
+	switch rw.encoding {
+	case EncodingGzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, fmt.Errorf("gzip compressing: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("gzip compressing: %w", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		if !rw.snappyFramed {
+			// The block format without stream framing, as the remote write
+			// spec requires
+			return snappy.Encode(nil, data), nil
+		}
+		var buf bytes.Buffer
+		sw := snappy.NewBufferedWriter(&buf)
+		if _, err := sw.Write(data); err != nil {
+			return nil, fmt.Errorf("snappy compressing: %w", err)
+		}
+		if err := sw.Close(); err != nil {
+			return nil, fmt.Errorf("snappy compressing: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// contentEncoding returns the Content-Encoding header value for the
+// writer, empty for uncompressed OTLP payloads
+func (rw *RemoteWriter) contentEncoding() string {
+	if rw.encoding == EncodingGzip {
+		return EncodingGzip
+	}
+	if rw.protocol == ProtocolOTLP {
+		return ""
+	}
+	return EncodingSnappy
+}
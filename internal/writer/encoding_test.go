@@ -0,0 +1,116 @@
+package writer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"promfire/internal/benchmarker/testutil"
+)
+
+// marshaledRequest returns the protobuf bytes of a small write request
+func marshaledRequest(t *testing.T) []byte {
+	t.Helper()
+
+	req := &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "a"}},
+		Samples: []prompb.Sample{{Value: 1, Timestamp: 1700000000000}},
+	}}}
+	data, err := req.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCompressGzipRoundTrip(t *testing.T) {
+	data := marshaledRequest(t)
+	compressed, err := (&RemoteWriter{encoding: EncodingGzip}).compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("decoding gzip payload: %v", err)
+	}
+	decoded, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("decoding gzip payload: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Error("gzip payload does not decode to the marshaled request")
+	}
+}
+
+func TestCompressSnappyRoundTrip(t *testing.T) {
+	data := marshaledRequest(t)
+
+	block, err := (&RemoteWriter{encoding: EncodingSnappy}).compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := snappy.Decode(nil, block)
+	if err != nil {
+		t.Fatalf("decoding block payload: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Error("block payload does not decode to the marshaled request")
+	}
+
+	framed, err := (&RemoteWriter{encoding: EncodingSnappy, snappyFramed: true}).compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err = io.ReadAll(snappy.NewReader(bytes.NewReader(framed)))
+	if err != nil {
+		t.Fatalf("decoding framed payload: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Error("framed payload does not decode to the marshaled request")
+	}
+
+	// Receivers decoding blocks reject the framed format and vice versa
+	if _, err := snappy.Decode(nil, framed); err == nil {
+		t.Error("framed payload decoded as a block")
+	}
+	if _, err := io.ReadAll(snappy.NewReader(bytes.NewReader(block))); err == nil {
+		t.Error("block payload decoded as a stream")
+	}
+}
+
+func TestSendBatchEncodingHeaders(t *testing.T) {
+	for _, encoding := range []string{EncodingSnappy, EncodingGzip} {
+		t.Run(encoding, func(t *testing.T) {
+			recv := testutil.NewFakeReceiver()
+			defer recv.Close()
+			rw := NewRemoteWriter(recv.WriteURL(), 10, Options{Encoding: encoding})
+			defer rw.Close()
+
+			ctx := context.Background()
+			if err := rw.WriteSamples(ctx, map[string]string{"__name__": "up"}, [][]interface{}{{1700000000.0, "1"}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := rw.Flush(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			headers := recv.Headers()
+			if len(headers) != 1 {
+				t.Fatalf("receiver got %d requests, want 1", len(headers))
+			}
+			if got := headers[0].Get("Content-Encoding"); got != encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, encoding)
+			}
+			if got := headers[0].Get("X-Prometheus-Remote-Write-Version"); got != "0.1.0" {
+				t.Errorf("X-Prometheus-Remote-Write-Version = %q, want 0.1.0 for either encoding", got)
+			}
+			if got := len(recv.Series()); got != 1 {
+				t.Errorf("receiver decoded %d series, want 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package writer
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// maxExemplarLabelRunes is the limit the OpenMetrics spec puts on the
+// combined length of an exemplar's label names and values
+const maxExemplarLabelRunes = 128
+
+// exemplarGenerator attaches synthetic exemplars with a random trace_id to a
+// fraction of samples
+type exemplarGenerator struct {
+	fraction float64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newExemplarGenerator(fraction float64) *exemplarGenerator {
+	if fraction <= 0 {
+		return nil
+	}
+	return &exemplarGenerator{
+		fraction: fraction,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// generate returns exemplars matching the timestamp and value of randomly
+// chosen samples
+func (g *exemplarGenerator) generate(samples []prompb.Sample) []prompb.Exemplar {
+	if g == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var exemplars []prompb.Exemplar
+	traceID := make([]byte, 16)
+	for _, s := range samples {
+		if g.rng.Float64() >= g.fraction {
+			continue
+		}
+		_, _ = g.rng.Read(traceID)
+		labels := []prompb.Label{{Name: "trace_id", Value: hex.EncodeToString(traceID)}}
+		if !validExemplarLabels(labels) {
+			continue
+		}
+		exemplars = append(exemplars, prompb.Exemplar{
+			Labels:    labels,
+			Value:     s.Value,
+			Timestamp: s.Timestamp,
+		})
+	}
+	return exemplars
+}
+
+// validExemplarLabels checks that labels are valid UTF-8 and within the
+// spec's combined length limit
+func validExemplarLabels(labels []prompb.Label) bool {
+	runes := 0
+	for _, l := range labels {
+		if !utf8.ValidString(l.Name) || !utf8.ValidString(l.Value) {
+			return false
+		}
+		runes += utf8.RuneCountInString(l.Name) + utf8.RuneCountInString(l.Value)
+	}
+	return runes <= maxExemplarLabelRunes
+}
@@ -0,0 +1,94 @@
+package writer
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// Future and old sample policies
+const (
+	FuturePolicyNone  = ""
+	FuturePolicyClamp = "clamp"
+	FuturePolicyDrop  = "drop"
+)
+
+// FutureGuard rejects samples whose timestamp lies too far ahead of
+// wall-clock time, which many backends refuse for the whole batch
+type FutureGuard struct {
+	Policy    string
+	Tolerance time.Duration
+}
+
+// guardCounters tracks samples affected by a timestamp guard
+type guardCounters struct {
+	clamped atomic.Int64
+	dropped atomic.Int64
+}
+
+// apply enforces the guard on samples, which must be ordered by timestamp.
+// Clamped samples that would collide with an earlier sample are dropped so
+// the series stays strictly increasing.
+func (g FutureGuard) apply(samples []prompb.Sample, counters *guardCounters) []prompb.Sample {
+	if g.Policy == FuturePolicyNone || len(samples) == 0 {
+		return samples
+	}
+
+	limit := time.Now().Add(g.Tolerance).UnixMilli()
+	if samples[len(samples)-1].Timestamp <= limit {
+		return samples
+	}
+
+	kept := samples[:0]
+	for _, s := range samples {
+		if s.Timestamp > limit {
+			if g.Policy == FuturePolicyDrop {
+				counters.dropped.Add(1)
+				continue
+			}
+			s.Timestamp = limit
+			if len(kept) > 0 && kept[len(kept)-1].Timestamp >= limit {
+				counters.dropped.Add(1)
+				continue
+			}
+			counters.clamped.Add(1)
+		}
+		kept = append(kept, s)
+	}
+	return kept
+}
+
+// AgeGuard rejects samples older than MaxAge, which backends refuse once
+// they fall outside the out-of-order window. Policy takes the same values as
+// FutureGuard.
+type AgeGuard struct {
+	Policy string
+	MaxAge time.Duration
+}
+
+// apply enforces the guard on samples, which must be ordered by timestamp.
+// Clamping moves only the newest too-old sample to the oldest accepted
+// timestamp and drops the rest, so the series stays strictly increasing.
+func (g AgeGuard) apply(samples []prompb.Sample, counters *guardCounters) []prompb.Sample {
+	if g.Policy == FuturePolicyNone || g.MaxAge <= 0 || len(samples) == 0 {
+		return samples
+	}
+
+	limit := time.Now().Add(-g.MaxAge).UnixMilli()
+	if samples[0].Timestamp >= limit {
+		return samples
+	}
+
+	old := sort.Search(len(samples), func(i int) bool { return samples[i].Timestamp >= limit })
+	if g.Policy == FuturePolicyDrop || (old < len(samples) && samples[old].Timestamp == limit) {
+		counters.dropped.Add(int64(old))
+		return samples[old:]
+	}
+
+	counters.dropped.Add(int64(old - 1))
+	counters.clamped.Add(1)
+	samples[old-1].Timestamp = limit
+	return samples[old-1:]
+}
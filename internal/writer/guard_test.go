@@ -0,0 +1,88 @@
+package writer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// agedSamples returns samples valued 0..n-1 at the given ages before now,
+// oldest first
+func agedSamples(ages ...time.Duration) []prompb.Sample {
+	now := time.Now()
+	samples := make([]prompb.Sample, len(ages))
+	for i, age := range ages {
+		samples[i] = prompb.Sample{Value: float64(i), Timestamp: now.Add(-age).UnixMilli()}
+	}
+	return samples
+}
+
+func TestAgeGuardDrop(t *testing.T) {
+	var counters guardCounters
+	guard := AgeGuard{Policy: FuturePolicyDrop, MaxAge: time.Hour}
+
+	kept := guard.apply(agedSamples(3*time.Hour, 2*time.Hour, 30*time.Minute, time.Minute), &counters)
+	if len(kept) != 2 || kept[0].Value != 2 || kept[1].Value != 3 {
+		t.Fatalf("kept %+v, want the two samples within the hour", kept)
+	}
+	if counters.dropped.Load() != 2 || counters.clamped.Load() != 0 {
+		t.Errorf("dropped %d and clamped %d, want 2 dropped", counters.dropped.Load(), counters.clamped.Load())
+	}
+}
+
+func TestAgeGuardClamp(t *testing.T) {
+	var counters guardCounters
+	guard := AgeGuard{Policy: FuturePolicyClamp, MaxAge: time.Hour}
+
+	before := time.Now().Add(-time.Hour).UnixMilli()
+	kept := guard.apply(agedSamples(3*time.Hour, 2*time.Hour, 30*time.Minute, time.Minute), &counters)
+	after := time.Now().Add(-time.Hour).UnixMilli()
+
+	// The newest too-old sample moves up to the limit, the older one is dropped
+	if len(kept) != 3 || kept[0].Value != 1 || kept[1].Value != 2 {
+		t.Fatalf("kept %+v, want the newest old sample followed by the recent ones", kept)
+	}
+	if ts := kept[0].Timestamp; ts < before || ts > after {
+		t.Errorf("clamped timestamp %d, want the oldest accepted %d..%d", ts, before, after)
+	}
+	for i := 1; i < len(kept); i++ {
+		if kept[i].Timestamp <= kept[i-1].Timestamp {
+			t.Errorf("timestamps %d and %d are not strictly increasing", kept[i-1].Timestamp, kept[i].Timestamp)
+		}
+	}
+	if counters.dropped.Load() != 1 || counters.clamped.Load() != 1 {
+		t.Errorf("dropped %d and clamped %d, want 1 each", counters.dropped.Load(), counters.clamped.Load())
+	}
+}
+
+func TestAgeGuardKeepsRecentSamples(t *testing.T) {
+	for _, guard := range []AgeGuard{
+		{Policy: FuturePolicyDrop, MaxAge: time.Hour},
+		{Policy: FuturePolicyNone, MaxAge: time.Minute},
+		{Policy: FuturePolicyDrop},
+	} {
+		var counters guardCounters
+		samples := agedSamples(50*time.Minute, 10*time.Minute)
+		if kept := guard.apply(samples, &counters); len(kept) != 2 {
+			t.Errorf("guard %+v kept %d of 2 samples, want both", guard, len(kept))
+		}
+		if counters.dropped.Load() != 0 || counters.clamped.Load() != 0 {
+			t.Errorf("guard %+v counted %d dropped and %d clamped, want none", guard, counters.dropped.Load(), counters.clamped.Load())
+		}
+	}
+}
+
+func TestAgeGuardDropsEverySampleTooOld(t *testing.T) {
+	var counters guardCounters
+	guard := AgeGuard{Policy: FuturePolicyDrop, MaxAge: time.Hour}
+	if kept := guard.apply(agedSamples(3*time.Hour, 2*time.Hour), &counters); len(kept) != 0 {
+		t.Errorf("kept %+v, want nothing", kept)
+	}
+
+	guard.Policy = FuturePolicyClamp
+	kept := guard.apply(agedSamples(3*time.Hour, 2*time.Hour), &counters)
+	if len(kept) != 1 || kept[0].Value != 1 {
+		t.Errorf("clamp kept %+v, want only the newest sample moved to the limit", kept)
+	}
+}
@@ -0,0 +1,253 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// HistogramPoint is a native histogram sample as returned by the Prometheus
+// query API: a [timestamp, histogram] pair
+type HistogramPoint struct {
+	Timestamp interface{}
+	Histogram APIHistogram
+}
+
+// APIHistogram is the query API representation of a native histogram. Each
+// bucket is [boundary_rule, lower, upper, count] with string-encoded numbers.
+type APIHistogram struct {
+	Count   string          `json:"count"`
+	Sum     string          `json:"sum"`
+	Buckets [][]interface{} `json:"buckets"`
+}
+
+// UnmarshalJSON decodes the [timestamp, histogram] pair form
+func (p *HistogramPoint) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 2 {
+		return fmt.Errorf("histogram point has %d elements, want 2", len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &p.Timestamp); err != nil {
+		return fmt.Errorf("decoding histogram timestamp: %w", err)
+	}
+	return json.Unmarshal(raw[1], &p.Histogram)
+}
+
+// WriteHistograms buffers native histogram samples for a single time series
+// like WriteSamples
+func (rw *RemoteWriter) WriteHistograms(ctx context.Context, labels map[string]string, points []HistogramPoint) error {
+	timeSeries, err := rw.convertHistogramsToTimeSeries(labels, points)
+	if err != nil {
+		return fmt.Errorf("converting to time series: %w", err)
+	}
+	if timeSeries == nil {
+		return nil // Dropped by the ordering check
+	}
+
+	return rw.enqueue(ctx, timeSeries)
+}
+
+// convertHistogramsToTimeSeries converts labels and histogram points to a
+// TimeSeries carrying native histograms, or nil when the ordering check drops it
+func (rw *RemoteWriter) convertHistogramsToTimeSeries(labels map[string]string, points []HistogramPoint) (*prompb.TimeSeries, error) {
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no histograms provided")
+	}
+
+	var histograms []prompb.Histogram
+	var prevSource int64
+	for _, point := range points {
+		h, err := toPromHistogram(point.Histogram)
+		if err != nil {
+			continue // Skip unparseable histograms
+		}
+
+		timestamp, ok := rw.sampleTimestamp(point.Timestamp)
+		if !ok {
+			continue // Skip unparseable timestamps
+		}
+		source := timestamp
+		if len(histograms) > 0 {
+			prev := histograms[len(histograms)-1].Timestamp
+			var action duplicateAction
+			timestamp, action = rw.duplicates.resolve(rw.strictlyAfter(timestamp, prev), prevSource, prev)
+			switch action {
+			case duplicateSkip:
+				continue
+			case duplicateReplace:
+				h.Timestamp = prev
+				histograms[len(histograms)-1] = h
+				continue
+			}
+		}
+		prevSource = source
+		h.Timestamp = timestamp
+
+		histograms = append(histograms, h)
+	}
+
+	if len(histograms) == 0 {
+		return nil, fmt.Errorf("no valid histograms found: %w", ErrNoValidSamples)
+	}
+
+	ok, err := rw.ordering.check(labels, len(histograms), func(i int) int64 { return histograms[i].Timestamp })
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	return &prompb.TimeSeries{
+		Labels:     rw.labelPairs(labels),
+		Histograms: histograms,
+	}, nil
+}
+
+// apiBucket is a parsed query API histogram bucket
+type apiBucket struct {
+	lower, upper, count float64
+}
+
+// toPromHistogram converts a query API histogram into a float native
+// histogram. The API only exposes bucket boundaries, so the schema and bucket
+// indexes are recovered from them: for schema s the bucket with index i has
+// upper bound base^i with base = 2^(2^-s).
+func toPromHistogram(h APIHistogram) (prompb.Histogram, error) {
+	count, err := strconv.ParseFloat(h.Count, 64)
+	if err != nil {
+		return prompb.Histogram{}, fmt.Errorf("parsing count: %w", err)
+	}
+	sum, err := strconv.ParseFloat(h.Sum, 64)
+	if err != nil {
+		return prompb.Histogram{}, fmt.Errorf("parsing sum: %w", err)
+	}
+
+	var positive, negative []apiBucket
+	var zeroThreshold, zeroCount float64
+	for _, raw := range h.Buckets {
+		b, err := parseAPIBucket(raw)
+		if err != nil {
+			return prompb.Histogram{}, err
+		}
+		switch {
+		case b.lower <= 0 && b.upper >= 0:
+			zeroThreshold = b.upper
+			zeroCount = b.count
+		case b.lower > 0:
+			positive = append(positive, b)
+		default:
+			negative = append(negative, b)
+		}
+	}
+
+	schema := inferSchema(positive, negative)
+	base := math.Pow(2, math.Pow(2, -float64(schema)))
+
+	positiveSpans, positiveCounts := bucketsToSpans(positive, base, func(b apiBucket) float64 { return b.upper })
+	negativeSpans, negativeCounts := bucketsToSpans(negative, base, func(b apiBucket) float64 { return -b.lower })
+
+	return prompb.Histogram{
+		Count:          &prompb.Histogram_CountFloat{CountFloat: count},
+		Sum:            sum,
+		Schema:         schema,
+		ZeroThreshold:  zeroThreshold,
+		ZeroCount:      &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: zeroCount},
+		PositiveSpans:  positiveSpans,
+		PositiveCounts: positiveCounts,
+		NegativeSpans:  negativeSpans,
+		NegativeCounts: negativeCounts,
+	}, nil
+}
+
+// parseAPIBucket parses a [boundary_rule, lower, upper, count] bucket
+func parseAPIBucket(raw []interface{}) (apiBucket, error) {
+	if len(raw) != 4 {
+		return apiBucket{}, fmt.Errorf("bucket has %d elements, want 4", len(raw))
+	}
+
+	var values [3]float64
+	for i := range values {
+		s, ok := raw[i+1].(string)
+		if !ok {
+			return apiBucket{}, fmt.Errorf("bucket element %d is not a string", i+1)
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return apiBucket{}, fmt.Errorf("parsing bucket element %d: %w", i+1, err)
+		}
+		values[i] = v
+	}
+
+	return apiBucket{lower: values[0], upper: values[1], count: values[2]}, nil
+}
+
+// inferSchema recovers the exponential schema from the ratio between the
+// bounds of the first regular bucket, defaulting to 0 without buckets
+func inferSchema(positive, negative []apiBucket) int32 {
+	var lower, upper float64
+	switch {
+	case len(positive) > 0:
+		lower, upper = positive[0].lower, positive[0].upper
+	case len(negative) > 0:
+		lower, upper = -negative[0].upper, -negative[0].lower
+	default:
+		return 0
+	}
+
+	// upper/lower = 2^(2^-schema)  =>  schema = -log2(log2(upper/lower))
+	schema := int32(math.Round(-math.Log2(math.Log2(upper / lower))))
+	if schema < -4 {
+		schema = -4
+	}
+	if schema > 8 {
+		schema = 8
+	}
+	return schema
+}
+
+// bucketsToSpans computes bucket indexes from their outer bounds and groups
+// them into spans with absolute float counts
+func bucketsToSpans(buckets []apiBucket, base float64, bound func(apiBucket) float64) ([]prompb.BucketSpan, []float64) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+
+	type indexed struct {
+		index int32
+		count float64
+	}
+	idx := make([]indexed, 0, len(buckets))
+	for _, b := range buckets {
+		i := int32(math.Round(math.Log(bound(b)) / math.Log(base)))
+		idx = append(idx, indexed{index: i, count: b.count})
+	}
+	sort.Slice(idx, func(i, j int) bool { return idx[i].index < idx[j].index })
+
+	var spans []prompb.BucketSpan
+	var counts []float64
+	var next int32
+	for i, b := range idx {
+		if i > 0 && b.index == next {
+			spans[len(spans)-1].Length++
+		} else {
+			offset := b.index
+			if i > 0 {
+				offset = b.index - next
+			}
+			spans = append(spans, prompb.BucketSpan{Offset: offset, Length: 1})
+		}
+		counts = append(counts, b.count)
+		next = b.index + 1
+	}
+
+	return spans, counts
+}
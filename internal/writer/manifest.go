@@ -0,0 +1,122 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// ManifestEntry describes a single series written during a run
+type ManifestEntry struct {
+	Labels         map[string]string `json:"labels"`
+	Samples        int64             `json:"samples"`
+	MinTimestampMs int64             `json:"min_timestamp_ms"`
+	MaxTimestampMs int64             `json:"max_timestamp_ms"`
+}
+
+// Manifest keeps an inventory of every distinct series that was written
+type Manifest struct {
+	mu      sync.Mutex
+	entries map[string]*ManifestEntry
+}
+
+// NewManifest creates an empty manifest
+func NewManifest() *Manifest {
+	return &Manifest{
+		entries: make(map[string]*ManifestEntry),
+	}
+}
+
+// Record adds the samples of a successfully written time series to the manifest
+func (m *Manifest) Record(ts *prompb.TimeSeries) {
+	if len(ts.Samples) == 0 {
+		return
+	}
+
+	key := seriesKey(ts.Labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		labels := make(map[string]string, len(ts.Labels))
+		for _, l := range ts.Labels {
+			labels[l.Name] = l.Value
+		}
+		entry = &ManifestEntry{
+			Labels:         labels,
+			MinTimestampMs: ts.Samples[0].Timestamp,
+			MaxTimestampMs: ts.Samples[0].Timestamp,
+		}
+		m.entries[key] = entry
+	}
+
+	for _, s := range ts.Samples {
+		if s.Timestamp < entry.MinTimestampMs {
+			entry.MinTimestampMs = s.Timestamp
+		}
+		if s.Timestamp > entry.MaxTimestampMs {
+			entry.MaxTimestampMs = s.Timestamp
+		}
+	}
+	entry.Samples += int64(len(ts.Samples))
+}
+
+// Len returns the number of distinct series in the manifest
+func (m *Manifest) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// WriteFile writes the manifest as newline-delimited JSON, one series per line
+func (m *Manifest) WriteFile(path string) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.entries))
+	for k := range m.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating manifest file: %w", err)
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("closing manifest file: %w", closeErr)
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, k := range keys {
+		if err := enc.Encode(m.entries[k]); err != nil {
+			return fmt.Errorf("encoding manifest entry: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing manifest file: %w", err)
+	}
+	return nil
+}
+
+// seriesKey builds a stable identity string for a label set
+func seriesKey(labels []prompb.Label) string {
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, l.Name+"="+l.Value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
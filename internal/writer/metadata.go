@@ -0,0 +1,86 @@
+package writer
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// MetricMetadata is the HELP/TYPE/UNIT metadata of a metric as returned by the
+// Prometheus /api/v1/metadata endpoint
+type MetricMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// metadataTracker attaches metadata to the first successful batch carrying
+// each metric name. Metrics without known metadata are sent as UNKNOWN.
+type metadataTracker struct {
+	mu    sync.Mutex
+	known map[string]MetricMetadata
+	sent  map[string]bool
+}
+
+func newMetadataTracker(known map[string]MetricMetadata) *metadataTracker {
+	return &metadataTracker{known: known, sent: make(map[string]bool)}
+}
+
+// pending returns metadata for the metrics in timeSeries not yet sent
+func (t *metadataTracker) pending(timeSeries []*prompb.TimeSeries) []prompb.MetricMetadata {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var metadata []prompb.MetricMetadata
+	seen := make(map[string]bool)
+	for _, ts := range timeSeries {
+		name := metricName(ts.Labels)
+		if name == "" || t.sent[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		md := t.known[name]
+		metadata = append(metadata, prompb.MetricMetadata{
+			Type:             metricType(md.Type),
+			MetricFamilyName: name,
+			Help:             md.Help,
+			Unit:             md.Unit,
+		})
+	}
+	return metadata
+}
+
+// lookup returns the known metadata of a metric, empty when unknown
+func (t *metadataTracker) lookup(name string) MetricMetadata {
+	if t == nil {
+		return MetricMetadata{}
+	}
+	return t.known[name]
+}
+
+// markSent records metadata that reached the target
+func (t *metadataTracker) markSent(metadata []prompb.MetricMetadata) {
+	if t == nil || len(metadata) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, md := range metadata {
+		t.sent[md.MetricFamilyName] = true
+	}
+}
+
+// metricType maps a query API metric type to its remote write enum
+func metricType(t string) prompb.MetricMetadata_MetricType {
+	if v, ok := prompb.MetricMetadata_MetricType_value[strings.ToUpper(t)]; ok {
+		return prompb.MetricMetadata_MetricType(v)
+	}
+	return prompb.MetricMetadata_UNKNOWN
+}
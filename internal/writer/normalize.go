@@ -0,0 +1,89 @@
+package writer
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// labelNormalizer rewrites label names that are illegal in Prometheus
+// (e.g. OTel-style dotted names) to the [a-zA-Z_][a-zA-Z0-9_]* convention
+type labelNormalizer struct {
+	seen       sync.Map // original name -> normalized name, logged once
+	normalized atomic.Int64
+	collisions atomic.Int64
+}
+
+// normalizeLabels builds sorted label pairs with every name normalized
+func (n *labelNormalizer) normalizeLabels(labels map[string]string) []prompb.Label {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	index := make(map[string]int, len(names))
+	pairs := make([]prompb.Label, 0, len(names))
+	for _, name := range names {
+		target := name
+		if !isValidLabelName(name) {
+			target = normalizeLabelName(name)
+			n.normalized.Add(1)
+			if _, loaded := n.seen.LoadOrStore(name, target); !loaded {
+				log.Info("Normalized label name", map[string]interface{}{
+					"original":   name,
+					"normalized": target,
+				})
+			}
+		}
+
+		if i, ok := index[target]; ok {
+			n.collisions.Add(1)
+			log.Warn("Label name collision after normalization", map[string]interface{}{
+				"label":    target,
+				"original": name,
+			})
+			pairs[i].Value = labels[name]
+			continue
+		}
+
+		index[target] = len(pairs)
+		pairs = append(pairs, prompb.Label{Name: target, Value: labels[name]})
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// isValidLabelName reports whether name matches [a-zA-Z_][a-zA-Z0-9_]*
+func isValidLabelName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9' && i > 0)) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLabelName replaces illegal characters with underscores and
+// prefixes names that start with a digit
+func normalizeLabelName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	out := []rune(name)
+	for i, c := range out {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')) {
+			out[i] = '_'
+		}
+	}
+	if out[0] >= '0' && out[0] <= '9' {
+		return "_" + string(out)
+	}
+	return string(out)
+}
@@ -0,0 +1,56 @@
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// Sample ordering check modes
+const (
+	OrderCheckOff  = "off"
+	OrderCheckFail = "fail"
+	OrderCheckDrop = "drop"
+)
+
+// ErrUnorderedSamples is returned when a series fails the ordering check in
+// fail mode
+var ErrUnorderedSamples = errors.New("samples are not strictly timestamp-ordered")
+
+// orderCheck asserts that a series' timestamps are strictly increasing before
+// it is marshaled, catching timestamp generation bugs that the backend would
+// otherwise reject as out-of-order
+type orderCheck struct {
+	mode    string
+	dropped atomic.Int64
+}
+
+// check validates n timestamps returned by ts. It returns false when the
+// series must be dropped and an error wrapping ErrUnorderedSamples in fail mode.
+func (c *orderCheck) check(labels map[string]string, n int, ts func(i int) int64) (bool, error) {
+	if c.mode == "" || c.mode == OrderCheckOff {
+		return true, nil
+	}
+
+	for i := 1; i < n; i++ {
+		prev, cur := ts(i-1), ts(i)
+		if cur > prev {
+			continue
+		}
+
+		fields := map[string]interface{}{
+			"labels":         labels,
+			"index":          i,
+			"previous_ts_ms": prev,
+			"timestamp_ms":   cur,
+		}
+		if c.mode == OrderCheckFail {
+			log.Error("Series samples out of order", fields)
+			return false, fmt.Errorf("%w: sample %d at %d after %d", ErrUnorderedSamples, i, cur, prev)
+		}
+		log.Warn("Dropping series with out-of-order samples", fields)
+		c.dropped.Add(1)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,401 @@
+package writer
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	"google.golang.org/protobuf/encoding/protowire"
+	"promfire/internal/version"
+)
+
+// Write protocols
+const (
+	ProtocolRemoteWrite = "remote_write"
+	ProtocolOTLP        = "otlp"
+)
+
+// OTLP field numbers used by encodeOTLP, from opentelemetry-proto's
+// collector/metrics/v1 and metrics/v1 packages
+const (
+	otlpRequestResourceMetrics = 1
+
+	otlpResourceMetricsResource     = 1
+	otlpResourceMetricsScopeMetrics = 2
+	otlpResourceAttributes          = 1
+
+	otlpScopeMetricsScope   = 1
+	otlpScopeMetricsMetrics = 2
+	otlpScopeName           = 1
+	otlpScopeVersion        = 2
+
+	otlpMetricName                 = 1
+	otlpMetricDescription          = 2
+	otlpMetricUnit                 = 3
+	otlpMetricGauge                = 5
+	otlpMetricSum                  = 7
+	otlpMetricExponentialHistogram = 10
+
+	otlpDataPoints       = 1
+	otlpSumTemporality   = 2
+	otlpSumMonotonic     = 3
+	otlpTemporalityCumul = 2
+
+	otlpPointStartTime  = 2
+	otlpPointTime       = 3
+	otlpPointAsDouble   = 4
+	otlpPointAttributes = 7
+	otlpPointFlags      = 8
+	otlpFlagNoValue     = 1
+
+	otlpHistogramAttributes    = 1
+	otlpHistogramCount         = 4
+	otlpHistogramSum           = 5
+	otlpHistogramScale         = 6
+	otlpHistogramZeroCount     = 7
+	otlpHistogramPositive      = 8
+	otlpHistogramNegative      = 9
+	otlpHistogramFlags         = 10
+	otlpHistogramZeroThreshold = 14
+	otlpBucketsOffset          = 1
+	otlpBucketsCounts          = 2
+
+	otlpKeyValueKey   = 1
+	otlpKeyValueValue = 2
+	otlpAnyValueStr   = 1
+)
+
+// otlpStartTimes remembers the first timestamp written of every cumulative
+// series, sent as the start time of all its points so backends don't read
+// a reset between batches. It holds one entry per counter or histogram
+// series written.
+type otlpStartTimes struct {
+	mu     sync.Mutex
+	starts map[string]int64
+}
+
+func newOTLPStartTimes() *otlpStartTimes {
+	return &otlpStartTimes{starts: make(map[string]int64)}
+}
+
+// get returns the start time in milliseconds of the series with key,
+// recording first when the series is new
+func (s *otlpStartTimes) get(key string, first int64) int64 {
+	if s == nil {
+		return first
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if start, ok := s.starts[key]; ok && start <= first {
+		return start
+	}
+	s.starts[key] = first
+	return first
+}
+
+// encodeOTLP converts time series into a protobuf ExportMetricsServiceRequest
+// the way Prometheus' OTLP receiver maps it back: job becomes the
+// service.namespace/service.name resource attributes and instance becomes
+// service.instance.id, so series sharing both share a resource, while all
+// other labels become data point attributes. Counters, as known from
+// metadata, are sent as cumulative monotonic sums, native histograms as
+// cumulative exponential histograms and everything else as gauges. Cumulative
+// points start at the first timestamp starts recorded for their series.
+// Exemplars are not converted.
+func encodeOTLP(timeSeries []prompb.TimeSeries, metadata func(name string) MetricMetadata, starts *otlpStartTimes) []byte {
+	type resource struct {
+		job, instance string
+		metrics       map[string][]*prompb.TimeSeries
+		order         []string
+	}
+	resources := make(map[[2]string]*resource)
+	var order [][2]string
+
+	for i := range timeSeries {
+		ts := &timeSeries[i]
+		var name, job, instance string
+		for _, l := range ts.Labels {
+			switch l.Name {
+			case "__name__":
+				name = l.Value
+			case "job":
+				job = l.Value
+			case "instance":
+				instance = l.Value
+			}
+		}
+		if name == "" || (len(ts.Samples) == 0 && len(ts.Histograms) == 0) {
+			continue
+		}
+
+		key := [2]string{job, instance}
+		r, ok := resources[key]
+		if !ok {
+			r = &resource{job: job, instance: instance, metrics: make(map[string][]*prompb.TimeSeries)}
+			resources[key] = r
+			order = append(order, key)
+		}
+		if _, ok := r.metrics[name]; !ok {
+			r.order = append(r.order, name)
+		}
+		r.metrics[name] = append(r.metrics[name], ts)
+	}
+
+	var request []byte
+	for _, key := range order {
+		r := resources[key]
+
+		var scope []byte
+		scope = appendStringField(scope, otlpScopeName, "promfire")
+		scope = appendStringField(scope, otlpScopeVersion, version.Version)
+
+		var scopeMetrics []byte
+		scopeMetrics = appendMessageField(scopeMetrics, otlpScopeMetricsScope, scope)
+		for _, name := range r.order {
+			scopeMetrics = appendMessageField(scopeMetrics, otlpScopeMetricsMetrics, encodeOTLPMetric(name, r.metrics[name], metadata(name), starts))
+		}
+
+		var resourceMsg []byte
+		namespace, service := "", r.job
+		if i := strings.Index(r.job, "/"); i >= 0 {
+			namespace, service = r.job[:i], r.job[i+1:]
+		}
+		for _, attr := range [][2]string{
+			{"service.namespace", namespace},
+			{"service.name", service},
+			{"service.instance.id", r.instance},
+		} {
+			if attr[1] != "" {
+				resourceMsg = appendMessageField(resourceMsg, otlpResourceAttributes, otlpKeyValue(attr[0], attr[1]))
+			}
+		}
+
+		var resourceMetrics []byte
+		resourceMetrics = appendMessageField(resourceMetrics, otlpResourceMetricsResource, resourceMsg)
+		resourceMetrics = appendMessageField(resourceMetrics, otlpResourceMetricsScopeMetrics, scopeMetrics)
+		request = appendMessageField(request, otlpRequestResourceMetrics, resourceMetrics)
+	}
+	return request
+}
+
+// encodeOTLPMetric encodes one OTLP Metric holding every sample of series
+func encodeOTLPMetric(name string, series []*prompb.TimeSeries, md MetricMetadata, starts *otlpStartTimes) []byte {
+	var metric []byte
+	metric = appendStringField(metric, otlpMetricName, name)
+	metric = appendStringField(metric, otlpMetricDescription, md.Help)
+	metric = appendStringField(metric, otlpMetricUnit, md.Unit)
+
+	for _, ts := range series {
+		if len(ts.Histograms) > 0 {
+			return appendMessageField(metric, otlpMetricExponentialHistogram, encodeOTLPHistograms(series, starts))
+		}
+	}
+
+	cumulative := strings.EqualFold(md.Type, "counter")
+	var points []byte
+	for _, ts := range series {
+		if len(ts.Samples) == 0 {
+			continue
+		}
+		attributes, key := otlpAttributes(ts.Labels)
+		var start int64
+		if cumulative {
+			start = starts.get(key, ts.Samples[0].Timestamp)
+		}
+
+		for _, s := range ts.Samples {
+			var point []byte
+			for _, attr := range attributes {
+				point = appendMessageField(point, otlpPointAttributes, attr)
+			}
+			if cumulative {
+				point = protowire.AppendTag(point, otlpPointStartTime, protowire.Fixed64Type)
+				point = protowire.AppendFixed64(point, uint64(start)*1e6)
+			}
+			point = protowire.AppendTag(point, otlpPointTime, protowire.Fixed64Type)
+			point = protowire.AppendFixed64(point, uint64(s.Timestamp)*1e6)
+			if value.IsStaleNaN(s.Value) {
+				// Prometheus turns this flag back into a staleness marker
+				point = protowire.AppendTag(point, otlpPointFlags, protowire.VarintType)
+				point = protowire.AppendVarint(point, otlpFlagNoValue)
+			} else {
+				point = protowire.AppendTag(point, otlpPointAsDouble, protowire.Fixed64Type)
+				point = protowire.AppendFixed64(point, math.Float64bits(s.Value))
+			}
+			points = appendMessageField(points, otlpDataPoints, point)
+		}
+	}
+
+	if cumulative {
+		sum := points
+		sum = protowire.AppendTag(sum, otlpSumTemporality, protowire.VarintType)
+		sum = protowire.AppendVarint(sum, otlpTemporalityCumul)
+		sum = protowire.AppendTag(sum, otlpSumMonotonic, protowire.VarintType)
+		sum = protowire.AppendVarint(sum, 1)
+		return appendMessageField(metric, otlpMetricSum, sum)
+	}
+	return appendMessageField(metric, otlpMetricGauge, points)
+}
+
+// encodeOTLPHistograms encodes the native histograms of series as a
+// cumulative ExponentialHistogram. The schema becomes the scale; Prometheus
+// bucket i spans (base^(i-1), base^i] while OTLP bucket i spans
+// (base^i, base^(i+1)], so bucket offsets shift down by one.
+func encodeOTLPHistograms(series []*prompb.TimeSeries, starts *otlpStartTimes) []byte {
+	var histogram []byte
+	for _, ts := range series {
+		if len(ts.Histograms) == 0 {
+			continue
+		}
+		attributes, key := otlpAttributes(ts.Labels)
+		start := starts.get(key, ts.Histograms[0].Timestamp)
+
+		for i := range ts.Histograms {
+			h := &ts.Histograms[i]
+			var point []byte
+			for _, attr := range attributes {
+				point = appendMessageField(point, otlpHistogramAttributes, attr)
+			}
+			point = protowire.AppendTag(point, otlpPointStartTime, protowire.Fixed64Type)
+			point = protowire.AppendFixed64(point, uint64(start)*1e6)
+			point = protowire.AppendTag(point, otlpPointTime, protowire.Fixed64Type)
+			point = protowire.AppendFixed64(point, uint64(h.Timestamp)*1e6)
+
+			if value.IsStaleNaN(h.Sum) {
+				point = protowire.AppendTag(point, otlpHistogramFlags, protowire.VarintType)
+				point = protowire.AppendVarint(point, otlpFlagNoValue)
+				histogram = appendMessageField(histogram, otlpDataPoints, point)
+				continue
+			}
+
+			count, zeroCount := h.GetCountInt(), h.GetZeroCountInt()
+			if h.IsFloatHistogram() {
+				count, zeroCount = roundCount(h.GetCountFloat()), roundCount(h.GetZeroCountFloat())
+			}
+			point = protowire.AppendTag(point, otlpHistogramCount, protowire.Fixed64Type)
+			point = protowire.AppendFixed64(point, count)
+			point = protowire.AppendTag(point, otlpHistogramSum, protowire.Fixed64Type)
+			point = protowire.AppendFixed64(point, math.Float64bits(h.Sum))
+			point = protowire.AppendTag(point, otlpHistogramScale, protowire.VarintType)
+			point = protowire.AppendVarint(point, protowire.EncodeZigZag(int64(h.Schema)))
+			point = protowire.AppendTag(point, otlpHistogramZeroCount, protowire.Fixed64Type)
+			point = protowire.AppendFixed64(point, zeroCount)
+			if buckets := otlpBuckets(h.PositiveSpans, h.PositiveDeltas, h.PositiveCounts); buckets != nil {
+				point = appendMessageField(point, otlpHistogramPositive, buckets)
+			}
+			if buckets := otlpBuckets(h.NegativeSpans, h.NegativeDeltas, h.NegativeCounts); buckets != nil {
+				point = appendMessageField(point, otlpHistogramNegative, buckets)
+			}
+			if h.ZeroThreshold != 0 {
+				point = protowire.AppendTag(point, otlpHistogramZeroThreshold, protowire.Fixed64Type)
+				point = protowire.AppendFixed64(point, math.Float64bits(h.ZeroThreshold))
+			}
+			histogram = appendMessageField(histogram, otlpDataPoints, point)
+		}
+	}
+
+	histogram = protowire.AppendTag(histogram, otlpSumTemporality, protowire.VarintType)
+	return protowire.AppendVarint(histogram, otlpTemporalityCumul)
+}
+
+// otlpBuckets encodes the buckets of one histogram side as dense OTLP
+// Buckets, from delta-encoded integer counts or absolute float counts. It
+// returns nil without buckets.
+func otlpBuckets(spans []prompb.BucketSpan, deltas []int64, counts []float64) []byte {
+	var dense []uint64
+	var first int32
+	var current int64
+	n := 0
+	for i, span := range spans {
+		if i == 0 {
+			first = span.Offset
+		} else {
+			// Offsets after the first span are gaps after the previous one
+			for j := int32(0); j < span.Offset; j++ {
+				dense = append(dense, 0)
+			}
+		}
+		for j := uint32(0); j < span.Length; j++ {
+			var count uint64
+			switch {
+			case n < len(deltas):
+				current += deltas[n]
+				count = uint64(current)
+			case n < len(counts):
+				count = roundCount(counts[n])
+			}
+			dense = append(dense, count)
+			n++
+		}
+	}
+	if len(dense) == 0 {
+		return nil
+	}
+
+	var buckets []byte
+	buckets = protowire.AppendTag(buckets, otlpBucketsOffset, protowire.VarintType)
+	buckets = protowire.AppendVarint(buckets, protowire.EncodeZigZag(int64(first-1)))
+	var packed []byte
+	for _, count := range dense {
+		packed = protowire.AppendVarint(packed, count)
+	}
+	return appendMessageField(buckets, otlpBucketsCounts, packed)
+}
+
+// roundCount converts a float histogram count to the integer OTLP expects
+func roundCount(v float64) uint64 {
+	if v <= 0 || math.IsNaN(v) {
+		return 0
+	}
+	return uint64(math.Round(v))
+}
+
+// otlpAttributes returns the data point attributes of a series, all labels
+// but the metric name, job and instance, and a key identifying the series
+func otlpAttributes(lbls []prompb.Label) ([][]byte, string) {
+	sorted := append([]prompb.Label(nil), lbls...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var attributes [][]byte
+	var key strings.Builder
+	for _, l := range sorted {
+		key.WriteString(l.Name)
+		key.WriteByte(0xff)
+		key.WriteString(l.Value)
+		key.WriteByte(0xff)
+		if l.Name != "__name__" && l.Name != "job" && l.Name != "instance" {
+			attributes = append(attributes, otlpKeyValue(l.Name, l.Value))
+		}
+	}
+	return attributes, key.String()
+}
+
+// otlpKeyValue encodes a KeyValue with a string value
+func otlpKeyValue(key, val string) []byte {
+	var anyValue []byte
+	anyValue = appendStringField(anyValue, otlpAnyValueStr, val)
+
+	var kv []byte
+	kv = appendStringField(kv, otlpKeyValueKey, key)
+	return appendMessageField(kv, otlpKeyValueValue, anyValue)
+}
+
+// appendStringField appends a string field, omitting it when empty as
+// proto3 does
+func appendStringField(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendMessageField appends an embedded message field
+func appendMessageField(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1,211 @@
+package writer
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/prompb"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// decodeOTLP decodes an ExportMetricsServiceRequest with the official OTLP
+// types. MetricsData shares its single resource_metrics field, so it decodes
+// the request without pulling in the gRPC service package.
+func decodeOTLP(t *testing.T, data []byte) *metricspb.MetricsData {
+	t.Helper()
+
+	var req metricspb.MetricsData
+	if err := proto.Unmarshal(data, &req); err != nil {
+		t.Fatalf("decoding OTLP request: %v", err)
+	}
+	return &req
+}
+
+// otlpAttrs flattens string attributes into a map
+func otlpAttrs(kvs []*commonpb.KeyValue) map[string]string {
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.GetStringValue()
+	}
+	return m
+}
+
+func TestEncodeOTLPRoundTrip(t *testing.T) {
+	metadata := map[string]MetricMetadata{
+		"http_requests_total": {Type: "counter", Help: "Requests served.", Unit: "requests"},
+	}
+	series := []prompb.TimeSeries{
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "http_requests_total"},
+				{Name: "job", Value: "shop/api"},
+				{Name: "instance", Value: "host:9090"},
+				{Name: "code", Value: "200"},
+			},
+			Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}, {Timestamp: 2000, Value: 3}},
+		},
+		{
+			Labels: []prompb.Label{
+				{Name: "__name__", Value: "temperature"},
+				{Name: "job", Value: "shop/api"},
+				{Name: "instance", Value: "host:9090"},
+			},
+			Samples: []prompb.Sample{{Timestamp: 1000, Value: 21.5}, {Timestamp: 2000, Value: math.Float64frombits(value.StaleNaN)}},
+		},
+	}
+
+	req := decodeOTLP(t, encodeOTLP(series, func(name string) MetricMetadata { return metadata[name] }, newOTLPStartTimes()))
+	if len(req.ResourceMetrics) != 1 {
+		t.Fatalf("got %d resources, want 1 for the shared job and instance", len(req.ResourceMetrics))
+	}
+	rm := req.ResourceMetrics[0]
+	wantResource := map[string]string{"service.namespace": "shop", "service.name": "api", "service.instance.id": "host:9090"}
+	if got := otlpAttrs(rm.Resource.Attributes); !reflect.DeepEqual(got, wantResource) {
+		t.Errorf("resource attributes = %v, want %v", got, wantResource)
+	}
+	if got := rm.ScopeMetrics[0].Scope.Name; got != "promfire" {
+		t.Errorf("scope = %q, want promfire", got)
+	}
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	if len(metrics) != 2 {
+		t.Fatalf("got %d metrics, want 2", len(metrics))
+	}
+
+	counter := metrics[0]
+	if counter.Name != "http_requests_total" || counter.Description != "Requests served." || counter.Unit != "requests" {
+		t.Errorf("counter metric = %q %q %q", counter.Name, counter.Description, counter.Unit)
+	}
+	sum := counter.GetSum()
+	if sum == nil || !sum.IsMonotonic || sum.AggregationTemporality != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Fatalf("counter data = %v, want a cumulative monotonic sum", counter.Data)
+	}
+	for i, p := range sum.DataPoints {
+		if p.StartTimeUnixNano != 1000*1e6 {
+			t.Errorf("point %d start = %d, want the first sample's time", i, p.StartTimeUnixNano)
+		}
+		if want := uint64(series[0].Samples[i].Timestamp) * 1e6; p.TimeUnixNano != want {
+			t.Errorf("point %d time = %d, want %d", i, p.TimeUnixNano, want)
+		}
+		if p.GetAsDouble() != series[0].Samples[i].Value {
+			t.Errorf("point %d value = %g, want %g", i, p.GetAsDouble(), series[0].Samples[i].Value)
+		}
+		if got := otlpAttrs(p.Attributes); !reflect.DeepEqual(got, map[string]string{"code": "200"}) {
+			t.Errorf("point %d attributes = %v, want only code", i, got)
+		}
+	}
+
+	gauge := metrics[1].GetGauge()
+	if gauge == nil || len(gauge.DataPoints) != 2 {
+		t.Fatalf("temperature data = %v, want a gauge with 2 points", metrics[1].Data)
+	}
+	if p := gauge.DataPoints[0]; p.GetAsDouble() != 21.5 || p.StartTimeUnixNano != 0 {
+		t.Errorf("gauge point = %v, want 21.5 without a start time", p)
+	}
+	if p := gauge.DataPoints[1]; p.Flags != uint32(metricspb.DataPointFlags_DATA_POINT_FLAGS_NO_RECORDED_VALUE_MASK) {
+		t.Errorf("stale point flags = %d, want no recorded value", p.Flags)
+	}
+}
+
+func TestEncodeOTLPNativeHistogram(t *testing.T) {
+	series := []prompb.TimeSeries{{
+		Labels: []prompb.Label{{Name: "__name__", Value: "rpc_seconds"}, {Name: "method", Value: "get"}},
+		Histograms: []prompb.Histogram{{
+			Count:         &prompb.Histogram_CountInt{CountInt: 7},
+			Sum:           10,
+			Schema:        1,
+			ZeroThreshold: 0.001,
+			ZeroCount:     &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+			// Buckets 0, 1 and 3 hold 2, 3 and 1 observations
+			PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}, {Offset: 1, Length: 1}},
+			PositiveDeltas: []int64{2, 1, -2},
+			Timestamp:      5000,
+		}, {
+			Count:          &prompb.Histogram_CountFloat{CountFloat: 3},
+			Sum:            4,
+			Schema:         1,
+			ZeroCount:      &prompb.Histogram_ZeroCountFloat{ZeroCountFloat: 0},
+			NegativeSpans:  []prompb.BucketSpan{{Offset: -2, Length: 1}},
+			NegativeCounts: []float64{3},
+			Timestamp:      6000,
+		}},
+	}}
+
+	req := decodeOTLP(t, encodeOTLP(series, func(string) MetricMetadata { return MetricMetadata{} }, newOTLPStartTimes()))
+	metric := req.ResourceMetrics[0].ScopeMetrics[0].Metrics[0]
+	h := metric.GetExponentialHistogram()
+	if h == nil || len(h.DataPoints) != 2 {
+		t.Fatalf("rpc_seconds data = %v, want an exponential histogram with 2 points", metric.Data)
+	}
+	if h.AggregationTemporality != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Errorf("temporality = %s, want cumulative", h.AggregationTemporality)
+	}
+
+	p := h.DataPoints[0]
+	if p.Count != 7 || p.GetSum() != 10 || p.Scale != 1 || p.ZeroCount != 1 || p.ZeroThreshold != 0.001 {
+		t.Errorf("point = count %d sum %g scale %d zero %d threshold %g", p.Count, p.GetSum(), p.Scale, p.ZeroCount, p.ZeroThreshold)
+	}
+	if p.StartTimeUnixNano != 5000*1e6 || p.TimeUnixNano != 5000*1e6 {
+		t.Errorf("point times = %d..%d, want both at 5s", p.StartTimeUnixNano, p.TimeUnixNano)
+	}
+	// Prometheus bucket i is OTLP bucket i-1, and the gap at 2 is filled
+	if p.Positive.Offset != -1 || !reflect.DeepEqual(p.Positive.BucketCounts, []uint64{2, 3, 0, 1}) {
+		t.Errorf("positive buckets = offset %d %v, want offset -1 [2 3 0 1]", p.Positive.Offset, p.Positive.BucketCounts)
+	}
+	if got := otlpAttrs(p.Attributes); !reflect.DeepEqual(got, map[string]string{"method": "get"}) {
+		t.Errorf("attributes = %v, want only method", got)
+	}
+
+	p = h.DataPoints[1]
+	if p.Count != 3 || p.Negative == nil || p.Negative.Offset != -3 || !reflect.DeepEqual(p.Negative.BucketCounts, []uint64{3}) {
+		t.Errorf("float histogram point = count %d negative %v, want count 3 and offset -3 [3]", p.Count, p.Negative)
+	}
+	if p.StartTimeUnixNano != 5000*1e6 {
+		t.Errorf("second point start = %d, want the series start", p.StartTimeUnixNano)
+	}
+}
+
+func TestOTLPWriterKeepsStartTimeAcrossBatches(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	rw := NewRemoteWriter(srv.URL, 1, Options{Protocol: ProtocolOTLP, TimestampMode: TimestampModePreserve})
+	defer rw.Close()
+	rw.SetMetadata(map[string]MetricMetadata{"requests_total": {Type: "counter"}})
+
+	ctx := context.Background()
+	labels := map[string]string{"__name__": "requests_total", "job": "api"}
+	for _, ts := range []float64{100, 200} {
+		if err := rw.WriteSamples(ctx, labels, [][]interface{}{{ts, "1"}}); err != nil {
+			t.Fatalf("writing sample at %g: %v", ts, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 2 {
+		t.Fatalf("got %d requests, want 2", len(bodies))
+	}
+	for i, body := range bodies {
+		p := decodeOTLP(t, body).ResourceMetrics[0].ScopeMetrics[0].Metrics[0].GetSum().DataPoints[0]
+		if p.StartTimeUnixNano != 100*1e9 {
+			t.Errorf("batch %d start = %d, want the first sample's time", i, p.StartTimeUnixNano)
+		}
+	}
+}
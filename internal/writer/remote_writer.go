@@ -3,30 +3,67 @@ package writer
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/golang/snappy"
 	"github.com/prometheus/prometheus/prompb"
+	"promfire/internal/httpclient"
 	"promfire/internal/logger"
+	"promfire/internal/selfmetrics"
 )
 
+// log writes the writer's entries with component "writer"
+var log = logger.With("writer")
+
+// ErrNoValidSamples is returned when none of a series' samples could be parsed
+var ErrNoValidSamples = errors.New("no valid samples found")
+
 // TimestampCoordinator ensures globally unique, strictly increasing timestamps
 type TimestampCoordinator struct {
 	mu            sync.Mutex
 	lastTimestamp int64
 	increment     int64
+	resolution    int64
 }
 
-// NewTimestampCoordinator creates a new timestamp coordinator
-func NewTimestampCoordinator() *TimestampCoordinator {
+// NewTimestampCoordinator creates a new timestamp coordinator that spaces
+// samples generated faster than wall-clock time by increment milliseconds.
+// Increments below 1ms are raised to 1ms.
+func NewTimestampCoordinator(increment int64) *TimestampCoordinator {
+	if increment < 1 {
+		increment = 1
+	}
 	return &TimestampCoordinator{
 		lastTimestamp: time.Now().UnixMilli(),
-		increment:     1, // 1ms increment between samples
+		increment:     increment,
+		resolution:    1,
+	}
+}
+
+// SetResolution aligns generated timestamps to multiples of resolutionMs
+// (e.g. 1000 for whole seconds). The increment is raised to the resolution
+// so timestamps stay strictly increasing at the chosen resolution.
+func (tc *TimestampCoordinator) SetResolution(resolutionMs int64) {
+	if resolutionMs < 1 {
+		resolutionMs = 1
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.resolution = resolutionMs
+	if tc.increment < resolutionMs {
+		tc.increment = resolutionMs
 	}
+	tc.increment -= tc.increment % resolutionMs
+	tc.lastTimestamp -= tc.lastTimestamp % resolutionMs
 }
 
 // NextTimestamp returns the next unique timestamp in milliseconds
@@ -35,6 +72,7 @@ func (tc *TimestampCoordinator) NextTimestamp() int64 {
 	defer tc.mu.Unlock()
 
 	now := time.Now().UnixMilli()
+	now -= now % tc.resolution
 	if now > tc.lastTimestamp {
 		tc.lastTimestamp = now
 	} else {
@@ -44,36 +82,336 @@ func (tc *TimestampCoordinator) NextTimestamp() int64 {
 	return tc.lastTimestamp
 }
 
+// StatusError is returned when the remote write endpoint answers with a non-2xx status
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("remote write failed with status %d", e.StatusCode)
+}
+
+// BasicAuth holds optional HTTP basic auth credentials for the remote write endpoint
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
 // RemoteWriter handles writing samples to Prometheus via remote write protocol
 type RemoteWriter struct {
-	client              *http.Client
-	endpoint            string
-	batchSize           int
+	client               *http.Client
+	endpoint             string
+	batchSize            int
+	maxRequestBytes      int
 	timestampCoordinator *TimestampCoordinator
+	manifest             *Manifest
+	auth                 BasicAuth
+	signer               *sigV4Signer
+	headers              map[string]string
+	requests             *requestStats
+	retry                RetryPolicy
+	retryMu              sync.Mutex
+	retryRand            *rand.Rand
+	normalizer           *labelNormalizer
+	bytesSent            atomic.Int64
+	batches              atomic.Int64
+	failedBatches        atomic.Int64
+	compression          *compressionTracker
+	futureGuard          FutureGuard
+	future               guardCounters
+	ageGuard             AgeGuard
+	old                  guardCounters
+	guardedSeries        atomic.Int64
+	ordering             orderCheck
+	duplicates           duplicatePolicy
+	timestampMode        string
+	acceleration         float64
+	shift                shiftOffset
+	encoding             string
+	snappyFramed         bool
+	protocol             string
+	otlpStarts           *otlpStartTimes
+	closed               atomic.Bool
+	onBatch              func(err error)
+	onResponse           func(status int, latency time.Duration)
+	breaker              *circuitBreaker
+	exemplars            *exemplarGenerator
+	metadata             *metadataTracker
+
+	// pending buffers series across writes until batchSize have accumulated;
+	// pendingTrackers holds the tracker of each, nil for untracked writes
+	pendingMu       sync.Mutex
+	pending         []*prompb.TimeSeries
+	pendingTrackers []*WriteTracker
+}
+
+// ErrWriterClosed is returned by writes after Close
+var ErrWriterClosed = errors.New("remote writer is closed")
+
+// Options holds optional RemoteWriter settings
+type Options struct {
+	Auth      BasicAuth
+	Retry     RetryPolicy
+	TLSConfig *tls.Config
+	// Pool sizes the client's connection pool
+	Pool httpclient.PoolOptions
+	// SigV4 signs every request with AWS credentials instead of basic auth
+	SigV4 *SigV4
+	// Headers are added to every request, e.g. X-Scope-OrgID
+	Headers map[string]string
+	// Timeout bounds each HTTP request; 0 means no timeout
+	Timeout time.Duration
+	// MaxRequestBytes caps the uncompressed size of each request, splitting
+	// larger batches; 0 means no limit
+	MaxRequestBytes int
+	// TimestampMode is one of the TimestampMode* constants, defaulting to coordinated
+	TimestampMode string
+	// TimeAcceleration divides the spacing of preserved and shifted source
+	// timestamps by this factor towards the shift origin; 0 and 1 keep the
+	// original spacing
+	TimeAcceleration float64
+	// DuplicateTimestamps is one of the DuplicatePolicy* constants, resolving
+	// preserved or shifted samples that share their predecessor's timestamp;
+	// unset sends them as they are
+	DuplicateTimestamps string
+	// Encoding is EncodingSnappy (default) or EncodingGzip
+	Encoding string
+	// SnappyFramed sends snappy payloads in the framed stream format instead
+	// of the block format, for receivers that decode them as a stream
+	SnappyFramed bool
+	// Protocol is ProtocolRemoteWrite (default) or ProtocolOTLP to post
+	// OTLP metrics, e.g. to Prometheus' /api/v1/otlp/v1/metrics
+	Protocol string
+	// TimestampResolution aligns coordinated timestamps, e.g. time.Second; 0 means 1ms
+	TimestampResolution time.Duration
+	// TimestampIncrement spaces coordinated timestamps once they run ahead
+	// of wall-clock time; 0 means 1ms
+	TimestampIncrement time.Duration
+	// FutureGuard clamps or drops samples too far ahead of wall-clock time
+	FutureGuard FutureGuard
+	// AgeGuard clamps or drops samples too far behind wall-clock time
+	AgeGuard AgeGuard
+	// OrderCheck validates that every series is strictly timestamp-ordered
+	// before sending: OrderCheckFail, OrderCheckDrop or OrderCheckOff
+	OrderCheck string
+	// NormalizeLabelNames rewrites label names that are illegal in Prometheus
+	NormalizeLabelNames bool
+	// ExemplarFraction attaches a synthetic exemplar to this fraction of
+	// samples; 0 disables exemplars
+	ExemplarFraction float64
+	// OnBatch is called with the outcome of every batch sent, nil on success
+	OnBatch func(err error)
+	// OnResponse is called with the status and latency of every request
+	// attempt, including retries; status 0 means no response was received
+	OnResponse func(status int, latency time.Duration)
+	// CircuitBreaker fails batches fast while the endpoint is down
+	CircuitBreaker CircuitBreaker
+	// LatencyWarnThreshold logs a warning when the p99 request latency
+	// exceeds it; 0 disables the warning
+	LatencyWarnThreshold time.Duration
 }
 
 // NewRemoteWriter creates a new RemoteWriter instance
-func NewRemoteWriter(endpoint string, batchSize int) *RemoteWriter {
+func NewRemoteWriter(endpoint string, batchSize int, opts Options) *RemoteWriter {
+	coordinator := NewTimestampCoordinator(opts.TimestampIncrement.Milliseconds())
+	if opts.TimestampResolution > 0 {
+		coordinator.SetResolution(opts.TimestampResolution.Milliseconds())
+	}
+
+	retryRand := opts.Retry.Rand
+	if retryRand == nil {
+		retryRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	var signer *sigV4Signer
+	if opts.SigV4 != nil {
+		signer = newSigV4Signer(*opts.SigV4)
+	}
+
+	var normalizer *labelNormalizer
+	if opts.NormalizeLabelNames {
+		normalizer = &labelNormalizer{}
+	}
+
+	var otlpStarts *otlpStartTimes
+	if opts.Protocol == ProtocolOTLP {
+		otlpStarts = newOTLPStartTimes()
+	}
+
 	return &RemoteWriter{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:               httpclient.New(opts.Timeout, opts.TLSConfig, opts.Pool),
 		endpoint:             endpoint,
 		batchSize:            batchSize,
-		timestampCoordinator: NewTimestampCoordinator(),
+		maxRequestBytes:      opts.MaxRequestBytes,
+		timestampCoordinator: coordinator,
+		auth:                 opts.Auth,
+		signer:               signer,
+		headers:              opts.Headers,
+		requests:             newRequestStats(opts.LatencyWarnThreshold),
+		retry:                opts.Retry,
+		retryRand:            retryRand,
+		normalizer:           normalizer,
+		compression:          newCompressionTracker(),
+		futureGuard:          opts.FutureGuard,
+		ageGuard:             opts.AgeGuard,
+		ordering:             orderCheck{mode: opts.OrderCheck},
+		duplicates:           duplicatePolicy{mode: opts.DuplicateTimestamps},
+		onBatch:              opts.OnBatch,
+		onResponse:           opts.OnResponse,
+		breaker:              newCircuitBreaker(opts.CircuitBreaker),
+		exemplars:            newExemplarGenerator(opts.ExemplarFraction),
+		timestampMode:        opts.TimestampMode,
+		acceleration:         opts.TimeAcceleration,
+		encoding:             opts.Encoding,
+		snappyFramed:         opts.SnappyFramed,
+		protocol:             opts.Protocol,
+		otlpStarts:           otlpStarts,
+	}
+}
+
+// SetManifest enables recording of every successfully written series into m
+func (rw *RemoteWriter) SetManifest(m *Manifest) {
+	rw.manifest = m
+}
+
+// SetMetadata enables sending metric metadata with the first batch carrying
+// each metric name, using known for type, help and unit
+func (rw *RemoteWriter) SetMetadata(known map[string]MetricMetadata) {
+	rw.metadata = newMetadataTracker(known)
+}
+
+// NormalizedLabels returns how many label names were normalized and how many
+// of those collided with another label of the same series
+func (rw *RemoteWriter) NormalizedLabels() (normalized, collisions int64) {
+	if rw.normalizer == nil {
+		return 0, 0
 	}
+	return rw.normalizer.normalized.Load(), rw.normalizer.collisions.Load()
 }
 
-// WriteSamples writes samples for a single time series to Prometheus
+// BytesSent returns the total compressed payload bytes successfully sent
+func (rw *RemoteWriter) BytesSent() int64 {
+	return rw.bytesSent.Load()
+}
+
+// Batches returns the number of batches sent or failed fast, excluding retries
+func (rw *RemoteWriter) Batches() int64 {
+	return rw.batches.Load()
+}
+
+// FailedBatches returns the number of batches that could not be written
+func (rw *RemoteWriter) FailedBatches() int64 {
+	return rw.failedBatches.Load()
+}
+
+// CompressionByMetric returns per-metric compressed vs uncompressed sizes of
+// everything sent so far, sorted by compressed bytes
+func (rw *RemoteWriter) CompressionByMetric() []MetricCompression {
+	return rw.compression.snapshot()
+}
+
+// FutureSamples returns how many future-dated samples were clamped or dropped
+func (rw *RemoteWriter) FutureSamples() (clamped, dropped int64) {
+	return rw.future.clamped.Load(), rw.future.dropped.Load()
+}
+
+// OldSamples returns how many samples older than the age guard allows were
+// clamped or dropped
+func (rw *RemoteWriter) OldSamples() (clamped, dropped int64) {
+	return rw.old.clamped.Load(), rw.old.dropped.Load()
+}
+
+// GuardedSeries returns how many series the future or age guard dropped
+// entirely
+func (rw *RemoteWriter) GuardedSeries() int64 {
+	return rw.guardedSeries.Load()
+}
+
+// Stats returns latency percentiles, status code counts and bytes of all
+// remote write requests sent so far
+func (rw *RemoteWriter) Stats() WriteStats {
+	return rw.requests.snapshot()
+}
+
+// LatencyHistogram returns the request latency buckets in seconds, the same
+// observations Stats derives its percentiles from
+func (rw *RemoteWriter) LatencyHistogram() selfmetrics.HistogramSnapshot {
+	return rw.requests.histogram()
+}
+
+// UnorderedSeries returns how many series were dropped by the ordering check
+func (rw *RemoteWriter) UnorderedSeries() int64 {
+	return rw.ordering.dropped.Load()
+}
+
+// Close rejects further writes and releases idle connections. Writes must
+// have finished and been flushed before Close is called.
+func (rw *RemoteWriter) Close() {
+	rw.closed.Store(true)
+	rw.client.CloseIdleConnections()
+}
+
+// CloseIdleConnections closes idle keep-alive connections of the HTTP client
+func (rw *RemoteWriter) CloseIdleConnections() {
+	rw.client.CloseIdleConnections()
+}
+
+// WriteSamples buffers samples for a single time series, sending a request
+// once batchSize series have accumulated; call Flush to send the remainder
 func (rw *RemoteWriter) WriteSamples(ctx context.Context, labels map[string]string, values [][]interface{}) error {
 	// Convert to Prometheus TimeSeries format
 	timeSeries, err := rw.convertToTimeSeries(labels, values)
 	if err != nil {
 		return fmt.Errorf("converting to time series: %w", err)
 	}
+	if timeSeries == nil {
+		return nil // Dropped by the ordering check or a timestamp guard
+	}
 
-	// Send in batches
-	return rw.sendInBatches(ctx, []*prompb.TimeSeries{timeSeries})
+	return rw.enqueue(ctx, timeSeries)
+}
+
+// enqueue buffers a series and sends the buffered batch once it holds
+// batchSize series. Concurrent writers share the buffer, so the batch may
+// hold series of other writers; their trackers learn its outcome.
+func (rw *RemoteWriter) enqueue(ctx context.Context, timeSeries *prompb.TimeSeries) error {
+	if rw.closed.Load() {
+		return ErrWriterClosed
+	}
+
+	tracker := trackerFrom(ctx)
+	tracker.add()
+	rw.pendingMu.Lock()
+	rw.pending = append(rw.pending, timeSeries)
+	rw.pendingTrackers = append(rw.pendingTrackers, tracker)
+	if len(rw.pending) < rw.batchSize {
+		rw.pendingMu.Unlock()
+		return nil
+	}
+	batch, trackers := rw.pending, rw.pendingTrackers
+	rw.pending = make([]*prompb.TimeSeries, 0, rw.batchSize)
+	rw.pendingTrackers = make([]*WriteTracker, 0, rw.batchSize)
+	rw.pendingMu.Unlock()
+
+	err := rw.sendInBatches(ctx, batch)
+	releaseTrackers(trackers, err)
+	return err
+}
+
+// Flush sends all buffered series. Batches other writers are already
+// sending are not waited for; use a WriteTracker for that.
+func (rw *RemoteWriter) Flush(ctx context.Context) error {
+	rw.pendingMu.Lock()
+	batch, trackers := rw.pending, rw.pendingTrackers
+	rw.pending, rw.pendingTrackers = nil, nil
+	rw.pendingMu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	err := rw.sendInBatches(ctx, batch)
+	releaseTrackers(trackers, err)
+	return err
 }
 
 // WriteBatch writes multiple time series to Prometheus
@@ -81,9 +419,12 @@ func (rw *RemoteWriter) WriteBatch(ctx context.Context, timeSeries []*prompb.Tim
 	return rw.sendInBatches(ctx, timeSeries)
 }
 
-// convertToTimeSeries converts labels and values to Prometheus TimeSeries format
-func (rw *RemoteWriter) convertToTimeSeries(labels map[string]string, values [][]interface{}) (*prompb.TimeSeries, error) {
-	// Create label pairs
+// labelPairs converts a label map into prompb label pairs
+func (rw *RemoteWriter) labelPairs(labels map[string]string) []prompb.Label {
+	if rw.normalizer != nil {
+		return rw.normalizer.normalizeLabels(labels)
+	}
+
 	var labelPairs []prompb.Label
 	for name, value := range labels {
 		labelPairs = append(labelPairs, prompb.Label{
@@ -91,6 +432,15 @@ func (rw *RemoteWriter) convertToTimeSeries(labels map[string]string, values [][
 			Value: value,
 		})
 	}
+	return labelPairs
+}
+
+// convertToTimeSeries converts labels and values to Prometheus TimeSeries
+// format, returning a nil series when the ordering check or a timestamp guard
+// drops it
+func (rw *RemoteWriter) convertToTimeSeries(labels map[string]string, values [][]interface{}) (*prompb.TimeSeries, error) {
+	// Create label pairs
+	labelPairs := rw.labelPairs(labels)
 
 	if len(values) == 0 {
 		return nil, fmt.Errorf("no values provided")
@@ -98,12 +448,12 @@ func (rw *RemoteWriter) convertToTimeSeries(labels map[string]string, values [][
 
 	// Convert ALL samples, not just the last one
 	var samples []prompb.Sample
+	var prevSource int64
 	for _, value := range values {
 		if len(value) != 2 {
 			continue // Skip invalid values
 		}
 
-		// Parse value (ignore original timestamp)
 		valueStr, ok := value[1].(string)
 		if !ok {
 			continue // Skip non-string values
@@ -114,8 +464,24 @@ func (rw *RemoteWriter) convertToTimeSeries(labels map[string]string, values [][
 			continue // Skip unparseable values
 		}
 
-		// Use coordinated timestamp to ensure strict ordering
-		timestamp := rw.timestampCoordinator.NextTimestamp()
+		timestamp, ok := rw.sampleTimestamp(value[0])
+		if !ok {
+			continue // Skip unparseable timestamps
+		}
+		source := timestamp
+		if len(samples) > 0 {
+			prev := samples[len(samples)-1].Timestamp
+			var action duplicateAction
+			timestamp, action = rw.duplicates.resolve(rw.strictlyAfter(timestamp, prev), prevSource, prev)
+			switch action {
+			case duplicateSkip:
+				continue
+			case duplicateReplace:
+				samples[len(samples)-1].Value = valueFloat
+				continue
+			}
+		}
+		prevSource = source
 
 		samples = append(samples, prompb.Sample{
 			Timestamp: timestamp,
@@ -123,18 +489,40 @@ func (rw *RemoteWriter) convertToTimeSeries(labels map[string]string, values [][
 		})
 	}
 
+	ok, err := rw.ordering.check(labels, len(samples), func(i int) int64 { return samples[i].Timestamp })
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	if len(samples) == 0 {
+		return nil, ErrNoValidSamples
+	}
+
+	samples = rw.futureGuard.apply(samples, &rw.future)
+	if len(samples) > 0 {
+		samples = rw.ageGuard.apply(samples, &rw.old)
+	}
 	if len(samples) == 0 {
-		return nil, fmt.Errorf("no valid samples found")
+		rw.guardedSeries.Add(1)
+		return nil, nil
 	}
 
 	return &prompb.TimeSeries{
-		Labels:  labelPairs,
-		Samples: samples,
+		Labels:    labelPairs,
+		Samples:   samples,
+		Exemplars: rw.exemplars.generate(samples),
 	}, nil
 }
 
 // sendInBatches sends time series data in configurable batch sizes
 func (rw *RemoteWriter) sendInBatches(ctx context.Context, timeSeries []*prompb.TimeSeries) error {
+	if rw.closed.Load() {
+		return ErrWriterClosed
+	}
+
 	for i := 0; i < len(timeSeries); i += rw.batchSize {
 		end := i + rw.batchSize
 		if end > len(timeSeries) {
@@ -142,11 +530,13 @@ func (rw *RemoteWriter) sendInBatches(ctx context.Context, timeSeries []*prompb.
 		}
 
 		batch := timeSeries[i:end]
-		if err := rw.sendBatch(ctx, batch); err != nil {
-			return fmt.Errorf("sending batch %d-%d: %w", i, end, err)
+		for _, request := range splitBySize(batch, rw.maxRequestBytes) {
+			if err := rw.sendBatch(ctx, request); err != nil {
+				return fmt.Errorf("sending batch %d-%d: %w", i, end, err)
+			}
 		}
 
-		logger.Debug("Batch sent successfully", map[string]interface{}{
+		log.DebugContext(ctx, "Batch sent successfully", map[string]interface{}{
 			"batch_size": len(batch),
 			"batch_id":   fmt.Sprintf("%d-%d", i, end),
 		})
@@ -156,42 +546,183 @@ func (rw *RemoteWriter) sendInBatches(ctx context.Context, timeSeries []*prompb.
 }
 
 // sendBatch sends a single batch of time series to Prometheus
-func (rw *RemoteWriter) sendBatch(ctx context.Context, timeSeries []*prompb.TimeSeries) error {
+func (rw *RemoteWriter) sendBatch(ctx context.Context, timeSeries []*prompb.TimeSeries) (err error) {
+	if rw.onBatch != nil {
+		defer func() { rw.onBatch(err) }()
+	}
+	rw.batches.Add(1)
+
+	probe, err := rw.breaker.allow()
+	if err != nil {
+		rw.failedBatches.Add(1)
+		selfmetrics.BatchesFailed.Inc()
+		return err
+	}
+	if probe {
+		defer rw.breaker.release()
+	}
+
 	// Create write request
 	writeRequest := &prompb.WriteRequest{}
 	for _, ts := range timeSeries {
 		writeRequest.Timeseries = append(writeRequest.Timeseries, *ts)
 	}
+	if rw.protocol != ProtocolOTLP {
+		// OTLP carries type, help and unit in every batch
+		writeRequest.Metadata = rw.metadata.pending(timeSeries)
+	}
 
 	// Marshal to protobuf
-	data, err := writeRequest.Marshal()
+	data, err := rw.marshal(writeRequest)
 	if err != nil {
 		return fmt.Errorf("marshaling write request: %w", err)
 	}
 
-	// Compress with snappy
-	compressed := snappy.Encode(nil, data)
-
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", rw.endpoint, bytes.NewReader(compressed))
+	// Compress with the configured content encoding
+	compressed, err := rw.compress(data)
 	if err != nil {
-		return fmt.Errorf("creating request: %w", err)
+		return err
 	}
 
-	req.Header.Set("Content-Type", "application/x-protobuf")
-	req.Header.Set("Content-Encoding", "snappy")
-	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for attempt := 0; ; attempt++ {
+		retryAfter, err := rw.post(ctx, compressed)
+		if err == nil {
+			break
+		}
 
-	// Send request
+		var statusErr *StatusError
+		isStatus := errors.As(err, &statusErr)
+		if isStatus && !isRetryableStatus(statusErr.StatusCode) {
+			// The endpoint is up and rejected the data
+			rw.breaker.record(false)
+			rw.failedBatches.Add(1)
+			selfmetrics.BatchesFailed.Inc()
+			return err
+		}
+		if !isStatus {
+			// A cancelled batch says nothing about the endpoint; a probe
+			// is released by its deferred release
+			if ctx.Err() == nil {
+				rw.breaker.record(true)
+			}
+			rw.failedBatches.Add(1)
+			selfmetrics.BatchesFailed.Inc()
+			return err
+		}
+		if attempt >= rw.retry.MaxRetries || rw.breaker.open() {
+			rw.breaker.record(true)
+			rw.failedBatches.Add(1)
+			selfmetrics.BatchesFailed.Inc()
+			if attempt > 0 {
+				return fmt.Errorf("giving up after %d retries: %w", attempt, err)
+			}
+			return err
+		}
+
+		rw.retryMu.Lock()
+		delay := rw.retry.backoff(attempt, rw.retryRand)
+		rw.retryMu.Unlock()
+		if retryAfter > 0 {
+			delay = retryAfter
+		}
+
+		log.DebugContext(ctx, "Retrying remote write", map[string]interface{}{
+			"attempt":  attempt + 1,
+			"status":   statusErr.StatusCode,
+			"delay_ms": delay.Milliseconds(),
+		})
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			rw.failedBatches.Add(1)
+			selfmetrics.BatchesFailed.Inc()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	rw.breaker.record(false)
+	rw.bytesSent.Add(int64(len(compressed)))
+	var written uint64
+	for _, ts := range timeSeries {
+		written += uint64(len(ts.Samples) + len(ts.Histograms))
+	}
+	selfmetrics.SamplesWritten.Add(written)
+	rw.compression.record(timeSeries, len(data), len(compressed))
+	rw.metadata.markSent(writeRequest.Metadata)
+
+	if rw.manifest != nil {
+		for _, ts := range timeSeries {
+			rw.manifest.Record(ts)
+		}
+	}
+
+	return nil
+}
+
+// post sends a compressed payload once, returning the Retry-After delay
+// requested by the server alongside any error
+func (rw *RemoteWriter) post(ctx context.Context, compressed []byte) (time.Duration, error) {
+	req, err := rw.newRequest(ctx, compressed)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+
+	start := time.Now()
 	resp, err := rw.client.Do(req)
+	latency := time.Since(start)
 	if err != nil {
-		return fmt.Errorf("sending request: %w", err)
+		rw.requests.record(ctx, latency, 0, len(compressed))
+		if rw.onResponse != nil {
+			rw.onResponse(0, latency)
+		}
+		return 0, fmt.Errorf("sending request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	rw.requests.record(ctx, latency, resp.StatusCode, len(compressed))
+	if rw.onResponse != nil {
+		rw.onResponse(resp.StatusCode, latency)
+	}
+	log.DebugContext(ctx, "Remote write request", map[string]interface{}{
+		"status":     resp.StatusCode,
+		"latency_ms": latency.Milliseconds(),
+		"bytes":      len(compressed),
+	})
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("remote write failed with status %d", resp.StatusCode)
+		retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"))
+		return retryAfter, &StatusError{StatusCode: resp.StatusCode}
 	}
 
-	return nil
+	return 0, nil
+}
+
+// newRequest builds a remote write POST request carrying the given payload
+func (rw *RemoteWriter) newRequest(ctx context.Context, compressed []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", rw.endpoint, bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if encoding := rw.contentEncoding(); encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	if rw.protocol != ProtocolOTLP {
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+	for name, value := range rw.headers {
+		req.Header.Set(name, value)
+	}
+	if rw.auth.Username != "" {
+		req.SetBasicAuth(rw.auth.Username, rw.auth.Password)
+	}
+	if rw.signer != nil {
+		rw.signer.sign(req, compressed)
+	}
+
+	return req, nil
 }
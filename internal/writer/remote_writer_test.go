@@ -0,0 +1,138 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"promfire/internal/benchmarker/testutil"
+	"promfire/internal/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestWriteSamplesBatchesAcrossSeries(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	const series, batchSize = 25, 10
+	rw := NewRemoteWriter(recv.WriteURL(), batchSize, Options{})
+	defer rw.Close()
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < series; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			labels := map[string]string{"__name__": "m", "i": fmt.Sprint(i)}
+			if err := rw.WriteSamples(ctx, labels, [][]interface{}{{1700000000.0, "1"}}); err != nil {
+				t.Errorf("writing series %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := recv.Requests(); got != series/batchSize {
+		t.Errorf("requests before flush = %d, want %d", got, series/batchSize)
+	}
+	if err := rw.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got, want := recv.Requests(), (series+batchSize-1)/batchSize; got != want {
+		t.Errorf("requests = %d, want %d", got, want)
+	}
+	if got := len(recv.Series()); got != series {
+		t.Errorf("received %d series, want %d", got, series)
+	}
+}
+
+func TestFlushWithoutBufferedSeries(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	rw := NewRemoteWriter(recv.WriteURL(), 10, Options{})
+	defer rw.Close()
+	if err := rw.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got := recv.Requests(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestLogsUnderWriterComponent(t *testing.T) {
+	var logs bytes.Buffer
+	logger.Init(logger.DEBUG, "promfire")
+	logger.SetOutput(&logs)
+	t.Cleanup(func() {
+		logger.SetOutput(io.Discard)
+		logger.SetLevel(logger.INFO)
+	})
+
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	rw := NewRemoteWriter(recv.WriteURL(), 10, Options{})
+	defer rw.Close()
+	ctx := context.Background()
+	if err := rw.WriteSamples(ctx, map[string]string{"__name__": "m"}, [][]interface{}{{1700000000.0, "1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent bool
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var entry logger.LogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if entry.Component != "writer" {
+			t.Errorf("entry %q has component %q, want writer", entry.Message, entry.Component)
+		}
+		sent = sent || entry.Message == "Batch sent successfully"
+	}
+	if !sent {
+		t.Errorf("no batch log entry written:\n%s", logs.String())
+	}
+}
+
+func TestSendBatchBasicAuth(t *testing.T) {
+	tests := []struct {
+		name string
+		auth BasicAuth
+	}{
+		{"credentials", BasicAuth{Username: "bench", Password: "s3cr$t"}},
+		{"none", BasicAuth{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recv := testutil.NewFakeReceiver()
+			defer recv.Close()
+			rw := NewRemoteWriter(recv.WriteURL(), 10, Options{Auth: tt.auth})
+			defer rw.Close()
+
+			if err := rw.sendBatch(context.Background(), testBatch()); err != nil {
+				t.Fatal(err)
+			}
+			headers := recv.Headers()
+			if len(headers) != 1 {
+				t.Fatalf("receiver got %d requests, want 1", len(headers))
+			}
+			user, password, ok := (&http.Request{Header: headers[0]}).BasicAuth()
+			if ok != (tt.auth.Username != "") || user != tt.auth.Username || password != tt.auth.Password {
+				t.Errorf("basic auth = %q, %q (%v), want %q, %q", user, password, ok, tt.auth.Username, tt.auth.Password)
+			}
+		})
+	}
+}
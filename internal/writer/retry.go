@@ -0,0 +1,114 @@
+package writer
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Jitter algorithms for retry backoff
+const (
+	JitterFull  = "full"
+	JitterEqual = "equal"
+	JitterNone  = "none"
+)
+
+// JitterFunc randomizes a capped backoff delay using the given random source
+type JitterFunc func(delay time.Duration, rng *rand.Rand) time.Duration
+
+// jitterFuncs maps jitter algorithm names to their implementation
+var jitterFuncs = map[string]JitterFunc{
+	// Full jitter picks uniformly in [0, delay], which best spreads retries
+	// of concurrent workers and instances
+	JitterFull: func(delay time.Duration, rng *rand.Rand) time.Duration {
+		return time.Duration(rng.Int63n(int64(delay) + 1))
+	},
+	// Equal jitter keeps half the delay and randomizes the other half
+	JitterEqual: func(delay time.Duration, rng *rand.Rand) time.Duration {
+		half := delay / 2
+		return half + time.Duration(rng.Int63n(int64(delay-half)+1))
+	},
+	JitterNone: func(delay time.Duration, _ *rand.Rand) time.Duration {
+		return delay
+	},
+}
+
+// JitterFor returns the jitter implementation for name, or false if unknown
+func JitterFor(name string) (JitterFunc, bool) {
+	fn, ok := jitterFuncs[name]
+	return fn, ok
+}
+
+// RetryPolicy controls how failed remote write requests are retried
+type RetryPolicy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	// MaxDelay caps the exponential backoff before jitter; 0 means uncapped
+	MaxDelay time.Duration
+	// Jitter randomizes each delay; nil defaults to full jitter
+	Jitter JitterFunc
+	// Rand is the random source for jitter; nil uses a time-seeded source.
+	// Set it to a fixed seed for deterministic delays.
+	Rand *rand.Rand
+}
+
+// isRetryableStatus reports whether a remote write response status is worth retrying
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry attempt (0-based),
+// doubling the initial backoff on every attempt up to MaxDelay and then
+// applying the jitter algorithm with rng
+func (p RetryPolicy) backoff(attempt int, rng *rand.Rand) time.Duration {
+	delay := p.InitialBackoff
+	for i := 0; i < attempt && delay > 0; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	jitter := p.Jitter
+	if jitter == nil {
+		jitter = jitterFuncs[JitterFull]
+	}
+	return jitter(delay, rng)
+}
+
+// parseRetryAfter parses a Retry-After header given either as seconds or as
+// an HTTP date, returning false when the header is absent or invalid
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		delay := time.Until(t)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
@@ -0,0 +1,117 @@
+package writer
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+)
+
+func TestBackoffDoublesUpToMaxDelay(t *testing.T) {
+	none, _ := JitterFor(JitterNone)
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxDelay: time.Second, Jitter: none}
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000, 1000}
+	for attempt, w := range want {
+		if got := p.backoff(attempt, nil); got != w*time.Millisecond {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, w*time.Millisecond)
+		}
+	}
+
+	// Without a cap the delay keeps doubling
+	p.MaxDelay = 0
+	if got := p.backoff(10, nil); got != 1024*100*time.Millisecond {
+		t.Errorf("uncapped backoff(10) = %s, want %s", got, 1024*100*time.Millisecond)
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	tests := []struct {
+		jitter string
+		low    func(delay time.Duration) time.Duration
+	}{
+		{JitterFull, func(time.Duration) time.Duration { return 0 }},
+		{JitterEqual, func(d time.Duration) time.Duration { return d / 2 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.jitter, func(t *testing.T) {
+			jitter, ok := JitterFor(tt.jitter)
+			if !ok {
+				t.Fatalf("no jitter %q", tt.jitter)
+			}
+			p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxDelay: 400 * time.Millisecond, Jitter: jitter}
+			rng := rand.New(rand.NewSource(1))
+
+			for attempt := 0; attempt < 6; attempt++ {
+				delay := min(100*time.Millisecond<<attempt, 400*time.Millisecond)
+				lowest, highest := delay, time.Duration(0)
+				for i := 0; i < 500; i++ {
+					got := p.backoff(attempt, rng)
+					if got < tt.low(delay) || got > delay {
+						t.Fatalf("backoff(%d) = %s, want within [%s, %s]", attempt, got, tt.low(delay), delay)
+					}
+					lowest, highest = min(lowest, got), max(highest, got)
+				}
+				// The draws cover most of the range rather than clustering
+				if spread := highest - lowest; spread < (delay-tt.low(delay))*9/10 {
+					t.Errorf("backoff(%d) spread over %s of %s", attempt, spread, delay-tt.low(delay))
+				}
+			}
+		})
+	}
+}
+
+func TestBackoffDeterministicWithSeed(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxDelay: time.Second}
+	draw := func(seed int64) []time.Duration {
+		rng := rand.New(rand.NewSource(seed))
+		delays := make([]time.Duration, 8)
+		for i := range delays {
+			delays[i] = p.backoff(i, rng)
+		}
+		return delays
+	}
+
+	first, again, other := draw(42), draw(42), draw(43)
+	same := true
+	for i := range first {
+		if first[i] != again[i] {
+			t.Fatalf("seed 42 drew %s and then %s for attempt %d, want it reproducible", first[i], again[i], i)
+		}
+		same = same && first[i] == other[i]
+	}
+	if same {
+		t.Error("seeds 42 and 43 drew the same delays")
+	}
+}
+
+func TestJitterForUnknown(t *testing.T) {
+	if _, ok := JitterFor("decorrelated"); ok {
+		t.Error("JitterFor accepted an unknown algorithm")
+	}
+}
+
+func TestSendBatchRetriesWithBackoff(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+	recv.SetFailures(testutil.FailurePattern{FirstK: 2})
+
+	none, _ := JitterFor(JitterNone)
+	rw := NewRemoteWriter(recv.WriteURL(), 10, Options{
+		Retry: RetryPolicy{MaxRetries: 3, InitialBackoff: 20 * time.Millisecond, MaxDelay: 30 * time.Millisecond, Jitter: none},
+	})
+	defer rw.Close()
+
+	// Two failures wait 20ms and then the capped 30ms
+	start := time.Now()
+	if err := rw.sendBatch(context.Background(), testBatch()); err != nil {
+		t.Fatalf("batch failed after retries: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("retries took %s, want at least the 50ms of backoff", elapsed)
+	}
+	if got := recv.Requests(); got != 3 {
+		t.Errorf("requests = %d, want 2 failures and 1 success", got)
+	}
+}
@@ -0,0 +1,88 @@
+package writer
+
+import (
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// protoFieldSize returns the encoded size of a length-delimited protobuf
+// field with a one-byte tag and a payload of n bytes
+func protoFieldSize(n int) int {
+	size := 1 + n
+	for v := uint64(n); v >= 0x80; v >>= 7 {
+		size++
+	}
+	return size + 1
+}
+
+// splitBySize splits a batch into requests whose marshalled WriteRequest
+// stays within maxBytes, keeping each series in a single request. A series
+// that alone exceeds maxBytes is split into several series with the same
+// labels, each carrying a consecutive run of its samples. Metadata attached
+// to the request is not counted. maxBytes <= 0 disables splitting.
+func splitBySize(timeSeries []*prompb.TimeSeries, maxBytes int) [][]*prompb.TimeSeries {
+	if maxBytes <= 0 {
+		return [][]*prompb.TimeSeries{timeSeries}
+	}
+
+	var (
+		requests [][]*prompb.TimeSeries
+		current  []*prompb.TimeSeries
+		size     int
+	)
+	add := func(ts *prompb.TimeSeries, n int) {
+		if len(current) > 0 && size+n > maxBytes {
+			requests = append(requests, current)
+			current, size = nil, 0
+		}
+		current = append(current, ts)
+		size += n
+	}
+
+	for _, ts := range timeSeries {
+		n := protoFieldSize(ts.Size())
+		if n <= maxBytes {
+			add(ts, n)
+			continue
+		}
+		for _, part := range splitSeries(ts, maxBytes) {
+			add(part, protoFieldSize(part.Size()))
+		}
+	}
+	if len(current) > 0 {
+		requests = append(requests, current)
+	}
+	return requests
+}
+
+// splitSeries splits the samples and histograms of ts into series that each
+// encode within maxBytes, keeping exemplars with the first part. A part
+// always holds at least one sample, so it can exceed maxBytes when the
+// labels alone come close to it.
+func splitSeries(ts *prompb.TimeSeries, maxBytes int) []*prompb.TimeSeries {
+	labelsOnly := prompb.TimeSeries{Labels: ts.Labels}
+	part := &prompb.TimeSeries{Labels: ts.Labels, Exemplars: ts.Exemplars}
+	// inner is part.Size(), kept up to date as samples are added; the length
+	// prefix is added on top of it because its varint grows with the part
+	inner := part.Size()
+
+	var parts []*prompb.TimeSeries
+	flush := func(n int) {
+		if protoFieldSize(inner+n) <= maxBytes || len(part.Samples)+len(part.Histograms) == 0 {
+			inner += n
+			return
+		}
+		parts = append(parts, part)
+		part = &prompb.TimeSeries{Labels: ts.Labels}
+		inner = labelsOnly.Size() + n
+	}
+
+	for _, s := range ts.Samples {
+		flush(protoFieldSize(s.Size()))
+		part.Samples = append(part.Samples, s)
+	}
+	for _, h := range ts.Histograms {
+		flush(protoFieldSize(h.Size()))
+		part.Histograms = append(part.Histograms, h)
+	}
+	return append(parts, part)
+}
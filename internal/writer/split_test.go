@@ -0,0 +1,98 @@
+package writer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// testSeries returns a series named name with n samples
+func testSeries(name string, n int) *prompb.TimeSeries {
+	ts := &prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: name}}}
+	for i := 0; i < n; i++ {
+		ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: int64(1700000000000 + i*15000), Value: float64(i)})
+	}
+	return ts
+}
+
+// requestSize returns the marshalled size of a WriteRequest holding series
+func requestSize(series []*prompb.TimeSeries) int {
+	req := prompb.WriteRequest{}
+	for _, ts := range series {
+		req.Timeseries = append(req.Timeseries, *ts)
+	}
+	return req.Size()
+}
+
+func TestSplitBySizeKeepsSeriesWhole(t *testing.T) {
+	var batch []*prompb.TimeSeries
+	for i := 0; i < 10; i++ {
+		batch = append(batch, testSeries(fmt.Sprintf("series_%d", i), 5))
+	}
+	one := requestSize(batch[:1])
+	maxBytes := 3*one + one/2
+
+	requests := splitBySize(batch, maxBytes)
+	if len(requests) != 4 {
+		t.Errorf("got %d requests, want 4 holding 3, 3, 3 and 1 series", len(requests))
+	}
+
+	var got []*prompb.TimeSeries
+	for i, req := range requests {
+		if size := requestSize(req); size > maxBytes {
+			t.Errorf("request %d is %d bytes, over the %d byte limit", i, size, maxBytes)
+		}
+		got = append(got, req...)
+	}
+	// The same series pointers in order: none was split or copied
+	if !reflect.DeepEqual(got, batch) {
+		t.Error("split requests do not hold the original series in order")
+	}
+}
+
+func TestSplitBySizeDisabled(t *testing.T) {
+	batch := []*prompb.TimeSeries{testSeries("a", 100), testSeries("b", 100)}
+	if requests := splitBySize(batch, 0); len(requests) != 1 || len(requests[0]) != 2 {
+		t.Errorf("max_request_bytes 0 split the batch into %d requests", len(requests))
+	}
+}
+
+func TestSplitBySizeSplitsOversizedSeries(t *testing.T) {
+	big := testSeries("big", 200)
+	big.Exemplars = []prompb.Exemplar{{Value: 1, Timestamp: 1700000000000}}
+	small := testSeries("small", 1)
+
+	// Every limit around the 128-byte length prefix boundary must hold
+	for maxBytes := 100; maxBytes <= 400; maxBytes++ {
+		requests := splitBySize([]*prompb.TimeSeries{small, big}, maxBytes)
+
+		var samples []prompb.Sample
+		var parts int
+		for i, req := range requests {
+			if size := requestSize(req); size > maxBytes {
+				t.Fatalf("max %d: request %d is %d bytes", maxBytes, i, size)
+			}
+			for _, ts := range req {
+				if ts == small {
+					continue
+				}
+				if !reflect.DeepEqual(ts.Labels, big.Labels) {
+					t.Fatalf("max %d: part has labels %v", maxBytes, ts.Labels)
+				}
+				if (parts == 0) != (len(ts.Exemplars) > 0) {
+					t.Fatalf("max %d: part %d has %d exemplars, want them only on the first", maxBytes, parts, len(ts.Exemplars))
+				}
+				samples = append(samples, ts.Samples...)
+				parts++
+			}
+		}
+		if parts < 2 {
+			t.Fatalf("max %d: oversized series was not split", maxBytes)
+		}
+		if !reflect.DeepEqual(samples, big.Samples) {
+			t.Fatalf("max %d: parts hold %d samples, want the original %d in order", maxBytes, len(samples), len(big.Samples))
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package writer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"promfire/internal/selfmetrics"
+)
+
+// latencyBuckets are the upper bounds of the request latency histogram,
+// doubling from 1ms to about 65s; slower requests fall into an overflow bucket
+var latencyBuckets = func() []time.Duration {
+	bounds := make([]time.Duration, 17)
+	for i := range bounds {
+		bounds[i] = time.Millisecond << i
+	}
+	return bounds
+}()
+
+// latencyWarnInterval is the minimum time between two latency warnings
+const latencyWarnInterval = 30 * time.Second
+
+// latencyCheckEvery is how many requests pass between two p99 checks
+const latencyCheckEvery = 50
+
+// WriteStats summarises the remote write requests sent so far, including
+// retried attempts. Percentiles are bucket upper bounds capped at Max, so
+// they are accurate to within a factor of two.
+type WriteStats struct {
+	Requests int64
+	Bytes    int64
+	// StatusCodes counts responses per HTTP status; requests that failed
+	// without a response are counted as TransportErrors
+	StatusCodes     map[int]int64
+	TransportErrors int64
+	P50             time.Duration
+	P90             time.Duration
+	P99             time.Duration
+	Max             time.Duration
+}
+
+// requestStats accumulates per-request latency, status and size
+type requestStats struct {
+	mu              sync.Mutex
+	buckets         []int64
+	requests        int64
+	bytes           int64
+	statusCodes     map[int]int64
+	transportErrors int64
+	sum             time.Duration
+	max             time.Duration
+
+	// warnP99 logs a warning when p99 latency exceeds it; 0 disables
+	warnP99  time.Duration
+	lastWarn time.Time
+}
+
+func newRequestStats(warnP99 time.Duration) *requestStats {
+	return &requestStats{
+		buckets:     make([]int64, len(latencyBuckets)+1),
+		statusCodes: make(map[int]int64),
+		warnP99:     warnP99,
+	}
+}
+
+// record adds one request; status is 0 when no response was received
+func (s *requestStats) record(ctx context.Context, latency time.Duration, status, bytes int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buckets[sort.Search(len(latencyBuckets), func(i int) bool { return latency <= latencyBuckets[i] })]++
+	s.requests++
+	s.sum += latency
+	s.bytes += int64(bytes)
+	if status == 0 {
+		s.transportErrors++
+	} else {
+		s.statusCodes[status]++
+	}
+	if latency > s.max {
+		s.max = latency
+	}
+
+	if s.warnP99 <= 0 || s.requests%latencyCheckEvery != 0 || time.Since(s.lastWarn) < latencyWarnInterval {
+		return
+	}
+	if p99 := s.percentile(0.99); p99 > s.warnP99 {
+		s.lastWarn = time.Now()
+		log.WarnContext(ctx, "Remote write p99 latency above threshold, the target may be saturated", map[string]interface{}{
+			"p99_ms":       p99.Milliseconds(),
+			"threshold_ms": s.warnP99.Milliseconds(),
+			"requests":     s.requests,
+		})
+	}
+}
+
+// percentile returns the bucket bound below which fraction q of requests
+// completed. The caller must hold mu.
+func (s *requestStats) percentile(q float64) time.Duration {
+	if s.requests == 0 {
+		return 0
+	}
+	rank := int64(q*float64(s.requests) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, n := range s.buckets {
+		seen += n
+		if seen >= rank {
+			if i == len(latencyBuckets) || latencyBuckets[i] > s.max {
+				return s.max
+			}
+			return latencyBuckets[i]
+		}
+	}
+	return s.max
+}
+
+func (s *requestStats) snapshot() WriteStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	codes := make(map[int]int64, len(s.statusCodes))
+	for code, n := range s.statusCodes {
+		codes[code] = n
+	}
+	return WriteStats{
+		Requests:        s.requests,
+		Bytes:           s.bytes,
+		StatusCodes:     codes,
+		TransportErrors: s.transportErrors,
+		P50:             s.percentile(0.5),
+		P90:             s.percentile(0.9),
+		P99:             s.percentile(0.99),
+		Max:             s.max,
+	}
+}
+
+// histogram returns the latency buckets as cumulative counts per bound in
+// seconds, the form of the promfire_remote_write_duration_seconds metric
+func (s *requestStats) histogram() selfmetrics.HistogramSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := selfmetrics.HistogramSnapshot{
+		Bounds: make([]float64, len(latencyBuckets)),
+		Counts: make([]uint64, len(latencyBuckets)),
+		Sum:    s.sum.Seconds(),
+		Count:  uint64(s.requests),
+	}
+	var cumulative int64
+	for i, bound := range latencyBuckets {
+		cumulative += s.buckets[i]
+		snap.Bounds[i] = bound.Seconds()
+		snap.Counts[i] = uint64(cumulative)
+	}
+	return snap
+}
@@ -0,0 +1,37 @@
+package writer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramMatchesStats(t *testing.T) {
+	s := newRequestStats(0)
+	ctx := context.Background()
+	for _, latency := range []time.Duration{500 * time.Microsecond, 3 * time.Millisecond, 3 * time.Millisecond, 2 * time.Second} {
+		s.record(ctx, latency, 200, 100)
+	}
+	s.record(ctx, 10*time.Millisecond, 0, 100)
+
+	snap := s.histogram()
+	stats := s.snapshot()
+	if snap.Count != uint64(stats.Requests) || snap.Count != 5 {
+		t.Fatalf("histogram count = %d, stats requests = %d, want both 5", snap.Count, stats.Requests)
+	}
+	if want := 2.0165; snap.Sum < want-1e-9 || snap.Sum > want+1e-9 {
+		t.Errorf("histogram sum = %g, want %g", snap.Sum, want)
+	}
+
+	// Cumulative counts at 1ms, 4ms, 16ms and 2.048s
+	for bound, want := range map[float64]uint64{0.001: 1, 0.004: 3, 0.016: 4, 2.048: 5} {
+		for i, b := range snap.Bounds {
+			if b == bound && snap.Counts[i] != want {
+				t.Errorf("count at le=%g = %d, want %d", bound, snap.Counts[i], want)
+			}
+		}
+	}
+	if last := snap.Counts[len(snap.Counts)-1]; last != snap.Count {
+		t.Errorf("last bucket = %d, want all %d requests", last, snap.Count)
+	}
+}
@@ -0,0 +1,88 @@
+package writer
+
+import (
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Timestamp modes
+const (
+	// TimestampModeCoordinated assigns fresh, globally increasing timestamps
+	TimestampModeCoordinated = "coordinated"
+	// TimestampModePreserve keeps the original sample timestamps
+	TimestampModePreserve = "preserve"
+	// TimestampModeShift keeps the relative spacing of the original samples
+	// but moves them so the latest source sample lands near now
+	TimestampModeShift = "shift"
+)
+
+// shiftOffset holds the millisecond offset added to source timestamps in
+// shift mode and the origin that time acceleration compresses towards
+type shiftOffset struct {
+	ms     atomic.Int64
+	origin atomic.Int64
+}
+
+// SetShiftOrigin sets the source time that should map to the current time in
+// shift mode, typically the end of the queried range. Accelerated source
+// timestamps keep their distance to it divided by the acceleration factor.
+func (rw *RemoteWriter) SetShiftOrigin(origin time.Time) {
+	rw.shift.ms.Store(time.Now().UnixMilli() - origin.UnixMilli())
+	rw.shift.origin.Store(origin.UnixMilli())
+}
+
+// accelerate divides the distance between a source timestamp and the shift
+// origin by the time acceleration factor, so with 10x a day of history
+// ending at the origin maps into 2.4h
+func (rw *RemoteWriter) accelerate(ts int64) int64 {
+	if rw.acceleration <= 0 || rw.acceleration == 1 {
+		return ts
+	}
+	origin := rw.shift.origin.Load()
+	return origin - int64(math.Floor(float64(origin-ts)/rw.acceleration))
+}
+
+// strictlyAfter returns ts, or prev+1 when acceleration squeezed samples of
+// a series onto or before the previous one, keeping them strictly increasing
+func (rw *RemoteWriter) strictlyAfter(ts, prev int64) int64 {
+	if rw.acceleration > 1 && ts <= prev {
+		return prev + 1
+	}
+	return ts
+}
+
+// sampleTimestamp returns the timestamp in milliseconds for a source sample
+// according to the writer's timestamp mode
+func (rw *RemoteWriter) sampleTimestamp(source interface{}) (int64, bool) {
+	switch rw.timestampMode {
+	case TimestampModePreserve:
+		ts, ok := parseSourceTimestamp(source)
+		return rw.accelerate(ts), ok
+	case TimestampModeShift:
+		ts, ok := parseSourceTimestamp(source)
+		return rw.accelerate(ts) + rw.shift.ms.Load(), ok
+	default:
+		// Use coordinated timestamp to ensure strict ordering
+		return rw.timestampCoordinator.NextTimestamp(), true
+	}
+}
+
+// parseSourceTimestamp converts a Prometheus API timestamp (float seconds) to milliseconds
+func parseSourceTimestamp(v interface{}) (int64, bool) {
+	var seconds float64
+	switch t := v.(type) {
+	case float64:
+		seconds = t
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		if err != nil {
+			return 0, false
+		}
+		seconds = f
+	default:
+		return 0, false
+	}
+	return int64(math.Round(seconds * 1000)), true
+}
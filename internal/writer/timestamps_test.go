@@ -0,0 +1,74 @@
+package writer
+
+import (
+	"testing"
+	"time"
+)
+
+// minuteValues returns n samples one minute apart, the last at end
+func minuteValues(n int, end time.Time) [][]interface{} {
+	values := make([][]interface{}, n)
+	for i := range values {
+		ts := end.Add(-time.Duration(n-1-i) * time.Minute)
+		values[i] = []interface{}{float64(ts.UnixMilli()) / 1000, "1"}
+	}
+	return values
+}
+
+func TestTimeAccelerationSpacing(t *testing.T) {
+	end := time.Unix(1_700_000_000, 0)
+	for _, mode := range []string{TimestampModePreserve, TimestampModeShift} {
+		t.Run(mode, func(t *testing.T) {
+			rw := NewRemoteWriter("http://127.0.0.1:1", 10, Options{TimestampMode: mode, TimeAcceleration: 10})
+			defer rw.Close()
+			rw.SetShiftOrigin(end)
+
+			ts, err := rw.convertToTimeSeries(map[string]string{"__name__": "m"}, minuteValues(61, end))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ts.Samples) != 61 {
+				t.Fatalf("got %d samples, want 61", len(ts.Samples))
+			}
+			for i := 1; i < len(ts.Samples); i++ {
+				if d := ts.Samples[i].Timestamp - ts.Samples[i-1].Timestamp; d != 6000 {
+					t.Fatalf("samples %d and %d are %dms apart, want a minute divided by 10", i-1, i, d)
+				}
+			}
+
+			// The hour of data ends at the origin, or near now when shifted
+			last := ts.Samples[len(ts.Samples)-1].Timestamp
+			want := end.UnixMilli()
+			if mode == TimestampModeShift {
+				want = time.Now().UnixMilli()
+			}
+			if d := want - last; d < 0 || d > time.Minute.Milliseconds() {
+				t.Errorf("last sample at %d, want it at %d", last, want)
+			}
+		})
+	}
+}
+
+func TestTimeAccelerationStaysStrictlyMonotonic(t *testing.T) {
+	end := time.Unix(1_700_000_000, 0)
+	rw := NewRemoteWriter("http://127.0.0.1:1", 10, Options{TimestampMode: TimestampModePreserve, TimeAcceleration: 1_000_000})
+	defer rw.Close()
+	rw.SetShiftOrigin(end)
+
+	// A million-fold squeezes minutes onto the same millisecond
+	ts, err := rw.convertToTimeSeries(map[string]string{"__name__": "m"}, minuteValues(10, end))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts.Samples) != 10 {
+		t.Fatalf("got %d samples, want all 10 kept", len(ts.Samples))
+	}
+	for i := 1; i < len(ts.Samples); i++ {
+		if ts.Samples[i].Timestamp <= ts.Samples[i-1].Timestamp {
+			t.Fatalf("sample %d at %d is not after %d", i, ts.Samples[i].Timestamp, ts.Samples[i-1].Timestamp)
+		}
+	}
+	if got := rw.DuplicateSamples(); got != 0 {
+		t.Errorf("resolved %d duplicates, want squeezed samples not counted as duplicates", got)
+	}
+}
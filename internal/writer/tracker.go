@@ -0,0 +1,59 @@
+package writer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// WriteTracker follows the series written with its context until the
+// batches holding them are sent. Buffered series of concurrent writers share
+// batches, and a batch is sent by whichever writer fills it or flushes, so
+// returning from WriteSamples or Flush does not mean a writer's own series
+// were written; Wait does.
+type WriteTracker struct {
+	wg     sync.WaitGroup
+	failed atomic.Int64
+}
+
+type trackerKey struct{}
+
+// WithTracker returns a context whose writes are followed by t
+func WithTracker(ctx context.Context, t *WriteTracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+// trackerFrom returns the tracker of ctx, nil if there is none
+func trackerFrom(ctx context.Context) *WriteTracker {
+	t, _ := ctx.Value(trackerKey{}).(*WriteTracker)
+	return t
+}
+
+// Wait blocks until every series tracked so far was sent or failed and
+// returns how many of them failed since the tracker was created. Series
+// still buffered are only sent once batchSize accumulate or Flush is called.
+func (t *WriteTracker) Wait() int64 {
+	t.wg.Wait()
+	return t.failed.Load()
+}
+
+// add starts tracking one buffered series
+func (t *WriteTracker) add() {
+	if t != nil {
+		t.wg.Add(1)
+	}
+}
+
+// releaseTrackers reports the outcome of a sent batch to the tracker of
+// every series in it, one entry per series
+func releaseTrackers(trackers []*WriteTracker, err error) {
+	for _, t := range trackers {
+		if t == nil {
+			continue
+		}
+		if err != nil {
+			t.failed.Add(1)
+		}
+		t.wg.Done()
+	}
+}
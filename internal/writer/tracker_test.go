@@ -0,0 +1,88 @@
+package writer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"promfire/internal/benchmarker/testutil"
+)
+
+func TestWriteTrackerWaitsForBatchSentByAnotherWriter(t *testing.T) {
+	sending := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(sending)
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	rw := NewRemoteWriter(srv.URL, 2, Options{})
+	defer rw.Close()
+
+	var a, b WriteTracker
+	ctxA := WithTracker(context.Background(), &a)
+	ctxB := WithTracker(context.Background(), &b)
+	values := [][]interface{}{{1700000000.0, "1"}}
+	if err := rw.WriteSamples(ctxA, map[string]string{"__name__": "a"}, values); err != nil {
+		t.Fatalf("writing a: %v", err)
+	}
+
+	// b fills the batch, so its write sends a's series as well
+	sent := make(chan error, 1)
+	go func() {
+		sent <- rw.WriteSamples(ctxB, map[string]string{"__name__": "b"}, values)
+	}()
+	<-sending
+
+	// a has nothing buffered left to flush while b's send is in flight
+	if err := rw.Flush(ctxA); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	waited := make(chan int64, 1)
+	go func() { waited <- a.Wait() }()
+	select {
+	case n := <-waited:
+		t.Fatalf("Wait returned %d before the batch holding a's series was sent", n)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-sent; err == nil {
+		t.Error("writing b succeeded, want the failed batch's error")
+	}
+	if n := <-waited; n != 1 {
+		t.Errorf("a failed series = %d, want 1", n)
+	}
+	if n := b.Wait(); n != 1 {
+		t.Errorf("b failed series = %d, want 1", n)
+	}
+}
+
+func TestWriteTrackerCountsNoFailuresForSentBatches(t *testing.T) {
+	recv := testutil.NewFakeReceiver()
+	defer recv.Close()
+
+	rw := NewRemoteWriter(recv.WriteURL(), 2, Options{})
+	defer rw.Close()
+
+	var tracker WriteTracker
+	ctx := WithTracker(context.Background(), &tracker)
+	for _, name := range []string{"a", "b", "c"} {
+		if err := rw.WriteSamples(ctx, map[string]string{"__name__": name}, [][]interface{}{{1700000000.0, "1"}}); err != nil {
+			t.Fatalf("writing %s: %v", name, err)
+		}
+	}
+	if err := rw.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if n := tracker.Wait(); n != 0 {
+		t.Errorf("failed series = %d, want 0", n)
+	}
+	if got := len(recv.Series()); got != 3 {
+		t.Errorf("received %d series, want 3", got)
+	}
+}